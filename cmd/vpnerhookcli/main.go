@@ -21,6 +21,7 @@ func main() {
 		family   string
 		table    string
 		timeout  time.Duration
+		dryRun   bool
 	)
 
 	flag.StringVar(&cfgPath, "config", "", "path to CLI config (default ~/.vpner.cnf)")
@@ -30,6 +31,7 @@ func main() {
 	flag.StringVar(&family, "family", "", "iptables family to restore (v4/v6)")
 	flag.StringVar(&table, "table", "", "iptables table that was flushed (nat/mangle)")
 	flag.DurationVar(&timeout, "timeout", 5*time.Second, "RPC timeout")
+	flag.BoolVar(&dryRun, "dry-run", false, "print the changes a restore would make without applying them")
 	var showVersion bool
 	flag.BoolVar(&showVersion, "version", false, "print version and exit")
 	flag.Parse()
@@ -70,13 +72,22 @@ func main() {
 	defer cancel()
 	ctx = hookscope.AppendOutgoingContext(ctx, hookscope.Scope{Family: family, Table: table})
 
-	resp, err := rt.Client().HookRestore(ctx, &grpcpb.Empty{})
+	resp, err := rt.Client().HookRestore(ctx, &grpcpb.HookRestoreRequest{DryRun: dryRun})
 	if err != nil {
 		log.Fatalf("hook restore failed: %v", err)
 	}
 
 	if success := resp.GetSuccess(); success != nil {
 		fmt.Println(success.Message)
+		for _, d := range resp.GetPlan().GetDiff() {
+			fmt.Printf("%s %s:\n", d.Tool, d.Table)
+			for _, line := range d.Removed {
+				fmt.Println("  -", line)
+			}
+			for _, line := range d.Added {
+				fmt.Println("  +", line)
+			}
+		}
 		return
 	}
 	if failure := resp.GetError(); failure != nil {
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/ApostolDmitry/vpner/internal/buildinfo"
+	"github.com/ApostolDmitry/vpner/internal/clientgroup"
 	"github.com/ApostolDmitry/vpner/internal/conf"
 	dnssvc "github.com/ApostolDmitry/vpner/internal/dnssvc"
 	firewall "github.com/ApostolDmitry/vpner/internal/firewall"
@@ -23,12 +24,24 @@ type runtimeGraph struct {
 	xraySvc    *proxysvc.Service
 	grpcServer *rpc.VpnerServer
 	resolver   *resolver.Upstream
+	unblock    *unblock.Service
 }
 
 func buildRuntimeGraph(cfg conf.FullConfig) (*runtimeGraph, error) {
 	resolver := resolver.NewUpstream(cfg.DoH)
 	ipsetRegistry := firewall.NewIPSetRegistry()
 
+	backendPref, err := firewall.ParseBackend(cfg.Network.FirewallBackend)
+	if err != nil {
+		return nil, err
+	}
+	backend, err := firewall.DetectBackend(backendPref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select firewall backend: %w", err)
+	}
+	firewall.UseBackend(backend)
+	log.Printf("Firewall backend: %s", backend)
+
 	tproxyEnabled := cfg.Network.EnableTProxy
 	if tproxyEnabled {
 		if err := firewall.EnsureTProxySupport(cfg.Network.EnableIPv6); err != nil {
@@ -43,12 +56,32 @@ func buildRuntimeGraph(cfg conf.FullConfig) (*runtimeGraph, error) {
 	}
 
 	iptables := firewall.NewIptablesManager(cfg.Network.EnableIPv6, tproxyEnabled)
+	if err := iptables.ConfigureMarks(firewall.MarkOptions{
+		StatePath:      cfg.Network.MarkStatePath,
+		Mask:           cfg.Network.MarkMask,
+		ReservedMarks:  cfg.Network.ReservedMarks,
+		ReservedTables: cfg.Network.ReservedTables,
+	}); err != nil {
+		return nil, fmt.Errorf("invalid fwmark settings: %w", err)
+	}
+	if cfg.Network.InterceptLocal {
+		xrayMgr.SetLocalBypass(cfg.Network.LocalBypassMark, cfg.Network.LocalBypassGID)
+		iptables.SetLocalIntercept(firewall.LocalIntercept{
+			Enabled:    true,
+			BypassMark: cfg.Network.LocalBypassMark,
+			BypassGID:  cfg.Network.LocalBypassGID,
+		})
+		log.Printf("Intercepting router-originated traffic (bypass mark %#x)", cfg.Network.LocalBypassMark)
+	}
 	iptables.CleanupStaleState()
 	xrayRouter := routing.NewXrayRouter(iptables, cfg.Network.LANInterfaces)
 
 	ifManager := netif.NewInterfaceManager("")
+	xrayMgr.SetSocksInbound(cfg.DNSServer.ResolveViaChain)
+	xrayMgr.SetFakeIP(cfg.DNSServer.FakeIP)
 	xraySvc := proxysvc.New(xrayMgr)
 
+	ipsetRegistry.SetMaxEntries(cfg.Network.IPSetMaxEntries, cfg.Network.IPSetChainMaxEntries)
 	unblockManager := firewall.NewUnblockManager(
 		cfg.UnblockRulesPath,
 		cfg.Network.EnableIPv6,
@@ -56,24 +89,51 @@ func buildRuntimeGraph(cfg conf.FullConfig) (*runtimeGraph, error) {
 		cfg.Network.IPSetStaleQueries,
 		ipsetRegistry,
 	)
+	if cfg.Network.IPSetTTLMode {
+		unblockManager.SetEntryTTL(firewall.EntryTTL{
+			Enabled: true,
+			Min:     cfg.Network.IPSetTTLMin,
+			Max:     cfg.Network.IPSetTTLMax,
+			Grace:   cfg.Network.IPSetTTLGrace,
+		})
+	}
 	unblockSvc := unblock.New(unblockManager, ifManager, xraySvc)
 	if err := unblockSvc.Init(); err != nil {
 		return nil, fmt.Errorf("failed to init unblock manager: %w", err)
 	}
+	if cfg.Network.IPSetSnapshotInterval >= 0 {
+		n, err := unblockSvc.RestoreSnapshot(cfg.Network.IPSetSnapshotPath)
+		if err != nil {
+			log.Printf("WARNING: ipset snapshot restored partially: %v", err)
+		}
+		if n > 0 {
+			log.Printf("Restored %d ipset entries from %s", n, cfg.Network.IPSetSnapshotPath)
+		}
+	}
+
+	clientGroups := clientgroup.New(cfg.ClientGroupsPath, iptables, unblockSvc)
+	if err := clientGroups.Init(); err != nil {
+		return nil, fmt.Errorf("failed to init client groups: %w", err)
+	}
 
 	dnsSvc := dnssvc.New(cfg.DNSServer, unblockSvc, resolver, ipsetRegistry)
+	if cfg.DNSServer.ResolveViaChain {
+		dnsSvc.SetChains(xraySvc)
+	}
 
 	deps := rpc.Dependencies{
 		DNS:              dnsSvc,
 		Unblock:          unblockSvc,
+		ClientGroups:     clientGroups,
 		InterfaceManager: ifManager,
 		XrayService:      xraySvc,
 		XrayRouter:       xrayRouter,
 		Info: rpc.StatusInfo{
-			Version:       buildinfo.String(),
-			StartedAt:     time.Now(),
-			DNSPort:       cfg.DNSServer.Port,
-			TProxyEnabled: tproxyEnabled,
+			Version:         buildinfo.String(),
+			StartedAt:       time.Now(),
+			DNSPort:         cfg.DNSServer.Port,
+			TProxyEnabled:   tproxyEnabled,
+			FirewallBackend: string(backend),
 		},
 	}
 
@@ -84,5 +144,6 @@ func buildRuntimeGraph(cfg conf.FullConfig) (*runtimeGraph, error) {
 		xraySvc:    xraySvc,
 		grpcServer: srv,
 		resolver:   resolver,
+		unblock:    unblockSvc,
 	}, nil
 }
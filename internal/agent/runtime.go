@@ -12,10 +12,17 @@ import (
 	proxysvc "github.com/ApostolDmitry/vpner/internal/proxysvc"
 	"github.com/ApostolDmitry/vpner/internal/resolver"
 	rpc "github.com/ApostolDmitry/vpner/internal/rpc"
+	unblock "github.com/ApostolDmitry/vpner/internal/unblock"
 	"golang.org/x/sync/errgroup"
 )
 
-const defaultReconcileInterval = 45 * time.Second
+const (
+	defaultReconcileInterval    = 45 * time.Second
+	defaultSnapshotInterval     = 5 * time.Minute
+	defaultDNSCacheSaveInterval = 5 * time.Minute
+	setMonitorInterval          = time.Minute
+	fakeIPSaveInterval          = time.Minute
+)
 
 type Runtime struct {
 	cfg conf.FullConfig
@@ -24,6 +31,7 @@ type Runtime struct {
 	xraySvc    *proxysvc.Service
 	serverImpl *rpc.VpnerServer
 	resolver   *resolver.Upstream
+	unblock    *unblock.Service
 
 	grpcServers []*grpcInstance
 	shutdown    sync.Once
@@ -40,6 +48,7 @@ func New(cfg conf.FullConfig) (*Runtime, error) {
 		xraySvc:    graph.xraySvc,
 		serverImpl: graph.grpcServer,
 		resolver:   graph.resolver,
+		unblock:    graph.unblock,
 	}, nil
 }
 
@@ -75,6 +84,13 @@ func (r *Runtime) Run(ctx context.Context) error {
 	}
 
 	go r.runWatchdog(ctx)
+	go r.runSnapshots(ctx)
+	go r.runSetMonitor(ctx)
+	go r.runFakeIPSaver(ctx)
+	go r.runDNSCacheSaver(ctx)
+	go r.dnsService.RunWarmer(ctx)
+	go r.dnsService.RunBlocklists(ctx)
+	go r.dnsService.RunLocalRecords(ctx)
 
 	errCh := make(chan error, 1)
 	go func() {
@@ -118,6 +134,7 @@ func (r *Runtime) runWatchdog(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
+			r.serverImpl.SyncKillSwitches()
 			if r.serverImpl.RoutingHealthy() {
 				misses, threshold = 0, baseThreshold
 				continue
@@ -135,6 +152,104 @@ func (r *Runtime) runWatchdog(ctx context.Context) {
 	}
 }
 
+func (r *Runtime) runSnapshots(ctx context.Context) {
+	interval := defaultSnapshotInterval
+	switch n := r.cfg.Network.IPSetSnapshotInterval; {
+	case n < 0:
+		logx.Infof("ipset snapshots disabled by config")
+		return
+	case n > 0:
+		interval = time.Duration(n) * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.saveSnapshot()
+		}
+	}
+}
+
+func (r *Runtime) saveSnapshot() {
+	if r.unblock == nil || r.cfg.Network.IPSetSnapshotInterval < 0 {
+		return
+	}
+	if err := r.unblock.SaveSnapshot(r.cfg.Network.IPSetSnapshotPath); err != nil {
+		logx.Warnf("failed to save ipset snapshot: %v", err)
+	}
+}
+
+func (r *Runtime) runSetMonitor(ctx context.Context) {
+	if r.unblock == nil {
+		return
+	}
+	ticker := time.NewTicker(setMonitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.unblock.CheckCapacity()
+		}
+	}
+}
+
+func (r *Runtime) runFakeIPSaver(ctx context.Context) {
+	if r.dnsService == nil || !r.dnsService.FakeIPEnabled() {
+		return
+	}
+	ticker := time.NewTicker(fakeIPSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.saveFakeIPs()
+		}
+	}
+}
+
+func (r *Runtime) runDNSCacheSaver(ctx context.Context) {
+	if r.dnsService == nil {
+		return
+	}
+	interval := defaultDNSCacheSaveInterval
+	switch n := r.cfg.DNSServer.CacheSaveInterval; {
+	case n < 0:
+		return
+	case n > 0:
+		interval = time.Duration(n) * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.dnsService.SaveCache(); err != nil {
+				logx.Warnf("failed to save DNS cache: %v", err)
+			}
+		}
+	}
+}
+
+func (r *Runtime) saveFakeIPs() {
+	if r.dnsService == nil {
+		return
+	}
+	if err := r.dnsService.SaveFakeIPs(); err != nil {
+		logx.Warnf("failed to save fake-ip mappings: %v", err)
+	}
+}
+
 func (r *Runtime) buildGRPCServers() ([]*grpcInstance, error) {
 	builder := newGRPCListenerBuilder(r.cfg.GRPC, r.serverImpl)
 	listeners, err := builder.Build()
@@ -159,9 +274,14 @@ func (r *Runtime) shutdownRuntime() {
 		}
 		r.grpcServers = nil
 
+		r.saveSnapshot()
+		r.saveFakeIPs()
 		if r.dnsService != nil {
 			logx.Infof("Stopping DNS service")
 			r.dnsService.Stop()
+			if err := r.dnsService.CloseQueryLog(); err != nil {
+				logx.Warnf("failed to close query log: %v", err)
+			}
 		}
 		if r.xraySvc != nil {
 			if r.serverImpl != nil {
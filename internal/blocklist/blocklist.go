@@ -0,0 +1,260 @@
+package blocklist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ApostolDmitry/vpner/internal/conf"
+	"github.com/ApostolDmitry/vpner/internal/logx"
+	"github.com/ApostolDmitry/vpner/internal/matcher"
+)
+
+const (
+	defaultRefreshInterval = 24 * time.Hour
+	fetchTimeout           = 2 * time.Minute
+)
+
+// Fetcher downloads the list at url.
+type Fetcher func(ctx context.Context, url string) (io.ReadCloser, error)
+
+type ListStat struct {
+	Name    string
+	Source  string
+	Rules   int
+	Hits    uint64
+	Updated time.Time
+	Err     error
+}
+
+type list struct {
+	source  conf.BlocklistSource
+	rules   int
+	updated time.Time
+	err     error
+	hits    atomic.Uint64
+}
+
+// Blocklist answers whether a domain is blocked by one of the configured
+// lists. Lists are read from files or downloaded, and a downloaded copy is
+// kept in the cache directory for when the next download fails.
+type Blocklist struct {
+	cfg   conf.BlocklistConfig
+	fetch Fetcher
+	allow []string
+
+	mu    sync.RWMutex
+	index *index
+	lists []*list
+}
+
+func New(cfg conf.BlocklistConfig, fetch Fetcher) *Blocklist {
+	b := &Blocklist{cfg: cfg, fetch: fetch, index: newIndex()}
+	for _, raw := range cfg.Allow {
+		if err := matcher.Validate(raw); err != nil || matcher.IsNetwork(raw) {
+			logx.Errorf("invalid blocklist allow pattern %q", raw)
+			continue
+		}
+		b.allow = append(b.allow, strings.ToLower(raw))
+	}
+	for i, src := range cfg.Lists {
+		if src.Name == "" {
+			src.Name = fmt.Sprintf("list%d", i+1)
+		}
+		b.lists = append(b.lists, &list{source: src})
+	}
+	return b
+}
+
+// Blocked reports the name of the list blocking domain.
+func (b *Blocklist) Blocked(domain string) (string, bool) {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if domain == "" {
+		return "", false
+	}
+	for _, pattern := range b.allow {
+		if matcher.Match(pattern, domain) {
+			return "", false
+		}
+	}
+	b.mu.RLock()
+	idx, ok := b.index.match(domain)
+	var l *list
+	if ok {
+		l = b.lists[idx]
+	}
+	b.mu.RUnlock()
+	if !ok {
+		return "", false
+	}
+	l.hits.Add(1)
+	return l.source.Name, true
+}
+
+// Run loads the lists and reloads them every refresh interval until ctx is
+// done.
+func (b *Blocklist) Run(ctx context.Context) {
+	b.Refresh(ctx)
+	interval := defaultRefreshInterval
+	switch n := b.cfg.RefreshInterval; {
+	case n < 0:
+		return
+	case n > 0:
+		interval = time.Duration(n) * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.Refresh(ctx)
+		}
+	}
+}
+
+// Refresh reloads every list and swaps the compiled index in one go. A list
+// that cannot be read keeps the rules of its cached copy, if any.
+func (b *Blocklist) Refresh(ctx context.Context) {
+	idx := newIndex()
+	lists := b.lists
+
+	type result struct {
+		rules   int
+		updated time.Time
+		err     error
+	}
+	results := make([]result, len(lists))
+	for i, l := range lists {
+		rules, fresh, err := b.load(ctx, idx, i, l.source)
+		results[i] = result{rules: rules, err: err}
+		if fresh {
+			results[i].updated = time.Now()
+		}
+		if err != nil {
+			logx.Warnf("blocklist %s: %v", l.source.Name, err)
+		}
+	}
+
+	total := 0
+	b.mu.Lock()
+	b.index = idx
+	for i, l := range lists {
+		r := results[i]
+		l.rules, l.err = r.rules, r.err
+		if !r.updated.IsZero() {
+			l.updated = r.updated
+		}
+		total += r.rules
+	}
+	b.mu.Unlock()
+	logx.Infof("blocklists loaded: %d rules from %d lists", total, len(lists))
+}
+
+// load adds the rules of src to idx and reports whether they came from the
+// source itself rather than the cached copy.
+func (b *Blocklist) load(ctx context.Context, idx *index, pos int, src conf.BlocklistSource) (int, bool, error) {
+	if src.Path != "" {
+		f, err := os.Open(src.Path)
+		if err != nil {
+			return 0, false, err
+		}
+		defer f.Close()
+		n, err := idx.add(f, pos)
+		return n, err == nil, err
+	}
+	if src.URL == "" {
+		return 0, false, errors.New("list has neither url nor path")
+	}
+
+	cache := b.cachePath(src)
+	err := b.download(ctx, src.URL, cache)
+	f, openErr := os.Open(cache)
+	if openErr != nil {
+		if err == nil {
+			err = openErr
+		}
+		return 0, false, err
+	}
+	defer f.Close()
+	n, parseErr := idx.add(f, pos)
+	if err == nil {
+		err = parseErr
+	} else {
+		err = fmt.Errorf("%w (using cached copy)", err)
+	}
+	return n, err == nil, err
+}
+
+func (b *Blocklist) download(ctx context.Context, url, path string) error {
+	if b.fetch == nil {
+		return errors.New("downloads are not available")
+	}
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	body, err := b.fetch(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+	}
+	return err
+}
+
+func (b *Blocklist) cachePath(src conf.BlocklistSource) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, src.Name)
+	return filepath.Join(b.cfg.CacheDir, name+".txt")
+}
+
+func (b *Blocklist) Stats() []ListStat {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	out := make([]ListStat, 0, len(b.lists))
+	for _, l := range b.lists {
+		source := l.source.URL
+		if l.source.Path != "" {
+			source = l.source.Path
+		}
+		out = append(out, ListStat{
+			Name:    l.source.Name,
+			Source:  source,
+			Rules:   l.rules,
+			Hits:    l.hits.Load(),
+			Updated: l.updated,
+			Err:     l.err,
+		})
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package blocklist
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ApostolDmitry/vpner/internal/conf"
+)
+
+func TestBlocklistRefreshAndCache(t *testing.T) {
+	dir := t.TempDir()
+	local := filepath.Join(dir, "local.txt")
+	if err := os.WriteFile(local, []byte("local.test\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	remote := "||remote.test^\n"
+	var fetchErr error
+	fetch := func(_ context.Context, url string) (io.ReadCloser, error) {
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		return io.NopCloser(strings.NewReader(remote)), nil
+	}
+	b := New(conf.BlocklistConfig{
+		CacheDir: filepath.Join(dir, "cache"),
+		Allow:    []string{"ok.remote.test"},
+		Lists: []conf.BlocklistSource{
+			{Name: "local", Path: local},
+			{Name: "remote", URL: "https://lists.test/remote.txt"},
+		},
+	}, fetch)
+	b.Refresh(context.Background())
+
+	if list, ok := b.Blocked("Local.Test."); !ok || list != "local" {
+		t.Fatalf("local.test: %q, %v", list, ok)
+	}
+	if list, ok := b.Blocked("a.remote.test"); !ok || list != "remote" {
+		t.Fatalf("a.remote.test: %q, %v", list, ok)
+	}
+	if _, ok := b.Blocked("ok.remote.test"); ok {
+		t.Fatal("allowlisted domain blocked")
+	}
+
+	// A failed download falls back to the cached copy.
+	fetchErr = errors.New("offline")
+	b.Refresh(context.Background())
+	if _, ok := b.Blocked("remote.test"); !ok {
+		t.Fatal("cached rules were dropped")
+	}
+
+	stats := b.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("expected two lists, got %d", len(stats))
+	}
+	if stats[0].Rules != 1 || stats[0].Hits != 1 || stats[0].Err != nil || stats[0].Updated.IsZero() {
+		t.Fatalf("unexpected local stats %+v", stats[0])
+	}
+	if stats[1].Rules != 1 || stats[1].Hits != 2 || stats[1].Err == nil || stats[1].Updated.IsZero() {
+		t.Fatalf("unexpected remote stats %+v", stats[1])
+	}
+}
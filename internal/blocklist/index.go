@@ -0,0 +1,203 @@
+package blocklist
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+type rule struct {
+	domain string
+	suffix bool
+	allow  bool
+}
+
+// index maps blocked names to the list that blocks them. A suffix entry also
+// covers every subdomain, so a lookup walks the labels of the name from the
+// full name up to its last label.
+type index struct {
+	exact       map[string]int
+	suffix      map[string]int
+	allowExact  map[string]struct{}
+	allowSuffix map[string]struct{}
+}
+
+func newIndex() *index {
+	return &index{
+		exact:       make(map[string]int),
+		suffix:      make(map[string]int),
+		allowExact:  make(map[string]struct{}),
+		allowSuffix: make(map[string]struct{}),
+	}
+}
+
+// add loads the rules of one list and returns how many it had.
+func (x *index) add(r io.Reader, list int) (int, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	n := 0
+	for sc.Scan() {
+		for _, ru := range parseLine(sc.Text()) {
+			n++
+			switch {
+			case ru.allow && ru.suffix:
+				x.allowSuffix[ru.domain] = struct{}{}
+			case ru.allow:
+				x.allowExact[ru.domain] = struct{}{}
+			case ru.suffix:
+				if _, ok := x.suffix[ru.domain]; !ok {
+					x.suffix[ru.domain] = list
+				}
+			default:
+				if _, ok := x.exact[ru.domain]; !ok {
+					x.exact[ru.domain] = list
+				}
+			}
+		}
+	}
+	return n, sc.Err()
+}
+
+func (x *index) match(domain string) (int, bool) {
+	if _, ok := x.allowExact[domain]; ok {
+		return 0, false
+	}
+	for name := domain; ; {
+		if _, ok := x.allowSuffix[name]; ok {
+			return 0, false
+		}
+		dot := strings.IndexByte(name, '.')
+		if dot < 0 {
+			break
+		}
+		name = name[dot+1:]
+	}
+	if list, ok := x.exact[domain]; ok {
+		return list, true
+	}
+	for name := domain; ; {
+		if list, ok := x.suffix[name]; ok {
+			return list, true
+		}
+		dot := strings.IndexByte(name, '.')
+		if dot < 0 {
+			return 0, false
+		}
+		name = name[dot+1:]
+	}
+}
+
+// parseLine understands hosts files ("0.0.0.0 ads.example.com"), plain
+// domain lists ("ads.example.com", "*.example.com") and the domain subset of
+// AdGuard/ABP syntax ("||example.com^", "|example.com^", "@@||example.com^").
+// Everything else, such as cosmetic, path or regex rules, is skipped.
+func parseLine(line string) []rule {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' || line[0] == '!' || line[0] == '[' {
+		return nil
+	}
+	if strings.HasPrefix(line, "@@") {
+		r, ok := parseAdblock(line[2:])
+		if !ok {
+			return nil
+		}
+		r.allow = true
+		return []rule{r}
+	}
+	if line[0] == '|' {
+		if r, ok := parseAdblock(line); ok {
+			return []rule{r}
+		}
+		return nil
+	}
+	if strings.Contains(line, "##") || strings.Contains(line, "#@#") || strings.Contains(line, "#?#") {
+		return nil
+	}
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) == 0:
+		return nil
+	case len(fields) == 1:
+		if r, ok := plainRule(fields[0]); ok {
+			return []rule{r}
+		}
+		return nil
+	case net.ParseIP(fields[0]) == nil:
+		return nil
+	}
+	var out []rule
+	for _, f := range fields[1:] {
+		if d, ok := domainName(f); ok && !hostsReserved[d] {
+			out = append(out, rule{domain: d})
+		}
+	}
+	return out
+}
+
+var hostsReserved = map[string]bool{
+	"localhost":             true,
+	"localhost.localdomain": true,
+	"local":                 true,
+	"broadcasthost":         true,
+	"ip6-localhost":         true,
+	"ip6-loopback":          true,
+	"ip6-localnet":          true,
+	"ip6-mcastprefix":       true,
+	"ip6-allnodes":          true,
+	"ip6-allrouters":        true,
+	"ip6-allhosts":          true,
+	"0.0.0.0":               true,
+}
+
+func parseAdblock(s string) (rule, bool) {
+	r := rule{}
+	switch {
+	case strings.HasPrefix(s, "||"):
+		r.suffix = true
+		s = s[2:]
+	case strings.HasPrefix(s, "|"):
+		s = s[1:]
+	default:
+		return rule{}, false
+	}
+	if i := strings.IndexByte(s, '$'); i >= 0 {
+		// Only $important keeps the rule meaningful for DNS.
+		if s[i+1:] != "important" {
+			return rule{}, false
+		}
+		s = s[:i]
+	}
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "|"), "^")
+	d, ok := domainName(s)
+	if !ok {
+		return rule{}, false
+	}
+	r.domain = d
+	return r, true
+}
+
+func plainRule(s string) (rule, bool) {
+	if rest, ok := strings.CutPrefix(s, "*."); ok {
+		d, ok := domainName(rest)
+		return rule{domain: d, suffix: true}, ok
+	}
+	d, ok := domainName(s)
+	return rule{domain: d}, ok && !hostsReserved[d]
+}
+
+func domainName(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSuffix(s, "."))
+	if s == "" || strings.ContainsAny(s, "/*:^|$@") || net.ParseIP(s) != nil {
+		return "", false
+	}
+	if _, ok := dns.IsDomainName(s); !ok {
+		return "", false
+	}
+	return s, true
+}
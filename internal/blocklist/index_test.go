@@ -0,0 +1,71 @@
+package blocklist
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIndexFormats(t *testing.T) {
+	lists := []string{
+		`# hosts
+127.0.0.1 localhost
+0.0.0.0 ads.example.com tracker.example.com # inline
+::1 ip6-localhost`,
+		`! AdGuard
+[Adblock Plus 2.0]
+||doubleclick.net^
+|exact.example.org^
+||cdn.example.net^$important
+||third.example.net^$third-party
+@@||good.doubleclick.net^
+example.com##.banner
+/ads[0-9]+\.example\.com/`,
+		`malware.test
+*.wild.test`,
+	}
+	x := newIndex()
+	for i, l := range lists {
+		if _, err := x.add(strings.NewReader(l), i); err != nil {
+			t.Fatalf("add list %d: %v", i, err)
+		}
+	}
+
+	cases := []struct {
+		domain string
+		list   int
+		ok     bool
+	}{
+		{"ads.example.com", 0, true},
+		{"tracker.example.com", 0, true},
+		{"sub.ads.example.com", 0, false},
+		{"localhost", 0, false},
+		{"doubleclick.net", 1, true},
+		{"x.y.doubleclick.net", 1, true},
+		{"good.doubleclick.net", 0, false},
+		{"a.good.doubleclick.net", 0, false},
+		{"exact.example.org", 1, true},
+		{"sub.exact.example.org", 0, false},
+		{"cdn.example.net", 1, true},
+		{"third.example.net", 0, false},
+		{"example.com", 0, false},
+		{"malware.test", 2, true},
+		{"a.malware.test", 0, false},
+		{"wild.test", 2, true},
+		{"a.b.wild.test", 2, true},
+	}
+	for _, tc := range cases {
+		list, ok := x.match(tc.domain)
+		if ok != tc.ok || (ok && list != tc.list) {
+			t.Errorf("match(%q) = %d, %v; want %d, %v", tc.domain, list, ok, tc.list, tc.ok)
+		}
+	}
+}
+
+func TestParseLineCountsHostsAliases(t *testing.T) {
+	if got := len(parseLine("0.0.0.0 a.test b.test c.test")); got != 3 {
+		t.Fatalf("expected three rules, got %d", got)
+	}
+	if got := parseLine("not a rule line"); got != nil {
+		t.Fatalf("expected no rules, got %v", got)
+	}
+}
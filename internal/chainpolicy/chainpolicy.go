@@ -0,0 +1,66 @@
+package chainpolicy
+
+import (
+	"fmt"
+	"strings"
+)
+
+type KillSwitch string
+
+const (
+	KillSwitchOff    KillSwitch = "off"
+	KillSwitchReject KillSwitch = "reject"
+	KillSwitchDrop   KillSwitch = "drop"
+)
+
+func ParseKillSwitch(value string) (KillSwitch, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "off", "none", "disabled":
+		return KillSwitchOff, nil
+	case "reject":
+		return KillSwitchReject, nil
+	case "drop":
+		return KillSwitchDrop, nil
+	default:
+		return "", fmt.Errorf("unsupported kill-switch mode %q (want off, reject or drop)", value)
+	}
+}
+
+func (k KillSwitch) Enabled() bool {
+	return k == KillSwitchReject || k == KillSwitchDrop
+}
+
+func (k KillSwitch) String() string {
+	if k == "" {
+		return string(KillSwitchOff)
+	}
+	return string(k)
+}
+
+type UDPPolicy string
+
+const (
+	UDPLeak          UDPPolicy = "leak"
+	UDPBlockQUIC     UDPPolicy = "block-quic"
+	UDPTProxyUDPOnly UDPPolicy = "tproxy-udp-only"
+)
+
+func ParseUDPPolicy(value string) (UDPPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "leak", "off", "none":
+		return UDPLeak, nil
+	case "block-quic", "block":
+		return UDPBlockQUIC, nil
+	case "tproxy-udp-only", "tproxy-udp", "tproxy":
+		return UDPTProxyUDPOnly, nil
+	default:
+		return "", fmt.Errorf("unsupported UDP policy %q (want leak, block-quic or tproxy-udp-only)", value)
+	}
+}
+
+func (u UDPPolicy) String() string {
+	if u == "" {
+		return string(UDPLeak)
+	}
+	return string(u)
+}
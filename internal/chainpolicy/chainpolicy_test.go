@@ -0,0 +1,68 @@
+package chainpolicy
+
+import "testing"
+
+func TestParseKillSwitch(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]KillSwitch{
+		"":         KillSwitchOff,
+		"off":      KillSwitchOff,
+		" Reject ": KillSwitchReject,
+		"DROP":     KillSwitchDrop,
+	}
+	for in, want := range cases {
+		got, err := ParseKillSwitch(in)
+		if err != nil {
+			t.Fatalf("ParseKillSwitch(%q): %v", in, err)
+		}
+		if got != want {
+			t.Fatalf("ParseKillSwitch(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	if _, err := ParseKillSwitch("block"); err == nil {
+		t.Fatal("expected error for unknown mode")
+	}
+}
+
+func TestKillSwitchEnabled(t *testing.T) {
+	t.Parallel()
+
+	if KillSwitchOff.Enabled() || KillSwitch("").Enabled() {
+		t.Fatal("off must not be enabled")
+	}
+	if !KillSwitchReject.Enabled() || !KillSwitchDrop.Enabled() {
+		t.Fatal("reject and drop must be enabled")
+	}
+	if KillSwitch("").String() != "off" {
+		t.Fatalf("empty mode string = %q", KillSwitch("").String())
+	}
+}
+
+func TestParseUDPPolicy(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]UDPPolicy{
+		"":                UDPLeak,
+		"leak":            UDPLeak,
+		" Block-QUIC ":    UDPBlockQUIC,
+		"tproxy-udp-only": UDPTProxyUDPOnly,
+	}
+	for in, want := range cases {
+		got, err := ParseUDPPolicy(in)
+		if err != nil {
+			t.Fatalf("ParseUDPPolicy(%q): %v", in, err)
+		}
+		if got != want {
+			t.Fatalf("ParseUDPPolicy(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	if _, err := ParseUDPPolicy("drop"); err == nil {
+		t.Fatal("expected error for unknown policy")
+	}
+	if UDPPolicy("").String() != "leak" {
+		t.Fatalf("empty policy string = %q", UDPPolicy("").String())
+	}
+}
@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	grpcpb "github.com/ApostolDmitry/vpner/internal/grpc"
+	"github.com/ApostolDmitry/vpner/internal/tablefmt"
+)
+
+var (
+	clientGroupCmd = &cobra.Command{
+		Use:   "client-group",
+		Short: "Manage LAN client groups and per-chain client policies",
+	}
+)
+
+func init() {
+	clientGroupCmd.AddCommand(clientGroupListCmd())
+	clientGroupCmd.AddCommand(clientGroupAddCmd())
+	clientGroupCmd.AddCommand(clientGroupRemoveCmd())
+	clientGroupCmd.AddCommand(clientGroupPolicyCmd())
+	clientGroupCmd.AddCommand(clientGroupFullTunnelCmd())
+}
+
+func clientGroupListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "Show client groups and chain policies",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withClient(func(ctx context.Context, c grpcpb.VpnerManagerClient) error {
+				resp, err := c.ClientGroupList(ctx, &grpcpb.Empty{})
+				if err != nil {
+					return err
+				}
+				groups := tablefmt.Table{Headers: []string{"Group", "Client", "Full tunnel"}}
+				for _, group := range resp.Groups {
+					fullTunnel := group.FullTunnel
+					if fullTunnel == "" {
+						fullTunnel = "-"
+					}
+					if len(group.Members) == 0 {
+						groups.Rows = append(groups.Rows, []string{group.Name, "-", fullTunnel})
+					}
+					for _, member := range group.Members {
+						groups.Rows = append(groups.Rows, []string{group.Name, member, fullTunnel})
+					}
+				}
+				printTable(groups)
+
+				if len(resp.Policies) == 0 {
+					return nil
+				}
+				fmt.Println()
+				policies := tablefmt.Table{Headers: []string{"Chain", "Only", "Except"}}
+				for _, policy := range resp.Policies {
+					policies.Rows = append(policies.Rows, []string{
+						policy.ChainName,
+						joinOrDash(policy.Only),
+						joinOrDash(policy.Except),
+					})
+				}
+				printTable(policies)
+				return nil
+			})
+		},
+	}
+}
+
+func clientGroupAddCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "add <group> <ip|cidr|mac>...",
+		Short: "Add clients to a group, creating it if needed",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withClient(func(ctx context.Context, c grpcpb.VpnerManagerClient) error {
+				resp, err := c.ClientGroupAdd(ctx, &grpcpb.ClientGroupRequest{
+					Name:    args[0],
+					Members: args[1:],
+				})
+				if err != nil {
+					return err
+				}
+				return printGenericResponse(resp)
+			})
+		},
+	}
+}
+
+func clientGroupRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove <group> [ip|cidr|mac...]",
+		Short: "Remove clients from a group, or the whole group when no clients are given",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withClient(func(ctx context.Context, c grpcpb.VpnerManagerClient) error {
+				resp, err := c.ClientGroupRemove(ctx, &grpcpb.ClientGroupRequest{
+					Name:    args[0],
+					Members: args[1:],
+				})
+				if err != nil {
+					return err
+				}
+				return printGenericResponse(resp)
+			})
+		},
+	}
+}
+
+func clientGroupPolicyCmd() *cobra.Command {
+	var only, except []string
+	cmd := &cobra.Command{
+		Use:   "policy <chain>",
+		Short: "Limit a chain to, or exclude it for, client groups (no flags resets to all clients)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withClient(func(ctx context.Context, c grpcpb.VpnerManagerClient) error {
+				resp, err := c.ClientGroupSetPolicy(ctx, &grpcpb.ClientPolicyRequest{
+					ChainName: args[0],
+					Only:      only,
+					Except:    except,
+				})
+				if err != nil {
+					return err
+				}
+				return printGenericResponse(resp)
+			})
+		},
+	}
+	cmd.Flags().StringSliceVar(&only, "only", nil, "route only clients from these groups through the chain")
+	cmd.Flags().StringSliceVar(&except, "except", nil, "bypass the chain for clients from these groups")
+	return cmd
+}
+
+func clientGroupFullTunnelCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "full-tunnel <group> <chain|off>",
+		Short: "Send all traffic of a client group through an Xray chain",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			chain := args[1]
+			if strings.EqualFold(chain, "off") {
+				chain = ""
+			}
+			return withClient(func(ctx context.Context, c grpcpb.VpnerManagerClient) error {
+				resp, err := c.ClientGroupSetFullTunnel(ctx, &grpcpb.ClientFullTunnelRequest{
+					Name:      args[0],
+					ChainName: chain,
+				})
+				if err != nil {
+					return err
+				}
+				return printGenericResponse(resp)
+			})
+		},
+	}
+}
+
+func joinOrDash(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ", ")
+}
@@ -2,10 +2,19 @@ package cli
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
 
 	grpcpb "github.com/ApostolDmitry/vpner/internal/grpc"
+	"github.com/ApostolDmitry/vpner/internal/tablefmt"
 )
 
 var dnsCmd = &cobra.Command{
@@ -18,6 +27,16 @@ func init() {
 	dnsCmd.AddCommand(newDNSManageCmd("stop", grpcpb.ManageAction_STOP))
 	dnsCmd.AddCommand(newDNSManageCmd("status", grpcpb.ManageAction_STATUS))
 	dnsCmd.AddCommand(newDNSManageCmd("restart", grpcpb.ManageAction_RESTART))
+
+	recordCmd := &cobra.Command{
+		Use:   "record",
+		Short: "Manage local DNS records",
+	}
+	recordCmd.AddCommand(dnsRecordListCmd())
+	recordCmd.AddCommand(dnsRecordAddCmd())
+	recordCmd.AddCommand(dnsRecordDelCmd())
+	dnsCmd.AddCommand(recordCmd)
+	dnsCmd.AddCommand(dnsLogCmd())
 }
 
 func newDNSManageCmd(name string, action grpcpb.ManageAction) *cobra.Command {
@@ -35,3 +54,164 @@ func newDNSManageCmd(name string, action grpcpb.ManageAction) *cobra.Command {
 		},
 	}
 }
+
+func dnsRecordListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "Show local DNS records",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withClient(func(ctx context.Context, c grpcpb.VpnerManagerClient) error {
+				resp, err := c.DnsRecordList(ctx, &grpcpb.Empty{})
+				if err != nil {
+					return err
+				}
+				tbl := tablefmt.Table{Headers: []string{"Name", "Type", "Value", "TTL", "Source"}}
+				for _, r := range resp.Records {
+					ttl := "-"
+					if r.Ttl > 0 {
+						ttl = fmt.Sprintf("%d", r.Ttl)
+					}
+					tbl.Rows = append(tbl.Rows, []string{r.Name, r.Type, r.Value, ttl, r.Source})
+				}
+				printTable(tbl)
+				return nil
+			})
+		},
+	}
+}
+
+func dnsRecordAddCmd() *cobra.Command {
+	var ttl int32
+	cmd := &cobra.Command{
+		Use:   "add <name> <A|AAAA|CNAME|TXT|PTR> <value>",
+		Short: "Add a local DNS record",
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withClient(func(ctx context.Context, c grpcpb.VpnerManagerClient) error {
+				resp, err := c.DnsRecordAdd(ctx, &grpcpb.DnsRecord{Name: args[0], Type: args[1], Value: args[2], Ttl: ttl})
+				if err != nil {
+					return err
+				}
+				return printGenericResponse(resp)
+			})
+		},
+	}
+	cmd.Flags().Int32Var(&ttl, "ttl", 0, "record TTL in seconds (default 300)")
+	return cmd
+}
+
+func dnsRecordDelCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "del <name> [type] [value]",
+		Short: "Delete local DNS records added with 'dns record add'",
+		Args:  cobra.RangeArgs(1, 3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := &grpcpb.DnsRecord{Name: args[0]}
+			if len(args) > 1 {
+				req.Type = args[1]
+			}
+			if len(args) > 2 {
+				req.Value = args[2]
+			}
+			return withClient(func(ctx context.Context, c grpcpb.VpnerManagerClient) error {
+				resp, err := c.DnsRecordDel(ctx, req)
+				if err != nil {
+					return err
+				}
+				return printGenericResponse(resp)
+			})
+		},
+	}
+}
+
+func dnsLogCmd() *cobra.Command {
+	var (
+		req    grpcpb.DnsQueryLogRequest
+		follow bool
+		asJSON bool
+	)
+	cmd := &cobra.Command{
+		Use:   "log",
+		Short: "Show recent DNS queries",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			show := printQueryLogEntry
+			if asJSON {
+				show = printQueryLogJSON
+			}
+			if !follow {
+				return withClient(func(ctx context.Context, c grpcpb.VpnerManagerClient) error {
+					resp, err := c.DnsQueryLog(ctx, &req)
+					if err != nil {
+						return err
+					}
+					if len(resp.Entries) == 0 && !asJSON {
+						fmt.Println("No data")
+					}
+					for _, e := range resp.Entries {
+						show(e)
+					}
+					return nil
+				})
+			}
+
+			if rt == nil {
+				return fmt.Errorf("client is not initialized")
+			}
+			ctx, cancel := rt.Context(0)
+			defer cancel()
+			ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			stream, err := rt.Client().DnsQueryLogFollow(ctx, &req)
+			if err != nil {
+				return err
+			}
+			for {
+				e, err := stream.Recv()
+				if err == io.EOF || ctx.Err() != nil {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				show(e)
+			}
+		},
+	}
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "keep printing new queries")
+	cmd.Flags().StringVar(&req.Client, "client", "", "only queries from this client address")
+	cmd.Flags().StringVar(&req.Domain, "domain", "", "only names containing this text")
+	cmd.Flags().StringVar(&req.Type, "type", "", "only this query type (A, AAAA, ...)")
+	cmd.Flags().StringVar(&req.Rcode, "rcode", "", "only this response code (NOERROR, NXDOMAIN, ...)")
+	cmd.Flags().StringVar(&req.Source, "source", "", "only answers from this source (cache, upstream, chain, blocklist, ...)")
+	cmd.Flags().Int32VarP(&req.Limit, "lines", "n", 50, "number of past queries to show, 0 for all")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print one JSON object per query")
+	return cmd
+}
+
+func printQueryLogEntry(e *grpcpb.DnsQueryLogEntry) {
+	source := e.Source
+	if e.Upstream != "" {
+		source += " " + e.Upstream
+	}
+	answers := "-"
+	if len(e.Answers) > 0 {
+		answers = strings.Join(e.Answers, ", ")
+	}
+	line := fmt.Sprintf("%s %-15s %-5s %s %s %s %s %s",
+		time.UnixMilli(e.TimeUnixMs).Format("15:04:05.000"),
+		e.Client, e.Type, e.Name, e.Rcode, source,
+		(time.Duration(e.LatencyUs) * time.Microsecond).Round(100*time.Microsecond), answers)
+	if e.Rule != "" {
+		line += fmt.Sprintf(" [%s -> %s]", e.Rule, e.Chain)
+	}
+	fmt.Println(line)
+}
+
+func printQueryLogJSON(e *grpcpb.DnsQueryLogEntry) {
+	out, err := protojson.Marshal(e)
+	if err != nil {
+		return
+	}
+	fmt.Println(string(out))
+}
@@ -9,31 +9,70 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/ApostolDmitry/vpner/internal/conf"
+	"github.com/ApostolDmitry/vpner/internal/firewall"
 	"github.com/ApostolDmitry/vpner/internal/tablefmt"
 )
 
 func doctorCmd() *cobra.Command {
-	return &cobra.Command{
+	var configPath string
+	cmd := &cobra.Command{
 		Use:               "doctor",
 		Short:             "Check the local environment for running vpnerd (no daemon needed)",
 		PersistentPreRunE: noDial,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runDoctor()
+			return runDoctor(configPath)
 		},
 	}
+	cmd.Flags().StringVar(&configPath, "config", defaultConfigPath, "daemon config file (for network.firewall-backend)")
+	return cmd
 }
 
 type checkResult struct {
 	name, status, detail string
 }
 
-func runDoctor() error {
+func runDoctor(configPath string) error {
 	var results []checkResult
 	add := func(name, status, detail string) {
 		results = append(results, checkResult{name, status, detail})
 	}
 
-	for _, bin := range []string{"xray", "iptables", "ip", "ipset"} {
+	pref := firewall.BackendAuto
+	if cfg, err := conf.LoadFullConfig(configPath); err != nil {
+		add("config", "WARN", err.Error()+" (assuming firewall-backend auto)")
+	} else if pref, err = firewall.ParseBackend(cfg.Network.FirewallBackend); err != nil {
+		add("config", "FAIL", err.Error())
+		pref = firewall.BackendAuto
+	}
+
+	backend, backendErr := firewall.DetectBackend(pref)
+	if backendErr != nil {
+		add("firewall backend", "FAIL", backendErr.Error())
+	} else {
+		add("firewall backend", "OK", string(backend))
+	}
+
+	required := []string{"xray", "ip", "iptables", "ipset"}
+	optional := []checkResult{
+		{"nft", "WARN", "not found (iptables backend in use)"},
+		{"ip6tables", "WARN", "not found (needed for IPv6 / restore)"},
+		{"iptables-save", "WARN", "not found (needed for IPv6 / restore)"},
+	}
+	if backend == firewall.BackendIptables && firewall.IPSetNetlinkAvailable() {
+		add("ipset netlink", "OK", "entries managed over nfnetlink")
+		required = []string{"xray", "ip", "iptables"}
+		optional = append(optional, checkResult{"ipset", "WARN", "not found (only needed when netlink is unavailable)"})
+	}
+	if backend == firewall.BackendNftables {
+		required = []string{"xray", "ip", "nft"}
+		optional = []checkResult{
+			{"iptables", "WARN", "not found (nftables backend in use)"},
+			{"ipset", "WARN", "not found (nftables backend in use)"},
+		}
+	}
+
+	for _, bin := range required {
 		if p, err := exec.LookPath(bin); err == nil {
 			add(bin, "OK", p)
 		} else {
@@ -41,28 +80,44 @@ func runDoctor() error {
 		}
 	}
 
-	for _, bin := range []string{"ip6tables", "iptables-save"} {
-		if p, err := exec.LookPath(bin); err == nil {
-			add(bin, "OK", p)
+	for _, opt := range optional {
+		if p, err := exec.LookPath(opt.name); err == nil {
+			add(opt.name, "OK", p)
 		} else {
-			add(bin, "WARN", "not found (needed for IPv6 / restore)")
+			add(opt.name, opt.status, opt.detail)
 		}
 	}
 
+	tproxyModules := []string{"xt_TPROXY", "xt_socket"}
+	if backend == firewall.BackendNftables {
+		tproxyModules = []string{"nft_tproxy", "nft_socket"}
+	}
 	rel := kernelRelease()
-	for _, mod := range []string{"xt_TPROXY", "xt_socket"} {
+	present := make(map[string]bool)
+	for _, mod := range tproxyModules {
 		switch {
 		case rel == "":
 			add(mod, "WARN", "could not determine kernel release (uname -r)")
 		default:
 			path := fmt.Sprintf("/lib/modules/%s/%s.ko", rel, mod)
 			if _, err := os.Stat(path); err == nil {
+				present[mod] = true
 				add(mod, "OK", path)
 			} else {
 				add(mod, "WARN", path+" not found (TPROXY may be unavailable; REDIRECT still works)")
 			}
 		}
 	}
+	if rel != "" {
+		switch tproxyTarget, socketMatch := present[tproxyModules[0]], present[tproxyModules[1]]; {
+		case tproxyTarget && socketMatch:
+			add("udp policy", "OK", "full TPROXY available, UDP is proxied")
+		case tproxyTarget:
+			add("udp policy", "WARN", "REDIRECT mode; use 'xray udp-policy <chain> tproxy-udp-only' to proxy UDP")
+		default:
+			add("udp policy", "WARN", "REDIRECT mode without TPROXY; use 'xray udp-policy <chain> block-quic' to stop QUIC leaks")
+		}
+	}
 
 	for _, dir := range []string{"/opt/etc/vpner", "/opt/etc/vpner/xray"} {
 		if writable(dir) {
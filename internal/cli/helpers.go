@@ -69,6 +69,9 @@ func handleGenericResponse(resp *grpcpb.GenericResponse, quiet bool) error {
 	case *grpcpb.GenericResponse_Success:
 		if !quiet {
 			fmt.Println(r.Success.Message)
+			if resp.Plan != nil {
+				printPlan(resp.Plan, false)
+			}
 		}
 	case *grpcpb.GenericResponse_Error:
 		return fmt.Errorf("%s", r.Error.Message)
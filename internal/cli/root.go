@@ -70,6 +70,9 @@ func init() {
 	rootCmd.AddCommand(configCmd())
 	rootCmd.AddCommand(dnsCmd)
 	rootCmd.AddCommand(unblockCmd)
+	rootCmd.AddCommand(clientGroupCmd)
 	rootCmd.AddCommand(interfaceCmd)
 	rootCmd.AddCommand(xrayCmd)
+	rootCmd.AddCommand(routingCmd)
+	rootCmd.AddCommand(traceCmd())
 }
@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	grpcpb "github.com/ApostolDmitry/vpner/internal/grpc"
+	"github.com/ApostolDmitry/vpner/internal/tablefmt"
+)
+
+var routingCmd = &cobra.Command{
+	Use:   "routing",
+	Short: "Inspect and plan firewall routing",
+}
+
+func init() {
+	routingCmd.AddCommand(routingShowCmd())
+	routingCmd.AddCommand(routingPlanCmd())
+}
+
+func routingShowCmd() *cobra.Command {
+	var (
+		chain  string
+		asJSON bool
+	)
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show managed firewall chains, sets and ip rules and whether they exist in the kernel",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withClient(func(ctx context.Context, c grpcpb.VpnerManagerClient) error {
+				resp, err := c.RoutingState(ctx, &grpcpb.RoutingStateRequest{ChainName: chain})
+				if err != nil {
+					return err
+				}
+				if asJSON {
+					out, err := protojson.MarshalOptions{Multiline: true, Indent: "  ", EmitUnpopulated: true}.Marshal(resp)
+					if err != nil {
+						return err
+					}
+					fmt.Println(string(out))
+					return nil
+				}
+				printRoutingState(resp)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&chain, "chain", "", "show only this chain")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print JSON instead of a table")
+	return cmd
+}
+
+func printRoutingState(resp *grpcpb.RoutingStateResponse) {
+	tbl := tablefmt.Table{Headers: []string{"Chain", "Family", "Table", "Managed chain", "Rule", "Jumps", "IPSet", "Entries", "Mark", "Route table", "IP rule"}}
+	var missing []string
+	for _, st := range resp.Chains {
+		present := 0
+		for _, j := range st.Jumps {
+			if j.Present {
+				present++
+			} else {
+				missing = append(missing, fmt.Sprintf("%s (%s): %s", st.Chain, st.Family, j.Rule))
+			}
+		}
+		mark, table, ipRule := "-", "-", "-"
+		if st.Mark != "" {
+			mark = st.Mark
+			if st.Tproxy {
+				mark += " (tproxy)"
+			}
+			table = fmt.Sprintf("%d", st.RouteTable)
+			ipRule = presence(st.IpRulePresent)
+		}
+		tbl.Rows = append(tbl.Rows, []string{
+			st.Chain, st.Family, st.Table,
+			st.ChainName + " " + presence(st.ChainPresent),
+			yesNo(st.TargetPresent),
+			fmt.Sprintf("%d/%d", present, len(st.Jumps)),
+			st.IpsetName + " " + presence(st.IpsetPresent),
+			fmt.Sprintf("%d", st.IpsetEntries),
+			mark, table, ipRule,
+		})
+	}
+	printTable(tbl)
+	if len(missing) > 0 {
+		fmt.Println()
+		fmt.Println("Missing jump rules:")
+		for _, line := range missing {
+			fmt.Println("  " + line)
+		}
+	}
+}
+
+func presence(ok bool) string {
+	if ok {
+		return "(ok)"
+	}
+	return "(missing)"
+}
+
+func routingPlanCmd() *cobra.Command {
+	var (
+		chain string
+		steps bool
+	)
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Show what a full routing restore would change, without applying it",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withClient(func(ctx context.Context, c grpcpb.VpnerManagerClient) error {
+				resp, err := c.RoutingPlan(ctx, &grpcpb.RoutingPlanRequest{ChainName: chain})
+				if err != nil {
+					return err
+				}
+				printPlan(resp, steps)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&chain, "chain", "", "limit the plan to one chain")
+	cmd.Flags().BoolVar(&steps, "steps", false, "also print the recorded commands")
+	return cmd
+}
+
+func printPlan(plan *grpcpb.Plan, withSteps bool) {
+	if withSteps {
+		for _, step := range plan.Steps {
+			switch {
+			case len(step.Lines) > 0 && step.Table != "":
+				fmt.Printf("$ %s -t %s\n", step.Tool, step.Table)
+			case len(step.Lines) > 0:
+				fmt.Printf("$ %s\n", step.Tool)
+			default:
+				fmt.Printf("$ %s %s\n", step.Tool, strings.Join(step.Args, " "))
+			}
+			for _, line := range step.Lines {
+				fmt.Println("    " + line)
+			}
+		}
+		if len(plan.Steps) > 0 {
+			fmt.Println()
+		}
+	}
+	if len(plan.Diff) == 0 {
+		fmt.Println("No changes against the live state")
+		return
+	}
+	for _, d := range plan.Diff {
+		fmt.Printf("%s %s:\n", d.Tool, d.Table)
+		for _, line := range d.Removed {
+			fmt.Println("  - " + line)
+		}
+		for _, line := range d.Added {
+			fmt.Println("  + " + line)
+		}
+	}
+}
@@ -38,10 +38,22 @@ func printStatus(s *grpcpb.StatusResponse) {
 		mode = "TPROXY"
 	}
 	fmt.Printf("vpnerd %s  (up %s)\n", s.Version, humanSeconds(s.UptimeSeconds))
-	fmt.Printf("DNS: %s   mode: %s   unblock rules: %d\n", dns, mode, s.UnblockRuleCount)
+	backend := s.FirewallBackend
+	if backend == "" {
+		backend = "iptables"
+	}
+	fmt.Printf("DNS: %s   mode: %s   firewall: %s   unblock rules: %d\n", dns, mode, backend, s.UnblockRuleCount)
+	if c := s.DnsCache; c != nil {
+		hitRate := "-"
+		if total := c.Hits + c.Misses; total > 0 {
+			hitRate = fmt.Sprintf("%d%%", c.Hits*100/total)
+		}
+		fmt.Printf("DNS cache: %d entries   hits: %d (%s)   misses: %d   stale: %d   prefetch: %d\n",
+			c.Entries, c.Hits, hitRate, c.Misses, c.Stale, c.Prefetch)
+	}
 
 	if len(s.Chains) > 0 {
-		tbl := tablefmt.Table{Headers: []string{"Chain", "Type", "Host", "Port", "In", "AutoRun", "State", "Restarts", "Uptime"}}
+		tbl := tablefmt.Table{Headers: []string{"Chain", "Type", "Host", "Port", "In", "AutoRun", "State", "Restarts", "Uptime", "Kill switch", "UDP"}}
 		for _, ch := range s.Chains {
 			state := "down"
 			if ch.Running {
@@ -52,6 +64,8 @@ func printStatus(s *grpcpb.StatusResponse) {
 				fmt.Sprintf("%d", ch.Port), fmt.Sprintf("%d", ch.InboundPort),
 				yesNo(ch.AutoRun), state,
 				fmt.Sprintf("%d", ch.Restarts), humanSeconds(ch.UptimeSeconds),
+				killSwitchState(ch),
+				udpPolicyState(ch),
 			})
 		}
 		fmt.Println()
@@ -59,7 +73,7 @@ func printStatus(s *grpcpb.StatusResponse) {
 	}
 
 	if len(s.DohServers) > 0 {
-		tbl := tablefmt.Table{Headers: []string{"DoH server", "OK", "Fail", "Latency"}}
+		tbl := tablefmt.Table{Headers: []string{"Upstream", "OK", "Fail", "Latency"}}
 		for _, d := range s.DohServers {
 			lat := "-"
 			if d.LastLatencyMs > 0 {
@@ -72,6 +86,62 @@ func printStatus(s *grpcpb.StatusResponse) {
 		fmt.Println()
 		printTable(tbl)
 	}
+
+	if len(s.Ipsets) > 0 {
+		tbl := tablefmt.Table{Headers: []string{"Ipset", "Chain", "Entries", "Fill"}}
+		for _, u := range s.Ipsets {
+			fill := "-"
+			if u.MaxEntries > 0 {
+				fill = fmt.Sprintf("%d%%", u.Entries*100/u.MaxEntries)
+			}
+			tbl.Rows = append(tbl.Rows, []string{
+				u.Name, u.Chain, fmt.Sprintf("%d/%d", u.Entries, u.MaxEntries), fill,
+			})
+		}
+		fmt.Println()
+		printTable(tbl)
+	}
+
+	if len(s.Blocklists) > 0 {
+		tbl := tablefmt.Table{Headers: []string{"Blocklist", "Rules", "Hits", "Updated", "Error"}}
+		for _, b := range s.Blocklists {
+			updated := "-"
+			if b.UpdatedUnix > 0 {
+				updated = time.Unix(b.UpdatedUnix, 0).Format("2006-01-02 15:04")
+			}
+			errText := b.Error
+			if errText == "" {
+				errText = "-"
+			}
+			tbl.Rows = append(tbl.Rows, []string{
+				b.Name, fmt.Sprintf("%d", b.Rules), fmt.Sprintf("%d", b.Hits), updated, errText,
+			})
+		}
+		fmt.Println()
+		printTable(tbl)
+	}
+}
+
+func killSwitchState(ch *grpcpb.ChainStatus) string {
+	mode := ch.KillSwitch
+	if mode == "" {
+		mode = "off"
+	}
+	if ch.KillSwitchEngaged {
+		return mode + " (engaged)"
+	}
+	return mode
+}
+
+func udpPolicyState(ch *grpcpb.ChainStatus) string {
+	policy := ch.UdpPolicy
+	if policy == "" {
+		policy = "leak"
+	}
+	if ch.UdpPolicyActive != "" && ch.UdpPolicyActive != policy {
+		return policy + " (" + ch.UdpPolicyActive + ")"
+	}
+	return policy
 }
 
 func yesNo(b bool) string {
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	grpcpb "github.com/ApostolDmitry/vpner/internal/grpc"
+	"github.com/ApostolDmitry/vpner/internal/tablefmt"
+)
+
+func traceCmd() *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "trace <domain|ip>",
+		Short: "Explain whether and why a destination is routed through a chain",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withClient(func(ctx context.Context, c grpcpb.VpnerManagerClient) error {
+				resp, err := c.Trace(ctx, &grpcpb.TraceRequest{Target: args[0]})
+				if err != nil {
+					return err
+				}
+				if asJSON {
+					out, err := protojson.MarshalOptions{Multiline: true, Indent: "  ", EmitUnpopulated: true}.Marshal(resp)
+					if err != nil {
+						return err
+					}
+					fmt.Println(string(out))
+					return nil
+				}
+				printTrace(resp)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print JSON instead of text")
+	return cmd
+}
+
+func printTrace(t *grpcpb.TraceResponse) {
+	fmt.Printf("Target:  %s\n", t.Target)
+	if t.Matched {
+		fmt.Printf("Rule:    %s -> %s (%s)\n", t.Pattern, t.Chain, t.VpnType)
+	} else {
+		fmt.Println("Rule:    none")
+	}
+	for _, d := range t.Dns {
+		answer := strings.Join(d.Answers, ", ")
+		switch {
+		case d.Error != "":
+			answer = "error: " + d.Error
+		case answer == "":
+			answer = "no addresses"
+		}
+		source := d.Source
+		if d.Server != "" {
+			source += " " + d.Server
+		}
+		fmt.Printf("DNS %-4s %s, %s: %s\n", d.Qtype, source, d.Rcode, answer)
+	}
+	if !t.Matched {
+		fmt.Printf("Verdict: %s\n", t.Verdict)
+		return
+	}
+
+	if len(t.Entries) > 0 {
+		tbl := tablefmt.Table{Headers: []string{"Set", "Entry", "Comment"}}
+		for _, e := range t.Entries {
+			tbl.Rows = append(tbl.Rows, []string{e.Set, e.Entry, e.Comment})
+		}
+		fmt.Println()
+		printTable(tbl)
+	} else {
+		fmt.Println("Set entries: none")
+	}
+
+	state := "stopped"
+	if t.ChainRunning {
+		state = "running"
+	}
+	fmt.Printf("\nChain:   %s %s\n", t.Chain, state)
+	if len(t.Routing) > 0 {
+		printRoutingState(&grpcpb.RoutingStateResponse{Chains: t.Routing})
+	}
+	fmt.Printf("\nVerdict: %s\n", t.Verdict)
+}
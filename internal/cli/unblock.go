@@ -54,7 +54,10 @@ func unblockListCmd() *cobra.Command {
 }
 
 func unblockAddCmd() *cobra.Command {
-	var chain string
+	var (
+		chain  string
+		dryRun bool
+	)
 	cmd := &cobra.Command{
 		Use:   "add <pattern>",
 		Short: "Add unblock pattern (domain, IP or subnet)",
@@ -70,6 +73,7 @@ func unblockAddCmd() *cobra.Command {
 				resp, err := c.UnblockAdd(ctx, &grpcpb.UnblockAddRequest{
 					Domain:    pattern,
 					ChainName: chain,
+					DryRun:    dryRun,
 				})
 				if err != nil {
 					return err
@@ -79,18 +83,20 @@ func unblockAddCmd() *cobra.Command {
 		},
 	}
 	cmd.Flags().StringVar(&chain, "chain", "", "chain name (VPN interface)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "show the changes without applying them")
 	return cmd
 }
 
 func unblockDelCmd() *cobra.Command {
-	return &cobra.Command{
+	var dryRun bool
+	cmd := &cobra.Command{
 		Use:   "del <pattern>",
 		Short: "Delete unblock pattern (domain, IP or subnet)",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			pattern := args[0]
 			return withClient(func(ctx context.Context, c grpcpb.VpnerManagerClient) error {
-				resp, err := c.UnblockDel(ctx, &grpcpb.UnblockDelRequest{Domain: pattern})
+				resp, err := c.UnblockDel(ctx, &grpcpb.UnblockDelRequest{Domain: pattern, DryRun: dryRun})
 				if err != nil {
 					return err
 				}
@@ -98,6 +104,8 @@ func unblockDelCmd() *cobra.Command {
 			})
 		},
 	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "show the changes without applying them")
+	return cmd
 }
 
 func unblockImportFileCmd() *cobra.Command {
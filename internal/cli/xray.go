@@ -6,6 +6,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/ApostolDmitry/vpner/internal/chainpolicy"
 	grpcpb "github.com/ApostolDmitry/vpner/internal/grpc"
 	"github.com/ApostolDmitry/vpner/internal/tablefmt"
 )
@@ -25,6 +26,8 @@ func init() {
 	xrayCmd.AddCommand(xrayStartStopCmd("status", grpcpb.ManageAction_STATUS))
 	xrayCmd.AddCommand(xrayTestCmd())
 	xrayCmd.AddCommand(xrayAutorunCmd())
+	xrayCmd.AddCommand(xrayKillSwitchCmd())
+	xrayCmd.AddCommand(xrayUDPPolicyCmd())
 }
 
 func xrayTestCmd() *cobra.Command {
@@ -145,7 +148,8 @@ func xrayDeleteCmd() *cobra.Command {
 }
 
 func xrayStartStopCmd(name string, action grpcpb.ManageAction) *cobra.Command {
-	return &cobra.Command{
+	var dryRun bool
+	cmd := &cobra.Command{
 		Use:   name + " <chain>",
 		Short: fmt.Sprintf("%s Xray chain", name),
 		Args:  cobra.ExactArgs(1),
@@ -155,6 +159,7 @@ func xrayStartStopCmd(name string, action grpcpb.ManageAction) *cobra.Command {
 				resp, err := c.XrayManage(ctx, &grpcpb.XrayManageRequest{
 					ChainName: chain,
 					Act:       action,
+					DryRun:    dryRun,
 				})
 				if err != nil {
 					return err
@@ -163,6 +168,10 @@ func xrayStartStopCmd(name string, action grpcpb.ManageAction) *cobra.Command {
 			})
 		},
 	}
+	if action != grpcpb.ManageAction_STATUS {
+		cmd.Flags().BoolVar(&dryRun, "dry-run", false, "show the routing changes without applying them")
+	}
+	return cmd
 }
 
 func xrayAutorunCmd() *cobra.Command {
@@ -196,3 +205,59 @@ func xrayAutorunCmd() *cobra.Command {
 	cmd.Flags().BoolVar(&disable, "disable", false, "disable autorun")
 	return cmd
 }
+
+func xrayKillSwitchCmd() *cobra.Command {
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "kill-switch <chain> <off|reject|drop>",
+		Short: "Block traffic for the chain's unblocked destinations while it is down",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mode, err := chainpolicy.ParseKillSwitch(args[1])
+			if err != nil {
+				return err
+			}
+			return withClient(func(ctx context.Context, c grpcpb.VpnerManagerClient) error {
+				resp, err := c.XraySetKillSwitch(ctx, &grpcpb.XrayKillSwitchRequest{
+					ChainName: args[0],
+					Mode:      mode.String(),
+					DryRun:    dryRun,
+				})
+				if err != nil {
+					return err
+				}
+				return printGenericResponse(resp)
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "show the routing changes without applying them")
+	return cmd
+}
+
+func xrayUDPPolicyCmd() *cobra.Command {
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "udp-policy <chain> <leak|block-quic|tproxy-udp-only>",
+		Short: "Choose how UDP to the chain's unblocked destinations is handled in REDIRECT mode",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			policy, err := chainpolicy.ParseUDPPolicy(args[1])
+			if err != nil {
+				return err
+			}
+			return withClient(func(ctx context.Context, c grpcpb.VpnerManagerClient) error {
+				resp, err := c.XraySetUDPPolicy(ctx, &grpcpb.XrayUDPPolicyRequest{
+					ChainName: args[0],
+					Policy:    policy.String(),
+					DryRun:    dryRun,
+				})
+				if err != nil {
+					return err
+				}
+				return printGenericResponse(resp)
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "show the routing changes without applying them")
+	return cmd
+}
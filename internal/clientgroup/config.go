@@ -0,0 +1,112 @@
+package clientgroup
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const maxGroupNameLen = 16
+
+var validGroupRe = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+type Policy struct {
+	Only   []string `yaml:"only,omitempty"`
+	Except []string `yaml:"except,omitempty"`
+}
+
+func (p Policy) Empty() bool {
+	return len(p.Only) == 0 && len(p.Except) == 0
+}
+
+type Config struct {
+	Groups     map[string][]string `yaml:"groups"`
+	Policies   map[string]Policy   `yaml:"policies"`
+	FullTunnel map[string]string   `yaml:"full-tunnel,omitempty"`
+}
+
+func newConfig() *Config {
+	return &Config{
+		Groups:     make(map[string][]string),
+		Policies:   make(map[string]Policy),
+		FullTunnel: make(map[string]string),
+	}
+}
+
+func (c *Config) clone() *Config {
+	out := newConfig()
+	for name, members := range c.Groups {
+		out.Groups[name] = append([]string(nil), members...)
+	}
+	for chain, policy := range c.Policies {
+		out.Policies[chain] = Policy{
+			Only:   append([]string(nil), policy.Only...),
+			Except: append([]string(nil), policy.Except...),
+		}
+	}
+	for group, chain := range c.FullTunnel {
+		out.FullTunnel[group] = chain
+	}
+	return out
+}
+
+func (c *Config) fullTunnelGroups(chain string) []string {
+	var groups []string
+	for group, target := range c.FullTunnel {
+		if target == chain {
+			groups = append(groups, group)
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+func (c *Config) referencedBy(group string) []string {
+	var chains []string
+	for chain, policy := range c.Policies {
+		for _, name := range append(append([]string(nil), policy.Only...), policy.Except...) {
+			if name == group {
+				chains = append(chains, chain)
+				break
+			}
+		}
+	}
+	if chain, ok := c.FullTunnel[group]; ok && !slices.Contains(chains, chain) {
+		chains = append(chains, chain)
+	}
+	sort.Strings(chains)
+	return chains
+}
+
+func ValidateGroupName(name string) error {
+	if name == "" {
+		return fmt.Errorf("group name is required")
+	}
+	if len(name) > maxGroupNameLen {
+		return fmt.Errorf("group name %q is longer than %d characters", name, maxGroupNameLen)
+	}
+	if !validGroupRe.MatchString(name) {
+		return fmt.Errorf("group name %q may only contain a-z, 0-9, '_' and '-'", name)
+	}
+	return nil
+}
+
+func NormalizeMember(member string) (string, error) {
+	member = strings.TrimSpace(member)
+	if mac, err := net.ParseMAC(member); err == nil {
+		if len(mac) != 6 {
+			return "", fmt.Errorf("invalid client %q: only 48-bit MAC addresses are supported", member)
+		}
+		return mac.String(), nil
+	}
+	if ip := net.ParseIP(member); ip != nil {
+		return ip.String(), nil
+	}
+	if _, ipnet, err := net.ParseCIDR(member); err == nil {
+		return ipnet.String(), nil
+	}
+	return "", fmt.Errorf("invalid client %q: expected IP, CIDR or MAC address", member)
+}
@@ -0,0 +1,230 @@
+package clientgroup
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ApostolDmitry/vpner/internal/firewall"
+	"github.com/ApostolDmitry/vpner/internal/logx"
+	"github.com/ApostolDmitry/vpner/internal/vpnkind"
+)
+
+type Firewall interface {
+	SetClientGroups(groups map[string][]string) error
+	SetClientFilter(ipsetName string, filter firewall.ClientFilter) error
+	SetFullTunnel(ipsetName string, groups []string) error
+}
+
+type ChainLookup interface {
+	ChainType(chainName string) (string, bool)
+}
+
+type Service struct {
+	store  *store
+	fw     Firewall
+	chains ChainLookup
+}
+
+func New(path string, fw Firewall, chains ChainLookup) *Service {
+	return &Service{
+		store:  newStore(path),
+		fw:     fw,
+		chains: chains,
+	}
+}
+
+func (s *Service) Init() error {
+	if s == nil || s.store == nil {
+		return fmt.Errorf("client group service is not initialized")
+	}
+	cfg, err := s.store.Load()
+	if err != nil {
+		return err
+	}
+	if err := s.fw.SetClientGroups(cfg.Groups); err != nil {
+		return err
+	}
+	for chain, policy := range cfg.Policies {
+		if err := s.applyPolicy(chain, policy); err != nil {
+			logx.Warnf("client policy %s: %v", chain, err)
+		}
+	}
+	applied := make(map[string]bool)
+	for _, chain := range cfg.FullTunnel {
+		if applied[chain] {
+			continue
+		}
+		applied[chain] = true
+		if err := s.applyFullTunnel(cfg, chain); err != nil {
+			logx.Warnf("full tunnel %s: %v", chain, err)
+		}
+	}
+	return nil
+}
+
+func (s *Service) List() (*Config, error) {
+	return s.store.Load()
+}
+
+func (s *Service) AddMembers(group string, members []string) error {
+	if err := ValidateGroupName(group); err != nil {
+		return err
+	}
+	if len(members) == 0 {
+		return fmt.Errorf("at least one IP, CIDR or MAC address is required")
+	}
+	normalized := make([]string, 0, len(members))
+	for _, member := range members {
+		value, err := NormalizeMember(member)
+		if err != nil {
+			return err
+		}
+		normalized = append(normalized, value)
+	}
+
+	cfg, err := s.store.modify(func(cfg *Config) error {
+		current := cfg.Groups[group]
+		for _, member := range normalized {
+			if !slices.Contains(current, member) {
+				current = append(current, member)
+			}
+		}
+		sort.Strings(current)
+		cfg.Groups[group] = current
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.fw.SetClientGroups(cfg.Groups)
+}
+
+func (s *Service) RemoveMembers(group string, members []string) error {
+	cfg, err := s.store.modify(func(cfg *Config) error {
+		current, ok := cfg.Groups[group]
+		if !ok {
+			return fmt.Errorf("client group %q not found", group)
+		}
+		if len(members) == 0 {
+			if chains := cfg.referencedBy(group); len(chains) > 0 {
+				return fmt.Errorf("client group %q is used by chains: %s", group, strings.Join(chains, ", "))
+			}
+			delete(cfg.Groups, group)
+			return nil
+		}
+		for _, member := range members {
+			value, err := NormalizeMember(member)
+			if err != nil {
+				return err
+			}
+			idx := slices.Index(current, value)
+			if idx < 0 {
+				return fmt.Errorf("client %s is not in group %q", value, group)
+			}
+			current = slices.Delete(current, idx, idx+1)
+		}
+		cfg.Groups[group] = current
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.fw.SetClientGroups(cfg.Groups)
+}
+
+func (s *Service) SetPolicy(chain string, policy Policy) error {
+	if chain == "" {
+		return fmt.Errorf("chain name is required")
+	}
+	if _, ok := s.chains.ChainType(chain); !ok {
+		return fmt.Errorf("chain name %q does not exist", chain)
+	}
+	for _, group := range policy.Only {
+		if slices.Contains(policy.Except, group) {
+			return fmt.Errorf("client group %q cannot be both included and excluded", group)
+		}
+	}
+
+	_, err := s.store.modify(func(cfg *Config) error {
+		for _, group := range append(append([]string(nil), policy.Only...), policy.Except...) {
+			if _, ok := cfg.Groups[group]; !ok {
+				return fmt.Errorf("client group %q not found", group)
+			}
+		}
+		if policy.Empty() {
+			delete(cfg.Policies, chain)
+			return nil
+		}
+		cfg.Policies[chain] = policy
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.applyPolicy(chain, policy)
+}
+
+func (s *Service) SetFullTunnel(group, chain string) error {
+	if chain != "" {
+		vpnType, ok := s.chains.ChainType(chain)
+		if !ok {
+			return fmt.Errorf("chain name %q does not exist", chain)
+		}
+		if vpnType != vpnkind.Xray.String() {
+			return fmt.Errorf("full tunnel requires an Xray chain, %q is %s", chain, vpnType)
+		}
+	}
+
+	var previous string
+	cfg, err := s.store.modify(func(cfg *Config) error {
+		if _, ok := cfg.Groups[group]; !ok {
+			return fmt.Errorf("client group %q not found", group)
+		}
+		previous = cfg.FullTunnel[group]
+		if chain == "" {
+			delete(cfg.FullTunnel, group)
+			return nil
+		}
+		cfg.FullTunnel[group] = chain
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	if previous != "" && previous != chain {
+		errs = append(errs, s.applyFullTunnel(cfg, previous))
+	}
+	if chain != "" {
+		errs = append(errs, s.applyFullTunnel(cfg, chain))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Service) applyFullTunnel(cfg *Config, chain string) error {
+	vpnType, ok := s.chains.ChainType(chain)
+	if !ok {
+		return fmt.Errorf("chain name %q does not exist", chain)
+	}
+	ipsetName, err := firewall.IpsetName(vpnType, chain)
+	if err != nil {
+		return err
+	}
+	return s.fw.SetFullTunnel(ipsetName, cfg.fullTunnelGroups(chain))
+}
+
+func (s *Service) applyPolicy(chain string, policy Policy) error {
+	vpnType, ok := s.chains.ChainType(chain)
+	if !ok {
+		return fmt.Errorf("chain name %q does not exist", chain)
+	}
+	ipsetName, err := firewall.IpsetName(vpnType, chain)
+	if err != nil {
+		return err
+	}
+	return s.fw.SetClientFilter(ipsetName, firewall.ClientFilter{Include: policy.Only, Exclude: policy.Except})
+}
@@ -0,0 +1,168 @@
+package clientgroup
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ApostolDmitry/vpner/internal/firewall"
+)
+
+type firewallStub struct {
+	groups     map[string][]string
+	filters    map[string]firewall.ClientFilter
+	fullTunnel map[string][]string
+}
+
+func (f *firewallStub) SetClientGroups(groups map[string][]string) error {
+	f.groups = groups
+	return nil
+}
+
+func (f *firewallStub) SetClientFilter(ipsetName string, filter firewall.ClientFilter) error {
+	if f.filters == nil {
+		f.filters = make(map[string]firewall.ClientFilter)
+	}
+	f.filters[ipsetName] = filter
+	return nil
+}
+
+func (f *firewallStub) SetFullTunnel(ipsetName string, groups []string) error {
+	if f.fullTunnel == nil {
+		f.fullTunnel = make(map[string][]string)
+	}
+	f.fullTunnel[ipsetName] = groups
+	return nil
+}
+
+type chainLookupStub map[string]string
+
+func (c chainLookupStub) ChainType(chain string) (string, bool) {
+	vpnType, ok := c[chain]
+	return vpnType, ok
+}
+
+func TestNormalizeMember(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"192.168.1.10":      "192.168.1.10",
+		"192.168.1.77/24":   "192.168.1.0/24",
+		"AA-BB-CC-DD-EE-FF": "aa:bb:cc:dd:ee:ff",
+		" fd00::1 ":         "fd00::1",
+	}
+	for in, want := range cases {
+		got, err := NormalizeMember(in)
+		if err != nil {
+			t.Fatalf("NormalizeMember(%q): %v", in, err)
+		}
+		if got != want {
+			t.Fatalf("NormalizeMember(%q) = %q, want %q", in, got, want)
+		}
+	}
+	for _, bad := range []string{"", "tv", "300.1.1.1", "00:11:22:33:44:55:66:77"} {
+		if _, err := NormalizeMember(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+	if err := ValidateGroupName("kids-room_2"); err != nil {
+		t.Fatalf("ValidateGroupName: %v", err)
+	}
+	for _, bad := range []string{"", "Kids", "a very long group name"} {
+		if err := ValidateGroupName(bad); err == nil {
+			t.Fatalf("expected error for group %q", bad)
+		}
+	}
+}
+
+func TestServicePolicyLifecycle(t *testing.T) {
+	t.Parallel()
+
+	fw := &firewallStub{}
+	svc := New(filepath.Join(t.TempDir(), "clients.yaml"), fw, chainLookupStub{"xray1": "Xray"})
+	if err := svc.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	if err := svc.AddMembers("tv", []string{"192.168.1.50", "AA:BB:CC:DD:EE:FF"}); err != nil {
+		t.Fatalf("AddMembers: %v", err)
+	}
+	if want := []string{"192.168.1.50", "aa:bb:cc:dd:ee:ff"}; !reflect.DeepEqual(fw.groups["tv"], want) {
+		t.Fatalf("groups = %v, want %v", fw.groups["tv"], want)
+	}
+
+	if err := svc.SetPolicy("xray1", Policy{Except: []string{"missing"}}); err == nil {
+		t.Fatalf("expected error for unknown group")
+	}
+	if err := svc.SetPolicy("nope", Policy{Except: []string{"tv"}}); err == nil {
+		t.Fatalf("expected error for unknown chain")
+	}
+	if err := svc.SetPolicy("xray1", Policy{Except: []string{"tv"}}); err != nil {
+		t.Fatalf("SetPolicy: %v", err)
+	}
+	if got := fw.filters["vpner-Xray-xray1"]; !reflect.DeepEqual(got.Exclude, []string{"tv"}) {
+		t.Fatalf("filter = %+v", got)
+	}
+
+	if err := svc.RemoveMembers("tv", nil); err == nil {
+		t.Fatalf("expected error removing a group referenced by a policy")
+	}
+	if err := svc.SetPolicy("xray1", Policy{}); err != nil {
+		t.Fatalf("reset policy: %v", err)
+	}
+	if err := svc.RemoveMembers("tv", nil); err != nil {
+		t.Fatalf("RemoveMembers: %v", err)
+	}
+
+	cfg, err := svc.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(cfg.Groups) != 0 || len(cfg.Policies) != 0 {
+		t.Fatalf("expected empty config, got %+v", cfg)
+	}
+}
+
+func TestServiceFullTunnel(t *testing.T) {
+	t.Parallel()
+
+	fw := &firewallStub{}
+	svc := New(filepath.Join(t.TempDir(), "clients.yaml"), fw, chainLookupStub{
+		"xray1": "Xray",
+		"xray2": "Xray",
+		"ovpn0": "OpenVPN",
+	})
+	if err := svc.AddMembers("tv", []string{"192.168.1.50"}); err != nil {
+		t.Fatalf("AddMembers: %v", err)
+	}
+
+	if err := svc.SetFullTunnel("tv", "ovpn0"); err == nil {
+		t.Fatalf("expected error for non-Xray chain")
+	}
+	if err := svc.SetFullTunnel("tv", "xray1"); err != nil {
+		t.Fatalf("SetFullTunnel: %v", err)
+	}
+	if got := fw.fullTunnel["vpner-Xray-xray1"]; !reflect.DeepEqual(got, []string{"tv"}) {
+		t.Fatalf("xray1 full tunnel = %v", got)
+	}
+
+	if err := svc.SetFullTunnel("tv", "xray2"); err != nil {
+		t.Fatalf("move full tunnel: %v", err)
+	}
+	if got := fw.fullTunnel["vpner-Xray-xray1"]; len(got) != 0 {
+		t.Fatalf("expected xray1 to be cleared, got %v", got)
+	}
+	if got := fw.fullTunnel["vpner-Xray-xray2"]; !reflect.DeepEqual(got, []string{"tv"}) {
+		t.Fatalf("xray2 full tunnel = %v", got)
+	}
+
+	if err := svc.RemoveMembers("tv", nil); err == nil {
+		t.Fatalf("expected error removing a group used for full tunnel")
+	}
+	if err := svc.SetFullTunnel("tv", ""); err != nil {
+		t.Fatalf("disable full tunnel: %v", err)
+	}
+	if err := svc.RemoveMembers("tv", nil); err != nil {
+		t.Fatalf("RemoveMembers: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package clientgroup
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/ApostolDmitry/vpner/internal/fileutil"
+	"gopkg.in/yaml.v3"
+)
+
+type store struct {
+	path string
+	mu   sync.RWMutex
+}
+
+func newStore(path string) *store {
+	return &store{path: path}
+}
+
+func (s *store) Load() (*Config, error) {
+	if err := fileutil.EnsureFile(s.path); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cfg, err := s.readLocked()
+	if err != nil {
+		return nil, err
+	}
+	return cfg.clone(), nil
+}
+
+func (s *store) modify(fn func(*Config) error) (*Config, error) {
+	if err := fileutil.EnsureFile(s.path); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg, err := s.readLocked()
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(cfg); err != nil {
+		return nil, err
+	}
+	if err := s.writeLocked(cfg); err != nil {
+		return nil, err
+	}
+	return cfg.clone(), nil
+}
+
+func (s *store) readLocked() (*Config, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return newConfig(), nil
+		}
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	var cfg Config
+	err = yaml.NewDecoder(file).Decode(&cfg)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse YAML file: %v", err)
+	}
+	if cfg.Groups == nil {
+		cfg.Groups = make(map[string][]string)
+	}
+	if cfg.Policies == nil {
+		cfg.Policies = make(map[string]Policy)
+	}
+	if cfg.FullTunnel == nil {
+		cfg.FullTunnel = make(map[string]string)
+	}
+	return &cfg, nil
+}
+
+func (s *store) writeLocked(cfg *Config) error {
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file for writing: %v", err)
+	}
+	defer file.Close()
+
+	encoder := yaml.NewEncoder(file)
+	defer encoder.Close()
+
+	if err := encoder.Encode(cfg); err != nil {
+		return fmt.Errorf("failed to write YAML data: %v", err)
+	}
+	return nil
+}
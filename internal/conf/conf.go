@@ -10,16 +10,63 @@ import (
 )
 
 type ServerConfig struct {
-	Port                 int                 `yaml:"port"`
-	Listen               string              `yaml:"listen"`
-	MaxConcurrentConn    int                 `yaml:"max-concurrent-connections"`
-	Verbose              bool                `yaml:"verbose"`
-	CustomResolve        map[string][]string `yaml:"custom-resolve"`
-	CustomResolveTimeout int                 `yaml:"custom-resolve-timeout"`
-	Cache                *bool               `yaml:"cache"`
-	CacheMaxEntries      int                 `yaml:"cache-max-entries"`
-	RateLimit            int                 `yaml:"rate-limit"`
-	Running              bool                `yaml:"running"`
+	Port                    int                 `yaml:"port"`
+	Listen                  string              `yaml:"listen"`
+	MaxConcurrentConn       int                 `yaml:"max-concurrent-connections"`
+	Verbose                 bool                `yaml:"verbose"`
+	CustomResolve           map[string][]string `yaml:"custom-resolve"`
+	CustomResolveTimeout    int                 `yaml:"custom-resolve-timeout"`
+	ForwardZones            map[string][]string `yaml:"forward-zones"`
+	LocalDNS                string              `yaml:"local-dns"`
+	Cache                   *bool               `yaml:"cache"`
+	CacheMaxEntries         int                 `yaml:"cache-max-entries"`
+	ServeStale              int                 `yaml:"serve-stale"`
+	PrefetchHits            int                 `yaml:"prefetch-hits"`
+	CachePath               string              `yaml:"cache-path"`
+	CacheSaveInterval       int                 `yaml:"cache-save-interval"`
+	RateLimit               int                 `yaml:"rate-limit"`
+	WarmInterval            int                 `yaml:"warm-interval"`
+	WarmSubdomains          []string            `yaml:"warm-subdomains"`
+	ResolveViaChain         bool                `yaml:"resolve-via-chain"`
+	ResolveViaChainFallback bool                `yaml:"resolve-via-chain-fallback"`
+	FakeIP                  bool                `yaml:"fake-ip"`
+	FakeIPRange             string              `yaml:"fake-ip-range"`
+	FakeIPRange6            string              `yaml:"fake-ip-range6"`
+	FakeIPPath              string              `yaml:"fake-ip-path"`
+	Blocklist               BlocklistConfig     `yaml:"blocklist"`
+	Records                 []StaticRecord      `yaml:"records"`
+	HostsFiles              []string            `yaml:"hosts-files"`
+	RecordsPath             string              `yaml:"records-path"`
+	QueryLog                QueryLogConfig      `yaml:"query-log"`
+	Running                 bool                `yaml:"running"`
+}
+
+type StaticRecord struct {
+	Name  string `yaml:"name"`
+	Type  string `yaml:"type"`
+	Value string `yaml:"value"`
+	TTL   int    `yaml:"ttl,omitempty"`
+}
+
+type BlocklistConfig struct {
+	Lists           []BlocklistSource `yaml:"lists"`
+	Allow           []string          `yaml:"allow"`
+	Mode            string            `yaml:"mode"`
+	RefreshInterval int               `yaml:"refresh-interval"`
+	CacheDir        string            `yaml:"cache-dir"`
+}
+
+type QueryLogConfig struct {
+	Size      int    `yaml:"size"`
+	Path      string `yaml:"path"`
+	MaxSizeKB int    `yaml:"max-size-kb"`
+	MaxFiles  int    `yaml:"max-files"`
+}
+
+type BlocklistSource struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+	Path string `yaml:"path"`
 }
 
 type UpstreamConfig struct {
@@ -70,13 +117,29 @@ type GRPCConfig struct {
 }
 
 type NetworkConfig struct {
-	LANInterface      string   `yaml:"lan-interface"`
-	LANInterfaces     []string `yaml:"lan-interfaces"`
-	EnableIPv6        bool     `yaml:"enable-ipv6"`
-	EnableTProxy      bool     `yaml:"enable-tproxy"`
-	IPSetDebug        bool     `yaml:"ipset-debug"`
-	IPSetStaleQueries int      `yaml:"ipset-stale-queries"`
-	ReconcileInterval int      `yaml:"reconcile-interval"`
+	LANInterface          string         `yaml:"lan-interface"`
+	LANInterfaces         []string       `yaml:"lan-interfaces"`
+	EnableIPv6            bool           `yaml:"enable-ipv6"`
+	EnableTProxy          bool           `yaml:"enable-tproxy"`
+	FirewallBackend       string         `yaml:"firewall-backend"`
+	IPSetDebug            bool           `yaml:"ipset-debug"`
+	IPSetStaleQueries     int            `yaml:"ipset-stale-queries"`
+	IPSetTTLMode          bool           `yaml:"ipset-ttl-mode"`
+	IPSetTTLMin           int            `yaml:"ipset-ttl-min"`
+	IPSetTTLMax           int            `yaml:"ipset-ttl-max"`
+	IPSetTTLGrace         int            `yaml:"ipset-ttl-grace"`
+	IPSetSnapshotPath     string         `yaml:"ipset-snapshot-path"`
+	IPSetSnapshotInterval int            `yaml:"ipset-snapshot-interval"`
+	IPSetMaxEntries       int            `yaml:"ipset-max-entries"`
+	IPSetChainMaxEntries  map[string]int `yaml:"ipset-chain-max-entries"`
+	ReconcileInterval     int            `yaml:"reconcile-interval"`
+	InterceptLocal        bool           `yaml:"intercept-local"`
+	LocalBypassMark       int            `yaml:"local-bypass-mark"`
+	LocalBypassGID        int            `yaml:"local-bypass-gid"`
+	MarkStatePath         string         `yaml:"mark-state-path"`
+	MarkMask              uint32         `yaml:"mark-mask"`
+	ReservedMarks         []string       `yaml:"reserved-marks"`
+	ReservedTables        []string       `yaml:"reserved-tables"`
 }
 
 type FullConfig struct {
@@ -84,6 +147,7 @@ type FullConfig struct {
 	GRPC             GRPCConfig     `yaml:"grpc"`
 	DoH              UpstreamConfig `yaml:"doh"`
 	UnblockRulesPath string         `yaml:"unblock-rules-path"`
+	ClientGroupsPath string         `yaml:"client-groups-path"`
 	Network          NetworkConfig  `yaml:"network"`
 }
 
@@ -115,9 +179,24 @@ func LoadFullConfig(path string) (*FullConfig, error) {
 	if cfg.UnblockRulesPath == "" {
 		cfg.UnblockRulesPath = "/opt/etc/vpner/vpner_unblock.yaml"
 	}
+	if cfg.ClientGroupsPath == "" {
+		cfg.ClientGroupsPath = "/opt/etc/vpner/vpner_clients.yaml"
+	}
 	if cfg.DNSServer.Port == 0 {
 		cfg.DNSServer.Port = 53
 	}
+	if cfg.DNSServer.FakeIPPath == "" {
+		cfg.DNSServer.FakeIPPath = "/opt/etc/vpner/vpner_fakeip.json"
+	}
+	if cfg.DNSServer.CachePath == "" {
+		cfg.DNSServer.CachePath = "/opt/etc/vpner/vpner_dnscache.json"
+	}
+	if cfg.DNSServer.RecordsPath == "" {
+		cfg.DNSServer.RecordsPath = "/opt/etc/vpner/vpner_records.yaml"
+	}
+	if cfg.DNSServer.Blocklist.CacheDir == "" {
+		cfg.DNSServer.Blocklist.CacheDir = "/opt/etc/vpner/blocklists"
+	}
 	if cfg.DNSServer.MaxConcurrentConn == 0 {
 		cfg.DNSServer.MaxConcurrentConn = 100
 	}
@@ -131,6 +210,15 @@ func LoadFullConfig(path string) (*FullConfig, error) {
 	if len(cfg.Network.LANInterfaces) == 0 {
 		cfg.Network.LANInterfaces = []string{"br0"}
 	}
+	if cfg.Network.MarkStatePath == "" {
+		cfg.Network.MarkStatePath = "/opt/etc/vpner/vpner_marks.json"
+	}
+	if cfg.Network.IPSetSnapshotPath == "" {
+		cfg.Network.IPSetSnapshotPath = "/opt/etc/vpner/vpner_ipsets.json"
+	}
+	if cfg.Network.LocalBypassMark == 0 {
+		cfg.Network.LocalBypassMark = 0x2000
+	}
 
 	return &cfg, nil
 }
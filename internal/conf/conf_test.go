@@ -23,6 +23,9 @@ func TestLoadFullConfigAppliesDefaults(t *testing.T) {
 	if cfg.UnblockRulesPath != "/opt/etc/vpner/vpner_unblock.yaml" {
 		t.Fatalf("unexpected unblock path: %s", cfg.UnblockRulesPath)
 	}
+	if cfg.ClientGroupsPath != "/opt/etc/vpner/vpner_clients.yaml" {
+		t.Fatalf("unexpected client groups path: %s", cfg.ClientGroupsPath)
+	}
 	if cfg.DNSServer.Port != 53 {
 		t.Fatalf("unexpected dns port: %d", cfg.DNSServer.Port)
 	}
@@ -38,6 +41,49 @@ func TestLoadFullConfigAppliesDefaults(t *testing.T) {
 	if len(cfg.Network.LANInterfaces) != 1 || cfg.Network.LANInterfaces[0] != "br0" {
 		t.Fatalf("unexpected lan interfaces: %#v", cfg.Network.LANInterfaces)
 	}
+	if cfg.Network.MarkStatePath != "/opt/etc/vpner/vpner_marks.json" || cfg.Network.MarkMask != 0 {
+		t.Fatalf("unexpected mark defaults: path=%s mask=%#x", cfg.Network.MarkStatePath, cfg.Network.MarkMask)
+	}
+	if cfg.Network.IPSetSnapshotPath != "/opt/etc/vpner/vpner_ipsets.json" || cfg.Network.IPSetSnapshotInterval != 0 {
+		t.Fatalf("unexpected ipset snapshot defaults: path=%s interval=%d", cfg.Network.IPSetSnapshotPath, cfg.Network.IPSetSnapshotInterval)
+	}
+	if cfg.Network.InterceptLocal || cfg.Network.LocalBypassMark != 0x2000 {
+		t.Fatalf("unexpected local intercept defaults: %v mark=%#x", cfg.Network.InterceptLocal, cfg.Network.LocalBypassMark)
+	}
+	if cfg.DNSServer.FakeIP || cfg.DNSServer.FakeIPPath != "/opt/etc/vpner/vpner_fakeip.json" {
+		t.Fatalf("unexpected fake-ip defaults: %v path=%s", cfg.DNSServer.FakeIP, cfg.DNSServer.FakeIPPath)
+	}
+	if cfg.DNSServer.Blocklist.CacheDir != "/opt/etc/vpner/blocklists" {
+		t.Fatalf("unexpected blocklist cache dir: %s", cfg.DNSServer.Blocklist.CacheDir)
+	}
+	if cfg.DNSServer.RecordsPath != "/opt/etc/vpner/vpner_records.yaml" {
+		t.Fatalf("unexpected records path: %s", cfg.DNSServer.RecordsPath)
+	}
+	if cfg.DNSServer.CachePath != "/opt/etc/vpner/vpner_dnscache.json" {
+		t.Fatalf("unexpected dns cache path: %s", cfg.DNSServer.CachePath)
+	}
+}
+
+func TestLoadFullConfigMarkSettings(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vpner.yaml")
+	data := "network:\n  mark-mask: 0xff0000\n  reserved-marks: [\"0x10000-0x1ffff\"]\n  reserved-tables: [\"300\"]\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := LoadFullConfig(path)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.Network.MarkMask != 0xff0000 {
+		t.Fatalf("unexpected mark mask: %#x", cfg.Network.MarkMask)
+	}
+	if len(cfg.Network.ReservedMarks) != 1 || len(cfg.Network.ReservedTables) != 1 {
+		t.Fatalf("unexpected reserved ranges: %#v %#v", cfg.Network.ReservedMarks, cfg.Network.ReservedTables)
+	}
 }
 
 func TestNormalizeInterfaces(t *testing.T) {
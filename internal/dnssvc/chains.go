@@ -0,0 +1,37 @@
+package dnssvc
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/ApostolDmitry/vpner/internal/proxy"
+	"github.com/ApostolDmitry/vpner/internal/vpnkind"
+)
+
+type chainSource interface {
+	GetInfo(name string) (proxy.ChainInfo, error)
+	IsRunning(name string) bool
+}
+
+type domainMatcher interface {
+	MatchDomain(domain string) (string, string, string, bool)
+}
+
+// chainProxies maps a domain to the SOCKS inbound of the running Xray chain
+// its unblock rule points at.
+type chainProxies struct {
+	rules  domainMatcher
+	chains chainSource
+}
+
+func (c chainProxies) ChainProxy(domain string) (string, bool) {
+	vpnType, chain, _, ok := c.rules.MatchDomain(domain)
+	if !ok || vpnType != vpnkind.Xray.String() || !c.chains.IsRunning(chain) {
+		return "", false
+	}
+	info, err := c.chains.GetInfo(chain)
+	if err != nil || info.SocksPort <= 0 {
+		return "", false
+	}
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(info.SocksPort)), true
+}
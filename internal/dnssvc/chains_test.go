@@ -0,0 +1,48 @@
+package dnssvc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ApostolDmitry/vpner/internal/proxy"
+)
+
+type fakeRules map[string][2]string
+
+func (f fakeRules) MatchDomain(domain string) (string, string, string, bool) {
+	m, ok := f[domain]
+	return m[0], m[1], domain, ok
+}
+
+type fakeChains map[string]int
+
+func (f fakeChains) GetInfo(name string) (proxy.ChainInfo, error) {
+	port, ok := f[name]
+	if !ok {
+		return proxy.ChainInfo{}, errors.New("not found")
+	}
+	return proxy.ChainInfo{SocksPort: port}, nil
+}
+
+func (f fakeChains) IsRunning(name string) bool { return name != "stopped" }
+
+func TestChainProxyOnlyForRunningXrayChains(t *testing.T) {
+	p := chainProxies{
+		rules: fakeRules{
+			"a.example": {"Xray", "vless1"},
+			"b.example": {"Wireguard", "Wireguard0"},
+			"c.example": {"Xray", "stopped"},
+			"d.example": {"Xray", "legacy"},
+			"e.example": {"Xray", "missing"},
+		},
+		chains: fakeChains{"vless1": 1081, "stopped": 1082, "legacy": 0},
+	}
+	if addr, ok := p.ChainProxy("a.example"); !ok || addr != "127.0.0.1:1081" {
+		t.Fatalf("ChainProxy(a) = %q, %v", addr, ok)
+	}
+	for _, domain := range []string{"b.example", "c.example", "d.example", "e.example", "none.example"} {
+		if addr, ok := p.ChainProxy(domain); ok {
+			t.Fatalf("ChainProxy(%s) = %q, want none", domain, addr)
+		}
+	}
+}
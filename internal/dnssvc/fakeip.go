@@ -0,0 +1,43 @@
+package dnssvc
+
+import (
+	"net"
+
+	"github.com/ApostolDmitry/vpner/internal/fakeip"
+	"github.com/ApostolDmitry/vpner/internal/vpnkind"
+)
+
+// fakeIPs gives domains whose unblock rule points at an Xray chain an address
+// from the pool. Xray sniffs the real domain back out of the connection, so
+// other chain types keep resolving normally.
+type fakeIPs struct {
+	pool  *fakeip.Pool
+	rules domainMatcher
+	ipv6  bool
+}
+
+func (f fakeIPs) FakeIP(domain string, ipv6 bool) (net.IP, bool) {
+	if !f.matches(domain) {
+		return nil, false
+	}
+	if ipv6 && !f.ipv6 {
+		return nil, true
+	}
+	return f.pool.Get(domain, ipv6), true
+}
+
+func (f fakeIPs) LookupFakeIP(domain string, ipv6 bool) (net.IP, bool) {
+	if !f.matches(domain) {
+		return nil, false
+	}
+	if ipv6 && !f.ipv6 {
+		return nil, true
+	}
+	ip, _ := f.pool.Lookup(domain, ipv6)
+	return ip, true
+}
+
+func (f fakeIPs) matches(domain string) bool {
+	vpnType, _, _, ok := f.rules.MatchDomain(domain)
+	return ok && vpnType == vpnkind.Xray.String()
+}
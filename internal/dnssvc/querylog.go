@@ -0,0 +1,43 @@
+package dnssvc
+
+import (
+	"errors"
+
+	"github.com/ApostolDmitry/vpner/internal/querylog"
+)
+
+var errQueryLogDisabled = errors.New("query log is disabled")
+
+// ruleTagger tags each entry with the unblock rule and chain of its domain.
+func ruleTagger(rules domainMatcher) func(*querylog.Entry) {
+	return func(e *querylog.Entry) {
+		if _, chain, pattern, ok := rules.MatchDomain(e.Name); ok {
+			e.Rule, e.Chain = pattern, chain
+		}
+	}
+}
+
+// QueryLog returns up to limit of the latest queries matching f.
+func (d *Service) QueryLog(f querylog.Filter, limit int) ([]querylog.Entry, error) {
+	if d.queryLog == nil {
+		return nil, errQueryLogDisabled
+	}
+	return d.queryLog.Tail(f, limit), nil
+}
+
+// FollowQueryLog delivers new queries until cancel is called.
+func (d *Service) FollowQueryLog() (<-chan querylog.Entry, func(), error) {
+	if d.queryLog == nil {
+		return nil, nil, errQueryLogDisabled
+	}
+	ch, cancel := d.queryLog.Subscribe()
+	return ch, cancel, nil
+}
+
+// CloseQueryLog flushes the query log file.
+func (d *Service) CloseQueryLog() error {
+	if d.queryLog == nil {
+		return nil
+	}
+	return d.queryLog.Close()
+}
@@ -3,12 +3,17 @@ package dnssvc
 import (
 	"context"
 	"fmt"
+	"net"
 	"sync"
 	"time"
 
+	"github.com/ApostolDmitry/vpner/internal/blocklist"
 	"github.com/ApostolDmitry/vpner/internal/conf"
+	"github.com/ApostolDmitry/vpner/internal/fakeip"
 	"github.com/ApostolDmitry/vpner/internal/firewall"
+	"github.com/ApostolDmitry/vpner/internal/localzone"
 	"github.com/ApostolDmitry/vpner/internal/logx"
+	"github.com/ApostolDmitry/vpner/internal/querylog"
 	"github.com/ApostolDmitry/vpner/internal/resolver"
 	unblock "github.com/ApostolDmitry/vpner/internal/unblock"
 )
@@ -24,19 +29,150 @@ type Service struct {
 	cfg       conf.ServerConfig
 	ipManager *firewall.IpRuleManager
 	resolver  *resolver.Upstream
+	warmer    *warmer
+	rules     *unblock.Service
+	chains    chainSource
+	fakeIPs   *fakeIPs
+	blocklist *blocklist.Blocklist
+	local     *localzone.Service
+	queryLog  *querylog.Log
 }
 
 func New(cfg conf.ServerConfig, unblock *unblock.Service, resolver *resolver.Upstream, registry *firewall.IPSetRegistry) *Service {
-	var ipManager *firewall.IpRuleManager
+	var (
+		ipManager *firewall.IpRuleManager
+		w         *warmer
+	)
 	if unblock != nil {
-		ipManager = firewall.NewIpRuleManager(unblock, unblock.RuntimeOptions(), resolver, registry)
+		opts := unblock.RuntimeOptions()
+		ipManager = firewall.NewIpRuleManager(unblock, opts, resolver, registry)
+		w = newWarmer(ipManager, resolver, unblock, cfg.WarmSubdomains, opts.IPv6Enabled, cfg.WarmInterval)
+	}
+
+	var bl *blocklist.Blocklist
+	if len(cfg.Blocklist.Lists) > 0 || len(cfg.Blocklist.Allow) > 0 {
+		var fetch blocklist.Fetcher
+		if resolver != nil {
+			fetch = resolver.Fetch
+		}
+		bl = blocklist.New(cfg.Blocklist, fetch)
+	}
+
+	ql := querylog.New(cfg.QueryLog)
+	if ql != nil && unblock != nil {
+		ql.SetTagger(ruleTagger(unblock))
 	}
 
 	return &Service{
 		cfg:       cfg,
 		ipManager: ipManager,
 		resolver:  resolver,
+		warmer:    w,
+		rules:     unblock,
+		fakeIPs:   newFakeIPs(cfg, unblock, ipManager),
+		blocklist: bl,
+		local:     localzone.New(cfg),
+		queryLog:  ql,
+	}
+}
+
+func newFakeIPs(cfg conf.ServerConfig, rules *unblock.Service, ipManager *firewall.IpRuleManager) *fakeIPs {
+	if !cfg.FakeIP || rules == nil {
+		return nil
+	}
+	pool, err := fakeip.New(cfg.FakeIPRange, cfg.FakeIPRange6)
+	if err != nil {
+		logx.Errorf("fake-ip disabled: %v", err)
+		return nil
+	}
+	if err := pool.Load(cfg.FakeIPPath); err != nil {
+		logx.Warnf("fake-ip mappings not restored: %v", err)
+	}
+	if ipManager != nil {
+		pool.SetEvictFunc(func(domain string, ip net.IP) {
+			if err := ipManager.RemoveAnswer(domain, ip); err != nil {
+				logx.Warnf("fake-ip %s reused, not removed from the set of %s: %v", ip, domain, err)
+			}
+		})
+	}
+	return &fakeIPs{pool: pool, rules: rules, ipv6: rules.RuntimeOptions().IPv6Enabled}
+}
+
+// FakeIPEnabled reports whether domain rules of Xray chains are answered
+// with fake addresses.
+func (d *Service) FakeIPEnabled() bool {
+	return d.fakeIPs != nil
+}
+
+// SaveFakeIPs persists the fake address of every domain so clients holding
+// one keep reaching the same site after a restart.
+func (d *Service) SaveFakeIPs() error {
+	if d.fakeIPs == nil {
+		return nil
+	}
+	return d.fakeIPs.pool.Save(d.cfg.FakeIPPath)
+}
+
+// SetChains makes the DNS server and the warmer resolve domains of unblock
+// rules through the SOCKS inbound of the rule's chain. The server picks it up
+// on its next Start.
+func (d *Service) SetChains(chains chainSource) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.chains = chains
+	if d.warmer != nil && d.rules != nil {
+		d.warmer.setProxies(chainProxies{rules: d.rules, chains: chains})
+	}
+}
+
+// RunWarmer keeps the ipsets of domain rules populated until ctx is done.
+func (d *Service) RunWarmer(ctx context.Context) {
+	if d.warmer == nil {
+		return
+	}
+	d.warmer.run(ctx)
+}
+
+// RunBlocklists loads the blocklists and keeps them up to date until ctx is
+// done.
+func (d *Service) RunBlocklists(ctx context.Context) {
+	if d.blocklist == nil {
+		return
+	}
+	d.blocklist.Run(ctx)
+}
+
+func (d *Service) BlocklistStats() []blocklist.ListStat {
+	if d.blocklist == nil {
+		return nil
+	}
+	return d.blocklist.Stats()
+}
+
+// RunLocalRecords reloads the local records whenever their files change,
+// until ctx is done.
+func (d *Service) RunLocalRecords(ctx context.Context) {
+	d.local.Run(ctx)
+}
+
+func (d *Service) Records() []localzone.Entry {
+	return d.local.List()
+}
+
+func (d *Service) AddRecord(rec conf.StaticRecord) error {
+	return d.local.Add(rec)
+}
+
+func (d *Service) DeleteRecord(name, typ, value string) (int, error) {
+	return d.local.Delete(name, typ, value)
+}
+
+// WarmRule resolves a newly added rule in the background.
+func (d *Service) WarmRule(pattern string) {
+	if d.warmer == nil {
+		return
 	}
+	go d.warmer.warmRule(pattern)
 }
 
 func (d *Service) Start() error {
@@ -50,7 +186,17 @@ func (d *Service) Start() error {
 
 	d.ctx, d.cancel = context.WithCancel(context.Background())
 	d.done = make(chan struct{})
-	d.server = resolver.NewServer(d.cfg, d.ipManager, d.resolver)
+	d.server = d.newServer(d.ipManager)
+	if d.cfg.CacheSaveInterval >= 0 {
+		if n, err := d.server.LoadCache(d.cfg.CachePath); err != nil {
+			logx.Warnf("DNS cache not restored: %v", err)
+		} else if n > 0 {
+			logx.Infof("Restored %d DNS cache entries", n)
+		}
+	}
+	if d.chains != nil && d.rules != nil {
+		d.server.SetChainProxies(chainProxies{rules: d.rules, chains: d.chains})
+	}
 	started := make(chan struct{})
 	errCh := make(chan error, 1)
 	d.server.SetNotifyStartedFunc(func() {
@@ -91,7 +237,26 @@ func (d *Service) Start() error {
 	return nil
 }
 
+func (d *Service) newServer(ipManager resolver.IPSyncer) *resolver.Server {
+	server := resolver.NewServer(d.cfg, ipManager, d.resolver)
+	server.SetLocalRecords(d.local)
+	if d.fakeIPs != nil {
+		server.SetFakeIPs(*d.fakeIPs)
+	}
+	if d.blocklist != nil {
+		server.SetBlocker(d.blocklist)
+	}
+	if d.queryLog != nil {
+		server.SetQueryLog(d.queryLog)
+	}
+	return server
+}
+
 func (d *Service) Stop() {
+	if err := d.SaveCache(); err != nil {
+		logx.Warnf("failed to save DNS cache: %v", err)
+	}
+
 	d.mu.Lock()
 	done := d.done
 	if d.cancel != nil {
@@ -123,3 +288,40 @@ func (d *Service) UpstreamStats() []resolver.ServerStat {
 	}
 	return d.resolver.ServerStats()
 }
+
+// SaveCache writes the answer and bootstrap caches of the current server to
+// cache-path so the next start does not begin cold.
+func (d *Service) SaveCache() error {
+	if d.cfg.CacheSaveInterval < 0 {
+		return nil
+	}
+	d.mu.Lock()
+	server := d.server
+	d.mu.Unlock()
+	if server == nil {
+		return nil
+	}
+	return server.SaveCache(d.cfg.CachePath)
+}
+
+// CacheStats reports the answer cache of the running server.
+func (d *Service) CacheStats() (resolver.CacheStats, bool) {
+	d.mu.Lock()
+	server := d.server
+	running := d.running
+	d.mu.Unlock()
+	if !running || server == nil {
+		return resolver.CacheStats{}, false
+	}
+	return server.CacheStats()
+}
+
+func (d *Service) Trace(domain string, qtype uint16) resolver.Trace {
+	d.mu.Lock()
+	server := d.server
+	if !d.running || server == nil {
+		server = d.newServer(nil)
+	}
+	d.mu.Unlock()
+	return server.Trace(domain, qtype)
+}
@@ -0,0 +1,225 @@
+package dnssvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ApostolDmitry/vpner/internal/firewall"
+	"github.com/ApostolDmitry/vpner/internal/logx"
+	"github.com/ApostolDmitry/vpner/internal/matcher"
+	"github.com/ApostolDmitry/vpner/internal/resolver"
+	"github.com/miekg/dns"
+)
+
+const (
+	defaultWarmInterval = time.Hour
+	minWarmInterval     = time.Minute
+	warmTick            = 30 * time.Second
+)
+
+type ruleSource interface {
+	DomainRules() []string
+}
+
+// warmer resolves domain rules through the upstream on its own and feeds the
+// answers into the ipsets, so routing does not depend on a LAN client asking
+// vpnerd first.
+type warmer struct {
+	ipManager  *firewall.IpRuleManager
+	resolver   *resolver.Upstream
+	rules      ruleSource
+	subdomains []string
+	ipv6       bool
+	maxAge     time.Duration
+
+	mu      sync.Mutex
+	due     map[string]time.Time
+	proxies resolver.ChainProxies
+}
+
+func newWarmer(ipManager *firewall.IpRuleManager, upstream *resolver.Upstream, rules ruleSource, subdomains []string, ipv6 bool, interval int) *warmer {
+	if ipManager == nil || upstream == nil || rules == nil || interval < 0 {
+		return nil
+	}
+	maxAge := defaultWarmInterval
+	if interval > 0 {
+		maxAge = max(time.Duration(interval)*time.Second, minWarmInterval)
+	}
+	return &warmer{
+		ipManager:  ipManager,
+		resolver:   upstream,
+		rules:      rules,
+		subdomains: subdomains,
+		ipv6:       ipv6,
+		maxAge:     maxAge,
+		due:        make(map[string]time.Time),
+	}
+}
+
+func (w *warmer) run(ctx context.Context) {
+	w.warmDue()
+	ticker := time.NewTicker(warmTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.warmDue()
+		}
+	}
+}
+
+// warmDue refreshes names whose answers are about to expire and forgets names
+// whose rule is gone.
+func (w *warmer) warmDue() {
+	names := warmNames(w.rules.DomainRules(), w.subdomains)
+	now := time.Now()
+
+	w.mu.Lock()
+	var due []string
+	for _, name := range names {
+		if next, ok := w.due[name]; !ok || !now.Before(next) {
+			due = append(due, name)
+		}
+	}
+	live := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		live[name] = struct{}{}
+	}
+	for name := range w.due {
+		if _, ok := live[name]; !ok {
+			delete(w.due, name)
+		}
+	}
+	w.mu.Unlock()
+
+	for _, name := range due {
+		w.warm(name)
+	}
+}
+
+func (w *warmer) warmRule(pattern string) {
+	for _, name := range warmNames([]string{pattern}, w.subdomains) {
+		w.warm(name)
+	}
+}
+
+func (w *warmer) warm(name string) {
+	qtypes := []uint16{dns.TypeA}
+	if w.ipv6 {
+		qtypes = append(qtypes, dns.TypeAAAA)
+	}
+	next := w.maxAge
+	var (
+		ips    []net.IP
+		minTTL uint32
+	)
+	upstream, err := w.upstreamFor(name)
+	if err != nil {
+		// Never warm a chain's name through the WAN: the answer may be
+		// geo-poisoned.
+		logx.Debugf("warm %s: %v", name, err)
+		w.mu.Lock()
+		w.due[name] = time.Now().Add(minWarmInterval)
+		w.mu.Unlock()
+		return
+	}
+	for _, qtype := range qtypes {
+		got, ttl, err := upstream.ResolveDomainTTL(name, qtype)
+		if err != nil {
+			if !errors.Is(err, resolver.ErrNoRecords) {
+				logx.Debugf("warm %s %s: %v", name, dns.TypeToString[qtype], err)
+				next = minWarmInterval
+			}
+			continue
+		}
+		if len(ips) == 0 || ttl < minTTL {
+			minTTL = ttl
+		}
+		ips = append(ips, got...)
+		// Refresh a little before the answer expires.
+		next = min(next, time.Duration(ttl)*time.Second*9/10)
+	}
+	next = max(next, minWarmInterval)
+
+	if err := w.ipManager.SyncFromAnswers(name, ips, minTTL); err != nil {
+		logx.Warnf("warm %s: failed to update ipset: %v", name, err)
+		next = minWarmInterval
+	}
+
+	w.mu.Lock()
+	w.due[name] = time.Now().Add(next)
+	w.mu.Unlock()
+}
+
+func (w *warmer) setProxies(p resolver.ChainProxies) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.proxies = p
+}
+
+// upstreamFor resolves through the rule's chain like the DNS server does. It
+// fails rather than fall back to the default upstream when the chain cannot
+// be used.
+func (w *warmer) upstreamFor(name string) (*resolver.Upstream, error) {
+	w.mu.Lock()
+	proxies := w.proxies
+	w.mu.Unlock()
+	if proxies == nil {
+		return w.resolver, nil
+	}
+	addr, ok := proxies.ChainProxy(name)
+	if !ok {
+		return w.resolver, nil
+	}
+	via, err := w.resolver.ViaProxy(addr)
+	if err != nil {
+		return nil, fmt.Errorf("chain proxy %s: %w", addr, err)
+	}
+	return via, nil
+}
+
+// warmNames expands domain rules into the names worth resolving: the rule
+// itself when it has no wildcard, otherwise its base domain and the configured
+// subdomains of it that the rule matches.
+func warmNames(rules, subdomains []string) []string {
+	seen := make(map[string]struct{})
+	add := func(pattern, name string) {
+		name = strings.Trim(name, ".")
+		if name == "" || strings.Contains(name, "*") || !matcher.Match(pattern, name) {
+			return
+		}
+		seen[name] = struct{}{}
+	}
+	for _, pattern := range rules {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if matcher.IsNetwork(pattern) {
+			continue
+		}
+		if !strings.Contains(pattern, "*") {
+			add(pattern, pattern)
+			continue
+		}
+		if strings.HasSuffix(pattern, "*") {
+			continue
+		}
+		base := strings.TrimLeft(pattern, "*.")
+		add(pattern, base)
+		for _, sub := range subdomains {
+			add(pattern, sub+"."+base)
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for name := range seen {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
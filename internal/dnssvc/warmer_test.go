@@ -0,0 +1,30 @@
+package dnssvc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWarmNamesExpandsRules(t *testing.T) {
+	rules := []string{
+		"Example.org",
+		"*.example.com",
+		"*video.net",
+		"*tracker*",
+		"cdn.*",
+		"10.0.0.0/8",
+		"example.org",
+	}
+	got := warmNames(rules, []string{"www", "api"})
+	want := []string{
+		"api.example.com",
+		"api.video.net",
+		"example.org",
+		"video.net",
+		"www.example.com",
+		"www.video.net",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("warmNames = %v, want %v", got, want)
+	}
+}
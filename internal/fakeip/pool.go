@@ -0,0 +1,330 @@
+package fakeip
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	DefaultRange  = "198.18.0.0/15"
+	DefaultRange6 = "fc00::/18"
+
+	stateVersion = 1
+	// maxPoolSize bounds the IPv6 pool, which would otherwise be endless.
+	maxPoolSize = 1 << 20
+)
+
+// Pool hands out stable synthetic addresses for domains. Addresses are taken
+// from each range in turn; once a range is used up the oldest allocation is
+// reused.
+type Pool struct {
+	mu    sync.Mutex
+	v4    *ring
+	v6    *ring
+	dirty bool
+	evict func(domain string, ip net.IP)
+}
+
+type ring struct {
+	base   net.IP
+	cidr   string
+	size   uint64
+	next   uint64
+	owners map[uint64]string
+	byName map[string]uint64
+}
+
+type state struct {
+	Version int        `json:"version"`
+	Range   string     `json:"range"`
+	Range6  string     `json:"range6"`
+	Next    uint64     `json:"next"`
+	Next6   uint64     `json:"next6"`
+	Entries []stateRow `json:"entries"`
+}
+
+type stateRow struct {
+	Domain string `json:"domain"`
+	IP     string `json:"ip,omitempty"`
+	IP6    string `json:"ip6,omitempty"`
+}
+
+// New creates a pool over cidr and cidr6; empty values select the defaults.
+func New(cidr, cidr6 string) (*Pool, error) {
+	if cidr == "" {
+		cidr = DefaultRange
+	}
+	if cidr6 == "" {
+		cidr6 = DefaultRange6
+	}
+	v4, err := newRing(cidr, false)
+	if err != nil {
+		return nil, err
+	}
+	v6, err := newRing(cidr6, true)
+	if err != nil {
+		return nil, err
+	}
+	return &Pool{v4: v4, v6: v6}, nil
+}
+
+func newRing(cidr string, ipv6 bool) (*ring, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fake-ip range %q: %w", cidr, err)
+	}
+	if (network.IP.To4() == nil) != ipv6 {
+		return nil, fmt.Errorf("fake-ip range %q has the wrong address family", cidr)
+	}
+	ones, bits := network.Mask.Size()
+	hostBits := bits - ones
+	size := uint64(maxPoolSize)
+	if hostBits < 21 {
+		size = 1 << hostBits
+	}
+	// Skip the network address and, for IPv4, the broadcast address.
+	size -= 2
+	if size == 0 || size > maxPoolSize {
+		return nil, fmt.Errorf("fake-ip range %q is too small", cidr)
+	}
+	base := network.IP.To16()
+	if !ipv6 {
+		base = network.IP.To4()
+	}
+	return &ring{
+		base:   base,
+		cidr:   network.String(),
+		size:   size,
+		owners: make(map[uint64]string),
+		byName: make(map[string]uint64),
+	}, nil
+}
+
+// SetEvictFunc makes Get call fn with the previous owner of an address it
+// hands to another domain, before returning the address.
+func (p *Pool) SetEvictFunc(fn func(domain string, ip net.IP)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.evict = fn
+}
+
+// Get returns the address of domain, allocating one if needed.
+func (p *Pool) Get(domain string, ipv6 bool) net.IP {
+	domain = normalize(domain)
+	p.mu.Lock()
+	r := p.ring(ipv6)
+	if slot, ok := r.byName[domain]; ok {
+		p.mu.Unlock()
+		return r.addr(slot)
+	}
+	slot := r.next
+	r.next = (r.next + 1) % r.size
+	prev, evicted := r.owners[slot]
+	if evicted {
+		delete(r.byName, prev)
+	}
+	r.owners[slot] = domain
+	r.byName[domain] = slot
+	p.dirty = true
+	evict := p.evict
+	p.mu.Unlock()
+
+	ip := r.addr(slot)
+	if evicted && evict != nil {
+		evict(prev, ip)
+	}
+	return ip
+}
+
+// Lookup returns the address already handed out for domain without
+// allocating one.
+func (p *Pool) Lookup(domain string, ipv6 bool) (net.IP, bool) {
+	domain = normalize(domain)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r := p.ring(ipv6)
+	slot, ok := r.byName[domain]
+	if !ok {
+		return nil, false
+	}
+	return r.addr(slot), true
+}
+
+// Domain returns the domain an address was handed out for.
+func (p *Pool) Domain(ip net.IP) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r := p.ring(ip.To4() == nil)
+	slot, ok := r.slot(ip)
+	if !ok {
+		return "", false
+	}
+	domain, ok := r.owners[slot]
+	return domain, ok
+}
+
+// Contains reports whether ip belongs to one of the pool ranges.
+func (p *Pool) Contains(ip net.IP) bool {
+	_, ok := p.ring(ip.To4() == nil).slot(ip)
+	return ok
+}
+
+func (p *Pool) ring(ipv6 bool) *ring {
+	if ipv6 {
+		return p.v6
+	}
+	return p.v4
+}
+
+func (r *ring) addr(slot uint64) net.IP {
+	ip := make(net.IP, len(r.base))
+	copy(ip, r.base)
+	if len(ip) == net.IPv4len {
+		binary.BigEndian.PutUint32(ip, binary.BigEndian.Uint32(ip)+uint32(slot)+1)
+		return ip
+	}
+	low := ip[8:]
+	binary.BigEndian.PutUint64(low, binary.BigEndian.Uint64(low)+slot+1)
+	return ip
+}
+
+func (r *ring) slot(ip net.IP) (uint64, bool) {
+	if len(r.base) == net.IPv4len {
+		ip4 := ip.To4()
+		if ip4 == nil {
+			return 0, false
+		}
+		off := uint64(binary.BigEndian.Uint32(ip4)) - uint64(binary.BigEndian.Uint32(r.base))
+		if off == 0 || off > r.size {
+			return 0, false
+		}
+		return off - 1, true
+	}
+	ip16 := ip.To16()
+	if ip16 == nil || ip.To4() != nil || !ip16[:8].Equal(r.base[:8]) {
+		return 0, false
+	}
+	off := binary.BigEndian.Uint64(ip16[8:]) - binary.BigEndian.Uint64(r.base[8:])
+	if off == 0 || off > r.size {
+		return 0, false
+	}
+	return off - 1, true
+}
+
+// Load restores the allocations saved by Save. A missing file or one written
+// for other ranges leaves the pool empty.
+func (p *Pool) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read fake-ip state: %w", err)
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fmt.Errorf("parse fake-ip state %s: %w", path, err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if st.Version != stateVersion || st.Range != p.v4.cidr || st.Range6 != p.v6.cidr {
+		return nil
+	}
+	for _, row := range st.Entries {
+		domain := normalize(row.Domain)
+		if ip := net.ParseIP(row.IP); ip != nil {
+			p.v4.restore(domain, ip)
+		}
+		if ip := net.ParseIP(row.IP6); ip != nil {
+			p.v6.restore(domain, ip)
+		}
+	}
+	p.v4.next = st.Next % p.v4.size
+	p.v6.next = st.Next6 % p.v6.size
+	return nil
+}
+
+func (r *ring) restore(domain string, ip net.IP) {
+	slot, ok := r.slot(ip)
+	if !ok {
+		return
+	}
+	if _, taken := r.owners[slot]; taken {
+		return
+	}
+	r.owners[slot] = domain
+	r.byName[domain] = slot
+}
+
+// Save writes the allocations to path if they changed since the last save.
+func (p *Pool) Save(path string) error {
+	p.mu.Lock()
+	if !p.dirty {
+		p.mu.Unlock()
+		return nil
+	}
+	st := state{
+		Version: stateVersion,
+		Range:   p.v4.cidr,
+		Range6:  p.v6.cidr,
+		Next:    p.v4.next,
+		Next6:   p.v6.next,
+	}
+	rows := make(map[string]*stateRow)
+	row := func(domain string) *stateRow {
+		if r, ok := rows[domain]; ok {
+			return r
+		}
+		r := &stateRow{Domain: domain}
+		rows[domain] = r
+		return r
+	}
+	for domain, slot := range p.v4.byName {
+		row(domain).IP = p.v4.addr(slot).String()
+	}
+	for domain, slot := range p.v6.byName {
+		row(domain).IP6 = p.v6.addr(slot).String()
+	}
+	p.dirty = false
+	p.mu.Unlock()
+
+	for _, r := range rows {
+		st.Entries = append(st.Entries, *r)
+	}
+	data, err := json.Marshal(st)
+	if err == nil {
+		err = writeAtomic(path, data)
+	}
+	if err != nil {
+		p.mu.Lock()
+		p.dirty = true
+		p.mu.Unlock()
+	}
+	return err
+}
+
+func writeAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func normalize(domain string) string {
+	return strings.ToLower(strings.TrimSuffix(domain, "."))
+}
@@ -0,0 +1,118 @@
+package fakeip
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestPoolStableAndReverse(t *testing.T) {
+	p, err := New("", "")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	a := p.Get("Example.com.", false)
+	if !a.Equal(net.ParseIP("198.18.0.1")) {
+		t.Fatalf("unexpected first address %s", a)
+	}
+	if b := p.Get("example.com", false); !b.Equal(a) {
+		t.Fatalf("address changed: %s != %s", b, a)
+	}
+	if other := p.Get("example.org", false); other.Equal(a) {
+		t.Fatalf("two domains share %s", a)
+	}
+	v6 := p.Get("example.com", true)
+	if v6.To4() != nil || !p.Contains(v6) {
+		t.Fatalf("unexpected IPv6 address %s", v6)
+	}
+	if domain, ok := p.Domain(a); !ok || domain != "example.com" {
+		t.Fatalf("Domain(%s) = %q, %v", a, domain, ok)
+	}
+	if domain, ok := p.Domain(v6); !ok || domain != "example.com" {
+		t.Fatalf("Domain(%s) = %q, %v", v6, domain, ok)
+	}
+	if p.Contains(net.ParseIP("8.8.8.8")) {
+		t.Fatal("address outside the range reported as fake")
+	}
+}
+
+func TestPoolLookupDoesNotAllocate(t *testing.T) {
+	p, err := New("", "")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if ip, ok := p.Lookup("example.com", false); ok || ip != nil {
+		t.Fatalf("Lookup of a new domain = %s, %v", ip, ok)
+	}
+	a := p.Get("example.com", false)
+	if !a.Equal(net.ParseIP("198.18.0.1")) {
+		t.Fatalf("Lookup took a slot: first address is %s", a)
+	}
+	if ip, ok := p.Lookup("Example.com.", false); !ok || !ip.Equal(a) {
+		t.Fatalf("Lookup = %s, %v, want %s", ip, ok, a)
+	}
+}
+
+func TestPoolReusesOldestSlot(t *testing.T) {
+	p, err := New("10.0.0.0/30", "")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var evicted []string
+	p.SetEvictFunc(func(domain string, ip net.IP) {
+		evicted = append(evicted, domain+" "+ip.String())
+	})
+	a := p.Get("a.test", false)
+	p.Get("b.test", false)
+	if len(evicted) != 0 {
+		t.Fatalf("eviction reported before the range was used up: %v", evicted)
+	}
+	c := p.Get("c.test", false)
+	if !c.Equal(a) {
+		t.Fatalf("expected %s to be reused, got %s", a, c)
+	}
+	if len(evicted) != 1 || evicted[0] != "a.test "+a.String() {
+		t.Fatalf("evictions = %v", evicted)
+	}
+	if _, ok := p.Domain(net.ParseIP("10.0.0.3")); ok {
+		t.Fatal("broadcast address handed out")
+	}
+	if domain, _ := p.Domain(a); domain != "c.test" {
+		t.Fatalf("slot still owned by %q", domain)
+	}
+	if again := p.Get("a.test", false); again.Equal(a) {
+		t.Fatal("evicted domain kept its address")
+	}
+}
+
+func TestPoolSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fakeip.json")
+	p, _ := New("", "")
+	a := p.Get("example.com", false)
+	v6 := p.Get("example.com", true)
+	if err := p.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	restored, _ := New("", "")
+	if err := restored.Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := restored.Get("example.com", false); !got.Equal(a) {
+		t.Fatalf("restored %s, want %s", got, a)
+	}
+	if got := restored.Get("example.com", true); !got.Equal(v6) {
+		t.Fatalf("restored %s, want %s", got, v6)
+	}
+	if got := restored.Get("example.org", false); got.Equal(a) {
+		t.Fatalf("new domain reused %s", got)
+	}
+
+	other, _ := New("100.64.0.0/10", "")
+	if err := other.Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, ok := other.Domain(a); ok {
+		t.Fatal("state of another range was restored")
+	}
+}
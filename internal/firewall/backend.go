@@ -0,0 +1,89 @@
+package firewall
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ApostolDmitry/vpner/internal/logx"
+)
+
+type Backend string
+
+const (
+	BackendAuto     Backend = "auto"
+	BackendIptables Backend = "iptables"
+	BackendNftables Backend = "nftables"
+)
+
+var activeBackend = BackendIptables
+
+func ParseBackend(value string) (Backend, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", string(BackendAuto):
+		return BackendAuto, nil
+	case string(BackendIptables), "ipset":
+		return BackendIptables, nil
+	case string(BackendNftables), "nft":
+		return BackendNftables, nil
+	default:
+		return "", fmt.Errorf("unknown firewall backend %q (expected auto, iptables or nftables)", value)
+	}
+}
+
+func iptablesToolsPresent() bool {
+	for _, bin := range []string{"iptables", "iptables-restore", "iptables-save"} {
+		if !commandExists(bin) {
+			return false
+		}
+	}
+	return commandExists("ipset") || IPSetNetlinkAvailable()
+}
+
+func IPSetNetlinkAvailable() bool {
+	_, err := newNetlinkSets()
+	return err == nil
+}
+
+func DetectBackend(pref Backend) (Backend, error) {
+	switch pref {
+	case BackendIptables:
+		if !iptablesToolsPresent() {
+			return "", fmt.Errorf("iptables backend requested but iptables/ipset tools are missing")
+		}
+		return BackendIptables, nil
+	case BackendNftables:
+		if !commandExists("nft") {
+			return "", errNftNotFound
+		}
+		return BackendNftables, nil
+	}
+	if iptablesToolsPresent() {
+		return BackendIptables, nil
+	}
+	if commandExists("nft") {
+		return BackendNftables, nil
+	}
+	return "", fmt.Errorf("no firewall backend available: need iptables+ipset or nft")
+}
+
+func UseBackend(b Backend) {
+	switch b {
+	case BackendNftables:
+		sets = nftSets{}
+		rules = newNftRules()
+	default:
+		b = BackendIptables
+		rules = iptablesRules{}
+		if nlSets, err := newNetlinkSets(); err == nil {
+			sets = nlSets
+		} else {
+			logx.Warnf("ipset: %v; falling back to the ipset binary", err)
+			sets = ipsetExec{}
+		}
+	}
+	activeBackend = b
+}
+
+func ActiveBackend() Backend {
+	return activeBackend
+}
@@ -0,0 +1,223 @@
+package firewall
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/ApostolDmitry/vpner/internal/logx"
+	"github.com/ApostolDmitry/vpner/internal/vpnkind"
+)
+
+const (
+	clientGroupPrefix    = defaultTag + "-cg-"
+	clientGroupMACSuffix = "-mac"
+)
+
+type ClientFilter struct {
+	Include []string
+	Exclude []string
+}
+
+func (c ClientFilter) Empty() bool {
+	return len(c.Include) == 0 && len(c.Exclude) == 0
+}
+
+func (c ClientFilter) clone() ClientFilter {
+	return ClientFilter{
+		Include: append([]string(nil), c.Include...),
+		Exclude: append([]string(nil), c.Exclude...),
+	}
+}
+
+func clientGroupSetName(group string, f ipFamily) string {
+	if f.nftAddr == familyV6.nftAddr {
+		return clientGroupPrefix + group + ipv6Suffix
+	}
+	return clientGroupPrefix + group
+}
+
+func clientGroupMACSetName(group string) string {
+	return clientGroupPrefix + group + clientGroupMACSuffix
+}
+
+func splitClientMembers(members []string) (v4, v6, macs []string, err error) {
+	for _, member := range members {
+		member = strings.TrimSpace(member)
+		if mac, macErr := net.ParseMAC(member); macErr == nil {
+			macs = append(macs, mac.String())
+			continue
+		}
+		ip := net.ParseIP(member)
+		if ip == nil {
+			var parseErr error
+			ip, _, parseErr = net.ParseCIDR(member)
+			if parseErr != nil {
+				return nil, nil, nil, fmt.Errorf("invalid client %q: expected IP, CIDR or MAC address", member)
+			}
+		}
+		if ip.To4() != nil {
+			v4 = append(v4, member)
+		} else {
+			v6 = append(v6, member)
+		}
+	}
+	return v4, v6, macs, nil
+}
+
+func (i *IptablesManager) SetClientGroups(groups map[string][]string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var errs []error
+	for group, members := range groups {
+		if err := i.syncClientGroupLocked(group, members); err != nil {
+			errs = append(errs, fmt.Errorf("client group %s: %w", group, err))
+			continue
+		}
+		i.clientGroups[group] = struct{}{}
+	}
+	for group := range i.clientGroups {
+		if _, ok := groups[group]; ok {
+			continue
+		}
+		i.destroyClientGroupLocked(group)
+		delete(i.clientGroups, group)
+	}
+	return errors.Join(errs...)
+}
+
+func (i *IptablesManager) syncClientGroupLocked(group string, members []string) error {
+	v4, v6, macs, err := splitClientMembers(members)
+	if err != nil {
+		return err
+	}
+
+	type groupSet struct {
+		name     string
+		hashType string
+		family   string
+		entries  []string
+	}
+	groupSets := []groupSet{
+		{clientGroupSetName(group, familyV4), "hash:net", "inet", v4},
+		{clientGroupMACSetName(group), "hash:mac", "", macs},
+	}
+	if i.ipv6Enabled {
+		groupSets = append(groupSets, groupSet{clientGroupSetName(group, familyV6), "hash:net", "inet6", v6})
+	}
+
+	for _, gs := range groupSets {
+		if _, err := validateIpsetName(gs.name); err != nil {
+			return err
+		}
+		set, err := NewIPset(gs.name, gs.hashType, &Params{HashFamily: gs.family})
+		if err != nil {
+			return err
+		}
+		if err := set.Refresh(gs.entries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *IptablesManager) destroyClientGroupLocked(group string) {
+	names := []string{clientGroupSetName(group, familyV4), clientGroupMACSetName(group)}
+	if i.ipv6Enabled {
+		names = append(names, clientGroupSetName(group, familyV6))
+	}
+	for _, name := range names {
+		if !IPSetExists(name) {
+			continue
+		}
+		set := &IPSet{Name: name}
+		if err := set.Destroy(); err != nil {
+			logx.Warnf("client group %s: %v", group, err)
+		}
+	}
+}
+
+func (i *IptablesManager) SetClientFilter(ipsetName string, filter ClientFilter) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.storeClientFilterLocked(ipsetName, filter)
+	errs := []error{i.reapplyChainLocked(i.v4(), i.routingV4, ipsetName)}
+	if i.ipv6Enabled {
+		if ipsetName6, err := IpsetName6FromBase(ipsetName); err == nil {
+			i.storeClientFilterLocked(ipsetName6, filter)
+			errs = append(errs, i.reapplyChainLocked(i.v6(), i.routingV6, ipsetName6))
+		}
+	}
+	if info, ok := i.killSwitch[ipsetName]; ok {
+		errs = append(errs, i.applyKillSwitchLocked(ipsetName, info, true, true))
+	}
+	return errors.Join(errs...)
+}
+
+func (i *IptablesManager) storeClientFilterLocked(ipsetName string, filter ClientFilter) {
+	if filter.Empty() {
+		delete(i.clientFilters, ipsetName)
+		return
+	}
+	i.clientFilters[ipsetName] = filter.clone()
+}
+
+func (i *IptablesManager) reapplyChainLocked(f ipFamily, routing map[string]vpnRoutingInfo, ipsetName string) error {
+	info, ok := routing[ipsetName]
+	if !ok || !routeApplied(info) {
+		return nil
+	}
+	if info.VPNType == vpnkind.Xray {
+		return i.applyXrayBatch(f, routing, []ChainSpec{{IPSetName: ipsetName, Port: info.Port, Ifaces: info.Ifaces}})
+	}
+	jumps, err := rules.applyMarkChain(f, info.ChainName, ipsetName, info.Mark, info.Ifaces, i.clientFilters[ipsetName])
+	if err != nil {
+		return err
+	}
+	info.JumpRules = jumps
+	routing[ipsetName] = info
+	return nil
+}
+
+func clientFilterRuleSpecs(f ipFamily, chainName string, filter ClientFilter) []string {
+	var out []string
+	for _, group := range filter.Exclude {
+		out = append(out,
+			fmt.Sprintf("-A %s -m set --match-set %s src -j RETURN", chainName, clientGroupSetName(group, f)),
+			fmt.Sprintf("-A %s -m set --match-set %s src -j RETURN", chainName, clientGroupMACSetName(group)),
+		)
+	}
+	if len(filter.Include) == 0 {
+		return out
+	}
+	var match strings.Builder
+	for _, group := range filter.Include {
+		fmt.Fprintf(&match, " -m set ! --match-set %s src -m set ! --match-set %s src",
+			clientGroupSetName(group, f), clientGroupMACSetName(group))
+	}
+	return append(out, fmt.Sprintf("-A %s%s -j RETURN", chainName, match.String()))
+}
+
+func nftClientFilterRules(f ipFamily, filter ClientFilter) []string {
+	var out []string
+	for _, group := range filter.Exclude {
+		out = append(out,
+			fmt.Sprintf("%s saddr @%s return", f.nftAddr, clientGroupSetName(group, f)),
+			fmt.Sprintf("ether saddr @%s return", clientGroupMACSetName(group)),
+		)
+	}
+	if len(filter.Include) == 0 {
+		return out
+	}
+	match := make([]string, 0, 2*len(filter.Include))
+	for _, group := range filter.Include {
+		match = append(match,
+			fmt.Sprintf("%s saddr != @%s", f.nftAddr, clientGroupSetName(group, f)),
+			fmt.Sprintf("ether saddr != @%s", clientGroupMACSetName(group)),
+		)
+	}
+	return append(out, strings.Join(match, " ")+" return")
+}
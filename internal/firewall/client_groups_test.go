@@ -0,0 +1,77 @@
+package firewall
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClientFilterRuleSpecs(t *testing.T) {
+	filter := ClientFilter{Include: []string{"kids"}, Exclude: []string{"tv"}}
+
+	got := clientFilterRuleSpecs(familyV6, "VPN_TEST", filter)
+	want := []string{
+		"-A VPN_TEST -m set --match-set vpner-cg-tv-6 src -j RETURN",
+		"-A VPN_TEST -m set --match-set vpner-cg-tv-mac src -j RETURN",
+		"-A VPN_TEST -m set ! --match-set vpner-cg-kids-6 src -m set ! --match-set vpner-cg-kids-mac src -j RETURN",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("iptables rules:\n got %q\nwant %q", got, want)
+	}
+
+	gotNft := nftClientFilterRules(familyV4, filter)
+	wantNft := []string{
+		"ip saddr @vpner-cg-tv return",
+		"ether saddr @vpner-cg-tv-mac return",
+		"ip saddr != @vpner-cg-kids ether saddr != @vpner-cg-kids-mac return",
+	}
+	if !reflect.DeepEqual(gotNft, wantNft) {
+		t.Fatalf("nft rules:\n got %q\nwant %q", gotNft, wantNft)
+	}
+
+	if rules := clientFilterRuleSpecs(familyV4, "VPN_TEST", ClientFilter{}); len(rules) != 0 {
+		t.Fatalf("expected no rules for empty filter, got %v", rules)
+	}
+}
+
+func TestSplitClientMembers(t *testing.T) {
+	v4, v6, macs, err := splitClientMembers([]string{"10.0.0.0/24", "fd00::2", "aa:bb:cc:dd:ee:ff", "192.168.1.5"})
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	if !reflect.DeepEqual(v4, []string{"10.0.0.0/24", "192.168.1.5"}) ||
+		!reflect.DeepEqual(v6, []string{"fd00::2"}) ||
+		!reflect.DeepEqual(macs, []string{"aa:bb:cc:dd:ee:ff"}) {
+		t.Fatalf("split = %v %v %v", v4, v6, macs)
+	}
+	if _, _, _, err := splitClientMembers([]string{"printer"}); err == nil {
+		t.Fatalf("expected error for invalid member")
+	}
+}
+
+func TestFullTunnelRuleSpecs(t *testing.T) {
+	spec := ChainSpec{
+		IPSetName:   "vpner-Xray-xray1",
+		Port:        10800,
+		FullTunnel:  []string{"tv"},
+		ServerAddrs: []string{"203.0.113.7"},
+	}
+	f := ipFamily{localExceptions: []string{"10.0.0.0/8"}}
+
+	got := fullTunnelRuleSpecs(f, "VPN_TEST", spec, redirectFullTunnelTargets(spec.Port))
+	want := []string{
+		":VPN_TEST_FT - [0:0]",
+		"-A VPN_TEST_FT -d 10.0.0.0/8 -j RETURN",
+		"-A VPN_TEST_FT -d 203.0.113.7 -j RETURN",
+		"-A VPN_TEST_FT -p tcp -j REDIRECT --to-ports 10800",
+		"-A VPN_TEST -m set --match-set vpner-cg-tv src -j VPN_TEST_FT",
+		"-A VPN_TEST -m set --match-set vpner-cg-tv-mac src -j VPN_TEST_FT",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("full tunnel rules:\n got %q\nwant %q", got, want)
+	}
+
+	spec.FullTunnel = nil
+	if rules := fullTunnelRuleSpecs(f, "VPN_TEST", spec, nil); rules != nil {
+		t.Fatalf("expected no rules without full-tunnel groups, got %v", rules)
+	}
+}
@@ -0,0 +1,131 @@
+package firewall
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const fullTunnelSuffix = "_FT"
+
+func fullTunnelChainName(chainName string) string {
+	return chainName + fullTunnelSuffix
+}
+
+func (i *IptablesManager) SetFullTunnel(ipsetName string, groups []string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	errs := []error{i.setFullTunnelLocked(i.v4(), i.routingV4, ipsetName, groups)}
+	if i.ipv6Enabled {
+		if ipsetName6, err := IpsetName6FromBase(ipsetName); err == nil {
+			errs = append(errs, i.setFullTunnelLocked(i.v6(), i.routingV6, ipsetName6, groups))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (i *IptablesManager) setFullTunnelLocked(f ipFamily, routing map[string]vpnRoutingInfo, ipsetName string, groups []string) error {
+	_, enabled := i.fullTunnel[ipsetName]
+	if len(groups) == 0 {
+		delete(i.fullTunnel, ipsetName)
+	} else {
+		i.fullTunnel[ipsetName] = append([]string(nil), groups...)
+	}
+	if err := i.reapplyChainLocked(f, routing, ipsetName); err != nil {
+		return err
+	}
+	if enabled && len(groups) == 0 {
+		if info, ok := routing[ipsetName]; ok {
+			rules.removeFullTunnel(f, info.Table, info.ChainName)
+		}
+	}
+	return nil
+}
+
+func (i *IptablesManager) storeServerAddrsLocked(ipsetName, ipsetName6 string, addrs []string) {
+	var v4, v6 []string
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		switch {
+		case ip == nil:
+			continue
+		case ip.To4() != nil:
+			v4 = append(v4, ip.String())
+		default:
+			v6 = append(v6, ip.String())
+		}
+	}
+	i.serverAddrs[ipsetName] = v4
+	if ipsetName6 != "" {
+		i.serverAddrs[ipsetName6] = v6
+	}
+}
+
+func fullTunnelRuleSpecs(f ipFamily, chainName string, spec ChainSpec, targets []string) []string {
+	if len(spec.FullTunnel) == 0 {
+		return nil
+	}
+	ft := fullTunnelChainName(chainName)
+	out := []string{fmt.Sprintf(":%s - [0:0]", ft)}
+	for _, cidr := range f.localExceptions {
+		out = append(out, fmt.Sprintf("-A %s -d %s -j RETURN", ft, cidr))
+	}
+	for _, addr := range spec.ServerAddrs {
+		out = append(out, fmt.Sprintf("-A %s -d %s -j RETURN", ft, addr))
+	}
+	for _, target := range targets {
+		out = append(out, fmt.Sprintf("-A %s %s", ft, target))
+	}
+	for _, group := range spec.FullTunnel {
+		out = append(out,
+			fmt.Sprintf("-A %s -m set --match-set %s src -j %s", chainName, clientGroupSetName(group, f), ft),
+			fmt.Sprintf("-A %s -m set --match-set %s src -j %s", chainName, clientGroupMACSetName(group), ft),
+		)
+	}
+	return out
+}
+
+func redirectFullTunnelTargets(port int) []string {
+	return []string{fmt.Sprintf("-p tcp -j REDIRECT --to-ports %d", port)}
+}
+
+func tproxyFullTunnelTargets(port int) []string {
+	var out []string
+	for _, proto := range []string{"tcp", "udp"} {
+		out = append(out, fmt.Sprintf("-p %s -j TPROXY --on-port %d --tproxy-mark %s", proto, port, tproxyMark))
+	}
+	return out
+}
+
+func nftFullTunnelRules(f ipFamily, spec ChainSpec, tproxy bool) []string {
+	var out []string
+	if len(f.localExceptions) > 0 {
+		out = append(out, fmt.Sprintf("%s daddr { %s } return", f.nftAddr, strings.Join(f.localExceptions, ", ")))
+	}
+	if len(spec.ServerAddrs) > 0 {
+		out = append(out, fmt.Sprintf("%s daddr { %s } return", f.nftAddr, strings.Join(spec.ServerAddrs, ", ")))
+	}
+	nfproto := "meta nfproto " + nftNfproto(f)
+	if !tproxy {
+		return append(out, fmt.Sprintf("%s meta l4proto tcp redirect to :%d", nfproto, spec.Port))
+	}
+	for _, proto := range []string{"tcp", "udp"} {
+		out = append(out, fmt.Sprintf("%s meta l4proto %s meta mark set %s tproxy %s to :%d accept",
+			nfproto, proto, tproxyMark, f.nftAddr, spec.Port))
+	}
+	return out
+}
+
+func nftFullTunnelJumps(f ipFamily, chainName string, groups []string) []string {
+	ft := fullTunnelChainName(chainName)
+	var out []string
+	for _, group := range groups {
+		out = append(out,
+			fmt.Sprintf("%s saddr @%s jump %s", f.nftAddr, clientGroupSetName(group, f), ft),
+			fmt.Sprintf("ether saddr @%s jump %s", clientGroupMACSetName(group), ft),
+		)
+	}
+	return out
+}
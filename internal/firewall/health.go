@@ -1,13 +1,15 @@
 package firewall
 
 import (
-	"os/exec"
-
+	"github.com/ApostolDmitry/vpner/internal/chainpolicy"
 	"github.com/ApostolDmitry/vpner/internal/vpnkind"
 )
 
 func (i *IptablesManager) XrayRoutingIntact() bool {
-	type probe struct{ iptablesCmd, table, chain, ipsetName string }
+	type probe struct {
+		f                       ipFamily
+		table, chain, ipsetName string
+	}
 
 	var probes []probe
 	i.mu.Lock()
@@ -19,21 +21,27 @@ func (i *IptablesManager) XrayRoutingIntact() bool {
 		{familyV6, i.routingV6},
 	} {
 		for ipsetName, info := range fam.routing {
-			if info.VPNType == vpnkind.Xray {
-				probes = append(probes, probe{fam.f.iptablesCmd, info.Table, info.ChainName, ipsetName})
+			if info.VPNType != vpnkind.Xray {
+				continue
+			}
+			probes = append(probes, probe{fam.f, info.Table, info.ChainName, ipsetName})
+			if !routeApplied(info) {
+				continue
+			}
+			switch policy := i.udpPolicyLocked(ipsetName); policy {
+			case chainpolicy.UDPBlockQUIC:
+				probes = append(probes, probe{fam.f, udpPolicyTable(policy), quicBlockChainName(info.ChainName), ipsetName})
+			case chainpolicy.UDPTProxyUDPOnly:
+				probes = append(probes, probe{fam.f, udpPolicyTable(policy), udpTProxyChainName(info.ChainName), ipsetName})
 			}
 		}
 	}
 	i.mu.Unlock()
 
 	for _, p := range probes {
-		if !chainExists(p.iptablesCmd, p.table, p.chain) || !IPSetExists(p.ipsetName) {
+		if !rules.chainPresent(p.f, p.table, p.chain) || !IPSetExists(p.ipsetName) {
 			return false
 		}
 	}
-	return true
-}
-
-func chainExists(iptablesCmd, table, chain string) bool {
-	return exec.Command(iptablesCmd, "-t", table, "-n", "-L", chain).Run() == nil
+	return i.killSwitchIntact()
 }
@@ -25,6 +25,7 @@ type RuleRuntimeOptions struct {
 	IPv6Enabled       bool
 	IPSetDebug        bool
 	IPSetStaleQueries int
+	EntryTTL          EntryTTL
 }
 
 type IpRuleManager struct {
@@ -34,6 +35,7 @@ type IpRuleManager struct {
 	ipv6Enabled       bool
 	ipsetDebug        bool
 	ipsetStaleQueries int
+	entryTTL          EntryTTL
 }
 
 func NewIpRuleManager(matcher DomainRuleMatcher, opts RuleRuntimeOptions, resolver *resolver.Upstream, registry *IPSetRegistry) *IpRuleManager {
@@ -47,6 +49,7 @@ func NewIpRuleManager(matcher DomainRuleMatcher, opts RuleRuntimeOptions, resolv
 		ipv6Enabled:       opts.IPv6Enabled,
 		ipsetDebug:        opts.IPSetDebug,
 		ipsetStaleQueries: opts.IPSetStaleQueries,
+		entryTTL:          opts.EntryTTL,
 	}
 }
 
@@ -69,7 +72,9 @@ func (m *IpRuleManager) CheckIPsInIpset(domain string) error {
 	return nil
 }
 
-func (m *IpRuleManager) SyncFromAnswers(domain string, ips []net.IP) error {
+// SyncFromAnswers puts the addresses a DNS answer returned for domain into
+// the set of the matching rule; ttl is the smallest TTL of the answer.
+func (m *IpRuleManager) SyncFromAnswers(domain string, ips []net.IP, ttl uint32) error {
 	if len(ips) == 0 {
 		return nil
 	}
@@ -82,14 +87,14 @@ func (m *IpRuleManager) SyncFromAnswers(domain string, ips []net.IP) error {
 	}
 	v4 := filterIPs(ips, false)
 	if len(v4) > 0 {
-		if err := m.syncResolvedIPs(vpnType, chainName, rule, domain, v4, false); err != nil {
+		if err := m.syncResolvedIPs(vpnType, chainName, rule, domain, v4, ttl, false); err != nil {
 			return err
 		}
 	}
 	if m.ipv6Enabled {
 		v6 := filterIPs(ips, true)
 		if len(v6) > 0 {
-			if err := m.syncResolvedIPs(vpnType, chainName, rule, domain, v6, true); err != nil {
+			if err := m.syncResolvedIPs(vpnType, chainName, rule, domain, v6, ttl, true); err != nil {
 				return err
 			}
 		}
@@ -97,6 +102,49 @@ func (m *IpRuleManager) SyncFromAnswers(domain string, ips []net.IP) error {
 	return nil
 }
 
+// RemoveAnswer takes ip out of the set of the rule matching domain if it is
+// still there for domain. A fake address handed to another domain must not
+// keep routing the new owner through the old one's chain.
+func (m *IpRuleManager) RemoveAnswer(domain string, ip net.IP) error {
+	if m.matcher == nil || ip == nil {
+		return nil
+	}
+	ipv6 := ip.To4() == nil
+	if ipv6 && !m.ipv6Enabled {
+		return nil
+	}
+	vpnType, chainName, rule, ok := m.matcher.MatchDomain(domain)
+	if !ok {
+		return nil
+	}
+	ipsetName, _, err := ipsetNameForFamily(vpnType, chainName, ipv6)
+	if err != nil {
+		return fmt.Errorf("failed to get ipset name for %q: %w", domain, err)
+	}
+
+	unlock := m.registry.LockSet(ipsetName)
+	defer unlock()
+	if !IPSetExists(ipsetName) {
+		return nil
+	}
+	entries, err := m.registry.entries(ipsetName)
+	if err != nil {
+		return fmt.Errorf("failed to list ipset entries for %q: %w", domain, err)
+	}
+	key := canonicalSetEntry(ip.String())
+	comment := buildRuleComment(rule, domain)
+	for _, entry := range entries {
+		if entry.Entry != key || (entry.Comment != comment && entry.Comment != domain) {
+			continue
+		}
+		if m.ipsetDebug {
+			logx.Infof("ipset del: set=%s entry=%s reason=fake-ip-reused domain=%s rule=%s", ipsetName, entry.Entry, domain, rule)
+		}
+		return m.registry.removeEntries(ipsetName, []string{entry.Entry})
+	}
+	return nil
+}
+
 func cleanupDomainEntries(registry *IPSetRegistry, vpnType, chainName, pattern string, ipv6Enabled bool, ipsetDebug bool) error {
 	if pattern == "" {
 		return nil
@@ -130,7 +178,7 @@ func buildStaleKey(ipsetName, comment string) string {
 }
 
 func (m *IpRuleManager) syncDomainIPs(vpnType, chainName, rule, domain string, qtype uint16, ipv6 bool) error {
-	ips, err := m.resolver.ResolveDomain(domain, qtype)
+	ips, ttl, err := m.resolver.ResolveDomainTTL(domain, qtype)
 	if err != nil {
 		if !errors.Is(err, resolver.ErrNoRecords) {
 			return fmt.Errorf("failed to resolve domain %q: %w", domain, err)
@@ -141,7 +189,7 @@ func (m *IpRuleManager) syncDomainIPs(vpnType, chainName, rule, domain string, q
 	if len(resolved) == 0 {
 		return nil
 	}
-	return m.syncResolvedIPs(vpnType, chainName, rule, domain, resolved, ipv6)
+	return m.syncResolvedIPs(vpnType, chainName, rule, domain, resolved, ttl, ipv6)
 }
 
 func ipsetNameForFamily(vpnType, chainName string, ipv6 bool) (name, family string, err error) {
@@ -153,7 +201,7 @@ func ipsetNameForFamily(vpnType, chainName string, ipv6 bool) (name, family stri
 	return name, "inet", err
 }
 
-func (m *IpRuleManager) syncResolvedIPs(vpnType, chainName, rule, domain string, resolved []string, ipv6 bool) error {
+func (m *IpRuleManager) syncResolvedIPs(vpnType, chainName, rule, domain string, resolved []string, ttl uint32, ipv6 bool) error {
 	ipsetName, family, err := ipsetNameForFamily(vpnType, chainName, ipv6)
 	if err != nil {
 		return fmt.Errorf("failed to get ipset name for %q: %w", domain, err)
@@ -168,7 +216,10 @@ func (m *IpRuleManager) syncResolvedIPs(vpnType, chainName, rule, domain string,
 	}
 
 	comment := buildRuleComment(rule, domain)
-	entries, err := listEntriesWithComments(ipsetName)
+	if m.entryTTL.Enabled {
+		return m.refreshResolvedIPs(set, rule, domain, comment, resolved, m.entryTTL.timeout(ttl))
+	}
+	entries, err := m.registry.entries(ipsetName)
 	if err != nil {
 		return fmt.Errorf("failed to list ipset entries for %q: %w", domain, err)
 	}
@@ -189,7 +240,7 @@ func (m *IpRuleManager) syncResolvedIPs(vpnType, chainName, rule, domain string,
 			logx.Infof("ipset del: set=%s entry=%s reason=legacy-comment domain=%s rule=%s", ipsetName, entry, domain, rule)
 		}
 	}
-	if err := removeEntries(ipsetName, legacy); err != nil {
+	if err := m.registry.removeEntries(ipsetName, legacy); err != nil {
 		errs = append(errs, fmt.Errorf("cleanup legacy entries: %w", err))
 	}
 
@@ -209,7 +260,7 @@ func (m *IpRuleManager) syncResolvedIPs(vpnType, chainName, rule, domain string,
 		if m.ipsetDebug {
 			logx.Infof("ipset add: set=%s entry=%s reason=resolved domain=%s rule=%s", ipsetName, ip, domain, rule)
 		}
-		if err := set.AddComment(ip, comment, 0); err != nil {
+		if err := m.registry.addEntry(set, ip, comment, 0); err != nil {
 			errs = append(errs, fmt.Errorf("add %s: %w", ip, err))
 		}
 	}
@@ -222,7 +273,7 @@ func (m *IpRuleManager) syncResolvedIPs(vpnType, chainName, rule, domain string,
 			if m.ipsetDebug {
 				logx.Infof("ipset del: set=%s entry=%s reason=stale-miss misses=%d threshold=%d domain=%s rule=%s", ipsetName, entry.entry, entry.misses, m.ipsetStaleQueries, domain, rule)
 			}
-			if err := set.Del(entry.entry); err != nil {
+			if err := m.registry.delEntry(set, entry.entry); err != nil {
 				errs = append(errs, fmt.Errorf("del %s: %w", entry.entry, err))
 				continue
 			}
@@ -238,7 +289,7 @@ func (m *IpRuleManager) syncResolvedIPs(vpnType, chainName, rule, domain string,
 			if m.ipsetDebug {
 				logx.Infof("ipset del: set=%s entry=%s reason=stale-resolve domain=%s rule=%s", ipsetName, entry, domain, rule)
 			}
-			if err := set.Del(entry); err != nil {
+			if err := m.registry.delEntry(set, entry); err != nil {
 				errs = append(errs, fmt.Errorf("del %s: %w", entry, err))
 			}
 		}
@@ -247,6 +298,22 @@ func (m *IpRuleManager) syncResolvedIPs(vpnType, chainName, rule, domain string,
 	return errors.Join(errs...)
 }
 
+// refreshResolvedIPs adds resolved with a timeout, or extends the timeout of
+// the entries already there. Addresses that stop showing up in answers simply
+// expire, so the set is never listed.
+func (m *IpRuleManager) refreshResolvedIPs(set *IPSet, rule, domain, comment string, resolved []string, timeout int) error {
+	var errs []error
+	for _, ip := range resolved {
+		if m.ipsetDebug {
+			logx.Infof("ipset add: set=%s entry=%s reason=resolved timeout=%d domain=%s rule=%s", set.Name, ip, timeout, domain, rule)
+		}
+		if err := m.registry.addEntry(set, ip, comment, timeout); err != nil {
+			errs = append(errs, fmt.Errorf("add %s: %w", ip, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func cleanupDomainEntriesForSet(registry *IPSetRegistry, vpnType, chainName, pattern string, ipv6 bool, ipsetDebug bool) error {
 	var ipsetName string
 	var err error
@@ -262,7 +329,7 @@ func cleanupDomainEntriesForSet(registry *IPSetRegistry, vpnType, chainName, pat
 	unlock := registry.LockSet(ipsetName)
 	defer unlock()
 	registry.ClearStaleCountsForRule(ipsetName, pattern)
-	prefixed, err := entriesByCommentPrefix(ipsetName, ruleCommentPrefix(pattern))
+	prefixed, err := registry.entriesByCommentPrefix(ipsetName, ruleCommentPrefix(pattern))
 	if err != nil {
 		return err
 	}
@@ -271,10 +338,10 @@ func cleanupDomainEntriesForSet(registry *IPSetRegistry, vpnType, chainName, pat
 			logx.Infof("ipset del: set=%s entry=%s reason=rule-delete rule=%s", ipsetName, entry, pattern)
 		}
 	}
-	if err := removeEntries(ipsetName, prefixed); err != nil {
+	if err := registry.removeEntries(ipsetName, prefixed); err != nil {
 		return err
 	}
-	entries, err := listEntriesWithComments(ipsetName)
+	entries, err := registry.entries(ipsetName)
 	if err != nil {
 		return err
 	}
@@ -292,7 +359,7 @@ func cleanupDomainEntriesForSet(registry *IPSetRegistry, vpnType, chainName, pat
 			logx.Infof("ipset del: set=%s entry=%s reason=rule-delete-legacy rule=%s", ipsetName, entry, pattern)
 		}
 	}
-	return removeEntries(ipsetName, stale)
+	return registry.removeEntries(ipsetName, stale)
 }
 
 func filterIPs(ips []net.IP, ipv6 bool) []string {
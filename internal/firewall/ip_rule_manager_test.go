@@ -0,0 +1,39 @@
+package firewall
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/ApostolDmitry/vpner/internal/vpnkind"
+)
+
+func TestRemoveAnswerKeepsOtherOwners(t *testing.T) {
+	fake := useFakeSets(t)
+	mgr := NewUnblockManager(filepath.Join(t.TempDir(), "rules.yaml"), false, false, 3, NewIPSetRegistry())
+	if err := mgr.AddRule(vpnkind.Xray.String(), "a", "*.example.com"); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	ipm := NewIpRuleManager(mgr, RuleRuntimeOptions{}, nil, mgr.registry)
+
+	const name = "vpner-Xray-a"
+	ip := net.ParseIP("198.18.0.1")
+	if err := ipm.SyncFromAnswers("old.example.com", []net.IP{ip}, 60); err != nil {
+		t.Fatalf("SyncFromAnswers: %v", err)
+	}
+	if err := ipm.RemoveAnswer("new.example.com", ip); err != nil {
+		t.Fatalf("RemoveAnswer: %v", err)
+	}
+	if _, ok := fake.data[name]["198.18.0.1"]; !ok {
+		t.Fatal("entry of another domain removed")
+	}
+	if err := ipm.RemoveAnswer("old.example.com", ip); err != nil {
+		t.Fatalf("RemoveAnswer: %v", err)
+	}
+	if _, ok := fake.data[name]["198.18.0.1"]; ok {
+		t.Fatal("evicted address still in the set")
+	}
+	if err := ipm.RemoveAnswer("other.test", ip); err != nil {
+		t.Fatalf("RemoveAnswer for an unmatched domain: %v", err)
+	}
+}
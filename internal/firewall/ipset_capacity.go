@@ -0,0 +1,164 @@
+package firewall
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ApostolDmitry/vpner/internal/logx"
+)
+
+const (
+	// maxIPSetEntries caps automatic growth; beyond it sets only warn.
+	maxIPSetEntries = 1 << 20
+
+	capacityWarnPercent = 80
+	capacityGrowPercent = 90
+)
+
+type SetUsage struct {
+	Name       string
+	Chain      string
+	Entries    int
+	MaxEntries int
+}
+
+func (u SetUsage) Percent() int {
+	if u.MaxEntries <= 0 {
+		return 0
+	}
+	return u.Entries * 100 / u.MaxEntries
+}
+
+// SetMaxEntries sets the initial maxelem of the managed sets; perChain
+// overrides it for individual chains. Zero keeps the built-in default.
+func (r *IPSetRegistry) SetMaxEntries(def int, perChain map[string]int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.maxEntries = def
+	r.chainMaxEntries = make(map[string]int, len(perChain))
+	for chain, n := range perChain {
+		r.chainMaxEntries[chain] = n
+	}
+}
+
+func (r *IPSetRegistry) maxEntriesLocked(name string) int {
+	chain := ipsetChainName(strings.TrimSuffix(name, ipv6Suffix))
+	if n, ok := r.chainMaxEntries[chain]; ok && n > 0 {
+		return n
+	}
+	return r.maxEntries
+}
+
+// grow recreates the set with room for maxEntries, keeping its entries.
+func (r *IPSetRegistry) grow(name, family string, maxEntries int) error {
+	set, err := r.ObtainOrCreateFamily(name, family)
+	if err != nil {
+		return err
+	}
+	grown := *set
+	grown.MaxElem = maxEntries
+	if err := sets.ensure(&grown); err != nil {
+		return err
+	}
+	bumpSetGeneration(name)
+
+	r.mu.Lock()
+	r.sets[name] = &grown
+	r.mu.Unlock()
+	return nil
+}
+
+// growIfFull grows a set that has no room left and reports whether adding
+// again is worth a try.
+func (r *IPSetRegistry) growIfFull(set *IPSet) bool {
+	entries, maxEntries, err := sets.usage(set.Name)
+	if err != nil || maxEntries <= 0 || entries < maxEntries {
+		return false
+	}
+	if maxEntries >= maxIPSetEntries {
+		logx.Warnf("ipset %s is full (%d entries) and cannot grow further; new entries are dropped", set.Name, entries)
+		return false
+	}
+	target := min(maxEntries*2, maxIPSetEntries)
+	if err := r.grow(set.Name, set.HashFamily, target); err != nil {
+		logx.Warnf("ipset %s is full (%d entries) and could not grow: %v", set.Name, entries, err)
+		return false
+	}
+	logx.Warnf("ipset %s was full (%d entries); grown to %d", set.Name, entries, target)
+	return true
+}
+
+// SetUsage reports the fill level of every existing set owned by a rule.
+func (m *UnblockManager) SetUsage() []SetUsage {
+	targets, err := m.snapshotTargets()
+	if err != nil {
+		return nil
+	}
+	var out []SetUsage
+	for _, t := range targets {
+		if !IPSetExists(t.name) {
+			continue
+		}
+		entries, maxEntries, err := sets.usage(t.name)
+		if err != nil {
+			continue
+		}
+		out = append(out, SetUsage{
+			Name:       t.name,
+			Chain:      ipsetChainName(strings.TrimSuffix(t.name, ipv6Suffix)),
+			Entries:    entries,
+			MaxEntries: maxEntries,
+		})
+	}
+	return out
+}
+
+// CheckCapacity warns about sets filling up and doubles the ones close to
+// their limit before adds start failing.
+func (m *UnblockManager) CheckCapacity() {
+	targets, err := m.snapshotTargets()
+	if err != nil {
+		return
+	}
+	family := make(map[string]string, len(targets))
+	for _, t := range targets {
+		family[t.name] = t.family
+	}
+	for _, u := range m.SetUsage() {
+		if err := m.checkSetCapacity(u, family[u.Name]); err != nil {
+			logx.Warnf("%v", err)
+		}
+	}
+}
+
+func (m *UnblockManager) checkSetCapacity(u SetUsage, family string) error {
+	pct := u.Percent()
+	m.registry.mu.Lock()
+	warned := m.registry.capacityWarned[u.Name]
+	if pct < capacityWarnPercent {
+		delete(m.registry.capacityWarned, u.Name)
+	}
+	m.registry.mu.Unlock()
+
+	switch {
+	case pct < capacityWarnPercent:
+		return nil
+	case pct < capacityGrowPercent || u.MaxEntries >= maxIPSetEntries:
+		if warned {
+			return nil
+		}
+		m.registry.mu.Lock()
+		m.registry.capacityWarned[u.Name] = true
+		m.registry.mu.Unlock()
+		return fmt.Errorf("ipset %s is %d%% full (%d/%d)", u.Name, pct, u.Entries, u.MaxEntries)
+	}
+
+	target := min(u.MaxEntries*2, maxIPSetEntries)
+	unlock := m.registry.LockSet(u.Name)
+	defer unlock()
+	if err := m.registry.grow(u.Name, family, target); err != nil {
+		return fmt.Errorf("ipset %s is %d%% full (%d/%d) and could not grow: %w", u.Name, pct, u.Entries, u.MaxEntries, err)
+	}
+	logx.Infof("ipset %s was %d%% full (%d/%d); grown to %d", u.Name, pct, u.Entries, u.MaxEntries, target)
+	return nil
+}
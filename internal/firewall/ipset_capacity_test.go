@@ -0,0 +1,74 @@
+package firewall
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/ApostolDmitry/vpner/internal/vpnkind"
+)
+
+func TestAddRuleGrowsFullSet(t *testing.T) {
+	fake := useFakeSets(t)
+	registry := NewIPSetRegistry()
+	registry.SetMaxEntries(0, map[string]int{"a": 2})
+	mgr := NewUnblockManager(filepath.Join(t.TempDir(), "rules.yaml"), false, false, 0, registry)
+
+	for _, pattern := range []string{"10.0.0.0/16", "10.1.0.0/16", "10.2.0.0/16"} {
+		if err := mgr.AddRule(vpnkind.Xray.String(), "a", pattern); err != nil {
+			t.Fatalf("AddRule %s: %v", pattern, err)
+		}
+	}
+	if got := fake.maxElem["vpner-Xray-a"]; got != 4 {
+		t.Fatalf("maxelem = %d, want 4", got)
+	}
+	usage := mgr.SetUsage()
+	if len(usage) != 1 || usage[0].Chain != "a" || usage[0].Entries != 3 || usage[0].MaxEntries != 4 {
+		t.Fatalf("unexpected usage: %+v", usage)
+	}
+}
+
+func TestCheckCapacityGrowsNearlyFullSet(t *testing.T) {
+	fake := useFakeSets(t)
+	registry := NewIPSetRegistry()
+	registry.SetMaxEntries(10, nil)
+	mgr := NewUnblockManager(filepath.Join(t.TempDir(), "rules.yaml"), false, false, 0, registry)
+	if err := mgr.AddRule(vpnkind.Xray.String(), "a", "*.example.com"); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	if _, err := registry.ObtainOrCreateFamily("vpner-Xray-a", "inet"); err != nil {
+		t.Fatalf("ObtainOrCreateFamily: %v", err)
+	}
+
+	for i := range 8 {
+		fake.data["vpner-Xray-a"][fmt.Sprintf("192.0.2.%d", i)] = ""
+	}
+	mgr.CheckCapacity()
+	if got := fake.maxElem["vpner-Xray-a"]; got != 10 {
+		t.Fatalf("grown at 80%%: maxelem = %d", got)
+	}
+
+	fake.data["vpner-Xray-a"]["192.0.2.100"] = ""
+	mgr.CheckCapacity()
+	if got := fake.maxElem["vpner-Xray-a"]; got != 20 {
+		t.Fatalf("maxelem = %d, want 20", got)
+	}
+	if len(fake.data["vpner-Xray-a"]) != 9 {
+		t.Fatalf("entries lost while growing: %d", len(fake.data["vpner-Xray-a"]))
+	}
+}
+
+func TestParseIPSetListHeader(t *testing.T) {
+	out := []byte(`Name: vpner-Xray-a
+Type: hash:net
+Revision: 7
+Header: family inet hashsize 1024 maxelem 131072 timeout 0 comment bucketsize 12 initval 0x5a0bd8d7
+Size in memory: 1272
+References: 1
+Number of entries: 42
+`)
+	entries, maxElem := parseIPSetListHeader(out)
+	if entries != 42 || maxElem != 131072 {
+		t.Fatalf("parseIPSetListHeader = %d, %d", entries, maxElem)
+	}
+}
@@ -0,0 +1,515 @@
+package firewall
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ApostolDmitry/vpner/internal/logx"
+)
+
+const minIpsetVersion = "6.0"
+
+var (
+	ipsetPath            string
+	errIpsetNotFound     = errors.New("ipset utility not found")
+	errIpsetNotSupported = fmt.Errorf("ipset version must be >= %s", minIpsetVersion)
+)
+
+type ipsetExec struct{}
+
+func initCheck() error {
+	if ipsetPath != "" {
+		return nil
+	}
+
+	path, err := exec.LookPath("ipset")
+	if err != nil {
+		return errIpsetNotFound
+	}
+	ipsetPath = path
+
+	supported, err := getIpsetSupportedVersion()
+	if err != nil {
+		logx.Warnf("ipset: failed to detect version, assuming supported: %v", err)
+	} else if !supported {
+		return errIpsetNotSupported
+	}
+
+	return nil
+}
+
+func (ipsetExec) ready() error {
+	return initCheck()
+}
+
+func (ipsetExec) exists(name string) bool {
+	if err := initCheck(); err != nil {
+		return false
+	}
+	return exec.Command(ipsetPath, "-q", "list", name).Run() == nil
+}
+
+func (ipsetExec) ensure(set *IPSet) error {
+	return createHashSet(set, set.Name)
+}
+
+func createHashSet(s *IPSet, name string) error {
+	exists := exec.Command(ipsetPath, "-q", "list", name).Run() == nil
+	if !exists {
+		args := []string{"-exist", "create", name, s.HashType}
+		if s.HashType != "hash:mac" {
+			args = append(args, "family", s.HashFamily)
+		}
+		args = append(args,
+			"hashsize", strconv.Itoa(s.HashSize),
+			"maxelem", strconv.Itoa(s.MaxElem),
+		)
+		if s.timeouts() {
+			args = append(args, "timeout", strconv.Itoa(s.Timeout))
+		}
+		if s.WithComments {
+			args = append(args, "comment")
+		}
+		out, err := exec.Command(ipsetPath, args...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to create ipset %s: %v (%s)", name, err, out)
+		}
+		return nil
+	}
+	return ensureSetProperties(name, s)
+}
+
+func (ipsetExec) add(name, entry, comment string, timeout int) error {
+	args := []string{"add", name, entry}
+	if timeout > 0 {
+		args = append(args, "timeout", strconv.Itoa(timeout))
+	}
+	if comment != "" {
+		args = append(args, "comment", comment)
+	}
+	args = append(args, "-exist")
+	if out, err := exec.Command(ipsetPath, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to add entry %s: %v (%s)", entry, err, out)
+	}
+	return nil
+}
+
+func (e ipsetExec) addBatch(name string, entries []ipsetEntry) []error {
+	errs := make([]error, len(entries))
+	if len(entries) == 0 {
+		return errs
+	}
+	var script bytes.Buffer
+	for _, en := range entries {
+		fmt.Fprintf(&script, "add %s %s", name, en.Entry)
+		if en.Timeout > 0 {
+			fmt.Fprintf(&script, " timeout %d", en.Timeout)
+		}
+		if en.Comment != "" {
+			fmt.Fprintf(&script, " comment \"%s\"", en.Comment)
+		}
+		script.WriteByte('\n')
+	}
+	cmd := exec.Command(ipsetPath, "restore", "-exist")
+	cmd.Stdin = &script
+	if out, err := cmd.CombinedOutput(); err != nil {
+		// restore stops at the first bad line; add one by one to find out
+		// which entries were rejected.
+		logx.Debugf("ipset restore into %s failed, adding entries one by one: %v (%s)", name, err, strings.TrimSpace(string(out)))
+		for i, en := range entries {
+			errs[i] = e.add(name, en.Entry, en.Comment, en.Timeout)
+		}
+	}
+	return errs
+}
+
+func (ipsetExec) addOption(name, entry, option string, timeout int) error {
+	args := []string{
+		"add", name, entry,
+		option, "timeout", strconv.Itoa(timeout),
+		"-exist",
+	}
+	if out, err := exec.Command(ipsetPath, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to add entry %s with option %s: %v (%s)", entry, option, err, out)
+	}
+	return nil
+}
+
+func (ipsetExec) del(name, entry string) error {
+	out, err := exec.Command(ipsetPath, "del", name, entry, "-exist").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to delete entry %s: %v (%s)", entry, err, out)
+	}
+	return nil
+}
+
+func (ipsetExec) test(name, entry string) (bool, error) {
+	out, err := exec.Command(ipsetPath, "test", name, entry).CombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf("test failed for entry %s: %v (%s)", entry, err, out)
+	}
+	return !strings.Contains(string(out), "NOT"), nil
+}
+
+func (ipsetExec) list(name string) ([]ipsetEntry, error) {
+	if err := initCheck(); err != nil {
+		return nil, err
+	}
+	if err := exec.Command(ipsetPath, "-q", "list", name).Run(); err != nil {
+		return nil, nil
+	}
+	data, err := exec.Command(ipsetPath, "save", name).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect ipset %s: %v (%s)", name, err, data)
+	}
+	return extractEntriesWithComments(data, name), nil
+}
+
+func (ipsetExec) usage(name string) (int, int, error) {
+	if err := initCheck(); err != nil {
+		return 0, 0, err
+	}
+	out, err := exec.Command(ipsetPath, "list", "-t", name).CombinedOutput()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to inspect ipset %s: %v (%s)", name, err, out)
+	}
+	entries, maxElem := parseIPSetListHeader(out)
+	return entries, maxElem, nil
+}
+
+func parseIPSetListHeader(data []byte) (int, int) {
+	var entries, maxElem int
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if rest, ok := strings.CutPrefix(line, "Number of entries:"); ok {
+			entries, _ = strconv.Atoi(strings.TrimSpace(rest))
+		}
+		if strings.HasPrefix(line, "Header:") {
+			maxElem, _ = parseMaxElem(line)
+		}
+	}
+	return entries, maxElem
+}
+
+func (ipsetExec) refresh(set *IPSet, entries []string) error {
+	temp := set.Name + "-temp"
+
+	if err := createHashSet(set, temp); err != nil {
+		return err
+	}
+
+	if out, err := exec.Command(ipsetPath, "flush", temp).CombinedOutput(); err != nil {
+		logx.Warnf("ipset: failed to flush temp set %s: %v (%s)", temp, err, strings.TrimSpace(string(out)))
+	}
+
+	added := 0
+	for _, entry := range entries {
+		if out, err := exec.Command(ipsetPath, "add", temp, entry, "-exist").CombinedOutput(); err != nil {
+			logx.Warnf("ipset: failed to add %s to %s: %v (%s)", entry, temp, err, strings.TrimSpace(string(out)))
+			continue
+		}
+		added++
+	}
+
+	if len(entries) > 0 && added == 0 {
+		if err := execDestroy(temp); err != nil {
+			logx.Warnf("ipset: failed to destroy temp set %s after aborted refresh: %v", temp, err)
+		}
+		return fmt.Errorf("ipset refresh aborted for %s: all %d entries failed to load", set.Name, len(entries))
+	}
+
+	if err := execSwap(temp, set.Name); err != nil {
+		if derr := execDestroy(temp); derr != nil {
+			logx.Warnf("ipset: failed to destroy temp set %s after failed swap: %v", temp, derr)
+		}
+		return err
+	}
+
+	return execDestroy(temp)
+}
+
+func (ipsetExec) flush(name string) error {
+	out, err := exec.Command(ipsetPath, "flush", name).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to flush set %s: %v (%s)", name, err, out)
+	}
+	return nil
+}
+
+func (ipsetExec) destroy(name string) error {
+	return execDestroy(name)
+}
+
+func execDestroy(name string) error {
+	out, err := exec.Command(ipsetPath, "destroy", name).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to destroy ipset %s: %v (%s)", name, err, out)
+	}
+	return nil
+}
+
+func (ipsetExec) destroyAll() error {
+	if err := initCheck(); err != nil {
+		return err
+	}
+	out, err := exec.Command(ipsetPath, "destroy").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to destroy all ipsets: %v (%s)", err, out)
+	}
+	return nil
+}
+
+func (ipsetExec) swap(from, to string) error {
+	return execSwap(from, to)
+}
+
+func execSwap(from, to string) error {
+	out, err := exec.Command(ipsetPath, "swap", from, to).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to swap ipsets %s -> %s: %v (%s)", from, to, err, out)
+	}
+	return nil
+}
+
+func getIpsetSupportedVersion() (bool, error) {
+	vstring, err := getIpsetVersionString()
+	if err != nil {
+		return false, err
+	}
+	return compareVersions(vstring, minIpsetVersion) >= 0, nil
+}
+
+func getIpsetVersionString() (string, error) {
+	out, err := exec.Command(ipsetPath, "--version").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to get ipset version: %v (%s)", err, out)
+	}
+	versionMatcher := regexp.MustCompile(`v?(\d+\.\d+)`)
+	match := versionMatcher.FindStringSubmatch(string(out))
+	if len(match) < 2 {
+		return "", fmt.Errorf("could not parse ipset version from output: %s", out)
+	}
+	return match[1], nil
+}
+
+func compareVersions(v1, v2 string) int {
+	parts1 := strings.Split(v1, ".")
+	parts2 := strings.Split(v2, ".")
+
+	for i := 0; i < len(parts1) && i < len(parts2); i++ {
+		n1, _ := strconv.Atoi(parts1[i])
+		n2, _ := strconv.Atoi(parts2[i])
+		if n1 != n2 {
+			if n1 > n2 {
+				return 1
+			}
+			return -1
+		}
+	}
+	return len(parts1) - len(parts2)
+}
+
+func ensureSetProperties(name string, set *IPSet) error {
+	if !set.timeouts() && !set.WithComments {
+		return nil
+	}
+	data, err := exec.Command(ipsetPath, "save", name).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to inspect ipset %s: %v (%s)", name, err, data)
+	}
+	createLine, err := findCreateLine(data, name)
+	if err != nil {
+		return err
+	}
+	timeoutValue, hasTimeout := parseTimeoutValue(createLine)
+	hasComment := strings.Contains(createLine, " comment")
+	maxElem, _ := parseMaxElem(createLine)
+	needRecreate := maxElem < set.MaxElem
+	if set.timeouts() {
+		if !hasTimeout || timeoutValue != set.Timeout {
+			needRecreate = true
+		}
+	} else if hasTimeout {
+		needRecreate = true
+	}
+	if set.WithComments && !hasComment {
+		needRecreate = true
+	}
+	if !needRecreate {
+		return nil
+	}
+	logx.Infof("ipset %s missing required options; recreating", name)
+	entries := extractAddLines(data, name)
+	if !set.timeouts() {
+		for i, line := range entries {
+			entries[i] = stripTimeoutOption(line)
+		}
+	}
+	if err := recreateIPSetWithSwap(name, set, entries); err != nil {
+		return err
+	}
+	return nil
+}
+
+func findCreateLine(data []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "create ") {
+			parts := strings.Fields(line)
+			if len(parts) > 2 && parts[1] == name {
+				return line, nil
+			}
+		}
+	}
+	return "", scanner.Err()
+}
+
+func extractAddLines(data []byte, name string) []string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	var lines []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "add ") {
+			parts := strings.Fields(line)
+			if len(parts) > 1 && parts[1] == name {
+				lines = append(lines, line)
+			}
+		}
+	}
+	return lines
+}
+
+var timeoutOptionPattern = regexp.MustCompile(`\s+timeout\s+\d+`)
+var timeoutValuePattern = regexp.MustCompile(`\stimeout\s+(\d+)`)
+
+func stripTimeoutOption(line string) string {
+	return timeoutOptionPattern.ReplaceAllString(line, "")
+}
+
+var maxElemPattern = regexp.MustCompile(`\smaxelem\s+(\d+)`)
+
+func parseMaxElem(line string) (int, bool) {
+	match := maxElemPattern.FindStringSubmatch(line)
+	if len(match) < 2 {
+		return 0, false
+	}
+	val, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, false
+	}
+	return val, true
+}
+
+func parseTimeoutValue(line string) (int, bool) {
+	match := timeoutValuePattern.FindStringSubmatch(line)
+	if len(match) < 2 {
+		return 0, false
+	}
+	val, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, false
+	}
+	return val, true
+}
+
+func recreateIPSetWithSwap(name string, set *IPSet, entries []string) error {
+	temp := name + "-tmp"
+	if err := createHashSet(set, temp); err != nil {
+		return err
+	}
+
+	if len(entries) > 0 {
+		script := &bytes.Buffer{}
+		for _, line := range entries {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			line = replaceAddSetName(line, name, temp)
+			script.WriteString(line)
+			script.WriteByte('\n')
+		}
+		cmd := exec.Command(ipsetPath, "restore")
+		cmd.Stdin = script
+		if out, err := cmd.CombinedOutput(); err != nil {
+			if derr := execDestroy(temp); derr != nil {
+				logx.Warnf("ipset: failed to destroy temp set %s after failed restore: %v", temp, derr)
+			}
+			return fmt.Errorf("failed to restore ipset %s: %v (%s)", temp, err, out)
+		}
+	}
+
+	if err := execSwap(temp, name); err != nil {
+		if derr := execDestroy(temp); derr != nil {
+			logx.Warnf("ipset: failed to destroy temp set %s after failed swap: %v", temp, derr)
+		}
+		return err
+	}
+	if err := execDestroy(temp); err != nil {
+		logx.Warnf("ipset: failed to destroy temp ipset %s: %v", temp, err)
+	}
+	return nil
+}
+
+func replaceAddSetName(line, oldName, newName string) string {
+	prefix := "add " + oldName + " "
+	if strings.HasPrefix(line, prefix) {
+		return "add " + newName + " " + line[len(prefix):]
+	}
+	return line
+}
+
+func parseCommentFromLine(line string) string {
+	const key = " comment "
+	idx := strings.Index(line, key)
+	if idx == -1 {
+		return ""
+	}
+	rest := line[idx+len(key):]
+	if rest == "" {
+		return ""
+	}
+	if strings.HasPrefix(rest, "\"") {
+		rest = rest[1:]
+		end := strings.Index(rest, "\"")
+		if end == -1 {
+			return strings.TrimSpace(rest)
+		}
+		return rest[:end]
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+func extractEntriesWithComments(data []byte, name string) []ipsetEntry {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	var entries []ipsetEntry
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "add ") {
+			continue
+		}
+		parts := strings.Fields(line)
+		if len(parts) < 3 || parts[1] != name {
+			continue
+		}
+		timeout, _ := parseTimeoutValue(line)
+		entries = append(entries, ipsetEntry{
+			Entry:   parts[2],
+			Comment: parseCommentFromLine(line),
+			Timeout: timeout,
+		})
+	}
+	return entries
+}
@@ -0,0 +1,215 @@
+package firewall
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+type setMirror struct {
+	generation uint64
+	entries    map[string]string
+	// expires holds the deadline of entries added with a timeout; the kernel
+	// drops them on its own, so the mirror must too.
+	expires map[string]time.Time
+}
+
+func (m *setMirror) pruneExpired(now time.Time) {
+	for entry, deadline := range m.expires {
+		if !now.Before(deadline) {
+			delete(m.entries, entry)
+			delete(m.expires, entry)
+		}
+	}
+}
+
+func canonicalSetEntry(entry string) string {
+	entry = strings.TrimSpace(entry)
+	if strings.Contains(entry, "/") {
+		ip, ipnet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return entry
+		}
+		ones, bits := ipnet.Mask.Size()
+		if ones == bits {
+			return ip.String()
+		}
+		return ipnet.String()
+	}
+	if ip := net.ParseIP(entry); ip != nil {
+		return ip.String()
+	}
+	if mac, err := net.ParseMAC(entry); err == nil {
+		return mac.String()
+	}
+	return entry
+}
+
+func (r *IPSetRegistry) mirrorLocked(name string) (*setMirror, error) {
+	gen := setGeneration(name)
+	if m, ok := r.mirrors[name]; ok && m.generation == gen {
+		return m, nil
+	}
+	listed, err := listEntriesWithComments(name)
+	if err != nil {
+		delete(r.mirrors, name)
+		return nil, err
+	}
+	m := &setMirror{generation: gen, entries: make(map[string]string, len(listed)), expires: make(map[string]time.Time)}
+	now := time.Now()
+	for _, entry := range listed {
+		key := canonicalSetEntry(entry.Entry)
+		m.entries[key] = entry.Comment
+		if entry.Timeout > 0 {
+			m.expires[key] = now.Add(time.Duration(entry.Timeout) * time.Second)
+		}
+	}
+	r.mirrors[name] = m
+	return m, nil
+}
+
+func (r *IPSetRegistry) entries(name string) ([]ipsetEntry, error) {
+	r.mirrorMu.Lock()
+	defer r.mirrorMu.Unlock()
+
+	m, err := r.mirrorLocked(name)
+	if err != nil {
+		return nil, err
+	}
+	m.pruneExpired(time.Now())
+	out := make([]ipsetEntry, 0, len(m.entries))
+	for entry, comment := range m.entries {
+		out = append(out, ipsetEntry{Entry: entry, Comment: comment})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Entry < out[j].Entry })
+	return out, nil
+}
+
+func (r *IPSetRegistry) entriesByCommentPrefix(name, prefix string) ([]string, error) {
+	if prefix == "" {
+		return nil, nil
+	}
+	entries, err := r.entries(name)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Comment, prefix) {
+			out = append(out, entry.Entry)
+		}
+	}
+	return out, nil
+}
+
+func (r *IPSetRegistry) addEntry(set *IPSet, entry, comment string, timeout int) error {
+	err := set.AddComment(entry, comment, timeout)
+	if err != nil && r.growIfFull(set) {
+		err = set.AddComment(entry, comment, timeout)
+	}
+
+	if err != nil {
+		r.mirrorMu.Lock()
+		delete(r.mirrors, set.Name)
+		r.mirrorMu.Unlock()
+		return err
+	}
+	r.mirrorAdded(set.Name, []ipsetEntry{{Entry: entry, Comment: comment, Timeout: timeout}})
+	return nil
+}
+
+// addEntries adds entries to set in batches, growing the set and retrying
+// the rejected entries while it is full. It returns how many entries were
+// added and the error of each one that was not.
+func (r *IPSetRegistry) addEntries(set *IPSet, entries []ipsetEntry) (int, []error) {
+	var (
+		added []ipsetEntry
+		errs  []error
+	)
+	for {
+		var failed []ipsetEntry
+		errs = errs[:0]
+		for i, err := range sets.addBatch(set.Name, entries) {
+			if err != nil {
+				failed = append(failed, entries[i])
+				errs = append(errs, err)
+				continue
+			}
+			added = append(added, entries[i])
+		}
+		if len(failed) == 0 || !r.growIfFull(set) {
+			break
+		}
+		entries = failed
+	}
+	r.mirrorAdded(set.Name, added)
+	return len(added), errs
+}
+
+// mirrorAdded records entries added to name in its mirror.
+func (r *IPSetRegistry) mirrorAdded(name string, entries []ipsetEntry) {
+	r.mirrorMu.Lock()
+	defer r.mirrorMu.Unlock()
+	m, ok := r.mirrors[name]
+	if !ok || m.generation != setGeneration(name) {
+		return
+	}
+	now := time.Now()
+	for _, e := range entries {
+		key := canonicalSetEntry(e.Entry)
+		m.entries[key] = e.Comment
+		if e.Timeout > 0 {
+			m.expires[key] = now.Add(time.Duration(e.Timeout) * time.Second)
+		} else {
+			delete(m.expires, key)
+		}
+	}
+}
+
+// expiries returns the known deadlines of the entries of name that were added
+// with a timeout.
+func (r *IPSetRegistry) expiries(name string) map[string]time.Time {
+	r.mirrorMu.Lock()
+	defer r.mirrorMu.Unlock()
+
+	m, ok := r.mirrors[name]
+	if !ok || m.generation != setGeneration(name) {
+		return nil
+	}
+	out := make(map[string]time.Time, len(m.expires))
+	for entry, deadline := range m.expires {
+		out[entry] = deadline
+	}
+	return out
+}
+
+func (r *IPSetRegistry) delEntry(set *IPSet, entry string) error {
+	err := set.Del(entry)
+	r.forgetEntries(set.Name, []string{entry}, err)
+	return err
+}
+
+func (r *IPSetRegistry) removeEntries(name string, entries []string) error {
+	err := removeEntries(name, entries)
+	r.forgetEntries(name, entries, err)
+	return err
+}
+
+func (r *IPSetRegistry) forgetEntries(name string, entries []string, err error) {
+	r.mirrorMu.Lock()
+	defer r.mirrorMu.Unlock()
+	if err != nil {
+		delete(r.mirrors, name)
+		return
+	}
+	m, ok := r.mirrors[name]
+	if !ok {
+		return
+	}
+	for _, entry := range entries {
+		key := canonicalSetEntry(entry)
+		delete(m.entries, key)
+		delete(m.expires, key)
+	}
+}
@@ -0,0 +1,142 @@
+package firewall
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type fakeSets struct {
+	data    map[string]map[string]string
+	maxElem map[string]int
+	lists   int
+	batches int
+	reject  map[string]bool
+}
+
+func newFakeSets() *fakeSets {
+	return &fakeSets{data: make(map[string]map[string]string), maxElem: make(map[string]int)}
+}
+
+func (f *fakeSets) ready() error            { return nil }
+func (f *fakeSets) exists(name string) bool { _, ok := f.data[name]; return ok }
+func (f *fakeSets) ensure(set *IPSet) error {
+	if _, ok := f.data[set.Name]; !ok {
+		f.data[set.Name] = make(map[string]string)
+	}
+	f.maxElem[set.Name] = max(f.maxElem[set.Name], set.MaxElem)
+	return nil
+}
+func (f *fakeSets) add(name, entry, comment string, _ int) error {
+	if f.reject[entry] {
+		return fmt.Errorf("failed to add entry %s: rejected", entry)
+	}
+	if _, ok := f.data[name][entry]; !ok && f.maxElem[name] > 0 && len(f.data[name]) >= f.maxElem[name] {
+		return errors.New("set is full")
+	}
+	f.data[name][entry] = comment
+	return nil
+}
+func (f *fakeSets) addBatch(name string, entries []ipsetEntry) []error {
+	f.batches++
+	errs := make([]error, len(entries))
+	for i, e := range entries {
+		errs[i] = f.add(name, e.Entry, e.Comment, e.Timeout)
+	}
+	return errs
+}
+func (f *fakeSets) addOption(string, string, string, int) error { return nil }
+func (f *fakeSets) del(name, entry string) error {
+	delete(f.data[name], entry)
+	return nil
+}
+func (f *fakeSets) test(name, entry string) (bool, error) {
+	_, ok := f.data[name][entry]
+	return ok, nil
+}
+func (f *fakeSets) list(name string) ([]ipsetEntry, error) {
+	f.lists++
+	var out []ipsetEntry
+	for entry, comment := range f.data[name] {
+		out = append(out, ipsetEntry{Entry: entry, Comment: comment})
+	}
+	return out, nil
+}
+func (f *fakeSets) usage(name string) (int, int, error) {
+	return len(f.data[name]), f.maxElem[name], nil
+}
+func (f *fakeSets) refresh(*IPSet, []string) error { return nil }
+func (f *fakeSets) flush(name string) error {
+	f.data[name] = make(map[string]string)
+	return nil
+}
+func (f *fakeSets) destroy(name string) error { delete(f.data, name); return nil }
+func (f *fakeSets) destroyAll() error         { f.data = make(map[string]map[string]string); return nil }
+func (f *fakeSets) swap(string, string) error { return nil }
+
+func useFakeSets(t *testing.T) *fakeSets {
+	t.Helper()
+	fake := newFakeSets()
+	prev := sets
+	sets = fake
+	t.Cleanup(func() { sets = prev })
+	return fake
+}
+
+func TestCanonicalSetEntry(t *testing.T) {
+	cases := map[string]string{
+		"1.2.3.4":          "1.2.3.4",
+		"1.2.3.4/32":       "1.2.3.4",
+		"10.1.2.3/8":       "10.0.0.0/8",
+		"2001:DB8::1/128":  "2001:db8::1",
+		"2001:db8::/32":    "2001:db8::/32",
+		"1.1.1.1-1.1.1.10": "1.1.1.1-1.1.1.10",
+	}
+	for in, want := range cases {
+		if got := canonicalSetEntry(in); got != want {
+			t.Fatalf("canonicalSetEntry(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRegistryMirrorAvoidsRelisting(t *testing.T) {
+	fake := useFakeSets(t)
+	r := NewIPSetRegistry()
+	set, err := r.ObtainOrCreateFamily("vpner-Xray-mirror", "inet")
+	if err != nil {
+		t.Fatalf("obtain: %v", err)
+	}
+	fake.data[set.Name]["9.9.9.9"] = "vpner|rule=a|domain=a"
+
+	if _, err := r.entries(set.Name); err != nil {
+		t.Fatalf("entries: %v", err)
+	}
+	if err := r.addEntry(set, "1.1.1.1", "vpner|rule=b|domain=b", 0); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := r.delEntry(set, "9.9.9.9"); err != nil {
+		t.Fatalf("del: %v", err)
+	}
+
+	entries, err := r.entries(set.Name)
+	if err != nil {
+		t.Fatalf("entries: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Entry != "1.1.1.1" || entries[0].Comment != "vpner|rule=b|domain=b" {
+		t.Fatalf("mirror = %v", entries)
+	}
+	if fake.lists != 1 {
+		t.Fatalf("expected a single list call, got %d", fake.lists)
+	}
+
+	if err := set.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	entries, err = r.entries(set.Name)
+	if err != nil {
+		t.Fatalf("entries: %v", err)
+	}
+	if len(entries) != 0 || fake.lists != 2 {
+		t.Fatalf("expected reload after flush, got %v (lists=%d)", entries, fake.lists)
+	}
+}
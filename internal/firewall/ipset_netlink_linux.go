@@ -0,0 +1,573 @@
+//go:build linux
+
+package firewall
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+
+	"github.com/ApostolDmitry/vpner/internal/logx"
+)
+
+const (
+	ipsetFlagListHeader = 1 << 2
+
+	// ipsetBatchBytes caps one sendmsg of an add batch, so the kernel's acks
+	// for it always fit in the default socket receive buffer.
+	ipsetBatchBytes = 32 << 10
+	ipsetAckTimeout = 5 * time.Second
+)
+
+type ipsetNetlink struct {
+	fallback ipsetExec
+}
+
+type ipsetHeader struct {
+	hasTimeout bool
+	timeout    int
+	comments   bool
+	maxElem    int
+	elements   int
+}
+
+func newNetlinkSets() (setBackend, error) {
+	if _, _, err := netlink.IpsetProtocol(); err != nil {
+		return nil, fmt.Errorf("ipset netlink unavailable: %w", err)
+	}
+	return ipsetNetlink{}, nil
+}
+
+func ipsetRequest(cmd int, flags int) *nl.NetlinkRequest {
+	req := nl.NewNetlinkRequest(cmd|(unix.NFNL_SUBSYS_IPSET<<8), flags)
+	req.AddData(&nl.Nfgenmsg{NfgenFamily: uint8(unix.AF_NETLINK), Version: nl.NFNETLINK_V0})
+	req.AddData(nl.NewRtAttr(nl.IPSET_ATTR_PROTOCOL, nl.Uint8Attr(nl.IPSET_PROTOCOL)))
+	return req
+}
+
+func ipsetExecute(req *nl.NetlinkRequest) ([][]byte, error) {
+	msgs, err := req.Execute(unix.NETLINK_NETFILTER, 0)
+	return msgs, ipsetErrno(err)
+}
+
+func ipsetErrno(err error) error {
+	if errno, ok := err.(syscall.Errno); ok && int(errno) >= nl.IPSET_ERR_PRIVATE {
+		return nl.IPSetError(uintptr(errno))
+	}
+	return err
+}
+
+func (ipsetNetlink) ready() error {
+	return nil
+}
+
+func (ipsetNetlink) exists(name string) bool {
+	req := ipsetRequest(nl.IPSET_CMD_HEADER, nl.GetIpsetFlags(nl.IPSET_CMD_HEADER))
+	req.AddData(nl.NewRtAttr(nl.IPSET_ATTR_SETNAME, nl.ZeroTerminated(name)))
+	_, err := ipsetExecute(req)
+	return err == nil
+}
+
+func (n ipsetNetlink) ensure(set *IPSet) error {
+	if !n.exists(set.Name) {
+		return netlinkCreate(set, set.Name)
+	}
+	if !set.timeouts() && !set.WithComments {
+		return nil
+	}
+	hdr, err := netlinkHeader(set.Name)
+	if err != nil {
+		return err
+	}
+	needRecreate := hdr.hasTimeout != set.timeouts() || hdr.timeout != set.Timeout || hdr.maxElem < set.MaxElem
+	if set.WithComments && !hdr.comments {
+		needRecreate = true
+	}
+	if !needRecreate {
+		return nil
+	}
+	logx.Infof("ipset %s missing required options; recreating", set.Name)
+	entries, err := n.list(set.Name)
+	if err != nil {
+		return err
+	}
+	return netlinkReplace(set, entries)
+}
+
+func netlinkCreate(set *IPSet, name string) error {
+	opts := netlink.IpsetCreateOptions{
+		Replace:     true,
+		Comments:    set.WithComments,
+		Family:      unix.AF_INET,
+		MaxElements: uint32(set.MaxElem),
+	}
+	if set.HashFamily == "inet6" {
+		opts.Family = unix.AF_INET6
+	}
+	if set.timeouts() {
+		timeout := uint32(set.Timeout)
+		opts.Timeout = &timeout
+	}
+	if err := netlink.IpsetCreate(name, set.HashType, opts); err != nil {
+		return fmt.Errorf("failed to create ipset %s: %w", name, err)
+	}
+	return nil
+}
+
+func netlinkReplace(set *IPSet, entries []ipsetEntry) error {
+	temp := set.Name + "-tmp"
+	if err := netlink.IpsetDestroy(temp); err != nil && !isNetlinkMissing(err) {
+		logx.Warnf("ipset: failed to destroy stale temp set %s: %v", temp, err)
+	}
+	if err := netlinkCreate(set, temp); err != nil {
+		return err
+	}
+	added := 0
+	for _, entry := range entries {
+		if err := netlinkAdd(temp, entry.Entry, entry.Comment, entry.Timeout); err != nil {
+			logx.Warnf("ipset: failed to add %s to %s: %v", entry.Entry, temp, err)
+			continue
+		}
+		added++
+	}
+	if len(entries) > 0 && added == 0 {
+		_ = netlink.IpsetDestroy(temp)
+		return fmt.Errorf("ipset refresh aborted for %s: all %d entries failed to load", set.Name, len(entries))
+	}
+	if err := netlink.IpsetSwap(temp, set.Name); err != nil {
+		_ = netlink.IpsetDestroy(temp)
+		return fmt.Errorf("failed to swap ipsets %s -> %s: %w", temp, set.Name, err)
+	}
+	if err := netlink.IpsetDestroy(temp); err != nil {
+		logx.Warnf("ipset: failed to destroy temp ipset %s: %v", temp, err)
+	}
+	return nil
+}
+
+func netlinkEntry(entry string) (*netlink.IPSetEntry, bool) {
+	entry = strings.TrimSpace(entry)
+	if mac, err := net.ParseMAC(entry); err == nil {
+		return &netlink.IPSetEntry{MAC: mac, Replace: true}, true
+	}
+	if ip := net.ParseIP(entry); ip != nil {
+		return &netlink.IPSetEntry{IP: ip, Replace: true}, true
+	}
+	ip, ipnet, err := net.ParseCIDR(entry)
+	if err != nil {
+		return nil, false
+	}
+	ones, _ := ipnet.Mask.Size()
+	return &netlink.IPSetEntry{IP: ip.Mask(ipnet.Mask), CIDR: uint8(ones), Replace: true}, true
+}
+
+func netlinkAdd(name, entry, comment string, timeout int) error {
+	e, ok := netlinkEntry(entry)
+	if !ok {
+		return fmt.Errorf("unsupported ipset entry %q", entry)
+	}
+	e.Comment = comment
+	if timeout > 0 {
+		t := uint32(timeout)
+		e.Timeout = &t
+	}
+	return netlink.IpsetAdd(name, e)
+}
+
+func (n ipsetNetlink) add(name, entry, comment string, timeout int) error {
+	if _, ok := netlinkEntry(entry); !ok {
+		if err := initCheck(); err != nil {
+			return fmt.Errorf("failed to add entry %s: %w", entry, err)
+		}
+		return n.fallback.add(name, entry, comment, timeout)
+	}
+	if err := netlinkAdd(name, entry, comment, timeout); err != nil {
+		return fmt.Errorf("failed to add entry %s: %w", entry, err)
+	}
+	return nil
+}
+
+func (n ipsetNetlink) addBatch(name string, entries []ipsetEntry) []error {
+	errs := make([]error, len(entries))
+	var (
+		batch []ipsetEntry
+		index []int
+	)
+	for i, e := range entries {
+		if _, ok := netlinkEntry(e.Entry); ok {
+			batch = append(batch, e)
+			index = append(index, i)
+			continue
+		}
+		errs[i] = n.add(name, e.Entry, e.Comment, e.Timeout)
+	}
+	for j, err := range netlinkAddBatch(name, batch) {
+		if err != nil {
+			errs[index[j]] = fmt.Errorf("failed to add entry %s: %w", batch[j].Entry, err)
+		}
+	}
+	return errs
+}
+
+// netlinkAddBatch sends an add message per entry over one socket, packing
+// as many messages into each sendmsg as ipsetBatchBytes allows, and returns
+// the result of each entry from the kernel's acks. Every entry must be
+// accepted by netlinkEntry.
+func netlinkAddBatch(name string, entries []ipsetEntry) []error {
+	errs := make([]error, len(entries))
+	if len(entries) == 0 {
+		return errs
+	}
+	sock, err := nl.Subscribe(unix.NETLINK_NETFILTER)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+	defer sock.Close()
+	timeout := unix.NsecToTimeval(ipsetAckTimeout.Nanoseconds())
+	if err := sock.SetReceiveTimeout(&timeout); err != nil {
+		logx.Debugf("ipset batch: receive timeout: %v", err)
+	}
+
+	var (
+		buf     []byte
+		pending = make(map[uint32]int)
+	)
+	send := func() {
+		defer func() {
+			// Entries without an ack failed with the socket.
+			for seq, i := range pending {
+				if errs[i] == nil {
+					errs[i] = errors.New("no ack from the kernel")
+				}
+				delete(pending, seq)
+			}
+			buf = buf[:0]
+		}()
+		if err := unix.Sendto(sock.GetFd(), buf, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+			for _, i := range pending {
+				errs[i] = err
+			}
+			return
+		}
+		for len(pending) > 0 {
+			msgs, _, err := sock.Receive()
+			if err != nil {
+				for _, i := range pending {
+					errs[i] = err
+				}
+				return
+			}
+			for _, m := range msgs {
+				i, ok := pending[m.Header.Seq]
+				if !ok || m.Header.Type != unix.NLMSG_ERROR {
+					continue
+				}
+				delete(pending, m.Header.Seq)
+				if len(m.Data) < 4 {
+					errs[i] = errors.New("short netlink ack")
+					continue
+				}
+				if errno := -int32(nl.NativeEndian().Uint32(m.Data[:4])); errno != 0 {
+					errs[i] = ipsetErrno(syscall.Errno(errno))
+				}
+			}
+		}
+	}
+	for i, e := range entries {
+		req, err := netlinkAddRequest(name, e)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		msg := req.Serialize()
+		if len(buf) > 0 && len(buf)+len(msg) > ipsetBatchBytes {
+			send()
+		}
+		buf = append(buf, msg...)
+		pending[req.Seq] = i
+	}
+	if len(buf) > 0 {
+		send()
+	}
+	return errs
+}
+
+// netlinkAddRequest builds the add message "ipset add -exist" would send.
+func netlinkAddRequest(name string, e ipsetEntry) (*nl.NetlinkRequest, error) {
+	entry, ok := netlinkEntry(e.Entry)
+	if !ok {
+		return nil, fmt.Errorf("unsupported ipset entry %q", e.Entry)
+	}
+	req := ipsetRequest(nl.IPSET_CMD_ADD, nl.GetIpsetFlags(nl.IPSET_CMD_ADD))
+	req.AddData(nl.NewRtAttr(nl.IPSET_ATTR_SETNAME, nl.ZeroTerminated(name)))
+	data := nl.NewRtAttr(nl.IPSET_ATTR_DATA|int(nl.NLA_F_NESTED), nil)
+	if e.Comment != "" {
+		data.AddChild(nl.NewRtAttr(nl.IPSET_ATTR_COMMENT, nl.ZeroTerminated(e.Comment)))
+	}
+	if e.Timeout > 0 {
+		data.AddChild(&nl.Uint32Attribute{Type: nl.IPSET_ATTR_TIMEOUT | nl.NLA_F_NET_BYTEORDER, Value: uint32(e.Timeout)})
+	}
+	if entry.IP != nil {
+		typ, ip := nl.IPSET_ATTR_IPADDR_IPV6, entry.IP
+		if v4 := ip.To4(); v4 != nil {
+			typ, ip = nl.IPSET_ATTR_IPADDR_IPV4, v4
+		}
+		addr := nl.NewRtAttr(typ|int(nl.NLA_F_NET_BYTEORDER), ip)
+		data.AddChild(nl.NewRtAttr(nl.IPSET_ATTR_IP|int(nl.NLA_F_NESTED), addr.Serialize()))
+	}
+	if entry.MAC != nil {
+		data.AddChild(nl.NewRtAttr(nl.IPSET_ATTR_ETHER, entry.MAC))
+	}
+	if entry.CIDR != 0 {
+		data.AddChild(nl.NewRtAttr(nl.IPSET_ATTR_CIDR, nl.Uint8Attr(entry.CIDR)))
+	}
+	data.AddChild(&nl.Uint32Attribute{Type: nl.IPSET_ATTR_LINENO | nl.NLA_F_NET_BYTEORDER, Value: 0})
+	req.AddData(data)
+	return req, nil
+}
+
+func (n ipsetNetlink) addOption(name, entry, option string, timeout int) error {
+	if err := initCheck(); err != nil {
+		return fmt.Errorf("failed to add entry %s with option %s: %w", entry, option, err)
+	}
+	return n.fallback.addOption(name, entry, option, timeout)
+}
+
+func (n ipsetNetlink) del(name, entry string) error {
+	e, ok := netlinkEntry(entry)
+	if !ok {
+		if err := initCheck(); err != nil {
+			return fmt.Errorf("failed to delete entry %s: %w", entry, err)
+		}
+		return n.fallback.del(name, entry)
+	}
+	if err := netlink.IpsetDel(name, e); err != nil && !errors.Is(err, nl.IPSetError(nl.IPSET_ERR_EXIST)) {
+		return fmt.Errorf("failed to delete entry %s: %w", entry, err)
+	}
+	return nil
+}
+
+func (n ipsetNetlink) test(name, entry string) (bool, error) {
+	e, ok := netlinkEntry(entry)
+	if !ok {
+		if err := initCheck(); err != nil {
+			return false, fmt.Errorf("test failed for entry %s: %w", entry, err)
+		}
+		return n.fallback.test(name, entry)
+	}
+	e.Replace = false
+	found, err := netlink.IpsetTest(name, e)
+	if err != nil {
+		return false, fmt.Errorf("test failed for entry %s: %w", entry, err)
+	}
+	return found, nil
+}
+
+func (ipsetNetlink) list(name string) ([]ipsetEntry, error) {
+	msgs, err := netlinkDump(name, false)
+	if err != nil {
+		if isNetlinkMissing(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to inspect ipset %s: %w", name, err)
+	}
+	var entries []ipsetEntry
+	for _, msg := range msgs {
+		entries = append(entries, parseIPSetDumpEntries(msg)...)
+	}
+	return entries, nil
+}
+
+func netlinkDump(name string, headerOnly bool) ([][]byte, error) {
+	req := ipsetRequest(nl.IPSET_CMD_LIST, nl.GetIpsetFlags(nl.IPSET_CMD_LIST))
+	req.AddData(nl.NewRtAttr(nl.IPSET_ATTR_SETNAME, nl.ZeroTerminated(name)))
+	if headerOnly {
+		req.AddData(&nl.Uint32Attribute{Type: nl.IPSET_ATTR_FLAGS | nl.NLA_F_NET_BYTEORDER, Value: ipsetFlagListHeader})
+	}
+	return ipsetExecute(req)
+}
+
+func netlinkHeader(name string) (ipsetHeader, error) {
+	msgs, err := netlinkDump(name, true)
+	if err != nil {
+		return ipsetHeader{}, fmt.Errorf("failed to inspect ipset %s: %w", name, err)
+	}
+	var hdr ipsetHeader
+	for _, msg := range msgs {
+		if len(msg) < 4 {
+			continue
+		}
+		attrs, err := nl.ParseRouteAttr(msg[4:])
+		if err != nil {
+			continue
+		}
+		for _, attr := range attrs {
+			if attr.Attr.Type&nl.NLA_TYPE_MASK != nl.IPSET_ATTR_DATA {
+				continue
+			}
+			data, err := nl.ParseRouteAttr(attr.Value)
+			if err != nil {
+				continue
+			}
+			for _, d := range data {
+				switch d.Attr.Type & nl.NLA_TYPE_MASK {
+				case nl.IPSET_ATTR_TIMEOUT:
+					hdr.hasTimeout = true
+					hdr.timeout = int(beUint32(d.Value))
+				case nl.IPSET_ATTR_CADT_FLAGS:
+					hdr.comments = beUint32(d.Value)&nl.IPSET_FLAG_WITH_COMMENT != 0
+				case nl.IPSET_ATTR_MAXELEM:
+					hdr.maxElem = int(beUint32(d.Value))
+				case nl.IPSET_ATTR_ELEMENTS:
+					hdr.elements = int(beUint32(d.Value))
+				}
+			}
+		}
+	}
+	return hdr, nil
+}
+
+func parseIPSetDumpEntries(msg []byte) []ipsetEntry {
+	if len(msg) < 4 {
+		return nil
+	}
+	attrs, err := nl.ParseRouteAttr(msg[4:])
+	if err != nil {
+		return nil
+	}
+	var entries []ipsetEntry
+	for _, attr := range attrs {
+		if attr.Attr.Type&nl.NLA_TYPE_MASK != nl.IPSET_ATTR_ADT {
+			continue
+		}
+		items, err := nl.ParseRouteAttr(attr.Value)
+		if err != nil {
+			continue
+		}
+		for _, item := range items {
+			if entry, ok := parseIPSetDumpEntry(item.Value); ok {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return entries
+}
+
+func parseIPSetDumpEntry(data []byte) (ipsetEntry, bool) {
+	attrs, err := nl.ParseRouteAttr(data)
+	if err != nil {
+		return ipsetEntry{}, false
+	}
+	var (
+		ip      net.IP
+		mac     net.HardwareAddr
+		cidr    int
+		comment string
+		timeout int
+	)
+	for _, attr := range attrs {
+		switch attr.Attr.Type & nl.NLA_TYPE_MASK {
+		case nl.IPSET_ATTR_IP:
+			nested, err := nl.ParseRouteAttr(attr.Value)
+			if err != nil || len(nested) == 0 {
+				continue
+			}
+			ip = net.IP(append([]byte(nil), nested[0].Value...))
+		case nl.IPSET_ATTR_ETHER:
+			mac = net.HardwareAddr(append([]byte(nil), attr.Value...))
+		case nl.IPSET_ATTR_CIDR:
+			if len(attr.Value) > 0 {
+				cidr = int(attr.Value[0])
+			}
+		case nl.IPSET_ATTR_COMMENT:
+			comment = nl.BytesToString(attr.Value)
+		case nl.IPSET_ATTR_TIMEOUT:
+			timeout = int(beUint32(attr.Value))
+		}
+	}
+	if mac != nil {
+		return ipsetEntry{Entry: mac.String(), Comment: comment, Timeout: timeout}, true
+	}
+	if ip == nil {
+		return ipsetEntry{}, false
+	}
+	return ipsetEntry{Entry: formatSetEntry(ip, cidr), Comment: comment, Timeout: timeout}, true
+}
+
+func formatSetEntry(ip net.IP, cidr int) string {
+	bits := 128
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+		bits = 32
+	}
+	if cidr == 0 || cidr == bits {
+		return ip.String()
+	}
+	return ip.String() + "/" + strconv.Itoa(cidr)
+}
+
+func beUint32(b []byte) uint32 {
+	if len(b) < 4 {
+		return 0
+	}
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+func (n ipsetNetlink) usage(name string) (int, int, error) {
+	hdr, err := netlinkHeader(name)
+	if err != nil {
+		return n.fallback.usage(name)
+	}
+	return hdr.elements, hdr.maxElem, nil
+}
+
+func (ipsetNetlink) refresh(set *IPSet, entries []string) error {
+	listed := make([]ipsetEntry, 0, len(entries))
+	for _, entry := range entries {
+		listed = append(listed, ipsetEntry{Entry: entry})
+	}
+	return netlinkReplace(set, listed)
+}
+
+func (ipsetNetlink) flush(name string) error {
+	if err := netlink.IpsetFlush(name); err != nil {
+		return fmt.Errorf("failed to flush set %s: %w", name, err)
+	}
+	return nil
+}
+
+func (ipsetNetlink) destroy(name string) error {
+	if err := netlink.IpsetDestroy(name); err != nil {
+		return fmt.Errorf("failed to destroy ipset %s: %w", name, err)
+	}
+	return nil
+}
+
+func (ipsetNetlink) destroyAll() error {
+	req := ipsetRequest(nl.IPSET_CMD_DESTROY, nl.GetIpsetFlags(nl.IPSET_CMD_DESTROY))
+	if _, err := ipsetExecute(req); err != nil {
+		return fmt.Errorf("failed to destroy all ipsets: %w", err)
+	}
+	return nil
+}
+
+func (ipsetNetlink) swap(from, to string) error {
+	if err := netlink.IpsetSwap(from, to); err != nil {
+		return fmt.Errorf("failed to swap ipsets %s -> %s: %w", from, to, err)
+	}
+	return nil
+}
+
+func isNetlinkMissing(err error) bool {
+	return errors.Is(err, unix.ENOENT)
+}
@@ -0,0 +1,46 @@
+package firewall
+
+import (
+	"testing"
+
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+func TestNetlinkAddRequestRoundTrip(t *testing.T) {
+	for _, want := range []ipsetEntry{
+		{Entry: "192.0.2.7", Comment: "vpner|rule=a|domain=example.com", Timeout: 300},
+		{Entry: "10.0.0.0/8"},
+		{Entry: "2001:db8::/32", Comment: "c"},
+		{Entry: "aa:bb:cc:dd:ee:ff"},
+	} {
+		req, err := netlinkAddRequest("vpner-Xray-a", want)
+		if err != nil {
+			t.Fatalf("%s: %v", want.Entry, err)
+		}
+		if req.Flags&unix.NLM_F_ACK == 0 || req.Flags&unix.NLM_F_EXCL != 0 {
+			t.Fatalf("%s: flags %#x, want an ack and no EXCL", want.Entry, req.Flags)
+		}
+		msg := req.Serialize()
+		attrs, err := nl.ParseRouteAttr(msg[unix.NLMSG_HDRLEN+4:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got ipsetEntry
+		var name string
+		for _, attr := range attrs {
+			switch attr.Attr.Type & nl.NLA_TYPE_MASK {
+			case nl.IPSET_ATTR_SETNAME:
+				name = nl.BytesToString(attr.Value)
+			case nl.IPSET_ATTR_DATA:
+				got, _ = parseIPSetDumpEntry(attr.Value)
+			}
+		}
+		if name != "vpner-Xray-a" || got != want {
+			t.Fatalf("round trip: set %q entry %+v, want %+v", name, got, want)
+		}
+	}
+	if _, err := netlinkAddRequest("vpner-Xray-a", ipsetEntry{Entry: "192.0.2.1-192.0.2.9"}); err == nil {
+		t.Fatal("range entry encoded for netlink")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package firewall
+
+import "fmt"
+
+func newNetlinkSets() (setBackend, error) {
+	return nil, fmt.Errorf("ipset netlink is only supported on linux")
+}
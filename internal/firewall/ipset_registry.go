@@ -1,6 +1,11 @@
 package firewall
 
-import "sync"
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
 
 type IPSetRegistry struct {
 	mu   sync.Mutex
@@ -11,6 +16,14 @@ type IPSetRegistry struct {
 
 	opMu    sync.Mutex
 	opLocks map[string]*sync.Mutex
+
+	mirrorMu sync.Mutex
+	mirrors  map[string]*setMirror
+
+	withTimeouts    bool
+	maxEntries      int
+	chainMaxEntries map[string]int
+	capacityWarned  map[string]bool
 }
 
 func NewIPSetRegistry() *IPSetRegistry {
@@ -18,6 +31,9 @@ func NewIPSetRegistry() *IPSetRegistry {
 		sets:        make(map[string]*IPSet),
 		staleCounts: make(map[string]map[string]int),
 		opLocks:     make(map[string]*sync.Mutex),
+		mirrors:     make(map[string]*setMirror),
+
+		capacityWarned: make(map[string]bool),
 	}
 }
 
@@ -29,7 +45,7 @@ func (r *IPSetRegistry) ObtainOrCreateFamily(name, family string) (*IPSet, error
 		return set, nil
 	}
 
-	params := &Params{Timeout: DefaultIPSetTimeout, WithComments: true, HashFamily: family}
+	params := &Params{Timeout: DefaultIPSetTimeout, WithComments: true, WithTimeouts: r.withTimeouts, HashFamily: family, MaxElem: r.maxEntriesLocked(name)}
 	set, err := NewIPset(name, "hash:net", params)
 	if err != nil {
 		return nil, err
@@ -38,6 +54,12 @@ func (r *IPSetRegistry) ObtainOrCreateFamily(name, family string) (*IPSet, error
 	return set, nil
 }
 
+func (r *IPSetRegistry) setEntryTimeouts(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.withTimeouts = enabled
+}
+
 func (r *IPSetRegistry) LockSet(name string) func() {
 	r.opMu.Lock()
 	lock, ok := r.opLocks[name]
@@ -133,3 +155,33 @@ func (r *IPSetRegistry) ConfirmStaleDeleted(key string, entries []string) {
 func hasKeyPrefix(key, prefix string) bool {
 	return len(key) >= len(prefix) && key[:len(prefix)] == prefix
 }
+
+func (r *IPSetRegistry) staleCountsFor(names []string) map[string]map[string]int {
+	r.staleMu.Lock()
+	defer r.staleMu.Unlock()
+
+	out := make(map[string]map[string]int)
+	for key, counts := range r.staleCounts {
+		name, _, _ := strings.Cut(key, "|")
+		if !slices.Contains(names, name) || len(counts) == 0 {
+			continue
+		}
+		out[key] = maps.Clone(counts)
+	}
+	return out
+}
+
+func (r *IPSetRegistry) restoreStaleCounts(ipsetName string, saved map[string]map[string]int, rules []string) {
+	r.staleMu.Lock()
+	defer r.staleMu.Unlock()
+
+	for key, counts := range saved {
+		name, comment, _ := strings.Cut(key, "|")
+		if name != ipsetName || !snapshotEntryLive(comment, rules) {
+			continue
+		}
+		if _, ok := r.staleCounts[key]; !ok {
+			r.staleCounts[key] = maps.Clone(counts)
+		}
+	}
+}
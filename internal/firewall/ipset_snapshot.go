@@ -0,0 +1,219 @@
+package firewall
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ApostolDmitry/vpner/internal/logx"
+	"github.com/ApostolDmitry/vpner/internal/matcher"
+)
+
+const ipsetSnapshotVersion = 1
+
+type ipsetSnapshot struct {
+	Version int                       `json:"version"`
+	Sets    map[string]snapshotSet    `json:"sets"`
+	Stale   map[string]map[string]int `json:"stale,omitempty"`
+}
+
+type snapshotSet struct {
+	Family  string          `json:"family"`
+	Entries []snapshotEntry `json:"entries"`
+}
+
+type snapshotEntry struct {
+	Entry   string `json:"entry"`
+	Comment string `json:"comment,omitempty"`
+	Expires int64  `json:"expires,omitempty"`
+}
+
+type snapshotTarget struct {
+	name   string
+	family string
+	rules  []string
+}
+
+// snapshotTargets lists the sets owned by the current rules together with the
+// domain rules of their chain.
+func (m *UnblockManager) snapshotTargets() ([]snapshotTarget, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []snapshotTarget
+	for vpnType, set := range m.cachedConf.Rules {
+		for chain, patterns := range set {
+			var rules []string
+			for _, pattern := range patterns {
+				if !isStaticPattern(pattern) {
+					rules = append(rules, pattern)
+				}
+			}
+			name, err := IpsetName(vpnType, chain)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, snapshotTarget{name: name, family: "inet", rules: rules})
+			if m.ipv6Enabled {
+				name6, err := IpsetName6FromBase(name)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, snapshotTarget{name: name6, family: "inet6", rules: rules})
+			}
+		}
+	}
+	return out, nil
+}
+
+// SaveSnapshot writes the domain-derived entries of every managed set and
+// their stale counters to path, replacing the previous snapshot atomically.
+func (m *UnblockManager) SaveSnapshot(path string) error {
+	targets, err := m.snapshotTargets()
+	if err != nil {
+		return err
+	}
+	snap := ipsetSnapshot{Version: ipsetSnapshotVersion, Sets: make(map[string]snapshotSet)}
+	var names []string
+	for _, t := range targets {
+		if !IPSetExists(t.name) {
+			continue
+		}
+		entries, err := m.registry.entries(t.name)
+		if err != nil {
+			return fmt.Errorf("list %s: %w", t.name, err)
+		}
+		expires := m.registry.expiries(t.name)
+		set := snapshotSet{Family: t.family}
+		for _, e := range entries {
+			if !strings.HasPrefix(e.Comment, ipsetCommentPrefix) {
+				continue
+			}
+			saved := snapshotEntry{Entry: e.Entry, Comment: e.Comment}
+			if deadline, ok := expires[e.Entry]; ok {
+				saved.Expires = deadline.Unix()
+			}
+			set.Entries = append(set.Entries, saved)
+		}
+		if len(set.Entries) > 0 {
+			snap.Sets[t.name] = set
+			names = append(names, t.name)
+		}
+	}
+	snap.Stale = m.registry.staleCountsFor(names)
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// RestoreSnapshot loads the entries saved by SaveSnapshot back into the sets.
+// Entries whose rule was removed in the meantime are dropped. It returns the
+// number of restored entries.
+func (m *UnblockManager) RestoreSnapshot(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read ipset snapshot: %w", err)
+	}
+	var snap ipsetSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return 0, fmt.Errorf("parse ipset snapshot %s: %w", path, err)
+	}
+	if snap.Version != ipsetSnapshotVersion {
+		logx.Warnf("ignoring ipset snapshot %s: unsupported version %d", path, snap.Version)
+		return 0, nil
+	}
+
+	targets, err := m.snapshotTargets()
+	if err != nil {
+		return 0, err
+	}
+	ttl := m.EntryTTL()
+	now := time.Now()
+	restored := 0
+	var errs []error
+	for _, t := range targets {
+		saved, ok := snap.Sets[t.name]
+		if !ok || saved.Family != t.family {
+			continue
+		}
+		var keep []snapshotEntry
+		for _, e := range saved.Entries {
+			if e.Expires != 0 && !now.Before(time.Unix(e.Expires, 0)) {
+				continue
+			}
+			if snapshotEntryLive(e.Comment, t.rules) {
+				keep = append(keep, e)
+			}
+		}
+		if len(keep) == 0 {
+			continue
+		}
+		set, err := m.registry.ObtainOrCreateFamily(t.name, t.family)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		batch := make([]ipsetEntry, 0, len(keep))
+		for _, e := range keep {
+			batch = append(batch, ipsetEntry{Entry: e.Entry, Comment: e.Comment, Timeout: restoreTimeout(ttl, e, now)})
+		}
+		unlock := m.registry.LockSet(t.name)
+		added, addErrs := m.registry.addEntries(set, batch)
+		unlock()
+		restored += added
+		for _, err := range addErrs {
+			errs = append(errs, fmt.Errorf("restore %s: %w", t.name, err))
+		}
+		m.registry.restoreStaleCounts(t.name, snap.Stale, t.rules)
+	}
+	return restored, errors.Join(errs...)
+}
+
+// restoreTimeout keeps the remaining lifetime of an entry saved in TTL mode.
+// Entries saved without one get the shortest lifetime until an answer
+// refreshes them.
+func restoreTimeout(ttl EntryTTL, e snapshotEntry, now time.Time) int {
+	if !ttl.Enabled {
+		return 0
+	}
+	if e.Expires == 0 {
+		return ttl.timeout(0)
+	}
+	return max(int(time.Unix(e.Expires, 0).Sub(now).Seconds()), 1)
+}
+
+// snapshotEntryLive reports whether an entry saved with comment still belongs
+// to one of rules.
+func snapshotEntryLive(comment string, rules []string) bool {
+	rest, ok := strings.CutPrefix(comment, ipsetCommentPrefix)
+	if !ok {
+		return false
+	}
+	rule, domain, ok := strings.Cut(rest, ipsetCommentDomainPart)
+	if !ok {
+		return false
+	}
+	return slices.Contains(rules, rule) && matcher.Match(rule, domain)
+}
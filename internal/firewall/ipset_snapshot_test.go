@@ -0,0 +1,107 @@
+package firewall
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ApostolDmitry/vpner/internal/vpnkind"
+)
+
+func TestIPSetSnapshotRoundTripDropsRemovedRules(t *testing.T) {
+	fake := useFakeSets(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ipsets.json")
+	xray := vpnkind.Xray.String()
+
+	mgr := NewUnblockManager(filepath.Join(dir, "rules.yaml"), false, false, 3, NewIPSetRegistry())
+	for _, pattern := range []string{"10.0.0.0/8", "*.example.com", "*.gone.org"} {
+		if err := mgr.AddRule(xray, "a", pattern); err != nil {
+			t.Fatalf("AddRule %s: %v", pattern, err)
+		}
+	}
+	keep := buildRuleComment("*.example.com", "www.example.com")
+	fake.data["vpner-Xray-a"]["93.184.216.34"] = keep
+	fake.data["vpner-Xray-a"]["198.51.100.7"] = buildRuleComment("*.gone.org", "cdn.gone.org")
+	mgr.registry.CollectStaleEntries(buildStaleKey("vpner-Xray-a", keep), []string{"93.184.216.34"}, nil, 3)
+
+	if err := mgr.SaveSnapshot(path); err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+
+	fake.data = make(map[string]map[string]string)
+	restarted := NewUnblockManager(mgr.FilePath, false, false, 3, NewIPSetRegistry())
+	if err := restarted.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := restarted.DelRule(xray, "a", "*.gone.org"); err != nil {
+		t.Fatalf("DelRule: %v", err)
+	}
+	fake.batches = 0
+	n, err := restarted.RestoreSnapshot(path)
+	if err != nil || n != 1 {
+		t.Fatalf("RestoreSnapshot = %d, %v", n, err)
+	}
+	if fake.batches != 1 {
+		t.Fatalf("restored in %d batches, want 1", fake.batches)
+	}
+
+	set := fake.data["vpner-Xray-a"]
+	if set["93.184.216.34"] != keep {
+		t.Fatalf("entry not restored: %v", set)
+	}
+	if _, ok := set["198.51.100.7"]; ok {
+		t.Fatal("entry of removed rule was restored")
+	}
+	if _, ok := set["10.0.0.0/8"]; !ok {
+		t.Fatal("static entry missing after Init")
+	}
+	if got := restarted.registry.staleCounts[buildStaleKey("vpner-Xray-a", keep)]["93.184.216.34"]; got != 1 {
+		t.Fatalf("stale counter = %d, want 1", got)
+	}
+}
+
+func TestRestoreSnapshotMissingFile(t *testing.T) {
+	mgr := NewUnblockManager(filepath.Join(t.TempDir(), "rules.yaml"), false, false, 0, nil)
+	if n, err := mgr.RestoreSnapshot(filepath.Join(t.TempDir(), "absent.json")); n != 0 || err != nil {
+		t.Fatalf("RestoreSnapshot = %d, %v", n, err)
+	}
+}
+
+func TestRestoreSnapshotKeepsGoodEntriesOfABatch(t *testing.T) {
+	fake := useFakeSets(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ipsets.json")
+	xray := vpnkind.Xray.String()
+
+	mgr := NewUnblockManager(filepath.Join(dir, "rules.yaml"), false, false, 0, NewIPSetRegistry())
+	if err := mgr.AddRule(xray, "a", "*.example.com"); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	comment := buildRuleComment("*.example.com", "www.example.com")
+	fake.data["vpner-Xray-a"] = make(map[string]string)
+	for _, ip := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+		fake.data["vpner-Xray-a"][ip] = comment
+	}
+	if err := mgr.SaveSnapshot(path); err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+
+	fake.data = make(map[string]map[string]string)
+	fake.reject = map[string]bool{"192.0.2.2": true}
+	restarted := NewUnblockManager(mgr.FilePath, false, false, 0, NewIPSetRegistry())
+	if err := restarted.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	n, err := restarted.RestoreSnapshot(path)
+	if n != 2 {
+		t.Fatalf("restored %d entries, want 2", n)
+	}
+	if err == nil || !strings.Contains(err.Error(), "192.0.2.2") || strings.Contains(err.Error(), "192.0.2.3") {
+		t.Fatalf("expected an error for the rejected entry only, got %v", err)
+	}
+	set := fake.data["vpner-Xray-a"]
+	if _, ok := set["192.0.2.3"]; !ok || len(set) != 2 {
+		t.Fatalf("entries after the rejected one not restored: %v", set)
+	}
+}
@@ -0,0 +1,63 @@
+package firewall
+
+const (
+	defaultEntryTTLMin   = 300
+	defaultEntryTTLMax   = 86400
+	defaultEntryTTLGrace = 600
+)
+
+// EntryTTL makes domain-derived entries expire on their own: every matching
+// answer adds or refreshes the entry with a timeout derived from its DNS TTL.
+type EntryTTL struct {
+	Enabled bool
+	Min     int
+	Max     int
+	Grace   int
+}
+
+func (t EntryTTL) normalized() EntryTTL {
+	if t.Min <= 0 {
+		t.Min = defaultEntryTTLMin
+	}
+	if t.Max <= 0 {
+		t.Max = defaultEntryTTLMax
+	}
+	if t.Max < t.Min {
+		t.Max = t.Min
+	}
+	switch {
+	case t.Grace == 0:
+		t.Grace = defaultEntryTTLGrace
+	case t.Grace < 0:
+		t.Grace = 0
+	}
+	return t
+}
+
+// timeout returns the ipset timeout in seconds for an answer with ttl, or 0
+// when entries should not expire.
+func (t EntryTTL) timeout(ttl uint32) int {
+	if !t.Enabled {
+		return 0
+	}
+	return min(max(int(ttl), t.Min), t.Max) + t.Grace
+}
+
+// SetEntryTTL switches domain-derived entries to TTL-bound timeouts. It must
+// be called before Init so the sets are created with timeout support.
+func (m *UnblockManager) SetEntryTTL(t EntryTTL) {
+	t = t.normalized()
+	m.mu.Lock()
+	m.entryTTL = t
+	m.mu.Unlock()
+	m.registry.setEntryTimeouts(t.Enabled)
+}
+
+func (m *UnblockManager) EntryTTL() EntryTTL {
+	if m == nil {
+		return EntryTTL{}
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.entryTTL
+}
@@ -0,0 +1,65 @@
+package firewall
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ApostolDmitry/vpner/internal/vpnkind"
+)
+
+func TestEntryTTLTimeout(t *testing.T) {
+	ttl := EntryTTL{Enabled: true, Min: 60, Max: 3600}.normalized()
+	for _, tc := range []struct {
+		ttl  uint32
+		want int
+	}{
+		{0, 60 + defaultEntryTTLGrace},
+		{300, 300 + defaultEntryTTLGrace},
+		{86400, 3600 + defaultEntryTTLGrace},
+	} {
+		if got := ttl.timeout(tc.ttl); got != tc.want {
+			t.Fatalf("timeout(%d) = %d, want %d", tc.ttl, got, tc.want)
+		}
+	}
+	if got := (EntryTTL{Enabled: true, Grace: -1}).normalized().timeout(10); got != defaultEntryTTLMin {
+		t.Fatalf("negative grace: timeout = %d, want %d", got, defaultEntryTTLMin)
+	}
+	if got := (EntryTTL{}).timeout(300); got != 0 {
+		t.Fatalf("disabled: timeout = %d, want 0", got)
+	}
+}
+
+func TestSyncFromAnswersTTLModeSkipsListing(t *testing.T) {
+	fake := useFakeSets(t)
+	mgr := NewUnblockManager(filepath.Join(t.TempDir(), "rules.yaml"), false, false, 3, NewIPSetRegistry())
+	mgr.SetEntryTTL(EntryTTL{Enabled: true, Min: 60, Max: 3600, Grace: -1})
+	if err := mgr.AddRule(vpnkind.Xray.String(), "a", "*.example.com"); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	ipm := NewIpRuleManager(mgr, RuleRuntimeOptions{EntryTTL: mgr.EntryTTL()}, nil, mgr.registry)
+
+	const name = "vpner-Xray-a"
+	if _, err := mgr.registry.ObtainOrCreateFamily(name, "inet"); err != nil {
+		t.Fatalf("ObtainOrCreateFamily: %v", err)
+	}
+	if _, err := mgr.registry.entries(name); err != nil {
+		t.Fatalf("entries: %v", err)
+	}
+	lists := fake.lists
+
+	if err := ipm.SyncFromAnswers("www.example.com", []net.IP{net.ParseIP("93.184.216.34")}, 120); err != nil {
+		t.Fatalf("SyncFromAnswers: %v", err)
+	}
+	if fake.lists != lists {
+		t.Fatalf("set listed %d times in TTL mode", fake.lists-lists)
+	}
+	if got := fake.data[name]["93.184.216.34"]; got != buildRuleComment("*.example.com", "www.example.com") {
+		t.Fatalf("entry comment = %q", got)
+	}
+	deadline, ok := mgr.registry.expiries(name)["93.184.216.34"]
+	if left := time.Until(deadline); !ok || left < 110*time.Second || left > 120*time.Second {
+		t.Fatalf("entry expires in %v (known %v), want ~120s", left, ok)
+	}
+}
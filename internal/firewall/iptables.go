@@ -4,16 +4,18 @@ import (
 	"fmt"
 	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 
+	"github.com/ApostolDmitry/vpner/internal/chainpolicy"
 	"github.com/ApostolDmitry/vpner/internal/logx"
 	"github.com/ApostolDmitry/vpner/internal/vpnkind"
 )
 
 type vpnRoutingInfo struct {
 	VPNType   vpnkind.Kind
-	Mark      int
+	Mark      fwmark
 	TableID   int
 	Dev       string
 	ChainName string
@@ -29,22 +31,37 @@ type jumpRule struct {
 }
 
 func (j jumpRule) deleteArgs() []string {
+	return j.withOp("-D")
+}
+
+func (j jumpRule) checkArgs() []string {
+	return j.withOp("-C")
+}
+
+func (j jumpRule) withOp(op string) []string {
 	args := make([]string, len(j.Args))
 	copy(args, j.Args)
 	for idx, a := range args {
 		if a == "-A" {
-			args[idx] = "-D"
+			args[idx] = op
 			break
 		}
 	}
 	return args
 }
 
+func (j jumpRule) String() string {
+	return strings.Join(j.Args, " ")
+}
+
 type ipFamily struct {
 	iptablesCmd     string
 	iptablesSaveCmd string
 	ipFlags         []string
+	nftAddr         string
 	localExceptions []string
+	// plan records the commands instead of running them during a dry run.
+	plan *planRecorder
 }
 
 var (
@@ -52,29 +69,50 @@ var (
 		iptablesCmd:     "iptables",
 		iptablesSaveCmd: "iptables-save",
 		ipFlags:         nil,
+		nftAddr:         "ip",
 		localExceptions: localExceptionsV4[:],
 	}
 	familyV6 = ipFamily{
 		iptablesCmd:     "ip6tables",
 		iptablesSaveCmd: "ip6tables-save",
 		ipFlags:         []string{"-6"},
+		nftAddr:         "ip6",
 		localExceptions: localExceptionsIPv6[:],
 	}
 )
 
 type IptablesManager struct {
-	mu            sync.Mutex
-	routingV4     map[string]vpnRoutingInfo
-	routingV6     map[string]vpnRoutingInfo
-	ipv6Enabled   bool
-	tproxyEnabled bool
-	ipInfraReady  bool
+	mu             sync.Mutex
+	routingV4      map[string]vpnRoutingInfo
+	routingV6      map[string]vpnRoutingInfo
+	killSwitch     map[string]killSwitchInfo
+	clientFilters  map[string]ClientFilter
+	clientGroups   map[string]struct{}
+	fullTunnel     map[string][]string
+	serverAddrs    map[string][]string
+	udpPolicy      map[string]chainpolicy.UDPPolicy
+	localIntercept LocalIntercept
+	marks          *markAllocator
+	ipv6Enabled    bool
+	tproxyEnabled  bool
+	ipInfraReady   bool
+	dryRun         *planRecorder
+
+	udpInfraReady   bool
+	udpTProxyProbed bool
+	udpTProxyErr    error
 }
 
 type ChainSpec struct {
 	IPSetName string
 	Port      int
 	Ifaces    []string
+	Clients   ClientFilter
+
+	FullTunnel  []string
+	ServerAddrs []string
+	UDP         chainpolicy.UDPPolicy
+	Local       LocalIntercept
 }
 
 const (
@@ -82,11 +120,14 @@ const (
 	tableMangle     = "mangle"
 	chainPrerouting = "PREROUTING"
 
-	tproxyMark    = "200"
-	tproxyTableID = 200
-	chainDivert   = "VPN_DIVERT"
+	tproxyMarkBits uint32 = 200
+	tproxyTableID         = 200
+	chainDivert           = "VPN_DIVERT"
 )
 
+// tproxyMark is tproxyMarkBits as iptables and ip rule arguments take it.
+var tproxyMark = strconv.FormatUint(uint64(tproxyMarkBits), 10)
+
 var localExceptionsV4 = [...]string{
 	"0.0.0.0/8", "127.0.0.0/8", "10.0.0.0/8",
 	"169.254.0.0/16", "172.16.0.0/12", "192.168.0.0/16",
@@ -110,7 +151,10 @@ func validateIface(iface string) error {
 	return nil
 }
 
-func run(name string, args ...string) error {
+func (f ipFamily) run(name string, args ...string) error {
+	if f.plan.record(PlanStep{Tool: name, Table: tableArg(args), Args: args}) {
+		return nil
+	}
 	out, err := exec.Command(name, args...).CombinedOutput()
 	if err != nil {
 		msg := strings.TrimSpace(string(out))
@@ -122,8 +166,8 @@ func run(name string, args ...string) error {
 	return nil
 }
 
-func tryRun(name string, args ...string) {
-	if err := run(name, args...); err != nil {
+func (f ipFamily) tryRun(name string, args ...string) {
+	if err := f.run(name, args...); err != nil {
 		logx.Debugf("network: best-effort cleanup failed: %v", err)
 	}
 }
@@ -133,20 +177,42 @@ func commandExists(cmd string) bool {
 	return err == nil
 }
 
+// v4 and v6 return the address families with the manager's dry-run
+// recorder, so every command they run is recorded during a plan.
+func (i *IptablesManager) v4() ipFamily {
+	f := familyV4
+	f.plan = i.dryRun
+	return f
+}
+
+func (i *IptablesManager) v6() ipFamily {
+	f := familyV6
+	f.plan = i.dryRun
+	return f
+}
+
 func NewIptablesManager(ipv6Enabled, tproxyEnabled bool) *IptablesManager {
 	return &IptablesManager{
 		routingV4:     make(map[string]vpnRoutingInfo),
 		routingV6:     make(map[string]vpnRoutingInfo),
+		killSwitch:    make(map[string]killSwitchInfo),
+		clientFilters: make(map[string]ClientFilter),
+		clientGroups:  make(map[string]struct{}),
+		fullTunnel:    make(map[string][]string),
+		serverAddrs:   make(map[string][]string),
+		udpPolicy:     make(map[string]chainpolicy.UDPPolicy),
+		marks:         newMarkAllocator(),
 		ipv6Enabled:   ipv6Enabled,
 		tproxyEnabled: tproxyEnabled,
 	}
 }
 
 func (i *IptablesManager) CleanupStaleState() {
-	i.cleanupFamily(familyV4)
+	i.cleanupFamily(i.v4())
 	if i.ipv6Enabled || commandExists(familyV6.iptablesSaveCmd) {
-		i.cleanupFamily(familyV6)
+		i.cleanupFamily(i.v6())
 	}
+	i.checkMarkCollisions()
 }
 
 func isSupportedVPNType(vpnType vpnkind.Kind) bool {
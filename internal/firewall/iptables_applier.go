@@ -12,7 +12,7 @@ func (i *IptablesManager) AddRules(vpnType vpnkind.Kind, ipsetName string, param
 	i.mu.Lock()
 	defer i.mu.Unlock()
 
-	if err := i.addRulesForFamily(familyV4, i.routingV4, vpnType, ipsetName, param, iface, vpnIface); err != nil {
+	if err := i.addRulesForFamily(i.v4(), i.routingV4, vpnType, ipsetName, param, iface, vpnIface); err != nil {
 		return err
 	}
 	if !i.ipv6Enabled {
@@ -23,14 +23,14 @@ func (i *IptablesManager) AddRules(vpnType vpnkind.Kind, ipsetName string, param
 	if err != nil {
 		return err
 	}
-	return i.addRulesForFamily(familyV6, i.routingV6, vpnType, ipsetName6, param, iface, vpnIface)
+	return i.addRulesForFamily(i.v6(), i.routingV6, vpnType, ipsetName6, param, iface, vpnIface)
 }
 
 func (i *IptablesManager) RemoveRules(ipsetName string) error {
 	i.mu.Lock()
 	defer i.mu.Unlock()
 
-	v4Err := i.removeRulesForFamily(familyV4, i.routingV4, ipsetName)
+	v4Err := i.removeRulesForFamily(i.v4(), i.routingV4, ipsetName)
 	if !i.ipv6Enabled {
 		return v4Err
 	}
@@ -38,13 +38,13 @@ func (i *IptablesManager) RemoveRules(ipsetName string) error {
 	if err != nil {
 		return errors.Join(v4Err, err)
 	}
-	return errors.Join(v4Err, i.removeRulesForFamily(familyV6, i.routingV6, ipsetName6))
+	return errors.Join(v4Err, i.removeRulesForFamily(i.v6(), i.routingV6, ipsetName6))
 }
 
 func (i *IptablesManager) RemoveRulesV4(ipsetName string) error {
 	i.mu.Lock()
 	defer i.mu.Unlock()
-	return i.removeRulesForFamily(familyV4, i.routingV4, ipsetName)
+	return i.removeRulesForFamily(i.v4(), i.routingV4, ipsetName)
 }
 
 func (i *IptablesManager) RemoveRulesV6(ipsetName string) error {
@@ -58,7 +58,7 @@ func (i *IptablesManager) RemoveRulesV6(ipsetName string) error {
 	if err != nil {
 		return err
 	}
-	return i.removeRulesForFamily(familyV6, i.routingV6, ipsetName6)
+	return i.removeRulesForFamily(i.v6(), i.routingV6, ipsetName6)
 }
 
 func (i *IptablesManager) addRulesForFamily(f ipFamily, routing map[string]vpnRoutingInfo, vpnType vpnkind.Kind, ipsetName string, param int, iface, vpnIface string) error {
@@ -81,31 +81,32 @@ func (i *IptablesManager) addRulesForFamily(f ipFamily, routing map[string]vpnRo
 
 	switch vpnType {
 	case vpnkind.OpenVPN, vpnkind.WireGuard, vpnkind.IKE, vpnkind.SSTP, vpnkind.PPPoE, vpnkind.L2TP, vpnkind.PPTP:
-		if err := ensureChain(f.iptablesCmd, tableMangle, chainName); err != nil {
+		mark, tableID, err := i.allocMarkLocked(f, ipsetName)
+		if err != nil {
 			return err
 		}
-		jmp, err := linkChain(f.iptablesCmd, tableMangle, chainName, iface)
+		jumps, err := rules.applyMarkChain(f, chainName, ipsetName, mark, []string{iface}, i.clientFilters[ipsetName])
 		if err != nil {
-
-			tryRun(f.iptablesCmd, "-t", tableMangle, "-F", chainName)
-			tryRun(f.iptablesCmd, "-t", tableMangle, "-X", chainName)
 			return err
 		}
-		mark, tableID := markAndTableFromIPSet(ipsetName)
+		info := vpnRoutingInfo{
+			VPNType:   vpnType,
+			Mark:      mark,
+			TableID:   tableID,
+			Dev:       vpnIface,
+			ChainName: chainName,
+			Table:     tableMangle,
+			Ifaces:    []string{iface},
+			JumpRules: jumps,
+		}
 
 		rollback := func(withIPRule bool) {
-			tryRun(jmp.Cmd, jmp.deleteArgs()...)
-			tryRun(f.iptablesCmd, "-t", tableMangle, "-F", chainName)
-			tryRun(f.iptablesCmd, "-t", tableMangle, "-X", chainName)
+			rules.removeChain(f, info)
 			if withIPRule {
-				tryRun("ip", append(f.ipFlags, "rule", "del", "fwmark", fmt.Sprintf("%d", mark), "table", fmt.Sprintf("%d", tableID))...)
-				tryRun("ip", append(f.ipFlags, "route", "flush", "table", fmt.Sprintf("%d", tableID))...)
+				f.tryRun("ip", append(f.ipFlags, "rule", "del", "fwmark", mark.String(), "table", fmt.Sprintf("%d", tableID))...)
+				f.tryRun("ip", append(f.ipFlags, "route", "flush", "table", fmt.Sprintf("%d", tableID))...)
 			}
 		}
-		if err := addMarkRules(f, chainName, ipsetName, mark, iface); err != nil {
-			rollback(false)
-			return err
-		}
 		if err := addIPRule(f, mark, tableID); err != nil {
 			rollback(false)
 			return err
@@ -114,16 +115,7 @@ func (i *IptablesManager) addRulesForFamily(f ipFamily, routing map[string]vpnRo
 			rollback(true)
 			return err
 		}
-		routing[ipsetName] = vpnRoutingInfo{
-			VPNType:   vpnType,
-			Mark:      mark,
-			TableID:   tableID,
-			Dev:       vpnIface,
-			ChainName: chainName,
-			Table:     tableMangle,
-			Ifaces:    []string{iface},
-			JumpRules: []jumpRule{jmp},
-		}
+		routing[ipsetName] = info
 		return nil
 	default:
 		return fmt.Errorf("unsupported VPN type: %s", vpnType)
@@ -139,22 +131,13 @@ func (i *IptablesManager) removeRulesForFamily(f ipFamily, routing map[string]vp
 		return nil
 	}
 
-	for _, jmp := range info.JumpRules {
-		tryRun(jmp.Cmd, jmp.deleteArgs()...)
-	}
+	rules.removeChain(f, info)
 
-	table := info.Table
-	if table == "" {
-		table = tableNat
-	}
-	tryRun(f.iptablesCmd, "-t", table, "-F", info.ChainName)
-	tryRun(f.iptablesCmd, "-t", table, "-X", info.ChainName)
-
-	if info.Mark != 0 && info.TableID != 0 {
-		delArgs := append(f.ipFlags, "rule", "del", "fwmark", fmt.Sprintf("%d", info.Mark), "table", fmt.Sprintf("%d", info.TableID))
-		tryRun("ip", delArgs...)
+	if info.Mark.Value != 0 && info.TableID != 0 {
+		delArgs := append(f.ipFlags, "rule", "del", "fwmark", info.Mark.String(), "table", fmt.Sprintf("%d", info.TableID))
+		f.tryRun("ip", delArgs...)
 		flushArgs := append(f.ipFlags, "route", "flush", "table", fmt.Sprintf("%d", info.TableID))
-		tryRun("ip", flushArgs...)
+		f.tryRun("ip", flushArgs...)
 	}
 
 	delete(routing, ipsetName)
@@ -169,7 +152,7 @@ func (i *IptablesManager) BatchApplyAllTProxy(specs []ChainSpec) error {
 	i.mu.Lock()
 	defer i.mu.Unlock()
 
-	if err := i.batchApplyBothFamilies(specs, i.buildTProxyBatch); err != nil {
+	if err := i.batchApplyBothFamilies(specs); err != nil {
 		return err
 	}
 	i.ipInfraReady = true
@@ -183,17 +166,15 @@ func (i *IptablesManager) BatchApplyAllRedirect(specs []ChainSpec) error {
 
 	i.mu.Lock()
 	defer i.mu.Unlock()
-	return i.batchApplyBothFamilies(specs, i.buildRedirectBatch)
+	return i.batchApplyBothFamilies(specs)
 }
 
-type batchBuilder func(f ipFamily, routing map[string]vpnRoutingInfo, specs []ChainSpec) error
-
 type xrayChainInit func(b *iptablesBatch, chainName string, spec ChainSpec)
 
 type xrayChainIfaceRules func(b *iptablesBatch, chainName string, spec ChainSpec, iface string)
 
-func (i *IptablesManager) batchApplyBothFamilies(specs []ChainSpec, build batchBuilder) error {
-	if err := build(familyV4, i.routingV4, specs); err != nil {
+func (i *IptablesManager) batchApplyBothFamilies(specs []ChainSpec) error {
+	if err := i.applyXrayBatch(i.v4(), i.routingV4, specs); err != nil {
 		return err
 	}
 	if !i.ipv6Enabled {
@@ -204,7 +185,7 @@ func (i *IptablesManager) batchApplyBothFamilies(specs []ChainSpec, build batchB
 	if len(v6Specs) == 0 {
 		return nil
 	}
-	return build(familyV6, i.routingV6, v6Specs)
+	return i.applyXrayBatch(i.v6(), i.routingV6, v6Specs)
 }
 
 func specsToV6(specs []ChainSpec) []ChainSpec {
@@ -222,72 +203,33 @@ func specsToV6(specs []ChainSpec) []ChainSpec {
 
 func (i *IptablesManager) applyXrayBatch(f ipFamily, routing map[string]vpnRoutingInfo, specs []ChainSpec) error {
 	if i.tproxyEnabled {
-		return i.buildTProxyBatch(f, routing, specs)
-	}
-	return i.buildRedirectBatch(f, routing, specs)
-}
-
-func (i *IptablesManager) buildTProxyBatch(f ipFamily, routing map[string]vpnRoutingInfo, specs []ChainSpec) error {
-	i.ensureTProxyLocalRouting(f)
-
-	i.cleanupLegacyTProxySocketRule(f)
-	if err := i.ensureMangleInputBypass(f); err != nil {
-		return fmt.Errorf("mangle INPUT bypass: %w", err)
+		i.ensureTProxyLocalRouting(f)
 	}
-
-	existing := listPreroutingRules(f.iptablesCmd, tableMangle)
-	b := newBatch(f.iptablesCmd, tableMangle)
-
-	b.Add(fmt.Sprintf(":%s - [0:0]", chainDivert))
-	b.Add(fmt.Sprintf("-A %s -j MARK --set-mark %s", chainDivert, tproxyMark))
-	b.Add(fmt.Sprintf("-A %s -j ACCEPT", chainDivert))
-
-	socketRule := tproxySocketRuleSpec()
-	if !existing[socketRule] {
-		b.Add(socketRule)
+	for idx := range specs {
+		specs[idx].Clients = i.clientFilters[specs[idx].IPSetName]
+		specs[idx].FullTunnel = i.fullTunnel[specs[idx].IPSetName]
+		specs[idx].ServerAddrs = i.serverAddrs[specs[idx].IPSetName]
+		specs[idx].UDP = i.udpPolicyLocked(specs[idx].IPSetName)
+		specs[idx].Local = i.localIntercept
 	}
-
-	buildXrayChains(b, existing, specs,
-		func(batch *iptablesBatch, chainName string, _ ChainSpec) {
-			batch.Add(fmt.Sprintf("-A %s -m mark --mark %s -j RETURN", chainName, tproxyMark))
-		},
-		func(batch *iptablesBatch, chainName string, spec ChainSpec, iface string) {
-			addReturnCIDRs(batch, chainName, iface, f.localExceptions)
-			addTProxyProtocolRules(batch, chainName, iface, spec.IPSetName, spec.Port)
-		},
-	)
-
-	if err := b.Commit(); err != nil {
+	i.ensureUDPTProxyRouting(f, specs)
+	if err := rules.applyXray(f, specs, i.tproxyEnabled); err != nil {
 		return err
 	}
-	updateRoutingMap(routing, specs, tableMangle, f.iptablesCmd)
+	updateRoutingMap(routing, specs, i.XrayTable(), f.iptablesCmd)
 	return nil
 }
 
-func (i *IptablesManager) buildRedirectBatch(f ipFamily, routing map[string]vpnRoutingInfo, specs []ChainSpec) error {
-	existing := listPreroutingRules(f.iptablesCmd, tableNat)
-	b := newBatch(f.iptablesCmd, tableNat)
-
-	buildXrayChains(b, existing, specs, nil,
-		func(batch *iptablesBatch, chainName string, spec ChainSpec, iface string) {
-			batch.Add(redirectRuleSpec(chainName, iface, spec.IPSetName, spec.Port))
-		},
-	)
-
-	if err := b.Commit(); err != nil {
-		return err
-	}
-	updateRoutingMap(routing, specs, tableNat, f.iptablesCmd)
-	return nil
-}
-
-func buildXrayChains(b *iptablesBatch, existing map[string]bool, specs []ChainSpec, initChain xrayChainInit, addIfaceRules xrayChainIfaceRules) {
+func buildXrayChains(b *iptablesBatch, f ipFamily, existing map[string]bool, specs []ChainSpec, initChain xrayChainInit, addIfaceRules xrayChainIfaceRules) {
 	for _, spec := range specs {
 		chainName := buildChainName(spec.IPSetName)
 		b.Add(fmt.Sprintf(":%s - [0:0]", chainName))
 		if initChain != nil {
 			initChain(b, chainName, spec)
 		}
+		for _, rule := range clientFilterRuleSpecs(f, chainName, spec.Clients) {
+			b.Add(rule)
+		}
 
 		for _, iface := range spec.Ifaces {
 			addJumpRuleIfMissing(b, existing, chainName, iface)
@@ -329,14 +271,14 @@ func addReturnCIDRs(b *iptablesBatch, chainName, iface string, cidrs []string) {
 	}
 }
 
-func addMarkRules(f ipFamily, chainName, ipsetName string, mark int, iface string) error {
-	b := newBatch(f.iptablesCmd, tableMangle)
+func addMarkRules(f ipFamily, chainName, ipsetName string, mark fwmark, iface string) error {
+	b := newBatch(f, tableMangle)
 
 	addReturnCIDRs(b, chainName, iface, f.localExceptions)
 
 	for _, proto := range []string{"tcp", "udp"} {
 		b.Add(fmt.Sprintf(
-			"-A %s -i %s -p %s -m set --match-set %s dst -j MARK --set-mark %d",
+			"-A %s -i %s -p %s -m set --match-set %s dst -j MARK --set-mark %s",
 			chainName, iface, proto, ipsetName, mark,
 		))
 	}
@@ -344,17 +286,12 @@ func addMarkRules(f ipFamily, chainName, ipsetName string, mark int, iface strin
 	return b.Commit()
 }
 
-func addIPRule(f ipFamily, mark, tableID int) error {
-	args := append(f.ipFlags, "rule", "add", "fwmark", fmt.Sprintf("%d", mark), "table", fmt.Sprintf("%d", tableID))
-	return run("ip", args...)
+func addIPRule(f ipFamily, mark fwmark, tableID int) error {
+	args := append(f.ipFlags, "rule", "add", "fwmark", mark.String(), "table", fmt.Sprintf("%d", tableID))
+	return f.run("ip", args...)
 }
 
 func addIPRoute(f ipFamily, tableID int, iface string) error {
 	args := append(f.ipFlags, "route", "add", "default", "dev", iface, "table", fmt.Sprintf("%d", tableID))
-	return run("ip", args...)
-}
-
-func markAndTableFromIPSet(ipsetName string) (mark int, tableID int) {
-	id := int(checksumIPSetName(ipsetName)&0xFFF) + 100
-	return id, id
+	return f.run("ip", args...)
 }
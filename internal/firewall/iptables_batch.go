@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"os/exec"
+	"strings"
 
 	"github.com/ApostolDmitry/vpner/internal/logx"
 )
@@ -11,13 +12,15 @@ import (
 type iptablesBatch struct {
 	cmd   string
 	table string
+	plan  *planRecorder
 	buf   bytes.Buffer
 }
 
-func newBatch(cmd, table string) *iptablesBatch {
+func newBatch(f ipFamily, table string) *iptablesBatch {
 	b := &iptablesBatch{
-		cmd:   cmd,
+		cmd:   f.iptablesCmd,
 		table: table,
+		plan:  f.plan,
 	}
 	b.buf.WriteString("*" + table + "\n")
 	return b
@@ -37,6 +40,10 @@ func (b *iptablesBatch) Commit() error {
 		restore = "ip6tables-restore"
 	}
 
+	if b.plan.record(PlanStep{Tool: restore, Table: b.table, Lines: b.lines()}) {
+		return nil
+	}
+
 	logx.Infof(
 		"iptables batch apply (cmd=%s table=%s)",
 		restore,
@@ -64,3 +71,14 @@ func (b *iptablesBatch) Commit() error {
 
 	return nil
 }
+
+func (b *iptablesBatch) lines() []string {
+	var out []string
+	for _, line := range strings.Split(b.buf.String(), "\n") {
+		if line == "" || line == "COMMIT" || strings.HasPrefix(line, "*") {
+			continue
+		}
+		out = append(out, line)
+	}
+	return out
+}
@@ -10,10 +10,10 @@ import (
 	"github.com/ApostolDmitry/vpner/internal/logx"
 )
 
-func ensureChain(iptablesCmd, table, chain string) error {
+func ensureChain(f ipFamily, table, chain string) error {
 	logx.Infof("ensure chain %s (table=%s)", chain, table)
 
-	err := run(iptablesCmd, "-t", table, "-N", chain)
+	err := f.run(f.iptablesCmd, "-t", table, "-N", chain)
 	if err != nil {
 		if strings.Contains(err.Error(), "exists") || strings.Contains(err.Error(), "File exists") {
 			logx.Debugf("chain %s already exists", chain)
@@ -26,13 +26,21 @@ func ensureChain(iptablesCmd, table, chain string) error {
 }
 
 func preroutingJumpSpec(chain, iface string) string {
-	return fmt.Sprintf("-A %s -i %s -j %s", chainPrerouting, iface, chain)
+	return hookJumpSpec(chainPrerouting, chain, iface)
+}
+
+func hookJumpSpec(hook, chain, iface string) string {
+	return fmt.Sprintf("-A %s -i %s -j %s", hook, iface, chain)
 }
 
 func newJumpRule(iptablesCmd, table, chain, iface string) jumpRule {
+	return newHookJumpRule(iptablesCmd, table, chainPrerouting, chain, iface)
+}
+
+func newHookJumpRule(iptablesCmd, table, hook, chain, iface string) jumpRule {
 	return jumpRule{
 		Cmd:  iptablesCmd,
-		Args: []string{"-t", table, "-A", chainPrerouting, "-i", iface, "-j", chain},
+		Args: []string{"-t", table, "-A", hook, "-i", iface, "-j", chain},
 	}
 }
 
@@ -43,7 +51,7 @@ func addJumpRuleIfMissing(b *iptablesBatch, existing map[string]bool, chainName,
 	}
 }
 
-func linkChain(iptablesCmd, table, chain, iface string) (jumpRule, error) {
+func linkChain(f ipFamily, table, chain, iface string) (jumpRule, error) {
 	logx.Infof(
 		"link PREROUTING -> %s (table=%s iface=%s)",
 		chain,
@@ -51,14 +59,14 @@ func linkChain(iptablesCmd, table, chain, iface string) (jumpRule, error) {
 		iface,
 	)
 
-	jmp := newJumpRule(iptablesCmd, table, chain, iface)
+	jmp := newJumpRule(f.iptablesCmd, table, chain, iface)
 	needle := preroutingJumpSpec(chain, iface)
-	if listPreroutingRules(iptablesCmd, table)[needle] {
+	if listPreroutingRules(f.iptablesCmd, table)[needle] {
 		logx.Debugf("PREROUTING jump already exists: %s -i %s -j %s", table, iface, chain)
 		return jmp, nil
 	}
 
-	if err := run(iptablesCmd, jmp.Args...); err != nil {
+	if err := f.run(f.iptablesCmd, jmp.Args...); err != nil {
 		if strings.Contains(err.Error(), "exists") {
 			return jmp, nil
 		}
@@ -2,57 +2,40 @@ package firewall
 
 import (
 	"bufio"
-	"fmt"
 	"os/exec"
+	"strconv"
 	"strings"
 
 	"github.com/ApostolDmitry/vpner/internal/logx"
 )
 
 func (i *IptablesManager) cleanupFamily(f ipFamily) {
-	i.cleanupOldChainsInTable(f, tableNat)
-	i.cleanupOldChainsInTable(f, tableMangle)
+	rules.cleanupStale(f)
 	i.cleanupOldIPRulesAndRoutes(f)
 	i.cleanupTProxyIPRule(f)
-	i.cleanupMangleInputBypass(f)
 }
 
 func (i *IptablesManager) cleanupOldIPRulesAndRoutes(f ipFamily) {
-	args := append(f.ipFlags, "rule")
-	out, err := exec.Command("ip", args...).Output()
+	live, err := listIPRules(f)
 	if err != nil {
 		logx.Warnf("failed to list ip rules: %v", err)
 		return
 	}
 
-	scanner := bufio.NewScanner(strings.NewReader(string(out)))
-	for scanner.Scan() {
-		line := scanner.Text()
-		if !strings.Contains(line, "fwmark") || !strings.Contains(line, "lookup") {
+	for _, rule := range live {
+		if !i.marks.owns(rule) {
 			continue
 		}
-		parts := strings.Fields(line)
-		var fwmark, tableID int
-		for idx, p := range parts {
-			if p == "fwmark" && idx+1 < len(parts) {
-				fmt.Sscanf(parts[idx+1], "%d", &fwmark)
-			}
-			if p == "lookup" && idx+1 < len(parts) {
-				fmt.Sscanf(parts[idx+1], "%d", &tableID)
-			}
-		}
-		if fwmark == tableID && fwmark >= 100 && fwmark <= 0xFFF+100 {
-			logx.Infof("cleanup ip rule fwmark=%d table=%d", fwmark, tableID)
-			delArgs := append(f.ipFlags, "rule", "del", "fwmark", fmt.Sprintf("%d", fwmark), "table", fmt.Sprintf("%d", tableID))
-			tryRun("ip", delArgs...)
-			logx.Infof("flush route table %d", tableID)
-			flushArgs := append(f.ipFlags, "route", "flush", "table", fmt.Sprintf("%d", tableID))
-			tryRun("ip", flushArgs...)
-		}
+		logx.Infof("cleanup ip rule fwmark=%s table=%d", rule.Mark, rule.Table)
+		delArgs := append(f.ipFlags, "rule", "del", "fwmark", rule.Mark.String(), "table", strconv.Itoa(rule.Table))
+		f.tryRun("ip", delArgs...)
+		logx.Infof("flush route table %d", rule.Table)
+		flushArgs := append(f.ipFlags, "route", "flush", "table", strconv.Itoa(rule.Table))
+		f.tryRun("ip", flushArgs...)
 	}
 }
 
-func (i *IptablesManager) cleanupOldChainsInTable(f ipFamily, table string) {
+func cleanupOldChainsInTable(f ipFamily, table string) {
 	out, err := exec.Command(f.iptablesSaveCmd, "-t", table).Output()
 	if err != nil {
 		logx.Warnf("failed to run %s -t %s: %v", f.iptablesSaveCmd, table, err)
@@ -72,7 +55,7 @@ func (i *IptablesManager) cleanupOldChainsInTable(f ipFamily, table string) {
 				chains = append(chains, chain)
 			}
 		}
-		if strings.HasPrefix(line, "-A PREROUTING") && strings.Contains(line, "-j VPN_") {
+		if isManagedJumpLine(line) {
 			jumps = append(jumps, line)
 		}
 	}
@@ -81,17 +64,29 @@ func (i *IptablesManager) cleanupOldChainsInTable(f ipFamily, table string) {
 		delRule := strings.Replace(rule, "-A", "-D", 1)
 		args := append([]string{"-t", table}, strings.Fields(delRule)...)
 		logx.Infof(
-			"cleanup %s PREROUTING jump: %s %s",
+			"cleanup %s jump: %s %s",
 			table,
 			f.iptablesCmd,
 			strings.Join(args, " "),
 		)
-		tryRun(f.iptablesCmd, args...)
+		f.tryRun(f.iptablesCmd, args...)
 	}
 
 	for _, chain := range chains {
 		logx.Infof("cleaning old %s chain: %s", table, chain)
-		tryRun(f.iptablesCmd, "-t", table, "-F", chain)
-		tryRun(f.iptablesCmd, "-t", table, "-X", chain)
+		f.tryRun(f.iptablesCmd, "-t", table, "-F", chain)
+		f.tryRun(f.iptablesCmd, "-t", table, "-X", chain)
+	}
+}
+
+func isManagedJumpLine(line string) bool {
+	if !strings.Contains(line, "-j VPN_") {
+		return false
+	}
+	for _, hook := range []string{chainPrerouting, chainForward, chainOutput} {
+		if strings.HasPrefix(line, "-A "+hook+" ") {
+			return true
+		}
 	}
+	return false
 }
@@ -0,0 +1,204 @@
+package firewall
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ApostolDmitry/vpner/internal/chainpolicy"
+	"github.com/ApostolDmitry/vpner/internal/logx"
+	"github.com/ApostolDmitry/vpner/internal/vpnkind"
+)
+
+const (
+	tableFilter  = "filter"
+	chainForward = "FORWARD"
+)
+
+type killSwitchInfo struct {
+	Mode    chainpolicy.KillSwitch
+	Ifaces  []string
+	Clients ClientFilter
+}
+
+func (i *IptablesManager) EngageKillSwitch(chain string, mode chainpolicy.KillSwitch, ifaces []string) error {
+	if !mode.Enabled() {
+		return i.ReleaseKillSwitch(chain)
+	}
+
+	ipsetName, err := IpsetName(vpnkind.Xray.String(), chain)
+	if err != nil {
+		return err
+	}
+	if err := i.ensureManagedIPSet(ipsetName, false); err != nil {
+		return err
+	}
+	if i.ipv6Enabled {
+		ipsetName6, err := IpsetName6FromBase(ipsetName)
+		if err != nil {
+			return err
+		}
+		if err := i.ensureManagedIPSet(ipsetName6, true); err != nil {
+			return err
+		}
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	info := killSwitchInfo{Mode: mode, Ifaces: append([]string(nil), ifaces...)}
+	i.killSwitch[ipsetName] = info
+	logx.Infof("kill switch engaged: chain=%s mode=%s", chain, mode)
+	return i.applyKillSwitchLocked(ipsetName, info, true, true)
+}
+
+func (i *IptablesManager) ReleaseKillSwitch(chain string) error {
+	ipsetName, err := IpsetName(vpnkind.Xray.String(), chain)
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	info, ok := i.killSwitch[ipsetName]
+	if !ok {
+		return nil
+	}
+	delete(i.killSwitch, ipsetName)
+	logx.Infof("kill switch released: chain=%s", chain)
+	i.removeKillSwitchLocked(ipsetName, info)
+	return nil
+}
+
+func (i *IptablesManager) ReleaseAllKillSwitches() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for ipsetName, info := range i.killSwitch {
+		i.removeKillSwitchLocked(ipsetName, info)
+	}
+	i.killSwitch = make(map[string]killSwitchInfo)
+}
+
+func (i *IptablesManager) KillSwitchEngaged(chain string) bool {
+	ipsetName, err := IpsetName(vpnkind.Xray.String(), chain)
+	if err != nil {
+		return false
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	_, ok := i.killSwitch[ipsetName]
+	return ok
+}
+
+func (i *IptablesManager) listKillSwitchIPSets() []string {
+	list := make([]string, 0, len(i.killSwitch))
+	for ipsetName := range i.killSwitch {
+		list = append(list, ipsetName)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func (i *IptablesManager) restoreKillSwitchesLocked(table string, restoreV4, restoreV6 bool) {
+	if table != "" && table != tableFilter {
+		return
+	}
+	for _, ipsetName := range i.listKillSwitchIPSets() {
+		if err := i.applyKillSwitchLocked(ipsetName, i.killSwitch[ipsetName], restoreV4, restoreV6); err != nil {
+			logx.Errorf("restore kill switch %s: %v", ipsetName, err)
+		}
+	}
+}
+
+func (i *IptablesManager) applyKillSwitchLocked(ipsetName string, info killSwitchInfo, applyV4, applyV6 bool) error {
+	if applyV4 {
+		info.Clients = i.clientFilters[ipsetName]
+		if err := rules.applyKillSwitch(i.v4(), ipsetName, info); err != nil {
+			return fmt.Errorf("kill switch %s: %w", ipsetName, err)
+		}
+	}
+	if applyV6 && i.ipv6Enabled {
+		ipsetName6, err := IpsetName6FromBase(ipsetName)
+		if err != nil {
+			return err
+		}
+		info.Clients = i.clientFilters[ipsetName6]
+		if err := rules.applyKillSwitch(i.v6(), ipsetName6, info); err != nil {
+			return fmt.Errorf("kill switch %s: %w", ipsetName6, err)
+		}
+	}
+	return nil
+}
+
+func buildKillSwitchBatch(f ipFamily, ipsetName string, info killSwitchInfo) *iptablesBatch {
+	chainName := buildChainName(ipsetName)
+	existing := listChainRules(f.iptablesCmd, tableFilter, chainForward)
+
+	b := newBatch(f, tableFilter)
+	b.Add(fmt.Sprintf(":%s - [0:0]", chainName))
+	for _, rule := range clientFilterRuleSpecs(f, chainName, info.Clients) {
+		b.Add(rule)
+	}
+	for _, iface := range info.Ifaces {
+		if jump := hookJumpSpec(chainForward, chainName, iface); !existing[jump] {
+			b.Add(jump)
+		}
+		for _, rule := range killSwitchRuleSpecs(chainName, iface, ipsetName, info.Mode) {
+			b.Add(rule)
+		}
+	}
+	return b
+}
+
+func killSwitchRuleSpecs(chainName, iface, ipsetName string, mode chainpolicy.KillSwitch) []string {
+	match := fmt.Sprintf("-A %s -i %s", chainName, iface)
+	set := fmt.Sprintf("-m set --match-set %s dst", ipsetName)
+	if mode == chainpolicy.KillSwitchDrop {
+		return []string{fmt.Sprintf("%s %s -j DROP", match, set)}
+	}
+	return []string{
+		fmt.Sprintf("%s -p tcp %s -j REJECT --reject-with tcp-reset", match, set),
+		fmt.Sprintf("%s %s -j REJECT", match, set),
+	}
+}
+
+func (i *IptablesManager) removeKillSwitchLocked(ipsetName string, info killSwitchInfo) {
+	rules.removeKillSwitch(i.v4(), ipsetName, info)
+	if !i.ipv6Enabled {
+		return
+	}
+	ipsetName6, err := IpsetName6FromBase(ipsetName)
+	if err != nil {
+		return
+	}
+	rules.removeKillSwitch(i.v6(), ipsetName6, info)
+}
+
+func (i *IptablesManager) killSwitchIntact() bool {
+	type probe struct {
+		f     ipFamily
+		chain string
+	}
+
+	i.mu.Lock()
+	var probes []probe
+	for ipsetName := range i.killSwitch {
+		probes = append(probes, probe{familyV4, buildChainName(ipsetName)})
+		if !i.ipv6Enabled {
+			continue
+		}
+		if ipsetName6, err := IpsetName6FromBase(ipsetName); err == nil {
+			probes = append(probes, probe{familyV6, buildChainName(ipsetName6)})
+		}
+	}
+	i.mu.Unlock()
+
+	for _, p := range probes {
+		if !rules.chainPresent(p.f, tableFilter, p.chain) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package firewall
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ApostolDmitry/vpner/internal/chainpolicy"
+)
+
+func TestKillSwitchRuleSpecs(t *testing.T) {
+	reject := killSwitchRuleSpecs("VPN_00000001", "br0", "vpner-Xray-a", chainpolicy.KillSwitchReject)
+	wantReject := []string{
+		"-A VPN_00000001 -i br0 -p tcp -m set --match-set vpner-Xray-a dst -j REJECT --reject-with tcp-reset",
+		"-A VPN_00000001 -i br0 -m set --match-set vpner-Xray-a dst -j REJECT",
+	}
+	if !reflect.DeepEqual(reject, wantReject) {
+		t.Fatalf("reject rules = %v, want %v", reject, wantReject)
+	}
+
+	drop := killSwitchRuleSpecs("VPN_00000001", "br0", "vpner-Xray-a", chainpolicy.KillSwitchDrop)
+	wantDrop := []string{"-A VPN_00000001 -i br0 -m set --match-set vpner-Xray-a dst -j DROP"}
+	if !reflect.DeepEqual(drop, wantDrop) {
+		t.Fatalf("drop rules = %v, want %v", drop, wantDrop)
+	}
+}
+
+func TestIsManagedJumpLine(t *testing.T) {
+	cases := map[string]bool{
+		"-A PREROUTING -i br0 -j VPN_0a1b2c3d": true,
+		"-A FORWARD -i br0 -j VPN_0a1b2c3d":    true,
+		"-A FORWARD -i br0 -j ACCEPT":          false,
+		"-A INPUT -i br0 -j VPN_0a1b2c3d":      false,
+		"-A VPN_0a1b2c3d -j VPN_DIVERT":        false,
+	}
+	for line, want := range cases {
+		if got := isManagedJumpLine(line); got != want {
+			t.Fatalf("isManagedJumpLine(%q) = %v, want %v", line, got, want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 
+	"github.com/ApostolDmitry/vpner/internal/chainpolicy"
 	"github.com/ApostolDmitry/vpner/internal/vpnkind"
 )
 
@@ -102,7 +103,7 @@ func (i *IptablesManager) RemoveAllXrayRoutes() {
 	}
 }
 
-func (i *IptablesManager) PrepareXrayChain(chain string, port int, ifaces []string) (ChainSpec, XrayRouteState, error) {
+func (i *IptablesManager) PrepareXrayChain(chain string, port int, ifaces, serverAddrs []string, udp chainpolicy.UDPPolicy) (ChainSpec, XrayRouteState, error) {
 	if port == 0 {
 		return ChainSpec{}, XrayRouteState{}, fmt.Errorf("missing inbound port for chain %s", chain)
 	}
@@ -111,15 +112,16 @@ func (i *IptablesManager) PrepareXrayChain(chain string, port int, ifaces []stri
 	if err != nil {
 		return ChainSpec{}, XrayRouteState{}, err
 	}
-	if err := ensureManagedIPSet(ipsetName, false); err != nil {
+	if err := i.ensureManagedIPSet(ipsetName, false); err != nil {
 		return ChainSpec{}, XrayRouteState{}, err
 	}
+	var ipsetName6 string
 	if i.ipv6Enabled {
-		ipsetName6, err := IpsetName6FromBase(ipsetName)
+		ipsetName6, err = IpsetName6FromBase(ipsetName)
 		if err != nil {
 			return ChainSpec{}, XrayRouteState{}, err
 		}
-		if err := ensureManagedIPSet(ipsetName6, true); err != nil {
+		if err := i.ensureManagedIPSet(ipsetName6, true); err != nil {
 			return ChainSpec{}, XrayRouteState{}, err
 		}
 	}
@@ -129,12 +131,19 @@ func (i *IptablesManager) PrepareXrayChain(chain string, port int, ifaces []stri
 	i.mu.Lock()
 	defer i.mu.Unlock()
 
+	if serverAddrs != nil {
+		i.storeServerAddrsLocked(ipsetName, ipsetName6, serverAddrs)
+	}
+	i.udpPolicy[ipsetName] = udp
+	if ipsetName6 != "" {
+		i.udpPolicy[ipsetName6] = udp
+	}
 	state := i.xrayStateLocked(ipsetName)
 	i.registerXrayEntryLocked(ipsetName, port, spec.Ifaces)
 	return spec, state, nil
 }
 
-func ensureManagedIPSet(ipsetName string, ipv6 bool) error {
+func (i *IptablesManager) ensureManagedIPSet(ipsetName string, ipv6 bool) error {
 	if IPSetExists(ipsetName) {
 		return nil
 	}
@@ -143,6 +152,9 @@ func ensureManagedIPSet(ipsetName string, ipv6 bool) error {
 	if ipv6 {
 		params.HashFamily = "inet6"
 	}
+	if i.dryRun.record(ipsetCreateStep(ipsetName, "hash:net", params)) {
+		return nil
+	}
 	if err := EnsureIPSet(ipsetName, "hash:net", params); err != nil {
 		return fmt.Errorf("ensure ipset %s: %w", ipsetName, err)
 	}
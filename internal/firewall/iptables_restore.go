@@ -1,8 +1,6 @@
 package firewall
 
 import (
-	"fmt"
-
 	"github.com/ApostolDmitry/vpner/internal/logx"
 	"github.com/ApostolDmitry/vpner/internal/vpnkind"
 )
@@ -19,16 +17,18 @@ func (i *IptablesManager) RestoreRouting(table string, restoreV4, restoreV6 bool
 	}
 
 	xrayApplied := false
-	if i.restoreXrayFamily(restoreV4, familyV4, i.routingV4, table) {
+	if i.restoreXrayFamily(restoreV4, i.v4(), i.routingV4, table) {
 		xrayApplied = true
 	}
-	if i.restoreXrayFamily(restoreV6, familyV6, i.routingV6, table) {
+	if i.restoreXrayFamily(restoreV6, i.v6(), i.routingV6, table) {
 		xrayApplied = true
 	}
 
 	if i.tproxyEnabled && xrayApplied {
 		i.ipInfraReady = true
 	}
+
+	i.restoreKillSwitchesLocked(table, restoreV4, restoreV6)
 }
 
 func (i *IptablesManager) restoreXrayFamily(enabled bool, f ipFamily, routing map[string]vpnRoutingInfo, table string) bool {
@@ -52,7 +52,7 @@ func (i *IptablesManager) restoreForFamily(f ipFamily, routing map[string]vpnRou
 	var xraySpecs []ChainSpec
 
 	for ipsetName, info := range routing {
-		if table != "" && info.Table != table {
+		if table != "" && info.Table != table && !i.udpPolicyInTableLocked(info, ipsetName, table) {
 			continue
 		}
 		if !IPSetExists(ipsetName) {
@@ -77,39 +77,19 @@ func (i *IptablesManager) restoreForFamily(f ipFamily, routing map[string]vpnRou
 func (i *IptablesManager) restoreMarkEntry(f ipFamily, routing map[string]vpnRoutingInfo, ipsetName string, info vpnRoutingInfo) {
 	logx.Infof("restore routing: vpn=%s ipset=%s chain=%s", info.VPNType, ipsetName, info.ChainName)
 
-	if err := ensureChain(f.iptablesCmd, info.Table, info.ChainName); err != nil {
-		logx.Errorf("restore ensureChain %s: %v", info.ChainName, err)
+	jumps, err := rules.applyMarkChain(f, info.ChainName, ipsetName, info.Mark, info.Ifaces, i.clientFilters[ipsetName])
+	if err != nil {
+		logx.Errorf("restore mark chain %s: %v", info.ChainName, err)
 		return
 	}
-	tryRun(f.iptablesCmd, "-t", info.Table, "-F", info.ChainName)
-
-	var newJumps []jumpRule
-	for _, iface := range info.Ifaces {
-		jmp, err := linkChain(f.iptablesCmd, info.Table, info.ChainName, iface)
-		if err != nil {
-			logx.Errorf("restore linkChain %s: %v", info.ChainName, err)
-			continue
-		}
-		newJumps = appendJumpRule(newJumps, jmp)
-	}
-
-	if info.Mark != 0 {
-		for _, iface := range info.Ifaces {
-			if err := addMarkRules(f, info.ChainName, ipsetName, info.Mark, iface); err != nil {
-				logx.Errorf("restore addMarkRules %s: %v", info.ChainName, err)
-			}
-		}
-	}
 
-	if info.Mark != 0 && info.TableID != 0 && info.Dev != "" {
-		mark := fmt.Sprintf("%d", info.Mark)
-		tableID := fmt.Sprintf("%d", info.TableID)
-		if !ipRuleExists(f, mark, tableID) {
+	if info.Mark.Value != 0 && info.TableID != 0 && info.Dev != "" {
+		if !ipRulePresent(f, info.Mark, info.TableID) {
 			_ = addIPRule(f, info.Mark, info.TableID)
 			_ = addIPRoute(f, info.TableID, info.Dev)
 		}
 	}
 
-	info.JumpRules = newJumps
+	info.JumpRules = jumps
 	routing[ipsetName] = info
 }
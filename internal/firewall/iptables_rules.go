@@ -0,0 +1,323 @@
+package firewall
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/ApostolDmitry/vpner/internal/chainpolicy"
+	"github.com/ApostolDmitry/vpner/internal/logx"
+	"github.com/ApostolDmitry/vpner/internal/vpnkind"
+)
+
+type ruleBackend interface {
+	applyXray(f ipFamily, specs []ChainSpec, tproxy bool) error
+	applyMarkChain(f ipFamily, chainName, ipsetName string, mark fwmark, ifaces []string, clients ClientFilter) ([]jumpRule, error)
+	removeChain(f ipFamily, info vpnRoutingInfo)
+	removeFullTunnel(f ipFamily, table, chainName string)
+	applyKillSwitch(f ipFamily, ipsetName string, info killSwitchInfo) error
+	removeKillSwitch(f ipFamily, ipsetName string, info killSwitchInfo)
+	chainPresent(f ipFamily, table, chain string) bool
+	jumpPresent(j jumpRule) bool
+	targetPresent(f ipFamily, table, chain, ipsetName string) bool
+	cleanupStale(f ipFamily)
+	cleanupTProxy(f ipFamily)
+	loadTProxyModules(release string) error
+	probeTProxy(f ipFamily) error
+	probeUDPTProxy(f ipFamily, release string) error
+	snapshot() (restore func())
+}
+
+var rules ruleBackend = iptablesRules{}
+
+type iptablesRules struct{}
+
+func (r iptablesRules) applyXray(f ipFamily, specs []ChainSpec, tproxy bool) error {
+	if tproxy {
+		return buildTProxyBatch(f, specs)
+	}
+	if err := buildRedirectBatch(f, specs); err != nil {
+		return err
+	}
+	return r.applyUDPPolicy(f, specs)
+}
+
+func buildTProxyBatch(f ipFamily, specs []ChainSpec) error {
+	cleanupLegacyTProxySocketRule(f)
+	if err := ensureMangleInputBypass(f); err != nil {
+		return fmt.Errorf("mangle INPUT bypass: %w", err)
+	}
+
+	existing := listPreroutingRules(f.iptablesCmd, tableMangle)
+	existingOutput := listChainRules(f.iptablesCmd, tableMangle, chainOutput)
+	b := newBatch(f, tableMangle)
+
+	b.Add(fmt.Sprintf(":%s - [0:0]", chainDivert))
+	b.Add(fmt.Sprintf("-A %s -j MARK --set-mark %s", chainDivert, tproxyMark))
+	b.Add(fmt.Sprintf("-A %s -j ACCEPT", chainDivert))
+
+	socketRule := tproxySocketRuleSpec()
+	if !existing[socketRule] {
+		b.Add(socketRule)
+	}
+
+	buildXrayChains(b, f, existing, specs,
+		func(batch *iptablesBatch, chainName string, spec ChainSpec) {
+			batch.Add(fmt.Sprintf("-A %s -m mark --mark %s -j RETURN", chainName, tproxyMark))
+			for _, rule := range fullTunnelRuleSpecs(f, chainName, spec, tproxyFullTunnelTargets(spec.Port)) {
+				batch.Add(rule)
+			}
+		},
+		func(batch *iptablesBatch, chainName string, spec ChainSpec, iface string) {
+			addReturnCIDRs(batch, chainName, iface, f.localExceptions)
+			addTProxyProtocolRules(batch, chainName, iface, spec.IPSetName, spec.Port)
+		},
+	)
+	for _, spec := range specs {
+		for _, rule := range localTProxyRuleSpecs(buildChainName(spec.IPSetName), spec, existingOutput, existing) {
+			b.Add(rule)
+		}
+	}
+
+	return b.Commit()
+}
+
+func buildRedirectBatch(f ipFamily, specs []ChainSpec) error {
+	existing := listPreroutingRules(f.iptablesCmd, tableNat)
+	existingOutput := listChainRules(f.iptablesCmd, tableNat, chainOutput)
+	b := newBatch(f, tableNat)
+
+	buildXrayChains(b, f, existing, specs,
+		func(batch *iptablesBatch, chainName string, spec ChainSpec) {
+			for _, rule := range fullTunnelRuleSpecs(f, chainName, spec, redirectFullTunnelTargets(spec.Port)) {
+				batch.Add(rule)
+			}
+		},
+		func(batch *iptablesBatch, chainName string, spec ChainSpec, iface string) {
+			batch.Add(redirectRuleSpec(chainName, iface, spec.IPSetName, spec.Port))
+		},
+	)
+	for _, spec := range specs {
+		for _, rule := range localRedirectRuleSpecs(buildChainName(spec.IPSetName), spec, existingOutput) {
+			b.Add(rule)
+		}
+	}
+
+	return b.Commit()
+}
+
+func (iptablesRules) applyMarkChain(f ipFamily, chainName, ipsetName string, mark fwmark, ifaces []string, clients ClientFilter) ([]jumpRule, error) {
+	if err := ensureChain(f, tableMangle, chainName); err != nil {
+		return nil, err
+	}
+	f.tryRun(f.iptablesCmd, "-t", tableMangle, "-F", chainName)
+
+	var jumps []jumpRule
+	rollback := func() {
+		for _, jmp := range jumps {
+			f.tryRun(jmp.Cmd, jmp.deleteArgs()...)
+		}
+		f.tryRun(f.iptablesCmd, "-t", tableMangle, "-F", chainName)
+		f.tryRun(f.iptablesCmd, "-t", tableMangle, "-X", chainName)
+	}
+
+	for _, iface := range ifaces {
+		jmp, err := linkChain(f, tableMangle, chainName, iface)
+		if err != nil {
+			rollback()
+			return nil, err
+		}
+		jumps = appendJumpRule(jumps, jmp)
+	}
+	if filterRules := clientFilterRuleSpecs(f, chainName, clients); len(filterRules) > 0 {
+		b := newBatch(f, tableMangle)
+		for _, rule := range filterRules {
+			b.Add(rule)
+		}
+		if err := b.Commit(); err != nil {
+			rollback()
+			return nil, err
+		}
+	}
+	for _, iface := range ifaces {
+		if err := addMarkRules(f, chainName, ipsetName, mark, iface); err != nil {
+			rollback()
+			return nil, err
+		}
+	}
+	return jumps, nil
+}
+
+func (r iptablesRules) removeChain(f ipFamily, info vpnRoutingInfo) {
+	for _, jmp := range info.JumpRules {
+		f.tryRun(jmp.Cmd, jmp.deleteArgs()...)
+	}
+
+	table := info.Table
+	if table == "" {
+		table = tableNat
+	}
+	f.tryRun(f.iptablesCmd, "-t", table, "-F", info.ChainName)
+	f.tryRun(f.iptablesCmd, "-t", table, "-X", info.ChainName)
+	if info.VPNType == vpnkind.Xray {
+		r.removeFullTunnel(f, table, info.ChainName)
+		r.removeUDPPolicy(f, info.ChainName)
+		r.removeHookedChain(f, table, chainOutput, localOutputChainName(info.ChainName))
+		r.removeHookedChain(f, tableMangle, chainPrerouting, localLoopbackChainName(info.ChainName))
+	}
+}
+
+func (r iptablesRules) removeFullTunnel(f ipFamily, table, chainName string) {
+	ft := fullTunnelChainName(chainName)
+	if !r.chainPresent(f, table, ft) {
+		return
+	}
+	f.tryRun(f.iptablesCmd, "-t", table, "-F", ft)
+	f.tryRun(f.iptablesCmd, "-t", table, "-X", ft)
+}
+
+func (r iptablesRules) applyUDPPolicy(f ipFamily, specs []ChainSpec) error {
+	for _, spec := range specs {
+		chainName := buildChainName(spec.IPSetName)
+		switch spec.UDP {
+		case chainpolicy.UDPBlockQUIC:
+			r.removeHookedChain(f, tableMangle, chainPrerouting, udpTProxyChainName(chainName))
+			b := newBatch(f, tableFilter)
+			for _, rule := range quicBlockRuleSpecs(f, chainName, spec, listChainRules(f.iptablesCmd, tableFilter, chainForward)) {
+				b.Add(rule)
+			}
+			if err := b.Commit(); err != nil {
+				return fmt.Errorf("block-quic %s: %w", chainName, err)
+			}
+		case chainpolicy.UDPTProxyUDPOnly:
+			r.removeHookedChain(f, tableFilter, chainForward, quicBlockChainName(chainName))
+			if err := ensureMangleInputBypass(f); err != nil {
+				return fmt.Errorf("mangle INPUT bypass: %w", err)
+			}
+			b := newBatch(f, tableMangle)
+			for _, rule := range udpTProxyRuleSpecs(f, chainName, spec, listPreroutingRules(f.iptablesCmd, tableMangle)) {
+				b.Add(rule)
+			}
+			if err := b.Commit(); err != nil {
+				return fmt.Errorf("tproxy-udp-only %s: %w", chainName, err)
+			}
+		default:
+			r.removeUDPPolicy(f, chainName)
+		}
+	}
+	return nil
+}
+
+func (r iptablesRules) removeUDPPolicy(f ipFamily, chainName string) {
+	r.removeHookedChain(f, tableFilter, chainForward, quicBlockChainName(chainName))
+	r.removeHookedChain(f, tableMangle, chainPrerouting, udpTProxyChainName(chainName))
+}
+
+func (r iptablesRules) removeHookedChain(f ipFamily, table, hook, chain string) {
+	if !r.chainPresent(f, table, chain) {
+		return
+	}
+	for rule := range listChainRules(f.iptablesCmd, table, hook) {
+		if !strings.HasSuffix(rule, " -j "+chain) {
+			continue
+		}
+		jmp := jumpRule{Cmd: f.iptablesCmd, Args: append([]string{"-t", table}, strings.Fields(rule)...)}
+		f.tryRun(jmp.Cmd, jmp.deleteArgs()...)
+	}
+	f.tryRun(f.iptablesCmd, "-t", table, "-F", chain)
+	f.tryRun(f.iptablesCmd, "-t", table, "-X", chain)
+}
+
+func (iptablesRules) applyKillSwitch(f ipFamily, ipsetName string, info killSwitchInfo) error {
+	return buildKillSwitchBatch(f, ipsetName, info).Commit()
+}
+
+func (iptablesRules) removeKillSwitch(f ipFamily, ipsetName string, info killSwitchInfo) {
+	chainName := buildChainName(ipsetName)
+	for _, iface := range info.Ifaces {
+		jmp := newHookJumpRule(f.iptablesCmd, tableFilter, chainForward, chainName, iface)
+		f.tryRun(f.iptablesCmd, jmp.deleteArgs()...)
+	}
+	f.tryRun(f.iptablesCmd, "-t", tableFilter, "-F", chainName)
+	f.tryRun(f.iptablesCmd, "-t", tableFilter, "-X", chainName)
+}
+
+func (iptablesRules) chainPresent(f ipFamily, table, chain string) bool {
+	return exec.Command(f.iptablesCmd, "-t", table, "-n", "-L", chain).Run() == nil
+}
+
+func (iptablesRules) jumpPresent(j jumpRule) bool {
+	return exec.Command(j.Cmd, j.checkArgs()...).Run() == nil
+}
+
+func (iptablesRules) targetPresent(f ipFamily, table, chain, ipsetName string) bool {
+	for rule := range listChainRules(f.iptablesCmd, table, chain) {
+		if strings.Contains(rule, "--match-set "+ipsetName+" dst") && isRoutingTarget(rule) {
+			return true
+		}
+	}
+	return false
+}
+
+func isRoutingTarget(rule string) bool {
+	for _, target := range []string{" -j REDIRECT", " -j TPROXY", " -j MARK", " redirect to ", " tproxy ", " mark set "} {
+		if strings.Contains(rule, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (iptablesRules) cleanupStale(f ipFamily) {
+	cleanupOldChainsInTable(f, tableNat)
+	cleanupOldChainsInTable(f, tableMangle)
+	cleanupOldChainsInTable(f, tableFilter)
+	cleanupMangleInputBypass(f)
+}
+
+func (iptablesRules) cleanupTProxy(f ipFamily) {
+	cleanupMangleInputBypass(f)
+	f.tryRun(f.iptablesCmd, "-t", tableMangle, "-D", chainPrerouting,
+		"-p", "tcp", "-m", "socket", "--transparent", "-j", chainDivert)
+	cleanupLegacyTProxySocketRule(f)
+	f.tryRun(f.iptablesCmd, "-t", tableMangle, "-F", chainDivert)
+	f.tryRun(f.iptablesCmd, "-t", tableMangle, "-X", chainDivert)
+}
+
+func (iptablesRules) loadTProxyModules(release string) error {
+	for _, mod := range []string{"xt_TPROXY", "xt_socket"} {
+		path := fmt.Sprintf("/lib/modules/%s/%s.ko", release, mod)
+		if err := loadKernelModule(path); err != nil {
+			return fmt.Errorf("module %s: %w", mod, err)
+		}
+	}
+	return nil
+}
+
+func (iptablesRules) probeTProxy(f ipFamily) error {
+	if !commandExists(f.iptablesCmd) {
+		return fmt.Errorf("%s not found", f.iptablesCmd)
+	}
+	return probeTProxyUserspace(f)
+}
+
+func (iptablesRules) probeUDPTProxy(f ipFamily, release string) error {
+	if !commandExists(f.iptablesCmd) {
+		return fmt.Errorf("%s not found", f.iptablesCmd)
+	}
+	path := fmt.Sprintf("/lib/modules/%s/xt_TPROXY.ko", release)
+	if err := loadKernelModule(path); err != nil {
+		logx.Debugf("load %s: %v", path, err)
+	}
+	return withTProxyProbeChain(f, func() error {
+		if err := f.run(f.iptablesCmd, "-t", tableMangle, "-A", tproxyProbeChain,
+			"-p", "udp", "-j", "TPROXY", "--on-port", "1", "--tproxy-mark", tproxyMark); err != nil {
+			return fmt.Errorf("xt_TPROXY udp target not supported: %w", err)
+		}
+		return nil
+	})
+}
+
+func (iptablesRules) snapshot() func() {
+	return func() {}
+}
@@ -3,7 +3,6 @@ package firewall
 import (
 	"fmt"
 	"os/exec"
-	"strconv"
 	"strings"
 )
 
@@ -37,21 +36,15 @@ func EnsureTProxySupport(ipv6Enabled bool) error {
 	if release == "" {
 		return fmt.Errorf("failed to determine kernel release (uname -r)")
 	}
-	for _, mod := range []string{"xt_TPROXY", "xt_socket"} {
-		path := fmt.Sprintf("/lib/modules/%s/%s.ko", release, mod)
-		if err := loadKernelModule(path); err != nil {
-			return fmt.Errorf("module %s: %w", mod, err)
-		}
+	if err := rules.loadTProxyModules(release); err != nil {
+		return err
 	}
 
-	if err := probeTProxyUserspace(familyV4); err != nil {
+	if err := rules.probeTProxy(familyV4); err != nil {
 		return fmt.Errorf("ipv4 tproxy probe: %w", err)
 	}
 	if ipv6Enabled {
-		if !commandExists(familyV6.iptablesCmd) {
-			return fmt.Errorf("ipv6 enabled but %s not found", familyV6.iptablesCmd)
-		}
-		if err := probeTProxyUserspace(familyV6); err != nil {
+		if err := rules.probeTProxy(familyV6); err != nil {
 			return fmt.Errorf("ipv6 tproxy probe: %w", err)
 		}
 	}
@@ -64,50 +57,58 @@ func EnsureTProxySupport(ipv6Enabled bool) error {
 const tproxyProbeChain = "VPN_TPROXY_PROBE"
 
 func probeTProxyUserspace(f ipFamily) error {
-	tryRun(f.iptablesCmd, "-t", tableMangle, "-F", tproxyProbeChain)
-	tryRun(f.iptablesCmd, "-t", tableMangle, "-X", tproxyProbeChain)
+	return withTProxyProbeChain(f, func() error {
+		if err := f.run(f.iptablesCmd, "-t", tableMangle, "-A", tproxyProbeChain,
+			"-p", "tcp", "-m", "socket", "--transparent", "-j", "ACCEPT"); err != nil {
+			return fmt.Errorf("xt_socket --transparent not supported by iptables/kernel: %w", err)
+		}
 
-	if err := run(f.iptablesCmd, "-t", tableMangle, "-N", tproxyProbeChain); err != nil {
-		return fmt.Errorf("create probe chain: %w", err)
-	}
-	defer func() {
-		tryRun(f.iptablesCmd, "-t", tableMangle, "-F", tproxyProbeChain)
-		tryRun(f.iptablesCmd, "-t", tableMangle, "-X", tproxyProbeChain)
-	}()
+		if err := f.run(f.iptablesCmd, "-t", tableMangle, "-A", tproxyProbeChain,
+			"-p", "tcp", "-j", "TPROXY", "--on-port", "1", "--tproxy-mark", tproxyMark); err != nil {
+			return fmt.Errorf("xt_TPROXY tcp target not supported: %w", err)
+		}
 
-	if err := run(f.iptablesCmd, "-t", tableMangle, "-A", tproxyProbeChain,
-		"-p", "tcp", "-m", "socket", "--transparent", "-j", "ACCEPT"); err != nil {
-		return fmt.Errorf("xt_socket --transparent not supported by iptables/kernel: %w", err)
-	}
+		if err := f.run(f.iptablesCmd, "-t", tableMangle, "-A", tproxyProbeChain,
+			"-p", "udp", "-j", "TPROXY", "--on-port", "1", "--tproxy-mark", tproxyMark); err != nil {
+			return fmt.Errorf("xt_TPROXY udp target not supported: %w", err)
+		}
+		return nil
+	})
+}
 
-	if err := run(f.iptablesCmd, "-t", tableMangle, "-A", tproxyProbeChain,
-		"-p", "tcp", "-j", "TPROXY", "--on-port", "1", "--tproxy-mark", tproxyMark); err != nil {
-		return fmt.Errorf("xt_TPROXY tcp target not supported: %w", err)
-	}
+func withTProxyProbeChain(f ipFamily, probe func() error) error {
+	f.tryRun(f.iptablesCmd, "-t", tableMangle, "-F", tproxyProbeChain)
+	f.tryRun(f.iptablesCmd, "-t", tableMangle, "-X", tproxyProbeChain)
 
-	if err := run(f.iptablesCmd, "-t", tableMangle, "-A", tproxyProbeChain,
-		"-p", "udp", "-j", "TPROXY", "--on-port", "1", "--tproxy-mark", tproxyMark); err != nil {
-		return fmt.Errorf("xt_TPROXY udp target not supported: %w", err)
+	if err := f.run(f.iptablesCmd, "-t", tableMangle, "-N", tproxyProbeChain); err != nil {
+		return fmt.Errorf("create probe chain: %w", err)
 	}
-	return nil
+	defer func() {
+		f.tryRun(f.iptablesCmd, "-t", tableMangle, "-F", tproxyProbeChain)
+		f.tryRun(f.iptablesCmd, "-t", tableMangle, "-X", tproxyProbeChain)
+	}()
+	return probe()
 }
 
 func (i *IptablesManager) ensureTProxyLocalRouting(f ipFamily) {
 	if i.ipInfraReady {
 		return
 	}
+	installTProxyLocalRoute(f)
+	i.ipInfraReady = true
+}
 
+func installTProxyLocalRoute(f ipFamily) {
 	tbl := fmt.Sprintf("%d", tproxyTableID)
 	if !ipRuleExists(f, tproxyMark, tbl) {
 		addRule := append(f.ipFlags, "rule", "add", "fwmark", tproxyMark, "lookup", tbl)
-		tryRun("ip", addRule...)
+		f.tryRun("ip", addRule...)
 	}
 	routeArgs := append(f.ipFlags, "route", "replace", "local", "default", "dev", "lo", "table", tbl)
-	tryRun("ip", routeArgs...)
-	i.ipInfraReady = true
+	f.tryRun("ip", routeArgs...)
 }
 
-func (i *IptablesManager) ensureMangleInputBypass(f ipFamily) error {
+func ensureMangleInputBypass(f ipFamily) error {
 	existing := listChainRules(f.iptablesCmd, tableMangle, chainInput)
 
 	if existing[inputBypassRuleSpec()] || existing[inputBypassRuleSpecHex()] {
@@ -115,18 +116,19 @@ func (i *IptablesManager) ensureMangleInputBypass(f ipFamily) error {
 	}
 	insert := []string{"-t", tableMangle, "-I", chainInput, "1",
 		"-m", "mark", "--mark", tproxyMark, "-j", "ACCEPT"}
-	return run(f.iptablesCmd, insert...)
+	return f.run(f.iptablesCmd, insert...)
 }
 
-func (i *IptablesManager) cleanupMangleInputBypass(f ipFamily) {
+func cleanupMangleInputBypass(f ipFamily) {
 	args := []string{"-t", tableMangle, "-D", chainInput,
 		"-m", "mark", "--mark", tproxyMark, "-j", "ACCEPT"}
-	for run(f.iptablesCmd, args...) == nil {
+	// Copies are deleted until none is left; a dry run records one delete.
+	for f.run(f.iptablesCmd, args...) == nil && f.plan == nil {
 	}
 }
 
-func (i *IptablesManager) cleanupLegacyTProxySocketRule(f ipFamily) {
-	tryRun(f.iptablesCmd, "-t", tableMangle, "-D", chainPrerouting,
+func cleanupLegacyTProxySocketRule(f ipFamily) {
+	f.tryRun(f.iptablesCmd, "-t", tableMangle, "-D", chainPrerouting,
 		"-p", "tcp", "-m", "socket", "-j", chainDivert)
 }
 
@@ -139,8 +141,7 @@ func inputBypassRuleSpec() string {
 }
 
 func inputBypassRuleSpecHex() string {
-	mark, _ := strconv.Atoi(tproxyMark)
-	return fmt.Sprintf("-A %s -m mark --mark 0x%x -j ACCEPT", chainInput, mark)
+	return fmt.Sprintf("-A %s -m mark --mark 0x%x -j ACCEPT", chainInput, tproxyMarkBits)
 }
 
 func addTProxyProtocolRules(b *iptablesBatch, chainName, iface, ipsetName string, port int) {
@@ -153,7 +154,7 @@ func addTProxyProtocolRules(b *iptablesBatch, chainName, iface, ipsetName string
 }
 
 func addTProxyRules(f ipFamily, chainName, ipsetName string, port int, iface string) error {
-	b := newBatch(f.iptablesCmd, tableMangle)
+	b := newBatch(f, tableMangle)
 	b.Add(fmt.Sprintf("-A %s -m mark --mark %s -j RETURN", chainName, tproxyMark))
 	addReturnCIDRs(b, chainName, iface, f.localExceptions)
 	addTProxyProtocolRules(b, chainName, iface, ipsetName, port)
@@ -184,19 +185,17 @@ func (i *IptablesManager) cleanupTProxyIPRule(f ipFamily) {
 	tbl := fmt.Sprintf("%d", tproxyTableID)
 	delArgs := append(f.ipFlags, "rule", "del", "fwmark", tproxyMark, "lookup", tbl)
 	for ipRuleExists(f, tproxyMark, tbl) {
-		tryRun("ip", delArgs...)
+		f.tryRun("ip", delArgs...)
+		if f.plan != nil {
+			break
+		}
 	}
 	flushArgs := append(f.ipFlags, "route", "flush", "table", tbl)
-	tryRun("ip", flushArgs...)
+	f.tryRun("ip", flushArgs...)
 }
 
 func (i *IptablesManager) cleanupTProxyInfraForFamily(f ipFamily) {
-	i.cleanupMangleInputBypass(f)
-	tryRun(f.iptablesCmd, "-t", tableMangle, "-D", chainPrerouting,
-		"-p", "tcp", "-m", "socket", "--transparent", "-j", chainDivert)
-	i.cleanupLegacyTProxySocketRule(f)
-	tryRun(f.iptablesCmd, "-t", tableMangle, "-F", chainDivert)
-	tryRun(f.iptablesCmd, "-t", tableMangle, "-X", chainDivert)
+	rules.cleanupTProxy(f)
 	i.cleanupTProxyIPRule(f)
 }
 
@@ -207,11 +206,12 @@ func (i *IptablesManager) TProxyEnabled() bool {
 func (i *IptablesManager) Shutdown() {
 	i.mu.Lock()
 	defer i.mu.Unlock()
-	if i.tproxyEnabled {
-		i.cleanupTProxyInfraForFamily(familyV4)
+	if i.tproxyEnabled || i.udpInfraReady {
+		i.cleanupTProxyInfraForFamily(i.v4())
 		if i.ipv6Enabled {
-			i.cleanupTProxyInfraForFamily(familyV6)
+			i.cleanupTProxyInfraForFamily(i.v6())
 		}
 		i.ipInfraReady = false
+		i.udpInfraReady = false
 	}
 }
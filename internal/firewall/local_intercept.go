@@ -0,0 +1,110 @@
+package firewall
+
+import "fmt"
+
+const (
+	chainOutput = "OUTPUT"
+	loIface     = "lo"
+
+	localOutputSuffix   = "_OUT"
+	localLoopbackSuffix = "_LO"
+)
+
+type LocalIntercept struct {
+	Enabled    bool
+	BypassMark int
+	BypassGID  int
+}
+
+func localOutputChainName(chainName string) string {
+	return chainName + localOutputSuffix
+}
+
+func localLoopbackChainName(chainName string) string {
+	return chainName + localLoopbackSuffix
+}
+
+func (i *IptablesManager) SetLocalIntercept(local LocalIntercept) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.localIntercept = local
+}
+
+func localBypassRuleSpecs(chain string, local LocalIntercept) []string {
+	var out []string
+	if local.BypassMark != 0 {
+		out = append(out, fmt.Sprintf("-A %s -m mark --mark %d -j RETURN", chain, local.BypassMark))
+	}
+	if local.BypassGID > 0 {
+		out = append(out, fmt.Sprintf("-A %s -m owner --gid-owner %d -j RETURN", chain, local.BypassGID))
+	}
+	return out
+}
+
+func localRedirectRuleSpecs(chainName string, spec ChainSpec, existing map[string]bool) []string {
+	if !spec.Local.Enabled {
+		return nil
+	}
+	out := []string{fmt.Sprintf(":%s - [0:0]", localOutputChainName(chainName))}
+	out = append(out, localBypassRuleSpecs(localOutputChainName(chainName), spec.Local)...)
+	out = append(out, fmt.Sprintf("-A %s -p tcp -m set --match-set %s dst -j REDIRECT --to-ports %d",
+		localOutputChainName(chainName), spec.IPSetName, spec.Port))
+	if jump := fmt.Sprintf("-A %s -j %s", chainOutput, localOutputChainName(chainName)); !existing[jump] {
+		out = append(out, jump)
+	}
+	return out
+}
+
+func localTProxyRuleSpecs(chainName string, spec ChainSpec, existingOutput, existingPrerouting map[string]bool) []string {
+	if !spec.Local.Enabled {
+		return nil
+	}
+	output, loopback := localOutputChainName(chainName), localLoopbackChainName(chainName)
+	out := []string{
+		fmt.Sprintf(":%s - [0:0]", output),
+		fmt.Sprintf(":%s - [0:0]", loopback),
+	}
+	out = append(out, localBypassRuleSpecs(output, spec.Local)...)
+	for _, proto := range []string{"tcp", "udp"} {
+		out = append(out,
+			fmt.Sprintf("-A %s -p %s -m set --match-set %s dst -j MARK --set-mark %s", output, proto, spec.IPSetName, tproxyMark),
+			fmt.Sprintf("-A %s -p %s -m mark --mark %s -m set --match-set %s dst -j TPROXY --on-port %d --tproxy-mark %s",
+				loopback, proto, tproxyMark, spec.IPSetName, spec.Port, tproxyMark),
+		)
+	}
+	if jump := fmt.Sprintf("-A %s -j %s", chainOutput, output); !existingOutput[jump] {
+		out = append(out, jump)
+	}
+	if jump := preroutingJumpSpec(loopback, loIface); !existingPrerouting[jump] {
+		out = append(out, jump)
+	}
+	return out
+}
+
+func nftLocalBypassRules(local LocalIntercept) []string {
+	var out []string
+	if local.BypassMark != 0 {
+		out = append(out, fmt.Sprintf("meta mark %d return", local.BypassMark))
+	}
+	if local.BypassGID > 0 {
+		out = append(out, fmt.Sprintf("meta skgid %d return", local.BypassGID))
+	}
+	return out
+}
+
+func nftLocalOutputRules(f ipFamily, spec ChainSpec, tproxy bool) []string {
+	out := nftLocalBypassRules(spec.Local)
+	if !tproxy {
+		return append(out, fmt.Sprintf("%s daddr @%s meta l4proto tcp redirect to :%d", f.nftAddr, spec.IPSetName, spec.Port))
+	}
+	return append(out, fmt.Sprintf("%s daddr @%s meta l4proto { tcp, udp } meta mark set %s", f.nftAddr, spec.IPSetName, tproxyMark))
+}
+
+func nftLocalLoopbackRules(f ipFamily, spec ChainSpec) []string {
+	var out []string
+	for _, proto := range []string{"tcp", "udp"} {
+		out = append(out, fmt.Sprintf("meta mark %s %s daddr @%s meta l4proto %s tproxy %s to :%d accept",
+			tproxyMark, f.nftAddr, spec.IPSetName, proto, f.nftAddr, spec.Port))
+	}
+	return out
+}
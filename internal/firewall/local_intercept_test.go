@@ -0,0 +1,48 @@
+package firewall
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLocalInterceptRuleSpecs(t *testing.T) {
+	spec := ChainSpec{
+		IPSetName: "vpner-Xray-xray1",
+		Port:      10800,
+		Local:     LocalIntercept{Enabled: true, BypassMark: 0x2000, BypassGID: 2000},
+	}
+
+	got := localRedirectRuleSpecs("VPN_TEST", spec, nil)
+	want := []string{
+		":VPN_TEST_OUT - [0:0]",
+		"-A VPN_TEST_OUT -m mark --mark 8192 -j RETURN",
+		"-A VPN_TEST_OUT -m owner --gid-owner 2000 -j RETURN",
+		"-A VPN_TEST_OUT -p tcp -m set --match-set vpner-Xray-xray1 dst -j REDIRECT --to-ports 10800",
+		"-A OUTPUT -j VPN_TEST_OUT",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("redirect rules:\n got %q\nwant %q", got, want)
+	}
+
+	spec.Local.BypassGID = 0
+	got = localTProxyRuleSpecs("VPN_TEST", spec,
+		map[string]bool{"-A OUTPUT -j VPN_TEST_OUT": true},
+		map[string]bool{"-A PREROUTING -i lo -j VPN_TEST_LO": true})
+	want = []string{
+		":VPN_TEST_OUT - [0:0]",
+		":VPN_TEST_LO - [0:0]",
+		"-A VPN_TEST_OUT -m mark --mark 8192 -j RETURN",
+		"-A VPN_TEST_OUT -p tcp -m set --match-set vpner-Xray-xray1 dst -j MARK --set-mark 200",
+		"-A VPN_TEST_LO -p tcp -m mark --mark 200 -m set --match-set vpner-Xray-xray1 dst -j TPROXY --on-port 10800 --tproxy-mark 200",
+		"-A VPN_TEST_OUT -p udp -m set --match-set vpner-Xray-xray1 dst -j MARK --set-mark 200",
+		"-A VPN_TEST_LO -p udp -m mark --mark 200 -m set --match-set vpner-Xray-xray1 dst -j TPROXY --on-port 10800 --tproxy-mark 200",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("tproxy rules:\n got %q\nwant %q", got, want)
+	}
+
+	spec.Local = LocalIntercept{}
+	if rules := localRedirectRuleSpecs("VPN_TEST", spec, nil); rules != nil {
+		t.Fatalf("expected no rules when intercept-local is off, got %v", rules)
+	}
+}
@@ -0,0 +1,452 @@
+package firewall
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ApostolDmitry/vpner/internal/logx"
+)
+
+const (
+	fullMarkMask uint32 = 0xFFFFFFFF
+
+	idBase  = 100
+	idSpace = 0x1000
+)
+
+var builtinReservedTables = []int{tproxyTableID, 253, 254, 255}
+
+type fwmark struct {
+	Value uint32
+	Mask  uint32
+}
+
+func (m fwmark) String() string {
+	if m.Mask == fullMarkMask || m.Mask == 0 {
+		return strconv.FormatUint(uint64(m.Value), 10)
+	}
+	return fmt.Sprintf("0x%x/0x%x", m.Value, m.Mask)
+}
+
+func (m fwmark) nftSet() string {
+	if m.Mask == fullMarkMask || m.Mask == 0 {
+		return fmt.Sprintf("meta mark set %d", m.Value)
+	}
+	return fmt.Sprintf("meta mark set meta mark & 0x%x | 0x%x", ^m.Mask, m.Value)
+}
+
+func (m fwmark) matches(value uint32) bool {
+	mask := m.Mask
+	if mask == 0 {
+		mask = fullMarkMask
+	}
+	return value&mask == m.Value&mask
+}
+
+type MarkOptions struct {
+	StatePath      string
+	Mask           uint32
+	ReservedMarks  []string
+	ReservedTables []string
+}
+
+type idRange struct {
+	lo, hi uint64
+}
+
+func (r idRange) contains(v uint64) bool {
+	return v >= r.lo && v <= r.hi
+}
+
+func parseIDRanges(values []string) ([]idRange, error) {
+	var out []idRange
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		lo, hi, isRange := strings.Cut(value, "-")
+		start, err := strconv.ParseUint(strings.TrimSpace(lo), 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q", value)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.ParseUint(strings.TrimSpace(hi), 0, 32); err != nil || end < start {
+				return nil, fmt.Errorf("invalid range %q", value)
+			}
+		}
+		out = append(out, idRange{lo: start, hi: end})
+	}
+	return out, nil
+}
+
+func inRanges(ranges []idRange, v uint64) bool {
+	for _, r := range ranges {
+		if r.contains(v) {
+			return true
+		}
+	}
+	return false
+}
+
+type markAssignment struct {
+	Mark  uint32 `json:"mark"`
+	Table int    `json:"table"`
+}
+
+type markState struct {
+	Mask        uint32                    `json:"mask"`
+	Assignments map[string]markAssignment `json:"assignments"`
+}
+
+type markAllocator struct {
+	path           string
+	mask           uint32
+	reservedMarks  []idRange
+	reservedTables []idRange
+	assigned       map[string]markAssignment
+	fresh          bool
+}
+
+func newMarkAllocator() *markAllocator {
+	return &markAllocator{mask: fullMarkMask, assigned: make(map[string]markAssignment), fresh: true}
+}
+
+func loadMarkAllocator(opts MarkOptions) (*markAllocator, error) {
+	a := newMarkAllocator()
+	if opts.Mask != 0 {
+		a.mask = opts.Mask
+	}
+	width := a.mask >> bits.TrailingZeros32(a.mask)
+	if width&(width+1) != 0 {
+		return nil, fmt.Errorf("mark mask 0x%x must be a contiguous run of bits", a.mask)
+	}
+	var err error
+	if a.reservedMarks, err = parseIDRanges(opts.ReservedMarks); err != nil {
+		return nil, fmt.Errorf("reserved marks: %w", err)
+	}
+	if a.reservedTables, err = parseIDRanges(opts.ReservedTables); err != nil {
+		return nil, fmt.Errorf("reserved tables: %w", err)
+	}
+
+	a.path = opts.StatePath
+	if a.path == "" {
+		return a, nil
+	}
+	data, err := os.ReadFile(a.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read mark state: %w", err)
+	}
+	var state markState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("parse mark state %s: %w", a.path, err)
+	}
+	a.fresh = false
+	if state.Mask != a.mask {
+		logx.Warnf("mark mask changed from 0x%x to 0x%x, reassigning fwmarks", state.Mask, a.mask)
+		return a, nil
+	}
+	for name, entry := range state.Assignments {
+		a.assigned[name] = entry
+	}
+	return a, nil
+}
+
+func (a *markAllocator) save() error {
+	if a.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(markState{Mask: a.mask, Assignments: a.assigned}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return err
+	}
+	tmp := a.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, a.path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func (a *markAllocator) fwmark(entry markAssignment) fwmark {
+	return fwmark{Value: entry.Mark, Mask: a.mask}
+}
+
+func (a *markAllocator) owns(rule ipRuleEntry) bool {
+	if !rule.HasMark {
+		return false
+	}
+	for _, entry := range a.assigned {
+		if rule.Table == entry.Table && rule.Mark == a.fwmark(entry) {
+			return true
+		}
+	}
+	return a.fresh && isLegacyIPRule(rule)
+}
+
+func isLegacyIPRule(rule ipRuleEntry) bool {
+	return rule.HasMark && rule.Mark.Mask == fullMarkMask &&
+		int(rule.Mark.Value) == rule.Table && rule.Table >= idBase && rule.Table < idBase+idSpace
+}
+
+func (a *markAllocator) markConflict(value uint32, name string, live []ipRuleEntry, fixed []uint32) string {
+	if value == 0 {
+		return "zero mark"
+	}
+	if inRanges(a.reservedMarks, uint64(value)) {
+		return "reserved mark range"
+	}
+	ours := fwmark{Value: value, Mask: a.mask}
+	for _, m := range fixed {
+		if m != 0 && ours.matches(m) {
+			return fmt.Sprintf("mark %d used by vpner", m)
+		}
+	}
+	for other, entry := range a.assigned {
+		if other != name && entry.Mark == value {
+			return "mark assigned to " + other
+		}
+	}
+	for _, rule := range live {
+		if !rule.HasMark || a.owns(rule) {
+			continue
+		}
+		if rule.Mark.matches(value) || ours.matches(rule.Mark.Value) {
+			return fmt.Sprintf("ip rule fwmark %s", rule.Mark)
+		}
+	}
+	return ""
+}
+
+func (a *markAllocator) tableConflict(table int, name string, live []ipRuleEntry) string {
+	for _, t := range builtinReservedTables {
+		if table == t {
+			return "reserved table"
+		}
+	}
+	if inRanges(a.reservedTables, uint64(table)) {
+		return "reserved table range"
+	}
+	for other, entry := range a.assigned {
+		if other != name && entry.Table == table {
+			return "table assigned to " + other
+		}
+	}
+	for _, rule := range live {
+		if rule.Table == table && !a.owns(rule) {
+			return "table used by ip rule"
+		}
+	}
+	return ""
+}
+
+func (a *markAllocator) markCandidates(start uint32) []uint32 {
+	if a.mask == fullMarkMask {
+		out := make([]uint32, 0, idSpace)
+		for k := uint32(0); k < idSpace; k++ {
+			out = append(out, idBase+(start+k)%idSpace)
+		}
+		return out
+	}
+	shift := bits.TrailingZeros32(a.mask)
+	width := uint64(a.mask>>shift) + 1
+	limit := width - 1
+	if limit > idSpace*16 {
+		limit = idSpace * 16
+	}
+	out := make([]uint32, 0, limit)
+	for k := uint64(0); k < limit; k++ {
+		out = append(out, uint32((uint64(start)+k)%(width-1)+1)<<shift)
+	}
+	return out
+}
+
+func (a *markAllocator) assign(name string, live []ipRuleEntry, fixed []uint32) (markAssignment, error) {
+	if entry, ok := a.assigned[name]; ok {
+		return entry, nil
+	}
+	start := checksumIPSetName(name) & (idSpace - 1)
+
+	var entry markAssignment
+	for _, value := range a.markCandidates(start) {
+		if a.markConflict(value, name, live, fixed) == "" {
+			entry.Mark = value
+			break
+		}
+	}
+	if entry.Mark == 0 {
+		return entry, fmt.Errorf("no free fwmark left under mask 0x%x for %s", a.mask, name)
+	}
+	for k := 0; k < idSpace; k++ {
+		table := idBase + (int(start)+k)%idSpace
+		if a.tableConflict(table, name, live) == "" {
+			entry.Table = table
+			break
+		}
+	}
+	if entry.Table == 0 {
+		return entry, fmt.Errorf("no free routing table left for %s", name)
+	}
+	a.assigned[name] = entry
+	return entry, nil
+}
+
+func (a *markAllocator) dropCollisions(live []ipRuleEntry, fixed []uint32) []string {
+	names := make([]string, 0, len(a.assigned))
+	for name := range a.assigned {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var dropped []string
+	for _, name := range names {
+		entry := a.assigned[name]
+		reason := a.markConflict(entry.Mark, name, live, fixed)
+		if reason == "" {
+			reason = a.tableConflict(entry.Table, name, live)
+		}
+		if reason == "" {
+			continue
+		}
+		logx.Warnf("fwmark collision for %s (mark %s, table %d): %s; reassigning", name, a.fwmark(entry), entry.Table, reason)
+		delete(a.assigned, name)
+		dropped = append(dropped, name)
+	}
+	return dropped
+}
+
+type ipRuleEntry struct {
+	HasMark bool
+	Mark    fwmark
+	Table   int
+}
+
+var namedRouteTables = map[string]int{"default": 253, "main": 254, "local": 255}
+
+func parseIPRules(out string) []ipRuleEntry {
+	var entries []ipRuleEntry
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.Fields(line)
+		var entry ipRuleEntry
+		for idx := 0; idx+1 < len(parts); idx++ {
+			switch parts[idx] {
+			case "fwmark":
+				value, mask, hasMask := strings.Cut(parts[idx+1], "/")
+				v, err := strconv.ParseUint(value, 0, 32)
+				if err != nil {
+					continue
+				}
+				entry.HasMark = true
+				entry.Mark = fwmark{Value: uint32(v), Mask: fullMarkMask}
+				if hasMask {
+					if m, err := strconv.ParseUint(mask, 0, 32); err == nil {
+						entry.Mark.Mask = uint32(m)
+					}
+				}
+			case "lookup", "table":
+				if t, ok := namedRouteTables[parts[idx+1]]; ok {
+					entry.Table = t
+				} else if t, err := strconv.Atoi(parts[idx+1]); err == nil {
+					entry.Table = t
+				}
+			}
+		}
+		if entry.HasMark || entry.Table != 0 {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func listIPRules(f ipFamily) ([]ipRuleEntry, error) {
+	out, err := exec.Command("ip", append(f.ipFlags, "rule", "show")...).Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseIPRules(string(out)), nil
+}
+
+func ipRulePresent(f ipFamily, mark fwmark, table int) bool {
+	live, err := listIPRules(f)
+	if err != nil {
+		return false
+	}
+	for _, rule := range live {
+		if rule.HasMark && rule.Mark == mark && rule.Table == table {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *IptablesManager) ConfigureMarks(opts MarkOptions) error {
+	marks, err := loadMarkAllocator(opts)
+	if err != nil {
+		return err
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.marks = marks
+	return nil
+}
+
+func (i *IptablesManager) fixedMarksLocked() []uint32 {
+	return []uint32{tproxyMarkBits, uint32(i.localIntercept.BypassMark)}
+}
+
+func (i *IptablesManager) allocMarkLocked(f ipFamily, ipsetName string) (fwmark, int, error) {
+	if entry, ok := i.marks.assigned[ipsetName]; ok {
+		return i.marks.fwmark(entry), entry.Table, nil
+	}
+	live, err := listIPRules(f)
+	if err != nil {
+		logx.Warnf("failed to list ip rules for fwmark allocation: %v", err)
+	}
+	entry, err := i.marks.assign(ipsetName, live, i.fixedMarksLocked())
+	if err != nil {
+		return fwmark{}, 0, err
+	}
+	if err := i.marks.save(); err != nil {
+		logx.Warnf("failed to persist fwmark assignments: %v", err)
+	}
+	logx.Infof("assigned fwmark %s table %d to %s", i.marks.fwmark(entry), entry.Table, ipsetName)
+	return i.marks.fwmark(entry), entry.Table, nil
+}
+
+func (i *IptablesManager) checkMarkCollisions() {
+	var live []ipRuleEntry
+	for _, f := range []ipFamily{familyV4, familyV6} {
+		if f.nftAddr == familyV6.nftAddr && !i.ipv6Enabled {
+			continue
+		}
+		entries, err := listIPRules(f)
+		if err != nil {
+			logx.Warnf("failed to list ip rules: %v", err)
+			continue
+		}
+		live = append(live, entries...)
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.marks.fresh = false
+	i.marks.dropCollisions(live, i.fixedMarksLocked())
+	if err := i.marks.save(); err != nil {
+		logx.Warnf("failed to persist fwmark assignments: %v", err)
+	}
+}
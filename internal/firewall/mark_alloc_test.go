@@ -0,0 +1,127 @@
+package firewall
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseIPRules(t *testing.T) {
+	out := `0:	from all lookup local
+100:	from all fwmark 0xc8 lookup 200
+1000:	from all fwmark 0xffffaaa/0xfffffff lookup 42
+32765:	from all fwmark 0x64 lookup 100
+32766:	from all lookup main
+`
+	got := parseIPRules(out)
+	want := []ipRuleEntry{
+		{Table: 255},
+		{HasMark: true, Mark: fwmark{Value: 200, Mask: fullMarkMask}, Table: 200},
+		{HasMark: true, Mark: fwmark{Value: 0xffffaaa, Mask: 0xfffffff}, Table: 42},
+		{HasMark: true, Mark: fwmark{Value: 100, Mask: fullMarkMask}, Table: 100},
+		{Table: 254},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d rules, want %d: %+v", len(got), len(want), got)
+	}
+	for idx := range want {
+		if got[idx] != want[idx] {
+			t.Fatalf("rule %d: got %+v, want %+v", idx, got[idx], want[idx])
+		}
+	}
+}
+
+func TestMarkAllocatorAvoidsCollisions(t *testing.T) {
+	a := newMarkAllocator()
+	name := "vpner-OpenVPN-tun0"
+	first, err := a.assign(name, nil, nil)
+	if err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	if first.Mark < idBase || first.Table < idBase {
+		t.Fatalf("unexpected assignment %+v", first)
+	}
+
+	b := newMarkAllocator()
+	b.reservedTables = []idRange{{lo: uint64(first.Table), hi: uint64(first.Table)}}
+	live := []ipRuleEntry{{HasMark: true, Mark: fwmark{Value: first.Mark, Mask: fullMarkMask}, Table: 7}}
+	got, err := b.assign(name, live, nil)
+	if err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	if got.Mark == first.Mark || got.Table == first.Table {
+		t.Fatalf("expected foreign mark and reserved table to be skipped, got %+v", got)
+	}
+
+	other, err := b.assign("vpner-WireGuard-wg0", nil, nil)
+	if err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	if other.Mark == got.Mark || other.Table == got.Table {
+		t.Fatalf("duplicate assignment %+v vs %+v", other, got)
+	}
+}
+
+func TestMarkAllocatorMask(t *testing.T) {
+	a, err := loadMarkAllocator(MarkOptions{Mask: 0xff0000})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	entry, err := a.assign("vpner-OpenVPN-tun0", nil, []uint32{tproxyMarkBits})
+	if err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	if entry.Mark == 0 || entry.Mark&^0xff0000 != 0 {
+		t.Fatalf("mark %#x outside mask", entry.Mark)
+	}
+	if got := a.fwmark(entry).String(); got != fmt.Sprintf("0x%x/0xff0000", entry.Mark) {
+		t.Fatalf("unexpected fwmark %s", got)
+	}
+
+	if _, err := loadMarkAllocator(MarkOptions{Mask: 0xf0f0}); err == nil {
+		t.Fatal("expected error for non-contiguous mask")
+	}
+}
+
+func TestMarkAllocatorPersistsAndDropsCollisions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "marks.json")
+	a, err := loadMarkAllocator(MarkOptions{StatePath: path})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !a.fresh {
+		t.Fatal("allocator without state file must be fresh")
+	}
+	entry, err := a.assign("vpner-OpenVPN-tun0", nil, nil)
+	if err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	if err := a.save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	b, err := loadMarkAllocator(MarkOptions{StatePath: path})
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if b.fresh || b.assigned["vpner-OpenVPN-tun0"] != entry {
+		t.Fatalf("assignment not restored: %+v", b.assigned)
+	}
+
+	own := ipRuleEntry{HasMark: true, Mark: b.fwmark(entry), Table: entry.Table}
+	if dropped := b.dropCollisions([]ipRuleEntry{own}, nil); len(dropped) != 0 {
+		t.Fatalf("own ip rule reported as collision: %v", dropped)
+	}
+	foreign := ipRuleEntry{Table: entry.Table}
+	if dropped := b.dropCollisions([]ipRuleEntry{foreign}, nil); len(dropped) != 1 {
+		t.Fatalf("expected collision with foreign table, got %v", dropped)
+	}
+
+	c, err := loadMarkAllocator(MarkOptions{StatePath: path, Mask: 0xff00})
+	if err != nil {
+		t.Fatalf("reload with mask: %v", err)
+	}
+	if len(c.assigned) != 0 {
+		t.Fatalf("mask change must drop assignments, got %+v", c.assigned)
+	}
+}
@@ -0,0 +1,203 @@
+package firewall
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/ApostolDmitry/vpner/internal/logx"
+)
+
+const (
+	nftFamily = "inet"
+	nftTable  = "vpner"
+)
+
+func nftRun(script string) error {
+	logx.Debugf("nft batch:\n%s", script)
+
+	cmd := exec.Command("nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(script)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("nft -f failed: %v (%s)", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func nftCheck(script string) error {
+	cmd := exec.Command("nft", "-c", "-f", "-")
+	cmd.Stdin = strings.NewReader(script)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("nft -c failed: %v (%s)", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func nftObject(kind, name string) string {
+	return fmt.Sprintf("%s %s %s %s", kind, nftFamily, nftTable, name)
+}
+
+func nftTableObjects(kind string) []string {
+	out, err := exec.Command("nft", "-j", "list", "table", nftFamily, nftTable).Output()
+	if err != nil {
+		return nil
+	}
+	return parseNftObjectNames(out, kind)
+}
+
+func parseNftObjectNames(data []byte, kind string) []string {
+	var doc struct {
+		Nftables []map[string]json.RawMessage `json:"nftables"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil
+	}
+	var names []string
+	for _, item := range doc.Nftables {
+		raw, ok := item[kind]
+		if !ok {
+			continue
+		}
+		var obj struct {
+			Name  string `json:"name"`
+			Table string `json:"table"`
+		}
+		if json.Unmarshal(raw, &obj) == nil && obj.Table == nftTable && obj.Name != "" {
+			names = append(names, obj.Name)
+		}
+	}
+	return names
+}
+
+func parseNftSetElements(data []byte) ([]ipsetEntry, error) {
+	var doc struct {
+		Nftables []struct {
+			Set *struct {
+				Elem []json.RawMessage `json:"elem"`
+			} `json:"set"`
+		} `json:"nftables"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse nft set: %w", err)
+	}
+
+	var entries []ipsetEntry
+	for _, item := range doc.Nftables {
+		if item.Set == nil {
+			continue
+		}
+		for _, raw := range item.Set.Elem {
+			entry, ok := parseNftElem(raw)
+			if ok {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return entries, nil
+}
+
+type nftSetInfo struct {
+	Flags    []string
+	Size     int
+	Elements int
+}
+
+func nftSetInspect(name string) (nftSetInfo, error) {
+	out, err := exec.Command("nft", "-j", "list", "set", nftFamily, nftTable, name).Output()
+	if err != nil {
+		return nftSetInfo{}, fmt.Errorf("failed to inspect nft set %s: %w", name, err)
+	}
+	return parseNftSetInfo(out)
+}
+
+func parseNftSetInfo(data []byte) (nftSetInfo, error) {
+	var doc struct {
+		Nftables []struct {
+			Set *struct {
+				Flags json.RawMessage   `json:"flags"`
+				Size  int               `json:"size"`
+				Elem  []json.RawMessage `json:"elem"`
+			} `json:"set"`
+		} `json:"nftables"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nftSetInfo{}, fmt.Errorf("parse nft set: %w", err)
+	}
+	for _, item := range doc.Nftables {
+		if item.Set == nil {
+			continue
+		}
+		info := nftSetInfo{Size: item.Set.Size, Elements: len(item.Set.Elem)}
+		if len(item.Set.Flags) == 0 {
+			return info, nil
+		}
+		if json.Unmarshal(item.Set.Flags, &info.Flags) == nil {
+			return info, nil
+		}
+		var flag string
+		if err := json.Unmarshal(item.Set.Flags, &flag); err != nil {
+			return nftSetInfo{}, fmt.Errorf("parse nft set flags: %w", err)
+		}
+		info.Flags = []string{flag}
+		return info, nil
+	}
+	return nftSetInfo{}, nil
+}
+
+func parseNftElem(raw json.RawMessage) (ipsetEntry, bool) {
+	var wrapped struct {
+		Elem *struct {
+			Val     json.RawMessage `json:"val"`
+			Comment string          `json:"comment"`
+			Expires int             `json:"expires"`
+		} `json:"elem"`
+	}
+	if json.Unmarshal(raw, &wrapped) == nil && wrapped.Elem != nil {
+		value, ok := parseNftValue(wrapped.Elem.Val)
+		return ipsetEntry{Entry: value, Comment: wrapped.Elem.Comment, Timeout: wrapped.Elem.Expires}, ok
+	}
+	value, ok := parseNftValue(raw)
+	return ipsetEntry{Entry: value}, ok
+}
+
+func parseNftValue(raw json.RawMessage) (string, bool) {
+	var plain string
+	if json.Unmarshal(raw, &plain) == nil {
+		return plain, plain != ""
+	}
+	var value struct {
+		Prefix *struct {
+			Addr string `json:"addr"`
+			Len  int    `json:"len"`
+		} `json:"prefix"`
+		Range []string `json:"range"`
+	}
+	if json.Unmarshal(raw, &value) != nil {
+		return "", false
+	}
+	switch {
+	case value.Prefix != nil:
+		return value.Prefix.Addr + "/" + strconv.Itoa(value.Prefix.Len), true
+	case len(value.Range) == 2:
+		return value.Range[0] + "-" + value.Range[1], true
+	}
+	return "", false
+}
+
+func nftQuote(value string) string {
+	var b bytes.Buffer
+	b.WriteByte('"')
+	for _, r := range value {
+		if r == '"' || r == '\\' {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
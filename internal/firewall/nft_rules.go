@@ -0,0 +1,488 @@
+package firewall
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ApostolDmitry/vpner/internal/chainpolicy"
+)
+
+const (
+	nftHookNat      = "prerouting_nat"
+	nftHookMangle   = "prerouting_mangle"
+	nftHookForward  = "forward_filter"
+	nftHookNatOut   = "output_nat"
+	nftHookRouteOut = "output_route"
+
+	nftKillSwitchSuffix = "_ks"
+)
+
+var nftBaseChains = []struct{ name, def string }{
+	{nftHookNat, "type nat hook prerouting priority dstnat; policy accept;"},
+	{nftHookMangle, "type filter hook prerouting priority mangle; policy accept;"},
+	{nftHookForward, "type filter hook forward priority filter; policy accept;"},
+	{nftHookNatOut, "type nat hook output priority -100; policy accept;"},
+	{nftHookRouteOut, "type route hook output priority mangle; policy accept;"},
+}
+
+type nftChain struct {
+	hook   string
+	ifaces []string
+	rules  map[string][]string
+}
+
+func (c nftChain) orderedRules() []string {
+	var out []string
+	for _, family := range []string{"", "ip", "ip6"} {
+		out = append(out, c.rules[family]...)
+	}
+	return out
+}
+
+type nftRules struct {
+	mu      sync.Mutex
+	chains  map[string]nftChain
+	dropped map[string]struct{}
+	tproxy  bool
+}
+
+func newNftRules() *nftRules {
+	return &nftRules{
+		chains:  make(map[string]nftChain),
+		dropped: make(map[string]struct{}),
+	}
+}
+
+func nftChainName(table, chain string) string {
+	if table == tableFilter {
+		return chain + nftKillSwitchSuffix
+	}
+	return chain
+}
+
+func (n *nftRules) applyXray(f ipFamily, specs []ChainSpec, tproxy bool) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	hook := nftHookNat
+	if tproxy {
+		hook = nftHookMangle
+	}
+	for _, spec := range specs {
+		name := buildChainName(spec.IPSetName)
+		var chainRules []string
+		if tproxy {
+			chainRules = append(chainRules, fmt.Sprintf("meta mark %s return", tproxyMark))
+		}
+		ft := fullTunnelChainName(name)
+		if len(spec.FullTunnel) > 0 {
+			n.setRulesLocked(ft, "", nil, f.nftAddr, nftFullTunnelRules(f, spec, tproxy))
+			chainRules = append(chainRules, nftFullTunnelJumps(f, name, spec.FullTunnel)...)
+		} else {
+			n.dropRulesLocked(ft, f.nftAddr)
+		}
+		chainRules = append(chainRules, nftClientFilterRules(f, spec.Clients)...)
+		for _, iface := range spec.Ifaces {
+			if tproxy {
+				chainRules = append(chainRules, nftReturnCIDRs(f, iface)...)
+				chainRules = append(chainRules, nftTProxyRules(f, iface, spec.IPSetName, spec.Port)...)
+				continue
+			}
+			chainRules = append(chainRules, nftRedirectRule(f, iface, spec.IPSetName, spec.Port))
+		}
+		n.setRulesLocked(name, hook, spec.Ifaces, f.nftAddr, chainRules)
+		n.setUDPPolicyLocked(f, name, spec)
+		n.setLocalInterceptLocked(f, name, spec, tproxy)
+	}
+	if tproxy {
+		n.setRulesLocked(chainDivert, "", nil, "", []string{fmt.Sprintf("meta mark set %s accept", tproxyMark)})
+		n.tproxy = true
+	}
+	return n.commitLocked(f)
+}
+
+func (n *nftRules) setUDPPolicyLocked(f ipFamily, name string, spec ChainSpec) {
+	quic, udp := nftChainName(tableFilter, quicBlockChainName(name)), udpTProxyChainName(name)
+	switch spec.UDP {
+	case chainpolicy.UDPBlockQUIC:
+		n.dropRulesLocked(udp, f.nftAddr)
+		n.setRulesLocked(quic, nftHookForward, spec.Ifaces, f.nftAddr, nftQUICBlockRules(f, spec))
+	case chainpolicy.UDPTProxyUDPOnly:
+		n.dropRulesLocked(quic, f.nftAddr)
+		n.setRulesLocked(udp, nftHookMangle, spec.Ifaces, f.nftAddr, nftUDPTProxyRules(f, spec))
+	default:
+		n.dropRulesLocked(quic, f.nftAddr)
+		n.dropRulesLocked(udp, f.nftAddr)
+	}
+}
+
+func (n *nftRules) setLocalInterceptLocked(f ipFamily, name string, spec ChainSpec, tproxy bool) {
+	output, loopback := localOutputChainName(name), localLoopbackChainName(name)
+	if !spec.Local.Enabled {
+		n.dropRulesLocked(output, f.nftAddr)
+		n.dropRulesLocked(loopback, f.nftAddr)
+		return
+	}
+	if !tproxy {
+		n.setRulesLocked(output, nftHookNatOut, nil, f.nftAddr, nftLocalOutputRules(f, spec, false))
+		n.dropRulesLocked(loopback, f.nftAddr)
+		return
+	}
+	n.setRulesLocked(output, nftHookRouteOut, nil, f.nftAddr, nftLocalOutputRules(f, spec, true))
+	n.setRulesLocked(loopback, nftHookMangle, []string{loIface}, f.nftAddr, nftLocalLoopbackRules(f, spec))
+}
+
+func nftIface(iface string) string {
+	return "iifname " + nftQuote(iface)
+}
+
+func nftReturnCIDRs(f ipFamily, iface string) []string {
+	if len(f.localExceptions) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s %s daddr { %s } return", nftIface(iface), f.nftAddr, strings.Join(f.localExceptions, ", "))}
+}
+
+func nftRedirectRule(f ipFamily, iface, ipsetName string, port int) string {
+	return fmt.Sprintf("%s %s daddr @%s meta l4proto tcp redirect to :%d", nftIface(iface), f.nftAddr, ipsetName, port)
+}
+
+func nftTProxyRules(f ipFamily, iface, ipsetName string, port int) []string {
+	var out []string
+	for _, proto := range []string{"tcp", "udp"} {
+		out = append(out, fmt.Sprintf(
+			"%s %s daddr @%s meta l4proto %s meta mark set %s tproxy %s to :%d accept",
+			nftIface(iface), f.nftAddr, ipsetName, proto, tproxyMark, f.nftAddr, port,
+		))
+	}
+	return out
+}
+
+func (n *nftRules) applyMarkChain(f ipFamily, chainName, ipsetName string, mark fwmark, ifaces []string, clients ClientFilter) ([]jumpRule, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	chainRules := nftClientFilterRules(f, clients)
+	var jumps []jumpRule
+	for _, iface := range ifaces {
+		chainRules = append(chainRules, nftReturnCIDRs(f, iface)...)
+		chainRules = append(chainRules, fmt.Sprintf(
+			"%s %s daddr @%s meta l4proto { tcp, udp } %s",
+			nftIface(iface), f.nftAddr, ipsetName, mark.nftSet(),
+		))
+		jumps = appendJumpRule(jumps, nftJumpRule(nftHookMangle, chainName, iface))
+	}
+	n.setRulesLocked(chainName, nftHookMangle, ifaces, f.nftAddr, chainRules)
+	if err := n.commitLocked(f); err != nil {
+		n.dropRulesLocked(chainName, f.nftAddr)
+		return nil, err
+	}
+	return jumps, nil
+}
+
+func nftJumpRule(hook, chain, iface string) jumpRule {
+	return jumpRule{
+		Cmd:  "nft",
+		Args: []string{"add", "rule", nftFamily, nftTable, hook, "iifname", iface, "jump", chain},
+	}
+}
+
+func (n *nftRules) removeChain(f ipFamily, info vpnRoutingInfo) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	dropped := n.dropRulesLocked(info.ChainName, f.nftAddr)
+	for _, extra := range []string{
+		fullTunnelChainName(info.ChainName),
+		nftChainName(tableFilter, quicBlockChainName(info.ChainName)),
+		udpTProxyChainName(info.ChainName),
+		localOutputChainName(info.ChainName),
+		localLoopbackChainName(info.ChainName),
+	} {
+		if n.dropRulesLocked(extra, f.nftAddr) {
+			dropped = true
+		}
+	}
+	if !dropped {
+		return
+	}
+	_ = n.commitLocked(f)
+}
+
+func (n *nftRules) removeFullTunnel(f ipFamily, _, chainName string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !n.dropRulesLocked(fullTunnelChainName(chainName), f.nftAddr) {
+		return
+	}
+	_ = n.commitLocked(f)
+}
+
+func (n *nftRules) applyKillSwitch(f ipFamily, ipsetName string, info killSwitchInfo) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	name := nftChainName(tableFilter, buildChainName(ipsetName))
+	chainRules := nftClientFilterRules(f, info.Clients)
+	for _, iface := range info.Ifaces {
+		match := fmt.Sprintf("%s %s daddr @%s", nftIface(iface), f.nftAddr, ipsetName)
+		if info.Mode == chainpolicy.KillSwitchDrop {
+			chainRules = append(chainRules, match+" drop")
+			continue
+		}
+		chainRules = append(chainRules,
+			match+" meta l4proto tcp reject with tcp reset",
+			match+" reject",
+		)
+	}
+	n.setRulesLocked(name, nftHookForward, info.Ifaces, f.nftAddr, chainRules)
+	return n.commitLocked(f)
+}
+
+func (n *nftRules) removeKillSwitch(f ipFamily, ipsetName string, _ killSwitchInfo) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !n.dropRulesLocked(nftChainName(tableFilter, buildChainName(ipsetName)), f.nftAddr) {
+		return
+	}
+	_ = n.commitLocked(f)
+}
+
+func (n *nftRules) chainPresent(_ ipFamily, table, chain string) bool {
+	return exec.Command("nft", "list", "chain", nftFamily, nftTable, nftChainName(table, chain)).Run() == nil
+}
+
+func (n *nftRules) targetPresent(_ ipFamily, table, chain, ipsetName string) bool {
+	out, err := exec.Command("nft", "list", "chain", nftFamily, nftTable, nftChainName(table, chain)).Output()
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.Contains(line, "@"+ipsetName+" ") && isRoutingTarget(line) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *nftRules) jumpPresent(j jumpRule) bool {
+	if len(j.Args) < 6 {
+		return false
+	}
+	out, err := exec.Command("nft", "list", "chain", j.Args[2], j.Args[3], j.Args[4]).Output()
+	if err != nil {
+		return false
+	}
+	want := strings.Join(j.Args[5:], " ")
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasSuffix(strings.TrimSpace(strings.ReplaceAll(line, `"`, "")), want) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *nftRules) cleanupStale(f ipFamily) {
+	chains := nftTableObjects("chain")
+	if len(chains) == 0 {
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "flush %s %s %s\n", "table", nftFamily, nftTable)
+	for _, name := range chains {
+		fmt.Fprintf(&b, "delete %s\n", nftObject("chain", name))
+	}
+	if err := nftRun(b.String()); err != nil {
+		f.tryRun("nft", "flush", "table", nftFamily, nftTable)
+	}
+}
+
+func (n *nftRules) cleanupTProxy(f ipFamily) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !n.tproxy {
+		return
+	}
+	n.tproxy = false
+	n.dropRulesLocked(chainDivert, "")
+	_ = n.commitLocked(f)
+}
+
+func (n *nftRules) loadTProxyModules(release string) error {
+	for _, mod := range []string{"nft_tproxy", "nft_socket"} {
+		path := fmt.Sprintf("/lib/modules/%s/%s.ko", release, mod)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if err := loadKernelModule(path); err != nil {
+			return fmt.Errorf("module %s: %w", mod, err)
+		}
+	}
+	return nil
+}
+
+func (n *nftRules) probeTProxy(f ipFamily) error {
+	const probeTable = "vpner_probe"
+	var b strings.Builder
+	fmt.Fprintf(&b, "add table %s %s\n", nftFamily, probeTable)
+	fmt.Fprintf(&b, "add chain %s %s p { type filter hook prerouting priority mangle; }\n", nftFamily, probeTable)
+	fmt.Fprintf(&b, "add rule %s %s p meta l4proto tcp socket transparent 1 accept\n", nftFamily, probeTable)
+	for _, proto := range []string{"tcp", "udp"} {
+		fmt.Fprintf(&b, "add rule %s %s p meta nfproto %s meta l4proto %s tproxy %s to :1 accept\n",
+			nftFamily, probeTable, nftNfproto(f), proto, f.nftAddr)
+	}
+	if err := nftCheck(b.String()); err != nil {
+		return fmt.Errorf("nft tproxy/socket expressions not supported: %w", err)
+	}
+	return nil
+}
+
+func (n *nftRules) probeUDPTProxy(f ipFamily, release string) error {
+	path := fmt.Sprintf("/lib/modules/%s/nft_tproxy.ko", release)
+	if _, err := os.Stat(path); err == nil {
+		if err := loadKernelModule(path); err != nil {
+			return fmt.Errorf("module nft_tproxy: %w", err)
+		}
+	}
+	const probeTable = "vpner_probe"
+	var b strings.Builder
+	fmt.Fprintf(&b, "add table %s %s\n", nftFamily, probeTable)
+	fmt.Fprintf(&b, "add chain %s %s p { type filter hook prerouting priority mangle; }\n", nftFamily, probeTable)
+	fmt.Fprintf(&b, "add rule %s %s p meta nfproto %s meta l4proto udp tproxy %s to :1 accept\n",
+		nftFamily, probeTable, nftNfproto(f), f.nftAddr)
+	if err := nftCheck(b.String()); err != nil {
+		return fmt.Errorf("nft udp tproxy not supported: %w", err)
+	}
+	return nil
+}
+
+func nftNfproto(f ipFamily) string {
+	if f.nftAddr == "ip6" {
+		return "ipv6"
+	}
+	return "ipv4"
+}
+
+func (n *nftRules) snapshot() func() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	chains := make(map[string]nftChain, len(n.chains))
+	for name, chain := range n.chains {
+		rules := make(map[string][]string, len(chain.rules))
+		for family, list := range chain.rules {
+			rules[family] = slices.Clone(list)
+		}
+		chains[name] = nftChain{hook: chain.hook, ifaces: slices.Clone(chain.ifaces), rules: rules}
+	}
+	dropped := maps.Clone(n.dropped)
+	tproxy := n.tproxy
+	return func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		n.chains, n.dropped, n.tproxy = chains, dropped, tproxy
+	}
+}
+
+func (n *nftRules) setRulesLocked(name, hook string, ifaces []string, family string, chainRules []string) {
+	chain, ok := n.chains[name]
+	if !ok {
+		chain = nftChain{hook: hook, rules: make(map[string][]string)}
+	}
+	for _, iface := range ifaces {
+		if !slices.Contains(chain.ifaces, iface) {
+			chain.ifaces = append(chain.ifaces, iface)
+		}
+	}
+	chain.rules[family] = chainRules
+	n.chains[name] = chain
+	delete(n.dropped, name)
+}
+
+func (n *nftRules) dropRulesLocked(name, family string) bool {
+	chain, ok := n.chains[name]
+	if !ok {
+		return false
+	}
+	delete(chain.rules, family)
+	if len(chain.rules) == 0 {
+		delete(n.chains, name)
+		n.dropped[name] = struct{}{}
+	}
+	return true
+}
+
+func (n *nftRules) commitLocked(f ipFamily) error {
+	script := n.renderLocked()
+	if f.plan.record(PlanStep{Tool: "nft", Table: nftFamily + " " + nftTable, Lines: strings.Split(strings.TrimSpace(script), "\n")}) {
+		return nil
+	}
+	if err := nftRun(script); err != nil {
+		return err
+	}
+	n.dropped = make(map[string]struct{})
+	return nil
+}
+
+func (n *nftRules) renderLocked() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "add table %s %s\n", nftFamily, nftTable)
+	for _, base := range nftBaseChains {
+		fmt.Fprintf(&b, "add %s { %s }\n", nftObject("chain", base.name), base.def)
+		fmt.Fprintf(&b, "flush %s\n", nftObject("chain", base.name))
+	}
+
+	names := make([]string, 0, len(n.chains))
+	for name := range n.chains {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(&b, "add %s\n", nftObject("chain", name))
+		fmt.Fprintf(&b, "flush %s\n", nftObject("chain", name))
+	}
+	for _, name := range names {
+		for _, rule := range n.chains[name].orderedRules() {
+			fmt.Fprintf(&b, "add rule %s %s %s %s\n", nftFamily, nftTable, name, rule)
+		}
+	}
+
+	if n.tproxy {
+		fmt.Fprintf(&b, "add rule %s %s %s meta l4proto tcp socket transparent 1 jump %s\n",
+			nftFamily, nftTable, nftHookMangle, chainDivert)
+	}
+	for _, name := range names {
+		chain := n.chains[name]
+		if chain.hook == "" {
+			continue
+		}
+		if chain.hook == nftHookNatOut || chain.hook == nftHookRouteOut {
+			fmt.Fprintf(&b, "add rule %s %s %s jump %s\n", nftFamily, nftTable, chain.hook, name)
+			continue
+		}
+		for _, iface := range chain.ifaces {
+			fmt.Fprintf(&b, "add rule %s %s %s %s jump %s\n", nftFamily, nftTable, chain.hook, nftIface(iface), name)
+		}
+	}
+
+	dropped := make([]string, 0, len(n.dropped))
+	for name := range n.dropped {
+		dropped = append(dropped, name)
+	}
+	sort.Strings(dropped)
+	for _, name := range dropped {
+		fmt.Fprintf(&b, "add %s\n", nftObject("chain", name))
+		fmt.Fprintf(&b, "delete %s\n", nftObject("chain", name))
+	}
+	return b.String()
+}
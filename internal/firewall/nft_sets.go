@@ -0,0 +1,233 @@
+package firewall
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/ApostolDmitry/vpner/internal/logx"
+)
+
+var errNftNotFound = errors.New("nft utility not found")
+
+type nftSets struct{}
+
+func (nftSets) ready() error {
+	if !commandExists("nft") {
+		return errNftNotFound
+	}
+	return nil
+}
+
+func (nftSets) exists(name string) bool {
+	return exec.Command("nft", "list", "set", nftFamily, nftTable, name).Run() == nil
+}
+
+// ensure creates the set. nft sets get no fixed size, so they never fill up
+// and never have to be rebuilt while rules point at them; sets left with a
+// size by older versions are recreated without one.
+func (n nftSets) ensure(set *IPSet) error {
+	if n.exists(set.Name) && set.HashType != "hash:mac" {
+		if info, err := nftSetInspect(set.Name); err == nil &&
+			(slices.Contains(info.Flags, "timeout") != set.timeouts() || info.Size > 0) {
+			return n.recreate(set)
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "add table %s %s\n", nftFamily, nftTable)
+	fmt.Fprintf(&b, "add %s { %s }\n", nftObject("set", set.Name), nftSetDefinition(set))
+	return nftRun(b.String())
+}
+
+func nftSetDefinition(set *IPSet) string {
+	addrType := "ipv4_addr"
+	if set.HashFamily == "inet6" {
+		addrType = "ipv6_addr"
+	}
+	var flags []string
+	if set.HashType == "hash:mac" {
+		addrType = "ether_addr"
+	} else {
+		flags = append(flags, "interval")
+	}
+	if set.timeouts() {
+		flags = append(flags, "timeout")
+	}
+	def := fmt.Sprintf("type %s;", addrType)
+	if len(flags) > 0 {
+		def += fmt.Sprintf(" flags %s;", strings.Join(flags, ", "))
+	}
+	if set.Timeout > 0 {
+		def += fmt.Sprintf(" timeout %ds;", set.Timeout)
+	}
+	return def
+}
+
+func nftElement(entry, comment string, timeout int) string {
+	elem := entry
+	if timeout > 0 {
+		elem += fmt.Sprintf(" timeout %ds", timeout)
+	}
+	if comment != "" {
+		elem += " comment " + nftQuote(comment)
+	}
+	return elem
+}
+
+func (nftSets) add(name, entry, comment string, timeout int) error {
+	script := fmt.Sprintf("add element %s %s %s { %s }\n", nftFamily, nftTable, name, nftElement(entry, comment, timeout))
+	if timeout > 0 {
+		// "add" keeps the old expiry of an existing element; re-adding it in
+		// the same transaction refreshes the timeout like ipset -exist does.
+		elem := fmt.Sprintf("%s %s %s { %s }\n", nftFamily, nftTable, name, entry)
+		script = "add element " + elem + "delete element " + elem + script
+	}
+	if err := nftRun(script); err != nil {
+		return fmt.Errorf("failed to add entry %s: %w", entry, err)
+	}
+	return nil
+}
+
+// addBatch adds all entries in one transaction, refreshing the timeouts of
+// the ones already present the same way add does. A transaction rejected as
+// a whole is retried entry by entry to find the bad ones.
+func (n nftSets) addBatch(name string, entries []ipsetEntry) []error {
+	errs := make([]error, len(entries))
+	if len(entries) == 0 {
+		return errs
+	}
+	var plain, elems []string
+	for _, e := range entries {
+		if e.Timeout > 0 {
+			plain = append(plain, e.Entry)
+		}
+		elems = append(elems, nftElement(e.Entry, e.Comment, e.Timeout))
+	}
+	var b strings.Builder
+	if len(plain) > 0 {
+		elem := fmt.Sprintf("%s %s %s { %s }\n", nftFamily, nftTable, name, strings.Join(plain, ", "))
+		b.WriteString("add element " + elem + "delete element " + elem)
+	}
+	fmt.Fprintf(&b, "add element %s %s %s { %s }\n", nftFamily, nftTable, name, strings.Join(elems, ", "))
+	if err := nftRun(b.String()); err != nil {
+		logx.Debugf("nft batch into %s failed, adding entries one by one: %v", name, err)
+		for i, e := range entries {
+			errs[i] = n.add(name, e.Entry, e.Comment, e.Timeout)
+		}
+	}
+	return errs
+}
+
+func (nftSets) addOption(name, entry, option string, _ int) error {
+	return fmt.Errorf("ipset option %q is not supported by the nftables backend (set %s, entry %s)", option, name, entry)
+}
+
+func (nftSets) del(name, entry string) error {
+	script := fmt.Sprintf("delete element %s %s %s { %s }\n", nftFamily, nftTable, name, entry)
+	if err := nftRun(script); err != nil {
+		if isNftMissing(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete entry %s: %w", entry, err)
+	}
+	return nil
+}
+
+func (nftSets) test(name, entry string) (bool, error) {
+	err := exec.Command("nft", "get", "element", nftFamily, nftTable, name, "{", entry, "}").Run()
+	return err == nil, nil
+}
+
+func (n nftSets) list(name string) ([]ipsetEntry, error) {
+	if !n.exists(name) {
+		return nil, nil
+	}
+	out, err := exec.Command("nft", "-j", "list", "set", nftFamily, nftTable, name).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect nft set %s: %w", name, err)
+	}
+	return parseNftSetElements(out)
+}
+
+func (nftSets) refresh(set *IPSet, entries []string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "add table %s %s\n", nftFamily, nftTable)
+	fmt.Fprintf(&b, "add %s { %s }\n", nftObject("set", set.Name), nftSetDefinition(set))
+	fmt.Fprintf(&b, "flush %s\n", nftObject("set", set.Name))
+	if len(entries) > 0 {
+		fmt.Fprintf(&b, "add element %s %s %s { %s }\n", nftFamily, nftTable, set.Name, strings.Join(entries, ", "))
+	}
+	if err := nftRun(b.String()); err != nil {
+		return fmt.Errorf("nft set refresh aborted for %s: %w", set.Name, err)
+	}
+	return nil
+}
+
+// recreate replaces a set whose timeout support or size no longer matches,
+// keeping its elements. It only succeeds while no rule references the set, which is the
+// case at startup after the stale chains were removed.
+func (n nftSets) recreate(set *IPSet) error {
+	entries, err := n.list(set.Name)
+	if err != nil {
+		return err
+	}
+	logx.Infof("nft set %s missing required options; recreating", set.Name)
+	var b strings.Builder
+	fmt.Fprintf(&b, "delete %s\n", nftObject("set", set.Name))
+	fmt.Fprintf(&b, "add %s { %s }\n", nftObject("set", set.Name), nftSetDefinition(set))
+	if len(entries) > 0 {
+		elems := make([]string, 0, len(entries))
+		for _, e := range entries {
+			elems = append(elems, nftElement(e.Entry, e.Comment, e.Timeout))
+		}
+		fmt.Fprintf(&b, "add element %s %s %s { %s }\n", nftFamily, nftTable, set.Name, strings.Join(elems, ", "))
+	}
+	if err := nftRun(b.String()); err != nil {
+		return fmt.Errorf("failed to recreate set %s: %w", set.Name, err)
+	}
+	return nil
+}
+
+// usage reports a maximum of 0 for sets without a size, which the capacity
+// checks treat as unbounded.
+func (nftSets) usage(name string) (int, int, error) {
+	info, err := nftSetInspect(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	return info.Elements, info.Size, nil
+}
+
+func (nftSets) flush(name string) error {
+	if err := nftRun(fmt.Sprintf("flush %s\n", nftObject("set", name))); err != nil {
+		return fmt.Errorf("failed to flush set %s: %w", name, err)
+	}
+	return nil
+}
+
+func (nftSets) destroy(name string) error {
+	if err := nftRun(fmt.Sprintf("delete %s\n", nftObject("set", name))); err != nil {
+		return fmt.Errorf("failed to destroy set %s: %w", name, err)
+	}
+	return nil
+}
+
+func (n nftSets) destroyAll() error {
+	var errs []error
+	for _, name := range nftTableObjects("set") {
+		if err := n.destroy(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (nftSets) swap(from, to string) error {
+	return fmt.Errorf("set swap (%s -> %s) is not supported by the nftables backend", from, to)
+}
+
+func isNftMissing(err error) bool {
+	return strings.Contains(err.Error(), "No such file or directory")
+}
@@ -0,0 +1,213 @@
+package firewall
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseBackend(t *testing.T) {
+	cases := map[string]Backend{
+		"":          BackendAuto,
+		"auto":      BackendAuto,
+		"IPTables":  BackendIptables,
+		"nft":       BackendNftables,
+		" nftables": BackendNftables,
+	}
+	for in, want := range cases {
+		got, err := ParseBackend(in)
+		if err != nil || got != want {
+			t.Fatalf("ParseBackend(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseBackend("pf"); err == nil {
+		t.Fatalf("expected error for unknown backend")
+	}
+}
+
+func TestParseNftSetElements(t *testing.T) {
+	data := []byte(`{"nftables": [
+		{"metainfo": {"version": "1.0.9"}},
+		{"set": {"family": "inet", "name": "vpner-Xray-a", "table": "vpner", "type": "ipv4_addr",
+			"elem": [
+				"1.1.1.1",
+				{"prefix": {"addr": "10.0.0.0", "len": 8}},
+				{"range": ["192.168.0.1", "192.168.0.9"]},
+				{"elem": {"val": "8.8.8.8", "timeout": 300, "expires": 120, "comment": "vpner|rule=a|domain=dns.google"}}
+			]}}
+	]}`)
+	got, err := parseNftSetElements(data)
+	if err != nil {
+		t.Fatalf("parseNftSetElements: %v", err)
+	}
+	want := []ipsetEntry{
+		{Entry: "1.1.1.1"},
+		{Entry: "10.0.0.0/8"},
+		{Entry: "192.168.0.1-192.168.0.9"},
+		{Entry: "8.8.8.8", Comment: "vpner|rule=a|domain=dns.google", Timeout: 120},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("entries = %#v, want %#v", got, want)
+	}
+}
+
+func TestParseNftSetInfo(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		want nftSetInfo
+	}{
+		{`{"nftables": [{"set": {"name": "a", "flags": ["interval", "timeout"], "size": 65536, "elem": ["1.1.1.1", "2.2.2.2"]}}]}`,
+			nftSetInfo{Flags: []string{"interval", "timeout"}, Size: 65536, Elements: 2}},
+		{`{"nftables": [{"set": {"name": "a", "flags": "interval"}}]}`, nftSetInfo{Flags: []string{"interval"}}},
+		{`{"nftables": [{"set": {"name": "a"}}]}`, nftSetInfo{}},
+	} {
+		got, err := parseNftSetInfo([]byte(tc.data))
+		if err != nil {
+			t.Fatalf("parseNftSetInfo(%s): %v", tc.data, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("parseNftSetInfo(%s) = %+v, want %+v", tc.data, got, tc.want)
+		}
+	}
+}
+
+func TestParseNftObjectNames(t *testing.T) {
+	data := []byte(`{"nftables": [
+		{"table": {"family": "inet", "name": "vpner"}},
+		{"chain": {"family": "inet", "table": "vpner", "name": "prerouting_nat"}},
+		{"chain": {"family": "inet", "table": "vpner", "name": "VPN_00000001"}},
+		{"set": {"family": "inet", "table": "vpner", "name": "vpner-Xray-a"}}
+	]}`)
+	if got := parseNftObjectNames(data, "chain"); !reflect.DeepEqual(got, []string{"prerouting_nat", "VPN_00000001"}) {
+		t.Fatalf("chains = %v", got)
+	}
+	if got := parseNftObjectNames(data, "set"); !reflect.DeepEqual(got, []string{"vpner-Xray-a"}) {
+		t.Fatalf("sets = %v", got)
+	}
+}
+
+func TestNftRulesRenderMergesFamilies(t *testing.T) {
+	n := newNftRules()
+	n.setRulesLocked("VPN_00000001", nftHookNat, []string{"br0"}, familyV4.nftAddr, []string{"v4 rule"})
+	n.setRulesLocked("VPN_00000001", nftHookNat, []string{"br0"}, familyV6.nftAddr, []string{"v6 rule"})
+
+	script := n.renderLocked()
+	for _, want := range []string{
+		"add rule inet vpner VPN_00000001 v4 rule\n",
+		"add rule inet vpner VPN_00000001 v6 rule\n",
+		"add rule inet vpner prerouting_nat iifname \"br0\" jump VPN_00000001\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Fatalf("script missing %q:\n%s", want, script)
+		}
+	}
+	if strings.Count(script, "jump VPN_00000001") != 1 {
+		t.Fatalf("expected a single jump per iface:\n%s", script)
+	}
+
+	n.dropRulesLocked("VPN_00000001", familyV4.nftAddr)
+	if _, ok := n.chains["VPN_00000001"]; !ok {
+		t.Fatalf("chain dropped while IPv6 rules remain")
+	}
+	n.dropRulesLocked("VPN_00000001", familyV6.nftAddr)
+	script = n.renderLocked()
+	if !strings.Contains(script, "delete chain inet vpner VPN_00000001\n") {
+		t.Fatalf("expected chain deletion:\n%s", script)
+	}
+}
+
+// useFakeNft puts an nft stub on PATH that answers "list set" with setJSON
+// and records the scripts passed to "nft -f -", each ending with "# end".
+func useFakeNft(t *testing.T, setJSON string) func() string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell")
+	}
+	dir := t.TempDir()
+	stub := `#!/bin/sh
+dir=$(dirname "$0")
+case "$*" in
+"-f -") { cat; echo "# end"; } >>"$dir/scripts" ;;
+"-j list set"*) cat "$dir/set.json" ;;
+"list set"*) [ -s "$dir/set.json" ] ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "nft"), []byte(stub), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "set.json"), []byte(setJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	prev := sets
+	sets = nftSets{}
+	t.Cleanup(func() { sets = prev })
+	return func() string {
+		data, _ := os.ReadFile(filepath.Join(dir, "scripts"))
+		return string(data)
+	}
+}
+
+func TestNftSetsEnsureDropsFixedSize(t *testing.T) {
+	scripts := useFakeNft(t, `{"nftables": [{"set": {"name": "vpner-Xray-a", "flags": ["interval"], "size": 65536, "elem": ["1.1.1.1"]}}]}`)
+
+	set := &IPSet{Name: "vpner-Xray-a", HashType: "hash:net", HashFamily: "inet", MaxElem: 65536}
+	if err := sets.ensure(set); err != nil {
+		t.Fatalf("ensure: %v", err)
+	}
+	got := scripts()
+	for _, want := range []string{
+		"delete set inet vpner vpner-Xray-a\n",
+		"add set inet vpner vpner-Xray-a { type ipv4_addr; flags interval; }\n",
+		"add element inet vpner vpner-Xray-a { 1.1.1.1 }\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("script missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "size") {
+		t.Fatalf("nft set created with a fixed size:\n%s", got)
+	}
+}
+
+func TestNftSetsNeverGrow(t *testing.T) {
+	scripts := useFakeNft(t, `{"nftables": [{"set": {"name": "vpner-Xray-a", "flags": ["interval"], "elem": ["1.1.1.1", "2.2.2.2"]}}]}`)
+
+	entries, maxEntries, err := sets.usage("vpner-Xray-a")
+	if err != nil || entries != 2 || maxEntries != 0 {
+		t.Fatalf("usage = %d, %d, %v; want 2, 0", entries, maxEntries, err)
+	}
+	registry := NewIPSetRegistry()
+	if registry.growIfFull(&IPSet{Name: "vpner-Xray-a", HashType: "hash:net", HashFamily: "inet"}) {
+		t.Fatalf("unbounded nft set reported as full")
+	}
+	if u := (SetUsage{Entries: entries, MaxEntries: maxEntries}); u.Percent() != 0 {
+		t.Fatalf("percent = %d, want 0", u.Percent())
+	}
+	if got := scripts(); got != "" {
+		t.Fatalf("unexpected nft changes:\n%s", got)
+	}
+}
+
+func TestNftSetsAddBatchRunsOneTransaction(t *testing.T) {
+	scripts := useFakeNft(t, "")
+
+	errs := sets.addBatch("vpner-Xray-a", []ipsetEntry{
+		{Entry: "1.1.1.1", Comment: "c1", Timeout: 60},
+		{Entry: "10.0.0.0/8"},
+	})
+	if err := errors.Join(errs...); err != nil {
+		t.Fatalf("addBatch: %v", err)
+	}
+	want := "add element inet vpner vpner-Xray-a { 1.1.1.1 }\n" +
+		"delete element inet vpner vpner-Xray-a { 1.1.1.1 }\n" +
+		"add element inet vpner vpner-Xray-a { 1.1.1.1 timeout 60s comment \"c1\", 10.0.0.0/8 }\n" +
+		"# end\n"
+	if got := scripts(); got != want {
+		t.Fatalf("scripts =\n%s\nwant\n%s", got, want)
+	}
+}
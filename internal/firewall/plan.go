@@ -0,0 +1,548 @@
+package firewall
+
+import (
+	"fmt"
+	"maps"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type PlanStep struct {
+	Tool  string
+	Table string
+	Args  []string
+	Lines []string
+}
+
+type PlanDiff struct {
+	Tool    string
+	Table   string
+	Added   []string
+	Removed []string
+}
+
+type Plan struct {
+	Steps []PlanStep
+	Diff  []PlanDiff
+}
+
+func (p *Plan) Empty() bool {
+	return p == nil || len(p.Steps) == 0
+}
+
+// planRecorder collects the commands of a dry run. A nil recorder executes
+// them.
+type planRecorder struct {
+	mu    sync.Mutex
+	steps []PlanStep
+}
+
+func (r *planRecorder) record(step PlanStep) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steps = append(r.steps, step)
+	return true
+}
+
+// Plan runs fn against a copy of the manager while every iptables, ip and nft
+// command is recorded instead of executed. i.mu stays held for the whole run so
+// no live change can be recorded by mistake.
+func (i *IptablesManager) Plan(chain string, fn func(*IptablesManager) error) (*Plan, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	shadow := i.cloneLocked(chain)
+	defer rules.snapshot()()
+
+	err := fn(shadow)
+
+	plan := &Plan{Steps: shadow.dryRun.steps}
+	plan.Diff = diffPlan(plan.Steps)
+	return plan, err
+}
+
+func (i *IptablesManager) cloneLocked(chain string) *IptablesManager {
+	c := NewIptablesManager(i.ipv6Enabled, i.tproxyEnabled)
+	c.dryRun = &planRecorder{}
+	keep := func(ipsetName string) bool {
+		return chain == "" || ipsetChainName(ipsetName) == chain
+	}
+	for name, info := range i.routingV4 {
+		if keep(name) {
+			c.routingV4[name] = info
+		}
+	}
+	for name, info := range i.routingV6 {
+		if keep(strings.TrimSuffix(name, ipv6Suffix)) {
+			c.routingV6[name] = info
+		}
+	}
+	for name, info := range i.killSwitch {
+		if keep(name) {
+			c.killSwitch[name] = info
+		}
+	}
+	maps.Copy(c.clientFilters, i.clientFilters)
+	maps.Copy(c.clientGroups, i.clientGroups)
+	maps.Copy(c.fullTunnel, i.fullTunnel)
+	maps.Copy(c.serverAddrs, i.serverAddrs)
+	maps.Copy(c.udpPolicy, i.udpPolicy)
+	c.localIntercept = i.localIntercept
+	c.marks = &markAllocator{
+		mask:           i.marks.mask,
+		reservedMarks:  i.marks.reservedMarks,
+		reservedTables: i.marks.reservedTables,
+		assigned:       maps.Clone(i.marks.assigned),
+	}
+	c.ipInfraReady = i.ipInfraReady
+	c.udpInfraReady = i.udpInfraReady
+	c.udpTProxyProbed = true
+	c.udpTProxyErr = i.udpTProxyErr
+	if !i.udpTProxyProbed {
+		c.udpTProxyErr = fmt.Errorf("UDP TPROXY support has not been probed yet")
+	}
+	return c
+}
+
+func ipsetChainName(ipsetName string) string {
+	_, chain, _ := strings.Cut(strings.TrimPrefix(ipsetName, defaultTag+"-"), "-")
+	return chain
+}
+
+func ipsetCreateStep(name, hashType string, p *Params) PlanStep {
+	params := normalizeParams(p)
+	return PlanStep{Tool: "ipset", Table: name, Args: []string{"create", name, hashType, "family", params.HashFamily}}
+}
+
+func ipsetEntryStep(op, name, entry string) PlanStep {
+	return PlanStep{Tool: "ipset", Table: name, Args: []string{op, name, entry}}
+}
+
+func tableArg(args []string) string {
+	for idx := 0; idx+1 < len(args); idx++ {
+		if args[idx] == "-t" {
+			return args[idx+1]
+		}
+	}
+	return ""
+}
+
+func diffPlan(steps []PlanStep) []PlanDiff {
+	var out []PlanDiff
+	var nftScript []string
+	tables := make(map[string]*iptablesState)
+	var tableOrder []string
+	state := func(cmd, table string) *iptablesState {
+		key := cmd + " " + table
+		if st, ok := tables[key]; ok {
+			return st
+		}
+		st := loadIPTablesState(cmd, table)
+		tables[key] = st
+		tableOrder = append(tableOrder, key)
+		return st
+	}
+
+	setDiff := make(map[string]*PlanDiff)
+	setLive := make(map[string]map[string]bool)
+	var setOrder []string
+	setDiffFor := func(name string) *PlanDiff {
+		if d, ok := setDiff[name]; ok {
+			return d
+		}
+		d := &PlanDiff{Tool: "ipset", Table: name}
+		setDiff[name] = d
+		setLive[name] = liveSetEntries(name)
+		setOrder = append(setOrder, name)
+		return d
+	}
+
+	ipDiff := make(map[string]*PlanDiff)
+	var ipOrder []string
+	ipDiffFor := func(tool string) *PlanDiff {
+		if d, ok := ipDiff[tool]; ok {
+			return d
+		}
+		d := &PlanDiff{Tool: tool, Table: "rule"}
+		ipDiff[tool] = d
+		ipOrder = append(ipOrder, tool)
+		return d
+	}
+
+	unblock := PlanDiff{Tool: "unblock"}
+
+	for _, step := range steps {
+		switch step.Tool {
+		case "iptables-restore", "ip6tables-restore":
+			st := state(strings.TrimSuffix(step.Tool, "-restore"), step.Table)
+			for _, line := range step.Lines {
+				st.apply(strings.Fields(line))
+			}
+		case "iptables", "ip6tables":
+			st := state(step.Tool, tableArg(step.Args))
+			st.apply(stripTableArg(step.Args))
+		case "ip":
+			tool := "ip"
+			args := step.Args
+			if len(args) > 0 && args[0] == "-6" {
+				tool, args = "ip -6", args[1:]
+			}
+			diffIPCommand(ipDiffFor(tool), tool, args)
+		case "nft":
+			nftScript = step.Lines
+		case "ipset":
+			diffIPSetCommand(setDiffFor(step.Table), setLive[step.Table], step.Args)
+		case "unblock":
+			diffUnblockCommand(&unblock, step)
+		}
+	}
+
+	for _, key := range tableOrder {
+		if d := tables[key].diff(); len(d.Added) > 0 || len(d.Removed) > 0 {
+			out = append(out, d)
+		}
+	}
+	for _, tool := range ipOrder {
+		if d := ipDiff[tool]; len(d.Added) > 0 || len(d.Removed) > 0 {
+			out = append(out, *d)
+		}
+	}
+	for _, name := range setOrder {
+		if d := setDiff[name]; len(d.Added) > 0 || len(d.Removed) > 0 {
+			out = append(out, *d)
+		}
+	}
+	if d := diffNft(nftScript); len(d.Added) > 0 || len(d.Removed) > 0 {
+		out = append(out, d)
+	}
+	if len(unblock.Added) > 0 || len(unblock.Removed) > 0 {
+		out = append(out, unblock)
+	}
+	return out
+}
+
+func stripTableArg(args []string) []string {
+	out := make([]string, 0, len(args))
+	for idx := 0; idx < len(args); idx++ {
+		if args[idx] == "-t" && idx+1 < len(args) {
+			idx++
+			continue
+		}
+		out = append(out, args[idx])
+	}
+	return out
+}
+
+type iptablesState struct {
+	cmd, table string
+	initial    map[string][]string
+	current    map[string][]string
+	order      []string
+}
+
+func loadIPTablesState(cmd, table string) *iptablesState {
+	st := &iptablesState{cmd: cmd, table: table, current: make(map[string][]string)}
+	out, err := exec.Command(iptablesSaveCmd(cmd), "-t", table).Output()
+	if err == nil {
+		for _, line := range strings.Split(string(out), "\n") {
+			st.apply(strings.Fields(line))
+		}
+	}
+	st.initial = make(map[string][]string, len(st.current))
+	for chain, lines := range st.current {
+		st.initial[chain] = slices.Clone(lines)
+	}
+	return st
+}
+
+func (s *iptablesState) touch(chain string) {
+	if _, ok := s.current[chain]; !ok {
+		s.current[chain] = []string{}
+		if !slices.Contains(s.order, chain) {
+			s.order = append(s.order, chain)
+		}
+	}
+}
+
+func (s *iptablesState) apply(fields []string) {
+	if len(fields) == 0 {
+		return
+	}
+	if strings.HasPrefix(fields[0], ":") {
+		chain := strings.TrimPrefix(fields[0], ":")
+		if _, ok := s.current[chain]; ok && isUserChain(chain) {
+			s.current[chain] = []string{}
+		}
+		s.touch(chain)
+		return
+	}
+	if len(fields) < 2 {
+		return
+	}
+	op, chain := fields[0], fields[1]
+	rest := fields[2:]
+	switch op {
+	case "-A", "-I":
+		if op == "-I" && len(rest) > 0 {
+			if _, err := strconv.Atoi(rest[0]); err == nil {
+				rest = rest[1:]
+			}
+		}
+		s.touch(chain)
+		rule := normalizeRuleSpec(chain, rest)
+		if !slices.Contains(s.current[chain], rule) {
+			s.current[chain] = append(s.current[chain], rule)
+		}
+	case "-D":
+		rule := normalizeRuleSpec(chain, rest)
+		if idx := slices.Index(s.current[chain], rule); idx >= 0 {
+			s.current[chain] = slices.Delete(s.current[chain], idx, idx+1)
+		}
+	case "-N":
+		s.touch(chain)
+	case "-F":
+		if _, ok := s.current[chain]; ok {
+			s.current[chain] = []string{}
+		}
+	case "-X":
+		delete(s.current, chain)
+	}
+}
+
+func isUserChain(chain string) bool {
+	switch chain {
+	case chainPrerouting, chainForward, chainOutput, chainInput, "POSTROUTING":
+		return false
+	}
+	return true
+}
+
+func (s *iptablesState) diff() PlanDiff {
+	d := PlanDiff{Tool: s.cmd, Table: s.table}
+	for _, chain := range s.order {
+		lines, ok := s.current[chain]
+		before, existed := s.initial[chain]
+		if ok && !existed {
+			d.Added = append(d.Added, ":"+chain)
+		}
+		for _, line := range lines {
+			if !slices.Contains(before, line) {
+				d.Added = append(d.Added, line)
+			}
+		}
+	}
+	for chain, before := range s.initial {
+		lines, ok := s.current[chain]
+		for _, line := range before {
+			if !ok || !slices.Contains(lines, line) {
+				d.Removed = append(d.Removed, line)
+			}
+		}
+		if !ok {
+			d.Removed = append(d.Removed, ":"+chain)
+		}
+	}
+	slices.Sort(d.Removed)
+	return d
+}
+
+// normalizeRuleSpec rewrites the parts iptables-save prints differently from
+// how the rules are written here, so unchanged rules do not show up in a diff.
+func normalizeRuleSpec(chain string, args []string) string {
+	out := []string{"-A", chain}
+	for idx := 0; idx < len(args); idx++ {
+		arg := args[idx]
+		next := ""
+		if idx+1 < len(args) {
+			next = args[idx+1]
+		}
+		switch {
+		case arg == "-m" && (next == "tcp" || next == "udp"):
+			idx++
+			continue
+		case arg == "--reject-with" && (next == "icmp-port-unreachable" || next == "icmp6-port-unreachable"):
+			idx++
+			continue
+		case arg == "--set-mark" || arg == "--set-xmark":
+			out = append(out, "--set-xmark", hexMark(next, true))
+			idx++
+			continue
+		case arg == "--tproxy-mark":
+			out = append(out, arg, hexMark(next, true))
+			idx++
+			continue
+		case arg == "--mark":
+			out = append(out, arg, hexMark(next, false))
+			idx++
+			continue
+		}
+		out = append(out, arg)
+	}
+	return strings.Join(out, " ")
+}
+
+func hexMark(value string, withMask bool) string {
+	v, m, hasMask := strings.Cut(value, "/")
+	mark, err := strconv.ParseUint(v, 0, 32)
+	if err != nil {
+		return value
+	}
+	mask := uint64(fullMarkMask)
+	if hasMask {
+		if mask, err = strconv.ParseUint(m, 0, 32); err != nil {
+			return value
+		}
+	}
+	if !withMask && mask == uint64(fullMarkMask) {
+		return fmt.Sprintf("0x%x", mark)
+	}
+	return fmt.Sprintf("0x%x/0x%x", mark, mask)
+}
+
+func diffIPCommand(d *PlanDiff, tool string, args []string) {
+	if len(args) < 2 {
+		return
+	}
+	family := familyV4
+	if tool == "ip -6" {
+		family = familyV6
+	}
+	switch args[0] {
+	case "rule":
+		spec := strings.Join(args[2:], " ")
+		present := ipRuleSpecPresent(family, args[2:])
+		switch args[1] {
+		case "add":
+			if !present && !slices.Contains(d.Added, spec) {
+				d.Added = append(d.Added, spec)
+			}
+		case "del":
+			if present && !slices.Contains(d.Removed, spec) {
+				d.Removed = append(d.Removed, spec)
+			}
+		}
+	case "route":
+		spec := "route " + strings.Join(args[2:], " ")
+		switch args[1] {
+		case "add", "replace":
+			d.Added = append(d.Added, spec)
+		case "flush":
+			d.Removed = append(d.Removed, spec)
+		}
+	}
+}
+
+// liveSetEntries lists the entries of set name as it is now; a missing set
+// has none.
+func liveSetEntries(name string) map[string]bool {
+	live := make(map[string]bool)
+	if !IPSetExists(name) {
+		return live
+	}
+	entries, err := listEntriesWithComments(name)
+	if err != nil {
+		return live
+	}
+	for _, entry := range entries {
+		live[canonicalSetEntry(entry.Entry)] = true
+	}
+	return live
+}
+
+// diffIPSetCommand applies args to live, the entries of the set, and records
+// only the adds and dels that change it.
+func diffIPSetCommand(d *PlanDiff, live map[string]bool, args []string) {
+	if len(args) < 2 {
+		return
+	}
+	switch args[0] {
+	case "create":
+		d.Added = append(d.Added, strings.Join(args, " "))
+	case "add":
+		if len(args) < 3 || live[canonicalSetEntry(args[2])] {
+			return
+		}
+		live[canonicalSetEntry(args[2])] = true
+		if idx := slices.Index(d.Removed, args[2]); idx >= 0 {
+			d.Removed = slices.Delete(d.Removed, idx, idx+1)
+			return
+		}
+		d.Added = append(d.Added, args[2])
+	case "del":
+		if len(args) < 3 || !live[canonicalSetEntry(args[2])] {
+			return
+		}
+		delete(live, canonicalSetEntry(args[2]))
+		if idx := slices.Index(d.Added, args[2]); idx >= 0 {
+			d.Added = slices.Delete(d.Added, idx, idx+1)
+			return
+		}
+		d.Removed = append(d.Removed, args[2])
+	}
+}
+
+func diffUnblockCommand(d *PlanDiff, step PlanStep) {
+	if len(step.Args) < 2 {
+		return
+	}
+	d.Table = step.Table
+	switch step.Args[0] {
+	case "add":
+		d.Added = append(d.Added, step.Args[1])
+	case "del":
+		d.Removed = append(d.Removed, step.Args[1])
+	}
+}
+
+func ipRuleSpecPresent(f ipFamily, args []string) bool {
+	parsed := parseIPRules(strings.Join(args, " "))
+	if len(parsed) != 1 {
+		return false
+	}
+	live, err := listIPRules(f)
+	if err != nil {
+		return false
+	}
+	return slices.Contains(live, parsed[0])
+}
+
+// diffNft compares chains only: nft prints rules back in its own syntax, so
+// the rendered rule text cannot be matched against the live ruleset.
+func diffNft(lines []string) PlanDiff {
+	d := PlanDiff{Tool: "nft", Table: nftFamily + " " + nftTable}
+	if len(lines) == 0 {
+		return d
+	}
+	live := nftTableObjects("chain")
+	added := make(map[string]bool)
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 5 || fields[1] != "chain" {
+			continue
+		}
+		added[fields[4]] = fields[0] == "add"
+	}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 5 || fields[1] != "chain" || fields[0] != "add" {
+			continue
+		}
+		name := fields[4]
+		if added[name] && !slices.Contains(live, name) && !slices.Contains(d.Added, "chain "+name) {
+			d.Added = append(d.Added, "chain "+name)
+		}
+	}
+	for name, keep := range added {
+		if !keep && slices.Contains(live, name) {
+			d.Removed = append(d.Removed, "chain "+name)
+		}
+	}
+	slices.Sort(d.Removed)
+	return d
+}
@@ -0,0 +1,91 @@
+package firewall
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeRuleSpecMatchesSaveOutput(t *testing.T) {
+	written := normalizeRuleSpec("VPN_1", []string{"-p", "tcp", "-m", "set", "--match-set", "vpner-Xray-a", "dst", "-j", "MARK", "--set-mark", "200"})
+	saved := normalizeRuleSpec("VPN_1", []string{"-p", "tcp", "-m", "tcp", "-m", "set", "--match-set", "vpner-Xray-a", "dst", "-j", "MARK", "--set-xmark", "0xc8/0xffffffff"})
+	if written != saved {
+		t.Fatalf("normalized specs differ:\n%s\n%s", written, saved)
+	}
+	if got := hexMark("0x2000/0xff00", false); got != "0x2000/0xff00" {
+		t.Fatalf("unexpected masked mark %s", got)
+	}
+	if got := hexMark("100", false); got != "0x64" {
+		t.Fatalf("unexpected mark %s", got)
+	}
+}
+
+func TestIPTablesStateDiff(t *testing.T) {
+	st := &iptablesState{cmd: "iptables", table: "mangle", current: make(map[string][]string)}
+	for _, line := range []string{":PREROUTING ACCEPT [0:0]", ":VPN_OLD - [0:0]", "-A VPN_OLD -j RETURN", "-A PREROUTING -j VPN_OLD"} {
+		st.apply(strings.Fields(line))
+	}
+	st.initial = map[string][]string{}
+	for chain, lines := range st.current {
+		st.initial[chain] = slices.Clone(lines)
+	}
+
+	for _, line := range []string{":VPN_NEW - [0:0]", "-A VPN_NEW -p udp -j RETURN", "-I PREROUTING 1 -j VPN_NEW", "-D PREROUTING -j VPN_OLD", "-F VPN_OLD", "-X VPN_OLD"} {
+		st.apply(strings.Fields(line))
+	}
+	d := st.diff()
+	wantAdded := []string{":VPN_NEW", "-A VPN_NEW -p udp -j RETURN", "-A PREROUTING -j VPN_NEW"}
+	for _, line := range wantAdded {
+		if !slices.Contains(d.Added, line) {
+			t.Fatalf("missing added %q in %v", line, d.Added)
+		}
+	}
+	wantRemoved := []string{"-A PREROUTING -j VPN_OLD", "-A VPN_OLD -j RETURN", ":VPN_OLD"}
+	if !slices.Equal(d.Removed, wantRemoved) {
+		t.Fatalf("removed = %v, want %v", d.Removed, wantRemoved)
+	}
+}
+
+func TestDiffPlanSetsAndUnblock(t *testing.T) {
+	useFakeSets(t)
+	steps := []PlanStep{
+		{Tool: "unblock", Table: "Xray/a", Args: []string{"add", "10.0.0.0/8"}},
+		ipsetCreateStep("vpner-Xray-a", "hash:net", &Params{HashFamily: "inet"}),
+		ipsetEntryStep("add", "vpner-Xray-a", "10.0.0.0/8"),
+		ipsetEntryStep("add", "vpner-Xray-a", "10.0.0.0/8"),
+	}
+	diff := diffPlan(steps)
+	if len(diff) != 2 {
+		t.Fatalf("expected ipset and unblock diffs, got %+v", diff)
+	}
+	if diff[0].Tool != "ipset" || len(diff[0].Added) != 2 || diff[0].Added[1] != "10.0.0.0/8" {
+		t.Fatalf("unexpected ipset diff %+v", diff[0])
+	}
+	if diff[1].Tool != "unblock" || diff[1].Table != "Xray/a" || !slices.Equal(diff[1].Added, []string{"10.0.0.0/8"}) {
+		t.Fatalf("unexpected unblock diff %+v", diff[1])
+	}
+	if got := ipsetChainName("vpner-Xray-my-chain"); got != "my-chain" {
+		t.Fatalf("ipsetChainName = %q", got)
+	}
+}
+
+func TestDiffPlanSkipsLiveSetEntries(t *testing.T) {
+	fake := useFakeSets(t)
+	const name = "vpner-Xray-a"
+	fake.data[name] = map[string]string{"10.0.0.0/8": "", "192.0.2.1": ""}
+
+	diff := diffPlan([]PlanStep{
+		ipsetEntryStep("add", name, "10.0.0.0/8"),
+		ipsetEntryStep("add", name, "198.51.100.0/24"),
+		ipsetEntryStep("del", name, "192.0.2.1"),
+		ipsetEntryStep("del", name, "203.0.113.1"),
+		ipsetEntryStep("add", name, "203.0.113.7"),
+		ipsetEntryStep("del", name, "203.0.113.7"),
+	})
+	if len(diff) != 1 {
+		t.Fatalf("expected one ipset diff, got %+v", diff)
+	}
+	if !slices.Equal(diff[0].Added, []string{"198.51.100.0/24"}) || !slices.Equal(diff[0].Removed, []string{"192.0.2.1"}) {
+		t.Fatalf("unexpected ipset diff %+v", diff[0])
+	}
+}
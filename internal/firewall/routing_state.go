@@ -0,0 +1,112 @@
+package firewall
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type JumpState struct {
+	Rule    string
+	Present bool
+}
+
+type ChainState struct {
+	Chain         string
+	VPNType       string
+	Family        string
+	Table         string
+	ChainName     string
+	ChainPresent  bool
+	TargetPresent bool
+	Jumps         []JumpState
+	IPSetName     string
+	IPSetPresent  bool
+	IPSetEntries  int
+	Mark          string
+	RouteTable    int
+	IPRulePresent bool
+	TProxy        bool
+}
+
+// RoutingState describes every managed chain, or only chain when it is set,
+// together with what the kernel actually has for each element.
+func (i *IptablesManager) RoutingState(chain string) []ChainState {
+	type entry struct {
+		f     ipFamily
+		state ChainState
+		jumps []jumpRule
+		mark  fwmark
+	}
+
+	var entries []entry
+	i.mu.Lock()
+	for _, fam := range []struct {
+		f       ipFamily
+		name    string
+		routing map[string]vpnRoutingInfo
+	}{
+		{familyV4, "ipv4", i.routingV4},
+		{familyV6, "ipv6", i.routingV6},
+	} {
+		for ipsetName, info := range fam.routing {
+			owner := ipsetChainName(strings.TrimSuffix(ipsetName, ipv6Suffix))
+			if chain != "" && owner != chain {
+				continue
+			}
+			e := entry{
+				f: fam.f,
+				state: ChainState{
+					Chain:      owner,
+					VPNType:    info.VPNType.String(),
+					Family:     fam.name,
+					Table:      info.Table,
+					ChainName:  info.ChainName,
+					IPSetName:  ipsetName,
+					RouteTable: info.TableID,
+				},
+				jumps: append([]jumpRule(nil), info.JumpRules...),
+				mark:  info.Mark,
+			}
+			if info.Mark.Value != 0 {
+				e.state.Mark = info.Mark.String()
+			} else if i.tproxyEnabled && info.Table == tableMangle {
+				e.state.TProxy = true
+				e.state.Mark = tproxyMark
+				e.state.RouteTable = tproxyTableID
+			}
+			entries = append(entries, e)
+		}
+	}
+	i.mu.Unlock()
+
+	out := make([]ChainState, 0, len(entries))
+	for _, e := range entries {
+		st := e.state
+		st.ChainPresent = rules.chainPresent(e.f, st.Table, st.ChainName)
+		st.TargetPresent = st.ChainPresent && rules.targetPresent(e.f, st.Table, st.ChainName, st.IPSetName)
+		st.IPSetPresent = IPSetExists(st.IPSetName)
+		if st.IPSetPresent {
+			if list, err := listEntriesWithComments(st.IPSetName); err == nil {
+				st.IPSetEntries = len(list)
+			}
+		}
+		for _, j := range e.jumps {
+			st.Jumps = append(st.Jumps, JumpState{Rule: j.String(), Present: rules.jumpPresent(j)})
+		}
+		switch {
+		case st.TProxy:
+			st.IPRulePresent = ipRuleExists(e.f, tproxyMark, strconv.Itoa(tproxyTableID))
+		case st.Mark != "":
+			st.IPRulePresent = ipRulePresent(e.f, e.mark, st.RouteTable)
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(a, b int) bool {
+		if out[a].Chain != out[b].Chain {
+			return out[a].Chain < out[b].Chain
+		}
+		return out[a].Family < out[b].Family
+	})
+	return out
+}
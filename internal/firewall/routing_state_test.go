@@ -0,0 +1,40 @@
+package firewall
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ApostolDmitry/vpner/internal/vpnkind"
+)
+
+func TestRoutingStateFiltersChainAndCountsEntries(t *testing.T) {
+	fake := useFakeSets(t)
+	fake.data["vpner-Xray-a"] = map[string]string{"1.1.1.1": "", "10.0.0.0/8": ""}
+
+	m := NewIptablesManager(false, true)
+	jump := newJumpRule("iptables", tableMangle, "VPN_A", "br0")
+	m.routingV4["vpner-Xray-a"] = vpnRoutingInfo{VPNType: vpnkind.Xray, ChainName: "VPN_A", Table: tableMangle, JumpRules: []jumpRule{jump}}
+	m.routingV4["vpner-Xray-b"] = vpnRoutingInfo{VPNType: vpnkind.Xray, ChainName: "VPN_B", Table: tableMangle}
+
+	got := m.RoutingState("a")
+	if len(got) != 1 {
+		t.Fatalf("expected one chain, got %+v", got)
+	}
+	st := got[0]
+	if st.Chain != "a" || st.Family != "ipv4" || st.ChainName != "VPN_A" || !st.IPSetPresent || st.IPSetEntries != 2 {
+		t.Fatalf("unexpected state %+v", st)
+	}
+	if !st.TProxy || st.Mark != tproxyMark || st.RouteTable != tproxyTableID {
+		t.Fatalf("expected TPROXY mark and table, got %+v", st)
+	}
+	if len(st.Jumps) != 1 || st.Jumps[0].Rule != "-t mangle -A PREROUTING -i br0 -j VPN_A" {
+		t.Fatalf("unexpected jumps %+v", st.Jumps)
+	}
+	if want := []string{"-t", "mangle", "-C", "PREROUTING", "-i", "br0", "-j", "VPN_A"}; !slices.Equal(jump.checkArgs(), want) {
+		t.Fatalf("checkArgs = %v", jump.checkArgs())
+	}
+
+	if all := m.RoutingState(""); len(all) != 2 || all[0].Chain != "a" || all[1].Chain != "b" {
+		t.Fatalf("unexpected full state %+v", all)
+	}
+}
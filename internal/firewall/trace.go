@@ -0,0 +1,102 @@
+package firewall
+
+import (
+	"net"
+	"strings"
+)
+
+type SetEntry struct {
+	Set     string
+	Entry   string
+	Comment string
+}
+
+func (e SetEntry) Contains(ip net.IP) bool {
+	return entryContains(e.Entry, ip)
+}
+
+// MatchIP finds the rule that routes ip: a static rule covering it, or else a
+// domain rule whose resolved addresses put it into one of the sets.
+func (m *UnblockManager) MatchIP(ip net.IP) (string, string, string, bool) {
+	var chains []ruleRef
+	m.mu.RLock()
+	for vpnType, set := range m.cachedConf.Rules {
+		for chain, rules := range set {
+			for _, pattern := range rules {
+				if isStaticPattern(pattern) && entryContains(pattern, ip) {
+					m.mu.RUnlock()
+					return vpnType, chain, pattern, true
+				}
+			}
+			chains = append(chains, ruleRef{vpnType: vpnType, chain: chain})
+		}
+	}
+	m.mu.RUnlock()
+
+	for _, ref := range chains {
+		entries, err := m.TraceEntries(ref.vpnType, ref.chain, "", []net.IP{ip})
+		if err != nil || len(entries) == 0 {
+			continue
+		}
+		rule, _, _ := strings.Cut(strings.TrimPrefix(entries[0].Comment, ipsetCommentPrefix), ipsetCommentDomainPart)
+		return ref.vpnType, ref.chain, rule, true
+	}
+	return "", "", "", false
+}
+
+// TraceEntries returns the entries of the chain's sets that were added for
+// domain or that cover one of ips.
+func (m *UnblockManager) TraceEntries(vpnType, chainName, domain string, ips []net.IP) ([]SetEntry, error) {
+	names := make([]string, 0, 2)
+	name, err := IpsetName(vpnType, chainName)
+	if err != nil {
+		return nil, err
+	}
+	names = append(names, name)
+	if m.ipv6Enabled {
+		name6, err := IpsetName6(vpnType, chainName)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name6)
+	}
+
+	var out []SetEntry
+	for _, name := range names {
+		if !IPSetExists(name) {
+			continue
+		}
+		entries, err := m.registry.entries(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if traceMatches(e, domain, ips) {
+				out = append(out, SetEntry{Set: name, Entry: e.Entry, Comment: e.Comment})
+			}
+		}
+	}
+	return out, nil
+}
+
+func traceMatches(e ipsetEntry, domain string, ips []net.IP) bool {
+	if domain != "" && strings.HasPrefix(e.Comment, ipsetCommentPrefix) && strings.HasSuffix(e.Comment, ipsetCommentDomainPart+domain) {
+		return true
+	}
+	for _, ip := range ips {
+		if entryContains(e.Entry, ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func entryContains(entry string, ip net.IP) bool {
+	if _, network, err := net.ParseCIDR(entry); err == nil {
+		return network.Contains(ip)
+	}
+	if parsed := net.ParseIP(entry); parsed != nil {
+		return parsed.Equal(ip)
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package firewall
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/ApostolDmitry/vpner/internal/vpnkind"
+)
+
+func TestUnblockManagerTrace(t *testing.T) {
+	fake := useFakeSets(t)
+	xray := vpnkind.Xray.String()
+	mgr := NewUnblockManager(filepath.Join(t.TempDir(), "rules.yaml"), false, false, 0, NewIPSetRegistry())
+	for _, pattern := range []string{"10.0.0.0/8", "*.example.com"} {
+		if err := mgr.AddRule(xray, "a", pattern); err != nil {
+			t.Fatalf("AddRule %s: %v", pattern, err)
+		}
+	}
+	fake.data["vpner-Xray-a"]["93.184.216.34"] = buildRuleComment("*.example.com", "www.example.com")
+
+	if _, chain, pattern, ok := mgr.MatchIP(net.ParseIP("10.1.2.3")); !ok || chain != "a" || pattern != "10.0.0.0/8" {
+		t.Fatalf("static match = %s %s %v", chain, pattern, ok)
+	}
+	if _, chain, pattern, ok := mgr.MatchIP(net.ParseIP("93.184.216.34")); !ok || chain != "a" || pattern != "*.example.com" {
+		t.Fatalf("set match = %s %s %v", chain, pattern, ok)
+	}
+	if _, _, _, ok := mgr.MatchIP(net.ParseIP("192.0.2.1")); ok {
+		t.Fatal("unexpected match for unrelated address")
+	}
+
+	entries, err := mgr.TraceEntries(xray, "a", "www.example.com", []net.IP{net.ParseIP("10.9.9.9")})
+	if err != nil {
+		t.Fatalf("TraceEntries: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Entry != "10.0.0.0/8" || entries[1].Entry != "93.184.216.34" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+}
@@ -0,0 +1,172 @@
+package firewall
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ApostolDmitry/vpner/internal/chainpolicy"
+	"github.com/ApostolDmitry/vpner/internal/logx"
+	"github.com/ApostolDmitry/vpner/internal/vpnkind"
+)
+
+const (
+	quicBlockSuffix = "_QUIC"
+	udpTProxySuffix = "_UDP"
+	quicPort        = 443
+)
+
+func quicBlockChainName(chainName string) string {
+	return chainName + quicBlockSuffix
+}
+
+func udpTProxyChainName(chainName string) string {
+	return chainName + udpTProxySuffix
+}
+
+func (i *IptablesManager) SetUDPPolicy(chain string, policy chainpolicy.UDPPolicy) error {
+	ipsetName, err := IpsetName(vpnkind.Xray.String(), chain)
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.udpPolicy[ipsetName] = policy
+	errs := []error{i.reapplyChainLocked(i.v4(), i.routingV4, ipsetName)}
+	if i.ipv6Enabled {
+		if ipsetName6, err := IpsetName6FromBase(ipsetName); err == nil {
+			i.udpPolicy[ipsetName6] = policy
+			errs = append(errs, i.reapplyChainLocked(i.v6(), i.routingV6, ipsetName6))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (i *IptablesManager) UDPPolicyActive(policy chainpolicy.UDPPolicy) string {
+	if i.tproxyEnabled {
+		return "tproxy"
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.effectiveUDPPolicyLocked(policy).String()
+}
+
+func (i *IptablesManager) udpPolicyLocked(ipsetName string) chainpolicy.UDPPolicy {
+	if i.tproxyEnabled {
+		return chainpolicy.UDPLeak
+	}
+	return i.effectiveUDPPolicyLocked(i.udpPolicy[ipsetName])
+}
+
+func (i *IptablesManager) effectiveUDPPolicyLocked(policy chainpolicy.UDPPolicy) chainpolicy.UDPPolicy {
+	if policy != chainpolicy.UDPTProxyUDPOnly || i.udpTProxySupportedLocked() {
+		return chainpolicy.UDPPolicy(policy.String())
+	}
+	return chainpolicy.UDPBlockQUIC
+}
+
+func (i *IptablesManager) udpTProxySupportedLocked() bool {
+	if !i.udpTProxyProbed {
+		i.udpTProxyProbed = true
+		i.udpTProxyErr = probeUDPTProxySupport(i.ipv6Enabled)
+		if i.udpTProxyErr != nil {
+			logx.Warnf("UDP TPROXY unavailable, tproxy-udp-only falls back to block-quic: %v", i.udpTProxyErr)
+		}
+	}
+	return i.udpTProxyErr == nil
+}
+
+func probeUDPTProxySupport(ipv6Enabled bool) error {
+	release := kernelRelease()
+	if release == "" {
+		return fmt.Errorf("failed to determine kernel release (uname -r)")
+	}
+	if err := rules.probeUDPTProxy(familyV4, release); err != nil {
+		return fmt.Errorf("ipv4: %w", err)
+	}
+	if ipv6Enabled {
+		if err := rules.probeUDPTProxy(familyV6, release); err != nil {
+			return fmt.Errorf("ipv6: %w", err)
+		}
+	}
+	return nil
+}
+
+func udpPolicyTable(policy chainpolicy.UDPPolicy) string {
+	switch policy {
+	case chainpolicy.UDPBlockQUIC:
+		return tableFilter
+	case chainpolicy.UDPTProxyUDPOnly:
+		return tableMangle
+	}
+	return ""
+}
+
+func (i *IptablesManager) udpPolicyInTableLocked(info vpnRoutingInfo, ipsetName, table string) bool {
+	return info.VPNType == vpnkind.Xray && udpPolicyTable(i.udpPolicyLocked(ipsetName)) == table
+}
+
+func (i *IptablesManager) ensureUDPTProxyRouting(f ipFamily, specs []ChainSpec) {
+	for _, spec := range specs {
+		if spec.UDP == chainpolicy.UDPTProxyUDPOnly {
+			installTProxyLocalRoute(f)
+			i.udpInfraReady = true
+			return
+		}
+	}
+}
+
+func quicBlockRuleSpecs(f ipFamily, chainName string, spec ChainSpec, existing map[string]bool) []string {
+	quic := quicBlockChainName(chainName)
+	out := []string{fmt.Sprintf(":%s - [0:0]", quic)}
+	out = append(out, clientFilterRuleSpecs(f, quic, spec.Clients)...)
+	for _, iface := range spec.Ifaces {
+		if jump := hookJumpSpec(chainForward, quic, iface); !existing[jump] {
+			out = append(out, jump)
+		}
+		out = append(out, fmt.Sprintf("-A %s -i %s -p udp --dport %d -m set --match-set %s dst -j REJECT",
+			quic, iface, quicPort, spec.IPSetName))
+	}
+	return out
+}
+
+func udpTProxyRuleSpecs(f ipFamily, chainName string, spec ChainSpec, existing map[string]bool) []string {
+	udp := udpTProxyChainName(chainName)
+	out := []string{
+		fmt.Sprintf(":%s - [0:0]", udp),
+		fmt.Sprintf("-A %s -m mark --mark %s -j RETURN", udp, tproxyMark),
+	}
+	out = append(out, clientFilterRuleSpecs(f, udp, spec.Clients)...)
+	for _, iface := range spec.Ifaces {
+		if jump := preroutingJumpSpec(udp, iface); !existing[jump] {
+			out = append(out, jump)
+		}
+		for _, cidr := range f.localExceptions {
+			out = append(out, fmt.Sprintf("-A %s -i %s -d %s -j RETURN", udp, iface, cidr))
+		}
+		out = append(out, fmt.Sprintf("-A %s -i %s -p udp -m set --match-set %s dst -j TPROXY --on-port %d --tproxy-mark %s",
+			udp, iface, spec.IPSetName, spec.Port, tproxyMark))
+	}
+	return out
+}
+
+func nftQUICBlockRules(f ipFamily, spec ChainSpec) []string {
+	out := nftClientFilterRules(f, spec.Clients)
+	for _, iface := range spec.Ifaces {
+		out = append(out, fmt.Sprintf("%s %s daddr @%s udp dport %d reject",
+			nftIface(iface), f.nftAddr, spec.IPSetName, quicPort))
+	}
+	return out
+}
+
+func nftUDPTProxyRules(f ipFamily, spec ChainSpec) []string {
+	out := []string{fmt.Sprintf("meta mark %s return", tproxyMark)}
+	out = append(out, nftClientFilterRules(f, spec.Clients)...)
+	for _, iface := range spec.Ifaces {
+		out = append(out, nftReturnCIDRs(f, iface)...)
+		out = append(out, fmt.Sprintf("%s %s daddr @%s meta l4proto udp meta mark set %s tproxy %s to :%d accept",
+			nftIface(iface), f.nftAddr, spec.IPSetName, tproxyMark, f.nftAddr, spec.Port))
+	}
+	return out
+}
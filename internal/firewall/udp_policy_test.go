@@ -0,0 +1,74 @@
+package firewall
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ApostolDmitry/vpner/internal/chainpolicy"
+)
+
+func TestUDPPolicyRuleSpecs(t *testing.T) {
+	spec := ChainSpec{
+		IPSetName: "vpner-Xray-xray1",
+		Port:      10800,
+		Ifaces:    []string{"br0"},
+		Clients:   ClientFilter{Exclude: []string{"tv"}},
+		UDP:       chainpolicy.UDPBlockQUIC,
+	}
+	f := ipFamily{nftAddr: "ip", localExceptions: []string{"10.0.0.0/8"}}
+
+	existing := map[string]bool{"-A FORWARD -i br0 -j VPN_TEST_QUIC": true}
+	got := quicBlockRuleSpecs(f, "VPN_TEST", spec, existing)
+	want := []string{
+		":VPN_TEST_QUIC - [0:0]",
+		"-A VPN_TEST_QUIC -m set --match-set vpner-cg-tv src -j RETURN",
+		"-A VPN_TEST_QUIC -m set --match-set vpner-cg-tv-mac src -j RETURN",
+		"-A VPN_TEST_QUIC -i br0 -p udp --dport 443 -m set --match-set vpner-Xray-xray1 dst -j REJECT",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("block-quic rules:\n got %q\nwant %q", got, want)
+	}
+
+	spec.Clients = ClientFilter{}
+	got = udpTProxyRuleSpecs(f, "VPN_TEST", spec, nil)
+	want = []string{
+		":VPN_TEST_UDP - [0:0]",
+		"-A VPN_TEST_UDP -m mark --mark 200 -j RETURN",
+		"-A PREROUTING -i br0 -j VPN_TEST_UDP",
+		"-A VPN_TEST_UDP -i br0 -d 10.0.0.0/8 -j RETURN",
+		"-A VPN_TEST_UDP -i br0 -p udp -m set --match-set vpner-Xray-xray1 dst -j TPROXY --on-port 10800 --tproxy-mark 200",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("tproxy-udp-only rules:\n got %q\nwant %q", got, want)
+	}
+
+	gotNft := nftQUICBlockRules(f, spec)
+	wantNft := []string{`iifname "br0" ip daddr @vpner-Xray-xray1 udp dport 443 reject`}
+	if !reflect.DeepEqual(gotNft, wantNft) {
+		t.Fatalf("nft block-quic rules:\n got %q\nwant %q", gotNft, wantNft)
+	}
+}
+
+func TestEffectiveUDPPolicy(t *testing.T) {
+	i := NewIptablesManager(false, false)
+	i.udpTProxyProbed = true
+
+	if got := i.effectiveUDPPolicyLocked(""); got != chainpolicy.UDPLeak {
+		t.Fatalf("empty policy = %q, want leak", got)
+	}
+	if got := i.effectiveUDPPolicyLocked(chainpolicy.UDPTProxyUDPOnly); got != chainpolicy.UDPTProxyUDPOnly {
+		t.Fatalf("supported tproxy-udp-only = %q", got)
+	}
+
+	i.udpTProxyErr = errors.New("xt_TPROXY missing")
+	if got := i.effectiveUDPPolicyLocked(chainpolicy.UDPTProxyUDPOnly); got != chainpolicy.UDPBlockQUIC {
+		t.Fatalf("unsupported tproxy-udp-only = %q, want block-quic fallback", got)
+	}
+
+	i.tproxyEnabled = true
+	i.udpPolicy["vpner-Xray-xray1"] = chainpolicy.UDPBlockQUIC
+	if got := i.udpPolicyLocked("vpner-Xray-xray1"); got != chainpolicy.UDPLeak {
+		t.Fatalf("full TPROXY mode must not add UDP rules, got %q", got)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"io"
 	"net"
 	"os"
+	"slices"
+	"strings"
 	"sync"
 
 	"github.com/ApostolDmitry/vpner/internal/logx"
@@ -83,6 +85,7 @@ type UnblockManager struct {
 	ipv6Enabled       bool
 	ipsetDebug        bool
 	ipsetStaleQueries int
+	entryTTL          EntryTTL
 }
 
 func NewUnblockManager(path string, ipv6Enabled bool, ipsetDebug bool, ipsetStaleQueries int, registry *IPSetRegistry) *UnblockManager {
@@ -258,6 +261,102 @@ func (m *UnblockManager) DelChain(vpnType, chainName string) error {
 	return nil
 }
 
+// PlanRule reports what AddRule or DelRule would change without touching the
+// rules file or any ipset.
+func (m *UnblockManager) PlanRule(vpnType, chainName, pattern string, add bool) (*Plan, error) {
+	if add && isStaticPattern(pattern) && isIPv6Pattern(pattern) && !m.ipv6Enabled {
+		return nil, fmt.Errorf("ipv6 support is disabled")
+	}
+
+	m.mu.RLock()
+	set, ok := m.cachedConf.lookupSet(vpnType)
+	exists := ok && slices.Contains(set[chainName], pattern)
+	m.mu.RUnlock()
+	if !add && !exists {
+		return nil, fmt.Errorf("pattern not found in chain: %s", chainName)
+	}
+
+	op := "del"
+	if add {
+		op = "add"
+	}
+	steps := []PlanStep{{Tool: "unblock", Table: vpnType + "/" + chainName, Args: []string{op, pattern}}}
+	if isStaticPattern(pattern) {
+		step, err := m.planStaticEntry(vpnType, chainName, pattern, add)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step...)
+	} else if !add {
+		for _, ipv6 := range []bool{false, true} {
+			if ipv6 && !m.ipv6Enabled {
+				continue
+			}
+			step, err := m.planDomainCleanup(vpnType, chainName, pattern, ipv6)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step...)
+		}
+	}
+	return &Plan{Steps: steps, Diff: diffPlan(steps)}, nil
+}
+
+func (m *UnblockManager) planStaticEntry(vpnType, chainName, pattern string, add bool) ([]PlanStep, error) {
+	isV6 := isIPv6Pattern(pattern)
+	if isV6 && !m.ipv6Enabled {
+		return nil, nil
+	}
+	name, family := "", "inet"
+	var err error
+	if isV6 {
+		family = "inet6"
+		name, err = IpsetName6(vpnType, chainName)
+	} else {
+		name, err = IpsetName(vpnType, chainName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !add {
+		return []PlanStep{ipsetEntryStep("del", name, pattern)}, nil
+	}
+	var steps []PlanStep
+	if !IPSetExists(name) {
+		steps = append(steps, ipsetCreateStep(name, "hash:net", &Params{Timeout: DefaultIPSetTimeout, WithComments: true, HashFamily: family}))
+	}
+	return append(steps, ipsetEntryStep("add", name, pattern)), nil
+}
+
+func (m *UnblockManager) planDomainCleanup(vpnType, chainName, pattern string, ipv6 bool) ([]PlanStep, error) {
+	var name string
+	var err error
+	if ipv6 {
+		name, err = IpsetName6(vpnType, chainName)
+	} else {
+		name, err = IpsetName(vpnType, chainName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !IPSetExists(name) {
+		return nil, nil
+	}
+	entries, err := m.registry.entries(name)
+	if err != nil {
+		return nil, err
+	}
+	var steps []PlanStep
+	prefix := ruleCommentPrefix(pattern)
+	for _, entry := range entries {
+		legacy := entry.Comment != "" && !strings.HasPrefix(entry.Comment, ipsetCommentPrefix) && matcher.Match(pattern, entry.Comment)
+		if strings.HasPrefix(entry.Comment, prefix) || legacy {
+			steps = append(steps, ipsetEntryStep("del", name, entry.Entry))
+		}
+	}
+	return steps, nil
+}
+
 func (m *UnblockManager) GetRules(vpnType, chainName string) ([]string, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -370,12 +469,12 @@ func (m *UnblockManager) applyStaticEntry(vpnType, chainName, pattern string, ad
 		if m.ipsetDebug {
 			logx.Infof("ipset add: set=%s entry=%s reason=static-rule vpn=%s chain=%s", ipsetName, pattern, vpnType, chainName)
 		}
-		return set.Add(pattern, 0)
+		return m.registry.addEntry(set, pattern, "", 0)
 	}
 	if m.ipsetDebug {
 		logx.Infof("ipset del: set=%s entry=%s reason=static-rule-delete vpn=%s chain=%s", ipsetName, pattern, vpnType, chainName)
 	}
-	return set.Del(pattern)
+	return m.registry.delEntry(set, pattern)
 }
 
 type ruleRef struct {
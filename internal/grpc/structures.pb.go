@@ -119,7 +119,7 @@ func (x InterfaceInfo_State) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use InterfaceInfo_State.Descriptor instead.
 func (InterfaceInfo_State) EnumDescriptor() ([]byte, []int) {
-	return file_structures_proto_rawDescGZIP(), []int{1, 0}
+	return file_structures_proto_rawDescGZIP(), []int{3, 0}
 }
 
 type UnblockInfo struct {
@@ -182,6 +182,126 @@ func (x *UnblockInfo) GetRules() []string {
 	return nil
 }
 
+type ClientGroupInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Members       []string               `protobuf:"bytes,2,rep,name=members,proto3" json:"members,omitempty"`
+	FullTunnel    string                 `protobuf:"bytes,3,opt,name=full_tunnel,json=fullTunnel,proto3" json:"full_tunnel,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientGroupInfo) Reset() {
+	*x = ClientGroupInfo{}
+	mi := &file_structures_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientGroupInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientGroupInfo) ProtoMessage() {}
+
+func (x *ClientGroupInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_structures_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientGroupInfo.ProtoReflect.Descriptor instead.
+func (*ClientGroupInfo) Descriptor() ([]byte, []int) {
+	return file_structures_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ClientGroupInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ClientGroupInfo) GetMembers() []string {
+	if x != nil {
+		return x.Members
+	}
+	return nil
+}
+
+func (x *ClientGroupInfo) GetFullTunnel() string {
+	if x != nil {
+		return x.FullTunnel
+	}
+	return ""
+}
+
+type ClientPolicyInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ChainName     string                 `protobuf:"bytes,1,opt,name=chain_name,json=chainName,proto3" json:"chain_name,omitempty"`
+	Only          []string               `protobuf:"bytes,2,rep,name=only,proto3" json:"only,omitempty"`
+	Except        []string               `protobuf:"bytes,3,rep,name=except,proto3" json:"except,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientPolicyInfo) Reset() {
+	*x = ClientPolicyInfo{}
+	mi := &file_structures_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientPolicyInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientPolicyInfo) ProtoMessage() {}
+
+func (x *ClientPolicyInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_structures_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientPolicyInfo.ProtoReflect.Descriptor instead.
+func (*ClientPolicyInfo) Descriptor() ([]byte, []int) {
+	return file_structures_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ClientPolicyInfo) GetChainName() string {
+	if x != nil {
+		return x.ChainName
+	}
+	return ""
+}
+
+func (x *ClientPolicyInfo) GetOnly() []string {
+	if x != nil {
+		return x.Only
+	}
+	return nil
+}
+
+func (x *ClientPolicyInfo) GetExcept() []string {
+	if x != nil {
+		return x.Except
+	}
+	return nil
+}
+
 type InterfaceInfo struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -195,7 +315,7 @@ type InterfaceInfo struct {
 
 func (x *InterfaceInfo) Reset() {
 	*x = InterfaceInfo{}
-	mi := &file_structures_proto_msgTypes[1]
+	mi := &file_structures_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -207,7 +327,7 @@ func (x *InterfaceInfo) String() string {
 func (*InterfaceInfo) ProtoMessage() {}
 
 func (x *InterfaceInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_structures_proto_msgTypes[1]
+	mi := &file_structures_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -220,7 +340,7 @@ func (x *InterfaceInfo) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use InterfaceInfo.ProtoReflect.Descriptor instead.
 func (*InterfaceInfo) Descriptor() ([]byte, []int) {
-	return file_structures_proto_rawDescGZIP(), []int{1}
+	return file_structures_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *InterfaceInfo) GetId() string {
@@ -272,7 +392,7 @@ type XrayInfo struct {
 
 func (x *XrayInfo) Reset() {
 	*x = XrayInfo{}
-	mi := &file_structures_proto_msgTypes[2]
+	mi := &file_structures_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -284,7 +404,7 @@ func (x *XrayInfo) String() string {
 func (*XrayInfo) ProtoMessage() {}
 
 func (x *XrayInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_structures_proto_msgTypes[2]
+	mi := &file_structures_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -297,7 +417,7 @@ func (x *XrayInfo) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use XrayInfo.ProtoReflect.Descriptor instead.
 func (*XrayInfo) Descriptor() ([]byte, []int) {
-	return file_structures_proto_rawDescGZIP(), []int{2}
+	return file_structures_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *XrayInfo) GetChainName() string {
@@ -352,7 +472,17 @@ const file_structures_proto_rawDesc = "" +
 	"\ttype_name\x18\x01 \x01(\tR\btypeName\x12\x1d\n" +
 	"\n" +
 	"chain_name\x18\x02 \x01(\tR\tchainName\x12\x14\n" +
-	"\x05rules\x18\x03 \x03(\tR\x05rules\"\xcc\x01\n" +
+	"\x05rules\x18\x03 \x03(\tR\x05rules\"`\n" +
+	"\x0fClientGroupInfo\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\amembers\x18\x02 \x03(\tR\amembers\x12\x1f\n" +
+	"\vfull_tunnel\x18\x03 \x01(\tR\n" +
+	"fullTunnel\"]\n" +
+	"\x10ClientPolicyInfo\x12\x1d\n" +
+	"\n" +
+	"chain_name\x18\x01 \x01(\tR\tchainName\x12\x12\n" +
+	"\x04only\x18\x02 \x03(\tR\x04only\x12\x16\n" +
+	"\x06except\x18\x03 \x03(\tR\x06except\"\xcc\x01\n" +
 	"\rInterfaceInfo\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
 	"\x04type\x18\x02 \x01(\tR\x04type\x12 \n" +
@@ -391,13 +521,15 @@ func file_structures_proto_rawDescGZIP() []byte {
 }
 
 var file_structures_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_structures_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_structures_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
 var file_structures_proto_goTypes = []any{
 	(ManageAction)(0),        // 0: structures.ManageAction
 	(InterfaceInfo_State)(0), // 1: structures.InterfaceInfo.State
 	(*UnblockInfo)(nil),      // 2: structures.UnblockInfo
-	(*InterfaceInfo)(nil),    // 3: structures.InterfaceInfo
-	(*XrayInfo)(nil),         // 4: structures.XrayInfo
+	(*ClientGroupInfo)(nil),  // 3: structures.ClientGroupInfo
+	(*ClientPolicyInfo)(nil), // 4: structures.ClientPolicyInfo
+	(*InterfaceInfo)(nil),    // 5: structures.InterfaceInfo
+	(*XrayInfo)(nil),         // 6: structures.XrayInfo
 }
 var file_structures_proto_depIdxs = []int32{
 	1, // 0: structures.InterfaceInfo.status:type_name -> structures.InterfaceInfo.State
@@ -419,7 +551,7 @@ func file_structures_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_structures_proto_rawDesc), len(file_structures_proto_rawDesc)),
 			NumEnums:      2,
-			NumMessages:   3,
+			NumMessages:   5,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -31,6 +31,10 @@ type StatusResponse struct {
 	UnblockRuleCount int32                  `protobuf:"varint,6,opt,name=unblock_rule_count,json=unblockRuleCount,proto3" json:"unblock_rule_count,omitempty"`
 	Chains           []*ChainStatus         `protobuf:"bytes,7,rep,name=chains,proto3" json:"chains,omitempty"`
 	DohServers       []*DohServerStatus     `protobuf:"bytes,8,rep,name=doh_servers,json=dohServers,proto3" json:"doh_servers,omitempty"`
+	FirewallBackend  string                 `protobuf:"bytes,9,opt,name=firewall_backend,json=firewallBackend,proto3" json:"firewall_backend,omitempty"`
+	Ipsets           []*IPSetUsage          `protobuf:"bytes,10,rep,name=ipsets,proto3" json:"ipsets,omitempty"`
+	Blocklists       []*BlocklistStatus     `protobuf:"bytes,11,rep,name=blocklists,proto3" json:"blocklists,omitempty"`
+	DnsCache         *DnsCacheStatus        `protobuf:"bytes,12,opt,name=dns_cache,json=dnsCache,proto3" json:"dns_cache,omitempty"`
 	unknownFields    protoimpl.UnknownFields
 	sizeCache        protoimpl.SizeCache
 }
@@ -121,20 +125,52 @@ func (x *StatusResponse) GetDohServers() []*DohServerStatus {
 	return nil
 }
 
+func (x *StatusResponse) GetFirewallBackend() string {
+	if x != nil {
+		return x.FirewallBackend
+	}
+	return ""
+}
+
+func (x *StatusResponse) GetIpsets() []*IPSetUsage {
+	if x != nil {
+		return x.Ipsets
+	}
+	return nil
+}
+
+func (x *StatusResponse) GetBlocklists() []*BlocklistStatus {
+	if x != nil {
+		return x.Blocklists
+	}
+	return nil
+}
+
+func (x *StatusResponse) GetDnsCache() *DnsCacheStatus {
+	if x != nil {
+		return x.DnsCache
+	}
+	return nil
+}
+
 type ChainStatus struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
-	Host          string                 `protobuf:"bytes,3,opt,name=host,proto3" json:"host,omitempty"`
-	Port          int32                  `protobuf:"varint,4,opt,name=port,proto3" json:"port,omitempty"`
-	InboundPort   int32                  `protobuf:"varint,5,opt,name=inbound_port,json=inboundPort,proto3" json:"inbound_port,omitempty"`
-	AutoRun       bool                   `protobuf:"varint,6,opt,name=auto_run,json=autoRun,proto3" json:"auto_run,omitempty"`
-	Running       bool                   `protobuf:"varint,7,opt,name=running,proto3" json:"running,omitempty"`
-	Restarts      int32                  `protobuf:"varint,8,opt,name=restarts,proto3" json:"restarts,omitempty"`
-	UptimeSeconds int64                  `protobuf:"varint,9,opt,name=uptime_seconds,json=uptimeSeconds,proto3" json:"uptime_seconds,omitempty"`
-	LastExit      string                 `protobuf:"bytes,10,opt,name=last_exit,json=lastExit,proto3" json:"last_exit,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Name              string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type              string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Host              string                 `protobuf:"bytes,3,opt,name=host,proto3" json:"host,omitempty"`
+	Port              int32                  `protobuf:"varint,4,opt,name=port,proto3" json:"port,omitempty"`
+	InboundPort       int32                  `protobuf:"varint,5,opt,name=inbound_port,json=inboundPort,proto3" json:"inbound_port,omitempty"`
+	AutoRun           bool                   `protobuf:"varint,6,opt,name=auto_run,json=autoRun,proto3" json:"auto_run,omitempty"`
+	Running           bool                   `protobuf:"varint,7,opt,name=running,proto3" json:"running,omitempty"`
+	Restarts          int32                  `protobuf:"varint,8,opt,name=restarts,proto3" json:"restarts,omitempty"`
+	UptimeSeconds     int64                  `protobuf:"varint,9,opt,name=uptime_seconds,json=uptimeSeconds,proto3" json:"uptime_seconds,omitempty"`
+	LastExit          string                 `protobuf:"bytes,10,opt,name=last_exit,json=lastExit,proto3" json:"last_exit,omitempty"`
+	KillSwitch        string                 `protobuf:"bytes,11,opt,name=kill_switch,json=killSwitch,proto3" json:"kill_switch,omitempty"`
+	KillSwitchEngaged bool                   `protobuf:"varint,12,opt,name=kill_switch_engaged,json=killSwitchEngaged,proto3" json:"kill_switch_engaged,omitempty"`
+	UdpPolicy         string                 `protobuf:"bytes,13,opt,name=udp_policy,json=udpPolicy,proto3" json:"udp_policy,omitempty"`
+	UdpPolicyActive   string                 `protobuf:"bytes,14,opt,name=udp_policy_active,json=udpPolicyActive,proto3" json:"udp_policy_active,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
 }
 
 func (x *ChainStatus) Reset() {
@@ -237,12 +273,41 @@ func (x *ChainStatus) GetLastExit() string {
 	return ""
 }
 
+func (x *ChainStatus) GetKillSwitch() string {
+	if x != nil {
+		return x.KillSwitch
+	}
+	return ""
+}
+
+func (x *ChainStatus) GetKillSwitchEngaged() bool {
+	if x != nil {
+		return x.KillSwitchEngaged
+	}
+	return false
+}
+
+func (x *ChainStatus) GetUdpPolicy() string {
+	if x != nil {
+		return x.UdpPolicy
+	}
+	return ""
+}
+
+func (x *ChainStatus) GetUdpPolicyActive() string {
+	if x != nil {
+		return x.UdpPolicyActive
+	}
+	return ""
+}
+
 type DohServerStatus struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Server        string                 `protobuf:"bytes,1,opt,name=server,proto3" json:"server,omitempty"`
 	Successes     uint64                 `protobuf:"varint,2,opt,name=successes,proto3" json:"successes,omitempty"`
 	Failures      uint64                 `protobuf:"varint,3,opt,name=failures,proto3" json:"failures,omitempty"`
 	LastLatencyMs int64                  `protobuf:"varint,4,opt,name=last_latency_ms,json=lastLatencyMs,proto3" json:"last_latency_ms,omitempty"`
+	Protocol      string                 `protobuf:"bytes,5,opt,name=protocol,proto3" json:"protocol,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -305,26 +370,37 @@ func (x *DohServerStatus) GetLastLatencyMs() int64 {
 	return 0
 }
 
-type Empty struct {
+func (x *DohServerStatus) GetProtocol() string {
+	if x != nil {
+		return x.Protocol
+	}
+	return ""
+}
+
+type IPSetUsage struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Chain         string                 `protobuf:"bytes,2,opt,name=chain,proto3" json:"chain,omitempty"`
+	Entries       int32                  `protobuf:"varint,3,opt,name=entries,proto3" json:"entries,omitempty"`
+	MaxEntries    int32                  `protobuf:"varint,4,opt,name=max_entries,json=maxEntries,proto3" json:"max_entries,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Empty) Reset() {
-	*x = Empty{}
+func (x *IPSetUsage) Reset() {
+	*x = IPSetUsage{}
 	mi := &file_vpner_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Empty) String() string {
+func (x *IPSetUsage) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Empty) ProtoMessage() {}
+func (*IPSetUsage) ProtoMessage() {}
 
-func (x *Empty) ProtoReflect() protoreflect.Message {
+func (x *IPSetUsage) ProtoReflect() protoreflect.Message {
 	mi := &file_vpner_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -336,36 +412,64 @@ func (x *Empty) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Empty.ProtoReflect.Descriptor instead.
-func (*Empty) Descriptor() ([]byte, []int) {
+// Deprecated: Use IPSetUsage.ProtoReflect.Descriptor instead.
+func (*IPSetUsage) Descriptor() ([]byte, []int) {
 	return file_vpner_proto_rawDescGZIP(), []int{3}
 }
 
-type GenericResponse struct {
-	state protoimpl.MessageState `protogen:"open.v1"`
-	// Types that are valid to be assigned to Result:
-	//
-	//	*GenericResponse_Success
-	//	*GenericResponse_Error
-	Result        isGenericResponse_Result `protobuf_oneof:"result"`
+func (x *IPSetUsage) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *IPSetUsage) GetChain() string {
+	if x != nil {
+		return x.Chain
+	}
+	return ""
+}
+
+func (x *IPSetUsage) GetEntries() int32 {
+	if x != nil {
+		return x.Entries
+	}
+	return 0
+}
+
+func (x *IPSetUsage) GetMaxEntries() int32 {
+	if x != nil {
+		return x.MaxEntries
+	}
+	return 0
+}
+
+type DnsCacheStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entries       int32                  `protobuf:"varint,1,opt,name=entries,proto3" json:"entries,omitempty"`
+	Hits          uint64                 `protobuf:"varint,2,opt,name=hits,proto3" json:"hits,omitempty"`
+	Misses        uint64                 `protobuf:"varint,3,opt,name=misses,proto3" json:"misses,omitempty"`
+	Stale         uint64                 `protobuf:"varint,4,opt,name=stale,proto3" json:"stale,omitempty"`
+	Prefetch      uint64                 `protobuf:"varint,5,opt,name=prefetch,proto3" json:"prefetch,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GenericResponse) Reset() {
-	*x = GenericResponse{}
+func (x *DnsCacheStatus) Reset() {
+	*x = DnsCacheStatus{}
 	mi := &file_vpner_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GenericResponse) String() string {
+func (x *DnsCacheStatus) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GenericResponse) ProtoMessage() {}
+func (*DnsCacheStatus) ProtoMessage() {}
 
-func (x *GenericResponse) ProtoReflect() protoreflect.Message {
+func (x *DnsCacheStatus) ProtoReflect() protoreflect.Message {
 	mi := &file_vpner_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -377,73 +481,72 @@ func (x *GenericResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GenericResponse.ProtoReflect.Descriptor instead.
-func (*GenericResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use DnsCacheStatus.ProtoReflect.Descriptor instead.
+func (*DnsCacheStatus) Descriptor() ([]byte, []int) {
 	return file_vpner_proto_rawDescGZIP(), []int{4}
 }
 
-func (x *GenericResponse) GetResult() isGenericResponse_Result {
+func (x *DnsCacheStatus) GetEntries() int32 {
 	if x != nil {
-		return x.Result
+		return x.Entries
 	}
-	return nil
+	return 0
 }
 
-func (x *GenericResponse) GetSuccess() *Success {
+func (x *DnsCacheStatus) GetHits() uint64 {
 	if x != nil {
-		if x, ok := x.Result.(*GenericResponse_Success); ok {
-			return x.Success
-		}
+		return x.Hits
 	}
-	return nil
+	return 0
 }
 
-func (x *GenericResponse) GetError() *Error {
+func (x *DnsCacheStatus) GetMisses() uint64 {
 	if x != nil {
-		if x, ok := x.Result.(*GenericResponse_Error); ok {
-			return x.Error
-		}
+		return x.Misses
 	}
-	return nil
-}
-
-type isGenericResponse_Result interface {
-	isGenericResponse_Result()
+	return 0
 }
 
-type GenericResponse_Success struct {
-	Success *Success `protobuf:"bytes,1,opt,name=success,proto3,oneof"`
+func (x *DnsCacheStatus) GetStale() uint64 {
+	if x != nil {
+		return x.Stale
+	}
+	return 0
 }
 
-type GenericResponse_Error struct {
-	Error *Error `protobuf:"bytes,2,opt,name=error,proto3,oneof"`
+func (x *DnsCacheStatus) GetPrefetch() uint64 {
+	if x != nil {
+		return x.Prefetch
+	}
+	return 0
 }
 
-func (*GenericResponse_Success) isGenericResponse_Result() {}
-
-func (*GenericResponse_Error) isGenericResponse_Result() {}
-
-type Success struct {
+type BlocklistStatus struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Source        string                 `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`
+	Rules         int32                  `protobuf:"varint,3,opt,name=rules,proto3" json:"rules,omitempty"`
+	Hits          uint64                 `protobuf:"varint,4,opt,name=hits,proto3" json:"hits,omitempty"`
+	UpdatedUnix   int64                  `protobuf:"varint,5,opt,name=updated_unix,json=updatedUnix,proto3" json:"updated_unix,omitempty"`
+	Error         string                 `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Success) Reset() {
-	*x = Success{}
+func (x *BlocklistStatus) Reset() {
+	*x = BlocklistStatus{}
 	mi := &file_vpner_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Success) String() string {
+func (x *BlocklistStatus) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Success) ProtoMessage() {}
+func (*BlocklistStatus) ProtoMessage() {}
 
-func (x *Success) ProtoReflect() protoreflect.Message {
+func (x *BlocklistStatus) ProtoReflect() protoreflect.Message {
 	mi := &file_vpner_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -455,84 +558,74 @@ func (x *Success) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Success.ProtoReflect.Descriptor instead.
-func (*Success) Descriptor() ([]byte, []int) {
+// Deprecated: Use BlocklistStatus.ProtoReflect.Descriptor instead.
+func (*BlocklistStatus) Descriptor() ([]byte, []int) {
 	return file_vpner_proto_rawDescGZIP(), []int{5}
 }
 
-func (x *Success) GetMessage() string {
+func (x *BlocklistStatus) GetName() string {
 	if x != nil {
-		return x.Message
+		return x.Name
 	}
 	return ""
 }
 
-type Error struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
-}
-
-func (x *Error) Reset() {
-	*x = Error{}
-	mi := &file_vpner_proto_msgTypes[6]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+func (x *BlocklistStatus) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
 }
 
-func (x *Error) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x *BlocklistStatus) GetRules() int32 {
+	if x != nil {
+		return x.Rules
+	}
+	return 0
 }
 
-func (*Error) ProtoMessage() {}
-
-func (x *Error) ProtoReflect() protoreflect.Message {
-	mi := &file_vpner_proto_msgTypes[6]
+func (x *BlocklistStatus) GetHits() uint64 {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+		return x.Hits
 	}
-	return mi.MessageOf(x)
+	return 0
 }
 
-// Deprecated: Use Error.ProtoReflect.Descriptor instead.
-func (*Error) Descriptor() ([]byte, []int) {
-	return file_vpner_proto_rawDescGZIP(), []int{6}
+func (x *BlocklistStatus) GetUpdatedUnix() int64 {
+	if x != nil {
+		return x.UpdatedUnix
+	}
+	return 0
 }
 
-func (x *Error) GetMessage() string {
+func (x *BlocklistStatus) GetError() string {
 	if x != nil {
-		return x.Message
+		return x.Error
 	}
 	return ""
 }
 
-type UnblockListResponse struct {
+type Empty struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Rules         []*UnblockInfo         `protobuf:"bytes,1,rep,name=rules,proto3" json:"rules,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *UnblockListResponse) Reset() {
-	*x = UnblockListResponse{}
-	mi := &file_vpner_proto_msgTypes[7]
+func (x *Empty) Reset() {
+	*x = Empty{}
+	mi := &file_vpner_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *UnblockListResponse) String() string {
+func (x *Empty) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*UnblockListResponse) ProtoMessage() {}
+func (*Empty) ProtoMessage() {}
 
-func (x *UnblockListResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_vpner_proto_msgTypes[7]
+func (x *Empty) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -543,41 +636,38 @@ func (x *UnblockListResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use UnblockListResponse.ProtoReflect.Descriptor instead.
-func (*UnblockListResponse) Descriptor() ([]byte, []int) {
-	return file_vpner_proto_rawDescGZIP(), []int{7}
-}
-
-func (x *UnblockListResponse) GetRules() []*UnblockInfo {
-	if x != nil {
-		return x.Rules
-	}
-	return nil
+// Deprecated: Use Empty.ProtoReflect.Descriptor instead.
+func (*Empty) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{6}
 }
 
-type UnblockAddRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Domain        string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
-	ChainName     string                 `protobuf:"bytes,2,opt,name=chain_name,json=chainName,proto3" json:"chain_name,omitempty"`
+type GenericResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Result:
+	//
+	//	*GenericResponse_Success
+	//	*GenericResponse_Error
+	Result        isGenericResponse_Result `protobuf_oneof:"result"`
+	Plan          *Plan                    `protobuf:"bytes,3,opt,name=plan,proto3" json:"plan,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *UnblockAddRequest) Reset() {
-	*x = UnblockAddRequest{}
-	mi := &file_vpner_proto_msgTypes[8]
+func (x *GenericResponse) Reset() {
+	*x = GenericResponse{}
+	mi := &file_vpner_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *UnblockAddRequest) String() string {
+func (x *GenericResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*UnblockAddRequest) ProtoMessage() {}
+func (*GenericResponse) ProtoMessage() {}
 
-func (x *UnblockAddRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_vpner_proto_msgTypes[8]
+func (x *GenericResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -588,46 +678,124 @@ func (x *UnblockAddRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use UnblockAddRequest.ProtoReflect.Descriptor instead.
-func (*UnblockAddRequest) Descriptor() ([]byte, []int) {
-	return file_vpner_proto_rawDescGZIP(), []int{8}
+// Deprecated: Use GenericResponse.ProtoReflect.Descriptor instead.
+func (*GenericResponse) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{7}
 }
 
-func (x *UnblockAddRequest) GetDomain() string {
+func (x *GenericResponse) GetResult() isGenericResponse_Result {
 	if x != nil {
-		return x.Domain
+		return x.Result
 	}
-	return ""
+	return nil
 }
 
-func (x *UnblockAddRequest) GetChainName() string {
+func (x *GenericResponse) GetSuccess() *Success {
 	if x != nil {
-		return x.ChainName
+		if x, ok := x.Result.(*GenericResponse_Success); ok {
+			return x.Success
+		}
 	}
-	return ""
+	return nil
 }
 
-type UnblockDelRequest struct {
+func (x *GenericResponse) GetError() *Error {
+	if x != nil {
+		if x, ok := x.Result.(*GenericResponse_Error); ok {
+			return x.Error
+		}
+	}
+	return nil
+}
+
+func (x *GenericResponse) GetPlan() *Plan {
+	if x != nil {
+		return x.Plan
+	}
+	return nil
+}
+
+type isGenericResponse_Result interface {
+	isGenericResponse_Result()
+}
+
+type GenericResponse_Success struct {
+	Success *Success `protobuf:"bytes,1,opt,name=success,proto3,oneof"`
+}
+
+type GenericResponse_Error struct {
+	Error *Error `protobuf:"bytes,2,opt,name=error,proto3,oneof"`
+}
+
+func (*GenericResponse_Success) isGenericResponse_Result() {}
+
+func (*GenericResponse_Error) isGenericResponse_Result() {}
+
+type RoutingStateRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Domain        string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	ChainName     string                 `protobuf:"bytes,1,opt,name=chain_name,json=chainName,proto3" json:"chain_name,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *UnblockDelRequest) Reset() {
-	*x = UnblockDelRequest{}
+func (x *RoutingStateRequest) Reset() {
+	*x = RoutingStateRequest{}
+	mi := &file_vpner_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoutingStateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoutingStateRequest) ProtoMessage() {}
+
+func (x *RoutingStateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoutingStateRequest.ProtoReflect.Descriptor instead.
+func (*RoutingStateRequest) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *RoutingStateRequest) GetChainName() string {
+	if x != nil {
+		return x.ChainName
+	}
+	return ""
+}
+
+type RoutingStateResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Chains        []*RoutingChainState   `protobuf:"bytes,1,rep,name=chains,proto3" json:"chains,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RoutingStateResponse) Reset() {
+	*x = RoutingStateResponse{}
 	mi := &file_vpner_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *UnblockDelRequest) String() string {
+func (x *RoutingStateResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*UnblockDelRequest) ProtoMessage() {}
+func (*RoutingStateResponse) ProtoMessage() {}
 
-func (x *UnblockDelRequest) ProtoReflect() protoreflect.Message {
+func (x *RoutingStateResponse) ProtoReflect() protoreflect.Message {
 	mi := &file_vpner_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -639,40 +807,1599 @@ func (x *UnblockDelRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use UnblockDelRequest.ProtoReflect.Descriptor instead.
-func (*UnblockDelRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use RoutingStateResponse.ProtoReflect.Descriptor instead.
+func (*RoutingStateResponse) Descriptor() ([]byte, []int) {
 	return file_vpner_proto_rawDescGZIP(), []int{9}
 }
 
-func (x *UnblockDelRequest) GetDomain() string {
+func (x *RoutingStateResponse) GetChains() []*RoutingChainState {
+	if x != nil {
+		return x.Chains
+	}
+	return nil
+}
+
+type RoutingChainState struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Chain         string                 `protobuf:"bytes,1,opt,name=chain,proto3" json:"chain,omitempty"`
+	VpnType       string                 `protobuf:"bytes,2,opt,name=vpn_type,json=vpnType,proto3" json:"vpn_type,omitempty"`
+	Family        string                 `protobuf:"bytes,3,opt,name=family,proto3" json:"family,omitempty"`
+	Table         string                 `protobuf:"bytes,4,opt,name=table,proto3" json:"table,omitempty"`
+	ChainName     string                 `protobuf:"bytes,5,opt,name=chain_name,json=chainName,proto3" json:"chain_name,omitempty"`
+	ChainPresent  bool                   `protobuf:"varint,6,opt,name=chain_present,json=chainPresent,proto3" json:"chain_present,omitempty"`
+	Jumps         []*RoutingJump         `protobuf:"bytes,7,rep,name=jumps,proto3" json:"jumps,omitempty"`
+	IpsetName     string                 `protobuf:"bytes,8,opt,name=ipset_name,json=ipsetName,proto3" json:"ipset_name,omitempty"`
+	IpsetPresent  bool                   `protobuf:"varint,9,opt,name=ipset_present,json=ipsetPresent,proto3" json:"ipset_present,omitempty"`
+	IpsetEntries  int32                  `protobuf:"varint,10,opt,name=ipset_entries,json=ipsetEntries,proto3" json:"ipset_entries,omitempty"`
+	Mark          string                 `protobuf:"bytes,11,opt,name=mark,proto3" json:"mark,omitempty"`
+	RouteTable    int32                  `protobuf:"varint,12,opt,name=route_table,json=routeTable,proto3" json:"route_table,omitempty"`
+	IpRulePresent bool                   `protobuf:"varint,13,opt,name=ip_rule_present,json=ipRulePresent,proto3" json:"ip_rule_present,omitempty"`
+	Tproxy        bool                   `protobuf:"varint,14,opt,name=tproxy,proto3" json:"tproxy,omitempty"`
+	TargetPresent bool                   `protobuf:"varint,15,opt,name=target_present,json=targetPresent,proto3" json:"target_present,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RoutingChainState) Reset() {
+	*x = RoutingChainState{}
+	mi := &file_vpner_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoutingChainState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoutingChainState) ProtoMessage() {}
+
+func (x *RoutingChainState) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoutingChainState.ProtoReflect.Descriptor instead.
+func (*RoutingChainState) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *RoutingChainState) GetChain() string {
+	if x != nil {
+		return x.Chain
+	}
+	return ""
+}
+
+func (x *RoutingChainState) GetVpnType() string {
+	if x != nil {
+		return x.VpnType
+	}
+	return ""
+}
+
+func (x *RoutingChainState) GetFamily() string {
+	if x != nil {
+		return x.Family
+	}
+	return ""
+}
+
+func (x *RoutingChainState) GetTable() string {
+	if x != nil {
+		return x.Table
+	}
+	return ""
+}
+
+func (x *RoutingChainState) GetChainName() string {
+	if x != nil {
+		return x.ChainName
+	}
+	return ""
+}
+
+func (x *RoutingChainState) GetChainPresent() bool {
+	if x != nil {
+		return x.ChainPresent
+	}
+	return false
+}
+
+func (x *RoutingChainState) GetJumps() []*RoutingJump {
+	if x != nil {
+		return x.Jumps
+	}
+	return nil
+}
+
+func (x *RoutingChainState) GetIpsetName() string {
+	if x != nil {
+		return x.IpsetName
+	}
+	return ""
+}
+
+func (x *RoutingChainState) GetIpsetPresent() bool {
+	if x != nil {
+		return x.IpsetPresent
+	}
+	return false
+}
+
+func (x *RoutingChainState) GetIpsetEntries() int32 {
+	if x != nil {
+		return x.IpsetEntries
+	}
+	return 0
+}
+
+func (x *RoutingChainState) GetMark() string {
+	if x != nil {
+		return x.Mark
+	}
+	return ""
+}
+
+func (x *RoutingChainState) GetRouteTable() int32 {
+	if x != nil {
+		return x.RouteTable
+	}
+	return 0
+}
+
+func (x *RoutingChainState) GetIpRulePresent() bool {
+	if x != nil {
+		return x.IpRulePresent
+	}
+	return false
+}
+
+func (x *RoutingChainState) GetTproxy() bool {
+	if x != nil {
+		return x.Tproxy
+	}
+	return false
+}
+
+func (x *RoutingChainState) GetTargetPresent() bool {
+	if x != nil {
+		return x.TargetPresent
+	}
+	return false
+}
+
+type RoutingJump struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Rule          string                 `protobuf:"bytes,1,opt,name=rule,proto3" json:"rule,omitempty"`
+	Present       bool                   `protobuf:"varint,2,opt,name=present,proto3" json:"present,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RoutingJump) Reset() {
+	*x = RoutingJump{}
+	mi := &file_vpner_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoutingJump) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoutingJump) ProtoMessage() {}
+
+func (x *RoutingJump) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoutingJump.ProtoReflect.Descriptor instead.
+func (*RoutingJump) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *RoutingJump) GetRule() string {
+	if x != nil {
+		return x.Rule
+	}
+	return ""
+}
+
+func (x *RoutingJump) GetPresent() bool {
+	if x != nil {
+		return x.Present
+	}
+	return false
+}
+
+type TraceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Target        string                 `protobuf:"bytes,1,opt,name=target,proto3" json:"target,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TraceRequest) Reset() {
+	*x = TraceRequest{}
+	mi := &file_vpner_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TraceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TraceRequest) ProtoMessage() {}
+
+func (x *TraceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TraceRequest.ProtoReflect.Descriptor instead.
+func (*TraceRequest) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *TraceRequest) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+type TraceResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Target        string                 `protobuf:"bytes,1,opt,name=target,proto3" json:"target,omitempty"`
+	Matched       bool                   `protobuf:"varint,2,opt,name=matched,proto3" json:"matched,omitempty"`
+	VpnType       string                 `protobuf:"bytes,3,opt,name=vpn_type,json=vpnType,proto3" json:"vpn_type,omitempty"`
+	Chain         string                 `protobuf:"bytes,4,opt,name=chain,proto3" json:"chain,omitempty"`
+	Pattern       string                 `protobuf:"bytes,5,opt,name=pattern,proto3" json:"pattern,omitempty"`
+	Dns           []*TraceDns            `protobuf:"bytes,6,rep,name=dns,proto3" json:"dns,omitempty"`
+	Entries       []*TraceSetEntry       `protobuf:"bytes,7,rep,name=entries,proto3" json:"entries,omitempty"`
+	ChainRunning  bool                   `protobuf:"varint,8,opt,name=chain_running,json=chainRunning,proto3" json:"chain_running,omitempty"`
+	Routing       []*RoutingChainState   `protobuf:"bytes,9,rep,name=routing,proto3" json:"routing,omitempty"`
+	Routed        bool                   `protobuf:"varint,10,opt,name=routed,proto3" json:"routed,omitempty"`
+	Verdict       string                 `protobuf:"bytes,11,opt,name=verdict,proto3" json:"verdict,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TraceResponse) Reset() {
+	*x = TraceResponse{}
+	mi := &file_vpner_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TraceResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TraceResponse) ProtoMessage() {}
+
+func (x *TraceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TraceResponse.ProtoReflect.Descriptor instead.
+func (*TraceResponse) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *TraceResponse) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *TraceResponse) GetMatched() bool {
+	if x != nil {
+		return x.Matched
+	}
+	return false
+}
+
+func (x *TraceResponse) GetVpnType() string {
+	if x != nil {
+		return x.VpnType
+	}
+	return ""
+}
+
+func (x *TraceResponse) GetChain() string {
+	if x != nil {
+		return x.Chain
+	}
+	return ""
+}
+
+func (x *TraceResponse) GetPattern() string {
+	if x != nil {
+		return x.Pattern
+	}
+	return ""
+}
+
+func (x *TraceResponse) GetDns() []*TraceDns {
+	if x != nil {
+		return x.Dns
+	}
+	return nil
+}
+
+func (x *TraceResponse) GetEntries() []*TraceSetEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+func (x *TraceResponse) GetChainRunning() bool {
+	if x != nil {
+		return x.ChainRunning
+	}
+	return false
+}
+
+func (x *TraceResponse) GetRouting() []*RoutingChainState {
+	if x != nil {
+		return x.Routing
+	}
+	return nil
+}
+
+func (x *TraceResponse) GetRouted() bool {
+	if x != nil {
+		return x.Routed
+	}
+	return false
+}
+
+func (x *TraceResponse) GetVerdict() string {
+	if x != nil {
+		return x.Verdict
+	}
+	return ""
+}
+
+type TraceDns struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Qtype         string                 `protobuf:"bytes,1,opt,name=qtype,proto3" json:"qtype,omitempty"`
+	Source        string                 `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`
+	Server        string                 `protobuf:"bytes,3,opt,name=server,proto3" json:"server,omitempty"`
+	Rcode         string                 `protobuf:"bytes,4,opt,name=rcode,proto3" json:"rcode,omitempty"`
+	Answers       []string               `protobuf:"bytes,5,rep,name=answers,proto3" json:"answers,omitempty"`
+	Error         string                 `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TraceDns) Reset() {
+	*x = TraceDns{}
+	mi := &file_vpner_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TraceDns) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TraceDns) ProtoMessage() {}
+
+func (x *TraceDns) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TraceDns.ProtoReflect.Descriptor instead.
+func (*TraceDns) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *TraceDns) GetQtype() string {
+	if x != nil {
+		return x.Qtype
+	}
+	return ""
+}
+
+func (x *TraceDns) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *TraceDns) GetServer() string {
+	if x != nil {
+		return x.Server
+	}
+	return ""
+}
+
+func (x *TraceDns) GetRcode() string {
+	if x != nil {
+		return x.Rcode
+	}
+	return ""
+}
+
+func (x *TraceDns) GetAnswers() []string {
+	if x != nil {
+		return x.Answers
+	}
+	return nil
+}
+
+func (x *TraceDns) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type TraceSetEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Set           string                 `protobuf:"bytes,1,opt,name=set,proto3" json:"set,omitempty"`
+	Entry         string                 `protobuf:"bytes,2,opt,name=entry,proto3" json:"entry,omitempty"`
+	Comment       string                 `protobuf:"bytes,3,opt,name=comment,proto3" json:"comment,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TraceSetEntry) Reset() {
+	*x = TraceSetEntry{}
+	mi := &file_vpner_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TraceSetEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TraceSetEntry) ProtoMessage() {}
+
+func (x *TraceSetEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TraceSetEntry.ProtoReflect.Descriptor instead.
+func (*TraceSetEntry) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *TraceSetEntry) GetSet() string {
+	if x != nil {
+		return x.Set
+	}
+	return ""
+}
+
+func (x *TraceSetEntry) GetEntry() string {
+	if x != nil {
+		return x.Entry
+	}
+	return ""
+}
+
+func (x *TraceSetEntry) GetComment() string {
+	if x != nil {
+		return x.Comment
+	}
+	return ""
+}
+
+type RoutingPlanRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ChainName     string                 `protobuf:"bytes,1,opt,name=chain_name,json=chainName,proto3" json:"chain_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RoutingPlanRequest) Reset() {
+	*x = RoutingPlanRequest{}
+	mi := &file_vpner_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoutingPlanRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoutingPlanRequest) ProtoMessage() {}
+
+func (x *RoutingPlanRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoutingPlanRequest.ProtoReflect.Descriptor instead.
+func (*RoutingPlanRequest) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *RoutingPlanRequest) GetChainName() string {
+	if x != nil {
+		return x.ChainName
+	}
+	return ""
+}
+
+type Plan struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Steps         []*PlanStep            `protobuf:"bytes,1,rep,name=steps,proto3" json:"steps,omitempty"`
+	Diff          []*PlanDiff            `protobuf:"bytes,2,rep,name=diff,proto3" json:"diff,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Plan) Reset() {
+	*x = Plan{}
+	mi := &file_vpner_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Plan) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Plan) ProtoMessage() {}
+
+func (x *Plan) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Plan.ProtoReflect.Descriptor instead.
+func (*Plan) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *Plan) GetSteps() []*PlanStep {
+	if x != nil {
+		return x.Steps
+	}
+	return nil
+}
+
+func (x *Plan) GetDiff() []*PlanDiff {
+	if x != nil {
+		return x.Diff
+	}
+	return nil
+}
+
+type PlanStep struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tool          string                 `protobuf:"bytes,1,opt,name=tool,proto3" json:"tool,omitempty"`
+	Table         string                 `protobuf:"bytes,2,opt,name=table,proto3" json:"table,omitempty"`
+	Args          []string               `protobuf:"bytes,3,rep,name=args,proto3" json:"args,omitempty"`
+	Lines         []string               `protobuf:"bytes,4,rep,name=lines,proto3" json:"lines,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlanStep) Reset() {
+	*x = PlanStep{}
+	mi := &file_vpner_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlanStep) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlanStep) ProtoMessage() {}
+
+func (x *PlanStep) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlanStep.ProtoReflect.Descriptor instead.
+func (*PlanStep) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *PlanStep) GetTool() string {
+	if x != nil {
+		return x.Tool
+	}
+	return ""
+}
+
+func (x *PlanStep) GetTable() string {
+	if x != nil {
+		return x.Table
+	}
+	return ""
+}
+
+func (x *PlanStep) GetArgs() []string {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *PlanStep) GetLines() []string {
+	if x != nil {
+		return x.Lines
+	}
+	return nil
+}
+
+type PlanDiff struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tool          string                 `protobuf:"bytes,1,opt,name=tool,proto3" json:"tool,omitempty"`
+	Table         string                 `protobuf:"bytes,2,opt,name=table,proto3" json:"table,omitempty"`
+	Added         []string               `protobuf:"bytes,3,rep,name=added,proto3" json:"added,omitempty"`
+	Removed       []string               `protobuf:"bytes,4,rep,name=removed,proto3" json:"removed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlanDiff) Reset() {
+	*x = PlanDiff{}
+	mi := &file_vpner_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlanDiff) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlanDiff) ProtoMessage() {}
+
+func (x *PlanDiff) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlanDiff.ProtoReflect.Descriptor instead.
+func (*PlanDiff) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *PlanDiff) GetTool() string {
+	if x != nil {
+		return x.Tool
+	}
+	return ""
+}
+
+func (x *PlanDiff) GetTable() string {
+	if x != nil {
+		return x.Table
+	}
+	return ""
+}
+
+func (x *PlanDiff) GetAdded() []string {
+	if x != nil {
+		return x.Added
+	}
+	return nil
+}
+
+func (x *PlanDiff) GetRemoved() []string {
+	if x != nil {
+		return x.Removed
+	}
+	return nil
+}
+
+type Success struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Success) Reset() {
+	*x = Success{}
+	mi := &file_vpner_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Success) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Success) ProtoMessage() {}
+
+func (x *Success) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Success.ProtoReflect.Descriptor instead.
+func (*Success) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *Success) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type Error struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Error) Reset() {
+	*x = Error{}
+	mi := &file_vpner_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Error) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Error) ProtoMessage() {}
+
+func (x *Error) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Error.ProtoReflect.Descriptor instead.
+func (*Error) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *Error) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type UnblockListResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Rules         []*UnblockInfo         `protobuf:"bytes,1,rep,name=rules,proto3" json:"rules,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnblockListResponse) Reset() {
+	*x = UnblockListResponse{}
+	mi := &file_vpner_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnblockListResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnblockListResponse) ProtoMessage() {}
+
+func (x *UnblockListResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnblockListResponse.ProtoReflect.Descriptor instead.
+func (*UnblockListResponse) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *UnblockListResponse) GetRules() []*UnblockInfo {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+type UnblockAddRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Domain        string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	ChainName     string                 `protobuf:"bytes,2,opt,name=chain_name,json=chainName,proto3" json:"chain_name,omitempty"`
+	DryRun        bool                   `protobuf:"varint,3,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnblockAddRequest) Reset() {
+	*x = UnblockAddRequest{}
+	mi := &file_vpner_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnblockAddRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnblockAddRequest) ProtoMessage() {}
+
+func (x *UnblockAddRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnblockAddRequest.ProtoReflect.Descriptor instead.
+func (*UnblockAddRequest) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *UnblockAddRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *UnblockAddRequest) GetChainName() string {
+	if x != nil {
+		return x.ChainName
+	}
+	return ""
+}
+
+func (x *UnblockAddRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type UnblockDelRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Domain        string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	DryRun        bool                   `protobuf:"varint,2,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnblockDelRequest) Reset() {
+	*x = UnblockDelRequest{}
+	mi := &file_vpner_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnblockDelRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnblockDelRequest) ProtoMessage() {}
+
+func (x *UnblockDelRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnblockDelRequest.ProtoReflect.Descriptor instead.
+func (*UnblockDelRequest) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *UnblockDelRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *UnblockDelRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type ClientGroupListResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Groups        []*ClientGroupInfo     `protobuf:"bytes,1,rep,name=groups,proto3" json:"groups,omitempty"`
+	Policies      []*ClientPolicyInfo    `protobuf:"bytes,2,rep,name=policies,proto3" json:"policies,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientGroupListResponse) Reset() {
+	*x = ClientGroupListResponse{}
+	mi := &file_vpner_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientGroupListResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientGroupListResponse) ProtoMessage() {}
+
+func (x *ClientGroupListResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientGroupListResponse.ProtoReflect.Descriptor instead.
+func (*ClientGroupListResponse) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *ClientGroupListResponse) GetGroups() []*ClientGroupInfo {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+func (x *ClientGroupListResponse) GetPolicies() []*ClientPolicyInfo {
+	if x != nil {
+		return x.Policies
+	}
+	return nil
+}
+
+type ClientGroupRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Members       []string               `protobuf:"bytes,2,rep,name=members,proto3" json:"members,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientGroupRequest) Reset() {
+	*x = ClientGroupRequest{}
+	mi := &file_vpner_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientGroupRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientGroupRequest) ProtoMessage() {}
+
+func (x *ClientGroupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientGroupRequest.ProtoReflect.Descriptor instead.
+func (*ClientGroupRequest) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *ClientGroupRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ClientGroupRequest) GetMembers() []string {
+	if x != nil {
+		return x.Members
+	}
+	return nil
+}
+
+type ClientPolicyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ChainName     string                 `protobuf:"bytes,1,opt,name=chain_name,json=chainName,proto3" json:"chain_name,omitempty"`
+	Only          []string               `protobuf:"bytes,2,rep,name=only,proto3" json:"only,omitempty"`
+	Except        []string               `protobuf:"bytes,3,rep,name=except,proto3" json:"except,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientPolicyRequest) Reset() {
+	*x = ClientPolicyRequest{}
+	mi := &file_vpner_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientPolicyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientPolicyRequest) ProtoMessage() {}
+
+func (x *ClientPolicyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientPolicyRequest.ProtoReflect.Descriptor instead.
+func (*ClientPolicyRequest) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *ClientPolicyRequest) GetChainName() string {
+	if x != nil {
+		return x.ChainName
+	}
+	return ""
+}
+
+func (x *ClientPolicyRequest) GetOnly() []string {
+	if x != nil {
+		return x.Only
+	}
+	return nil
+}
+
+func (x *ClientPolicyRequest) GetExcept() []string {
+	if x != nil {
+		return x.Except
+	}
+	return nil
+}
+
+type ClientFullTunnelRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	ChainName     string                 `protobuf:"bytes,2,opt,name=chain_name,json=chainName,proto3" json:"chain_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientFullTunnelRequest) Reset() {
+	*x = ClientFullTunnelRequest{}
+	mi := &file_vpner_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientFullTunnelRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientFullTunnelRequest) ProtoMessage() {}
+
+func (x *ClientFullTunnelRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientFullTunnelRequest.ProtoReflect.Descriptor instead.
+func (*ClientFullTunnelRequest) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *ClientFullTunnelRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ClientFullTunnelRequest) GetChainName() string {
+	if x != nil {
+		return x.ChainName
+	}
+	return ""
+}
+
+type InterfaceListResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Interfaces    []*InterfaceInfo       `protobuf:"bytes,1,rep,name=interfaces,proto3" json:"interfaces,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InterfaceListResponse) Reset() {
+	*x = InterfaceListResponse{}
+	mi := &file_vpner_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InterfaceListResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InterfaceListResponse) ProtoMessage() {}
+
+func (x *InterfaceListResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InterfaceListResponse.ProtoReflect.Descriptor instead.
+func (*InterfaceListResponse) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *InterfaceListResponse) GetInterfaces() []*InterfaceInfo {
+	if x != nil {
+		return x.Interfaces
+	}
+	return nil
+}
+
+type InterfaceActionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InterfaceActionRequest) Reset() {
+	*x = InterfaceActionRequest{}
+	mi := &file_vpner_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InterfaceActionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InterfaceActionRequest) ProtoMessage() {}
+
+func (x *InterfaceActionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InterfaceActionRequest.ProtoReflect.Descriptor instead.
+func (*InterfaceActionRequest) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *InterfaceActionRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type ManageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Act           ManageAction           `protobuf:"varint,1,opt,name=act,proto3,enum=structures.ManageAction" json:"act,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ManageRequest) Reset() {
+	*x = ManageRequest{}
+	mi := &file_vpner_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ManageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ManageRequest) ProtoMessage() {}
+
+func (x *ManageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ManageRequest.ProtoReflect.Descriptor instead.
+func (*ManageRequest) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *ManageRequest) GetAct() ManageAction {
+	if x != nil {
+		return x.Act
+	}
+	return ManageAction_START
+}
+
+type DnsRecord struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Value         string                 `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	Ttl           int32                  `protobuf:"varint,4,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	Source        string                 `protobuf:"bytes,5,opt,name=source,proto3" json:"source,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DnsRecord) Reset() {
+	*x = DnsRecord{}
+	mi := &file_vpner_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DnsRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DnsRecord) ProtoMessage() {}
+
+func (x *DnsRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DnsRecord.ProtoReflect.Descriptor instead.
+func (*DnsRecord) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *DnsRecord) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *DnsRecord) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *DnsRecord) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *DnsRecord) GetTtl() int32 {
+	if x != nil {
+		return x.Ttl
+	}
+	return 0
+}
+
+func (x *DnsRecord) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+type DnsRecordListResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Records       []*DnsRecord           `protobuf:"bytes,1,rep,name=records,proto3" json:"records,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DnsRecordListResponse) Reset() {
+	*x = DnsRecordListResponse{}
+	mi := &file_vpner_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DnsRecordListResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DnsRecordListResponse) ProtoMessage() {}
+
+func (x *DnsRecordListResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DnsRecordListResponse.ProtoReflect.Descriptor instead.
+func (*DnsRecordListResponse) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *DnsRecordListResponse) GetRecords() []*DnsRecord {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+type DnsQueryLogRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Client        string                 `protobuf:"bytes,1,opt,name=client,proto3" json:"client,omitempty"`
+	Domain        string                 `protobuf:"bytes,2,opt,name=domain,proto3" json:"domain,omitempty"`
+	Type          string                 `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	Rcode         string                 `protobuf:"bytes,4,opt,name=rcode,proto3" json:"rcode,omitempty"`
+	Source        string                 `protobuf:"bytes,5,opt,name=source,proto3" json:"source,omitempty"`
+	Limit         int32                  `protobuf:"varint,6,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DnsQueryLogRequest) Reset() {
+	*x = DnsQueryLogRequest{}
+	mi := &file_vpner_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DnsQueryLogRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DnsQueryLogRequest) ProtoMessage() {}
+
+func (x *DnsQueryLogRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DnsQueryLogRequest.ProtoReflect.Descriptor instead.
+func (*DnsQueryLogRequest) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *DnsQueryLogRequest) GetClient() string {
+	if x != nil {
+		return x.Client
+	}
+	return ""
+}
+
+func (x *DnsQueryLogRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *DnsQueryLogRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *DnsQueryLogRequest) GetRcode() string {
+	if x != nil {
+		return x.Rcode
+	}
+	return ""
+}
+
+func (x *DnsQueryLogRequest) GetSource() string {
 	if x != nil {
-		return x.Domain
+		return x.Source
 	}
 	return ""
 }
 
-type InterfaceListResponse struct {
+func (x *DnsQueryLogRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type DnsQueryLogEntry struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Interfaces    []*InterfaceInfo       `protobuf:"bytes,1,rep,name=interfaces,proto3" json:"interfaces,omitempty"`
+	TimeUnixMs    int64                  `protobuf:"varint,1,opt,name=time_unix_ms,json=timeUnixMs,proto3" json:"time_unix_ms,omitempty"`
+	Client        string                 `protobuf:"bytes,2,opt,name=client,proto3" json:"client,omitempty"`
+	Name          string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Type          string                 `protobuf:"bytes,4,opt,name=type,proto3" json:"type,omitempty"`
+	Rcode         string                 `protobuf:"bytes,5,opt,name=rcode,proto3" json:"rcode,omitempty"`
+	Answers       []string               `protobuf:"bytes,6,rep,name=answers,proto3" json:"answers,omitempty"`
+	Source        string                 `protobuf:"bytes,7,opt,name=source,proto3" json:"source,omitempty"`
+	Upstream      string                 `protobuf:"bytes,8,opt,name=upstream,proto3" json:"upstream,omitempty"`
+	Cached        bool                   `protobuf:"varint,9,opt,name=cached,proto3" json:"cached,omitempty"`
+	Rule          string                 `protobuf:"bytes,10,opt,name=rule,proto3" json:"rule,omitempty"`
+	Chain         string                 `protobuf:"bytes,11,opt,name=chain,proto3" json:"chain,omitempty"`
+	LatencyUs     int64                  `protobuf:"varint,12,opt,name=latency_us,json=latencyUs,proto3" json:"latency_us,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *InterfaceListResponse) Reset() {
-	*x = InterfaceListResponse{}
-	mi := &file_vpner_proto_msgTypes[10]
+func (x *DnsQueryLogEntry) Reset() {
+	*x = DnsQueryLogEntry{}
+	mi := &file_vpner_proto_msgTypes[35]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *InterfaceListResponse) String() string {
+func (x *DnsQueryLogEntry) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*InterfaceListResponse) ProtoMessage() {}
+func (*DnsQueryLogEntry) ProtoMessage() {}
 
-func (x *InterfaceListResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_vpner_proto_msgTypes[10]
+func (x *DnsQueryLogEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[35]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -683,84 +2410,117 @@ func (x *InterfaceListResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use InterfaceListResponse.ProtoReflect.Descriptor instead.
-func (*InterfaceListResponse) Descriptor() ([]byte, []int) {
-	return file_vpner_proto_rawDescGZIP(), []int{10}
+// Deprecated: Use DnsQueryLogEntry.ProtoReflect.Descriptor instead.
+func (*DnsQueryLogEntry) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{35}
 }
 
-func (x *InterfaceListResponse) GetInterfaces() []*InterfaceInfo {
+func (x *DnsQueryLogEntry) GetTimeUnixMs() int64 {
 	if x != nil {
-		return x.Interfaces
+		return x.TimeUnixMs
 	}
-	return nil
+	return 0
 }
 
-type InterfaceActionRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+func (x *DnsQueryLogEntry) GetClient() string {
+	if x != nil {
+		return x.Client
+	}
+	return ""
 }
 
-func (x *InterfaceActionRequest) Reset() {
-	*x = InterfaceActionRequest{}
-	mi := &file_vpner_proto_msgTypes[11]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+func (x *DnsQueryLogEntry) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
 }
 
-func (x *InterfaceActionRequest) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x *DnsQueryLogEntry) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
 }
 
-func (*InterfaceActionRequest) ProtoMessage() {}
+func (x *DnsQueryLogEntry) GetRcode() string {
+	if x != nil {
+		return x.Rcode
+	}
+	return ""
+}
 
-func (x *InterfaceActionRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_vpner_proto_msgTypes[11]
+func (x *DnsQueryLogEntry) GetAnswers() []string {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+		return x.Answers
 	}
-	return mi.MessageOf(x)
+	return nil
 }
 
-// Deprecated: Use InterfaceActionRequest.ProtoReflect.Descriptor instead.
-func (*InterfaceActionRequest) Descriptor() ([]byte, []int) {
-	return file_vpner_proto_rawDescGZIP(), []int{11}
+func (x *DnsQueryLogEntry) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
 }
 
-func (x *InterfaceActionRequest) GetId() string {
+func (x *DnsQueryLogEntry) GetUpstream() string {
 	if x != nil {
-		return x.Id
+		return x.Upstream
 	}
 	return ""
 }
 
-type ManageRequest struct {
+func (x *DnsQueryLogEntry) GetCached() bool {
+	if x != nil {
+		return x.Cached
+	}
+	return false
+}
+
+func (x *DnsQueryLogEntry) GetRule() string {
+	if x != nil {
+		return x.Rule
+	}
+	return ""
+}
+
+func (x *DnsQueryLogEntry) GetChain() string {
+	if x != nil {
+		return x.Chain
+	}
+	return ""
+}
+
+func (x *DnsQueryLogEntry) GetLatencyUs() int64 {
+	if x != nil {
+		return x.LatencyUs
+	}
+	return 0
+}
+
+type DnsQueryLogResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Act           ManageAction           `protobuf:"varint,1,opt,name=act,proto3,enum=structures.ManageAction" json:"act,omitempty"`
+	Entries       []*DnsQueryLogEntry    `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ManageRequest) Reset() {
-	*x = ManageRequest{}
-	mi := &file_vpner_proto_msgTypes[12]
+func (x *DnsQueryLogResponse) Reset() {
+	*x = DnsQueryLogResponse{}
+	mi := &file_vpner_proto_msgTypes[36]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ManageRequest) String() string {
+func (x *DnsQueryLogResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ManageRequest) ProtoMessage() {}
+func (*DnsQueryLogResponse) ProtoMessage() {}
 
-func (x *ManageRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_vpner_proto_msgTypes[12]
+func (x *DnsQueryLogResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[36]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -771,16 +2531,16 @@ func (x *ManageRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ManageRequest.ProtoReflect.Descriptor instead.
-func (*ManageRequest) Descriptor() ([]byte, []int) {
-	return file_vpner_proto_rawDescGZIP(), []int{12}
+// Deprecated: Use DnsQueryLogResponse.ProtoReflect.Descriptor instead.
+func (*DnsQueryLogResponse) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{36}
 }
 
-func (x *ManageRequest) GetAct() ManageAction {
+func (x *DnsQueryLogResponse) GetEntries() []*DnsQueryLogEntry {
 	if x != nil {
-		return x.Act
+		return x.Entries
 	}
-	return ManageAction_START
+	return nil
 }
 
 type XrayCreateRequest struct {
@@ -793,7 +2553,7 @@ type XrayCreateRequest struct {
 
 func (x *XrayCreateRequest) Reset() {
 	*x = XrayCreateRequest{}
-	mi := &file_vpner_proto_msgTypes[13]
+	mi := &file_vpner_proto_msgTypes[37]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -805,7 +2565,7 @@ func (x *XrayCreateRequest) String() string {
 func (*XrayCreateRequest) ProtoMessage() {}
 
 func (x *XrayCreateRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_vpner_proto_msgTypes[13]
+	mi := &file_vpner_proto_msgTypes[37]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -818,7 +2578,7 @@ func (x *XrayCreateRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use XrayCreateRequest.ProtoReflect.Descriptor instead.
 func (*XrayCreateRequest) Descriptor() ([]byte, []int) {
-	return file_vpner_proto_rawDescGZIP(), []int{13}
+	return file_vpner_proto_rawDescGZIP(), []int{37}
 }
 
 func (x *XrayCreateRequest) GetLink() string {
@@ -845,7 +2605,7 @@ type XrayUpdateRequest struct {
 
 func (x *XrayUpdateRequest) Reset() {
 	*x = XrayUpdateRequest{}
-	mi := &file_vpner_proto_msgTypes[14]
+	mi := &file_vpner_proto_msgTypes[38]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -857,7 +2617,7 @@ func (x *XrayUpdateRequest) String() string {
 func (*XrayUpdateRequest) ProtoMessage() {}
 
 func (x *XrayUpdateRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_vpner_proto_msgTypes[14]
+	mi := &file_vpner_proto_msgTypes[38]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -870,7 +2630,7 @@ func (x *XrayUpdateRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use XrayUpdateRequest.ProtoReflect.Descriptor instead.
 func (*XrayUpdateRequest) Descriptor() ([]byte, []int) {
-	return file_vpner_proto_rawDescGZIP(), []int{14}
+	return file_vpner_proto_rawDescGZIP(), []int{38}
 }
 
 func (x *XrayUpdateRequest) GetChainName() string {
@@ -896,7 +2656,7 @@ type XrayRequest struct {
 
 func (x *XrayRequest) Reset() {
 	*x = XrayRequest{}
-	mi := &file_vpner_proto_msgTypes[15]
+	mi := &file_vpner_proto_msgTypes[39]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -908,7 +2668,7 @@ func (x *XrayRequest) String() string {
 func (*XrayRequest) ProtoMessage() {}
 
 func (x *XrayRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_vpner_proto_msgTypes[15]
+	mi := &file_vpner_proto_msgTypes[39]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -921,7 +2681,7 @@ func (x *XrayRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use XrayRequest.ProtoReflect.Descriptor instead.
 func (*XrayRequest) Descriptor() ([]byte, []int) {
-	return file_vpner_proto_rawDescGZIP(), []int{15}
+	return file_vpner_proto_rawDescGZIP(), []int{39}
 }
 
 func (x *XrayRequest) GetChainName() string {
@@ -935,13 +2695,14 @@ type XrayManageRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	ChainName     string                 `protobuf:"bytes,1,opt,name=chain_name,json=chainName,proto3" json:"chain_name,omitempty"`
 	Act           ManageAction           `protobuf:"varint,2,opt,name=act,proto3,enum=structures.ManageAction" json:"act,omitempty"`
+	DryRun        bool                   `protobuf:"varint,3,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *XrayManageRequest) Reset() {
 	*x = XrayManageRequest{}
-	mi := &file_vpner_proto_msgTypes[16]
+	mi := &file_vpner_proto_msgTypes[40]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -953,7 +2714,7 @@ func (x *XrayManageRequest) String() string {
 func (*XrayManageRequest) ProtoMessage() {}
 
 func (x *XrayManageRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_vpner_proto_msgTypes[16]
+	mi := &file_vpner_proto_msgTypes[40]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -966,7 +2727,7 @@ func (x *XrayManageRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use XrayManageRequest.ProtoReflect.Descriptor instead.
 func (*XrayManageRequest) Descriptor() ([]byte, []int) {
-	return file_vpner_proto_rawDescGZIP(), []int{16}
+	return file_vpner_proto_rawDescGZIP(), []int{40}
 }
 
 func (x *XrayManageRequest) GetChainName() string {
@@ -983,6 +2744,13 @@ func (x *XrayManageRequest) GetAct() ManageAction {
 	return ManageAction_START
 }
 
+func (x *XrayManageRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
 type XrayAutoRunRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	ChainName     string                 `protobuf:"bytes,1,opt,name=chain_name,json=chainName,proto3" json:"chain_name,omitempty"`
@@ -993,7 +2761,7 @@ type XrayAutoRunRequest struct {
 
 func (x *XrayAutoRunRequest) Reset() {
 	*x = XrayAutoRunRequest{}
-	mi := &file_vpner_proto_msgTypes[17]
+	mi := &file_vpner_proto_msgTypes[41]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1005,7 +2773,7 @@ func (x *XrayAutoRunRequest) String() string {
 func (*XrayAutoRunRequest) ProtoMessage() {}
 
 func (x *XrayAutoRunRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_vpner_proto_msgTypes[17]
+	mi := &file_vpner_proto_msgTypes[41]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1018,7 +2786,7 @@ func (x *XrayAutoRunRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use XrayAutoRunRequest.ProtoReflect.Descriptor instead.
 func (*XrayAutoRunRequest) Descriptor() ([]byte, []int) {
-	return file_vpner_proto_rawDescGZIP(), []int{17}
+	return file_vpner_proto_rawDescGZIP(), []int{41}
 }
 
 func (x *XrayAutoRunRequest) GetChainName() string {
@@ -1035,6 +2803,170 @@ func (x *XrayAutoRunRequest) GetAutoRun() bool {
 	return false
 }
 
+type XrayKillSwitchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ChainName     string                 `protobuf:"bytes,1,opt,name=chain_name,json=chainName,proto3" json:"chain_name,omitempty"`
+	Mode          string                 `protobuf:"bytes,2,opt,name=mode,proto3" json:"mode,omitempty"`
+	DryRun        bool                   `protobuf:"varint,3,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *XrayKillSwitchRequest) Reset() {
+	*x = XrayKillSwitchRequest{}
+	mi := &file_vpner_proto_msgTypes[42]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *XrayKillSwitchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*XrayKillSwitchRequest) ProtoMessage() {}
+
+func (x *XrayKillSwitchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[42]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use XrayKillSwitchRequest.ProtoReflect.Descriptor instead.
+func (*XrayKillSwitchRequest) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *XrayKillSwitchRequest) GetChainName() string {
+	if x != nil {
+		return x.ChainName
+	}
+	return ""
+}
+
+func (x *XrayKillSwitchRequest) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *XrayKillSwitchRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type XrayUDPPolicyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ChainName     string                 `protobuf:"bytes,1,opt,name=chain_name,json=chainName,proto3" json:"chain_name,omitempty"`
+	Policy        string                 `protobuf:"bytes,2,opt,name=policy,proto3" json:"policy,omitempty"`
+	DryRun        bool                   `protobuf:"varint,3,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *XrayUDPPolicyRequest) Reset() {
+	*x = XrayUDPPolicyRequest{}
+	mi := &file_vpner_proto_msgTypes[43]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *XrayUDPPolicyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*XrayUDPPolicyRequest) ProtoMessage() {}
+
+func (x *XrayUDPPolicyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[43]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use XrayUDPPolicyRequest.ProtoReflect.Descriptor instead.
+func (*XrayUDPPolicyRequest) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *XrayUDPPolicyRequest) GetChainName() string {
+	if x != nil {
+		return x.ChainName
+	}
+	return ""
+}
+
+func (x *XrayUDPPolicyRequest) GetPolicy() string {
+	if x != nil {
+		return x.Policy
+	}
+	return ""
+}
+
+func (x *XrayUDPPolicyRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type HookRestoreRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DryRun        bool                   `protobuf:"varint,1,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HookRestoreRequest) Reset() {
+	*x = HookRestoreRequest{}
+	mi := &file_vpner_proto_msgTypes[44]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HookRestoreRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HookRestoreRequest) ProtoMessage() {}
+
+func (x *HookRestoreRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vpner_proto_msgTypes[44]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HookRestoreRequest.ProtoReflect.Descriptor instead.
+func (*HookRestoreRequest) Descriptor() ([]byte, []int) {
+	return file_vpner_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *HookRestoreRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
 type XrayListResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	List          []*XrayInfo            `protobuf:"bytes,1,rep,name=list,proto3" json:"list,omitempty"`
@@ -1044,7 +2976,7 @@ type XrayListResponse struct {
 
 func (x *XrayListResponse) Reset() {
 	*x = XrayListResponse{}
-	mi := &file_vpner_proto_msgTypes[18]
+	mi := &file_vpner_proto_msgTypes[45]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1056,7 +2988,7 @@ func (x *XrayListResponse) String() string {
 func (*XrayListResponse) ProtoMessage() {}
 
 func (x *XrayListResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_vpner_proto_msgTypes[18]
+	mi := &file_vpner_proto_msgTypes[45]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1069,7 +3001,7 @@ func (x *XrayListResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use XrayListResponse.ProtoReflect.Descriptor instead.
 func (*XrayListResponse) Descriptor() ([]byte, []int) {
-	return file_vpner_proto_rawDescGZIP(), []int{18}
+	return file_vpner_proto_rawDescGZIP(), []int{45}
 }
 
 func (x *XrayListResponse) GetList() []*XrayInfo {
@@ -1083,7 +3015,7 @@ var File_vpner_proto protoreflect.FileDescriptor
 
 const file_vpner_proto_rawDesc = "" +
 	"\n" +
-	"\vvpner.proto\x12\x05vpner\x1a\x10structures.proto\"\xc7\x02\n" +
+	"\vvpner.proto\x12\x05vpner\x1a\x10structures.proto\"\x89\x04\n" +
 	"\x0eStatusResponse\x12\x18\n" +
 	"\aversion\x18\x01 \x01(\tR\aversion\x12%\n" +
 	"\x0euptime_seconds\x18\x02 \x01(\x03R\ruptimeSeconds\x12\x1f\n" +
@@ -1094,7 +3026,14 @@ const file_vpner_proto_rawDesc = "" +
 	"\x12unblock_rule_count\x18\x06 \x01(\x05R\x10unblockRuleCount\x12*\n" +
 	"\x06chains\x18\a \x03(\v2\x12.vpner.ChainStatusR\x06chains\x127\n" +
 	"\vdoh_servers\x18\b \x03(\v2\x16.vpner.DohServerStatusR\n" +
-	"dohServers\"\x95\x02\n" +
+	"dohServers\x12)\n" +
+	"\x10firewall_backend\x18\t \x01(\tR\x0ffirewallBackend\x12)\n" +
+	"\x06ipsets\x18\n" +
+	" \x03(\v2\x11.vpner.IPSetUsageR\x06ipsets\x126\n" +
+	"\n" +
+	"blocklists\x18\v \x03(\v2\x16.vpner.BlocklistStatusR\n" +
+	"blocklists\x122\n" +
+	"\tdns_cache\x18\f \x01(\v2\x15.vpner.DnsCacheStatusR\bdnsCache\"\xb1\x03\n" +
 	"\vChainStatus\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
 	"\x04type\x18\x02 \x01(\tR\x04type\x12\x12\n" +
@@ -1106,29 +3045,144 @@ const file_vpner_proto_rawDesc = "" +
 	"\brestarts\x18\b \x01(\x05R\brestarts\x12%\n" +
 	"\x0euptime_seconds\x18\t \x01(\x03R\ruptimeSeconds\x12\x1b\n" +
 	"\tlast_exit\x18\n" +
-	" \x01(\tR\blastExit\"\x8b\x01\n" +
+	" \x01(\tR\blastExit\x12\x1f\n" +
+	"\vkill_switch\x18\v \x01(\tR\n" +
+	"killSwitch\x12.\n" +
+	"\x13kill_switch_engaged\x18\f \x01(\bR\x11killSwitchEngaged\x12\x1d\n" +
+	"\n" +
+	"udp_policy\x18\r \x01(\tR\tudpPolicy\x12*\n" +
+	"\x11udp_policy_active\x18\x0e \x01(\tR\x0fudpPolicyActive\"\xa7\x01\n" +
 	"\x0fDohServerStatus\x12\x16\n" +
 	"\x06server\x18\x01 \x01(\tR\x06server\x12\x1c\n" +
 	"\tsuccesses\x18\x02 \x01(\x04R\tsuccesses\x12\x1a\n" +
 	"\bfailures\x18\x03 \x01(\x04R\bfailures\x12&\n" +
-	"\x0flast_latency_ms\x18\x04 \x01(\x03R\rlastLatencyMs\"\a\n" +
-	"\x05Empty\"m\n" +
+	"\x0flast_latency_ms\x18\x04 \x01(\x03R\rlastLatencyMs\x12\x1a\n" +
+	"\bprotocol\x18\x05 \x01(\tR\bprotocol\"q\n" +
+	"\n" +
+	"IPSetUsage\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05chain\x18\x02 \x01(\tR\x05chain\x12\x18\n" +
+	"\aentries\x18\x03 \x01(\x05R\aentries\x12\x1f\n" +
+	"\vmax_entries\x18\x04 \x01(\x05R\n" +
+	"maxEntries\"\x88\x01\n" +
+	"\x0eDnsCacheStatus\x12\x18\n" +
+	"\aentries\x18\x01 \x01(\x05R\aentries\x12\x12\n" +
+	"\x04hits\x18\x02 \x01(\x04R\x04hits\x12\x16\n" +
+	"\x06misses\x18\x03 \x01(\x04R\x06misses\x12\x14\n" +
+	"\x05stale\x18\x04 \x01(\x04R\x05stale\x12\x1a\n" +
+	"\bprefetch\x18\x05 \x01(\x04R\bprefetch\"\xa0\x01\n" +
+	"\x0fBlocklistStatus\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06source\x18\x02 \x01(\tR\x06source\x12\x14\n" +
+	"\x05rules\x18\x03 \x01(\x05R\x05rules\x12\x12\n" +
+	"\x04hits\x18\x04 \x01(\x04R\x04hits\x12!\n" +
+	"\fupdated_unix\x18\x05 \x01(\x03R\vupdatedUnix\x12\x14\n" +
+	"\x05error\x18\x06 \x01(\tR\x05error\"\a\n" +
+	"\x05Empty\"\x8e\x01\n" +
 	"\x0fGenericResponse\x12*\n" +
 	"\asuccess\x18\x01 \x01(\v2\x0e.vpner.SuccessH\x00R\asuccess\x12$\n" +
-	"\x05error\x18\x02 \x01(\v2\f.vpner.ErrorH\x00R\x05errorB\b\n" +
-	"\x06result\"#\n" +
+	"\x05error\x18\x02 \x01(\v2\f.vpner.ErrorH\x00R\x05error\x12\x1f\n" +
+	"\x04plan\x18\x03 \x01(\v2\v.vpner.PlanR\x04planB\b\n" +
+	"\x06result\"4\n" +
+	"\x13RoutingStateRequest\x12\x1d\n" +
+	"\n" +
+	"chain_name\x18\x01 \x01(\tR\tchainName\"H\n" +
+	"\x14RoutingStateResponse\x120\n" +
+	"\x06chains\x18\x01 \x03(\v2\x18.vpner.RoutingChainStateR\x06chains\"\xe5\x03\n" +
+	"\x11RoutingChainState\x12\x14\n" +
+	"\x05chain\x18\x01 \x01(\tR\x05chain\x12\x19\n" +
+	"\bvpn_type\x18\x02 \x01(\tR\avpnType\x12\x16\n" +
+	"\x06family\x18\x03 \x01(\tR\x06family\x12\x14\n" +
+	"\x05table\x18\x04 \x01(\tR\x05table\x12\x1d\n" +
+	"\n" +
+	"chain_name\x18\x05 \x01(\tR\tchainName\x12#\n" +
+	"\rchain_present\x18\x06 \x01(\bR\fchainPresent\x12(\n" +
+	"\x05jumps\x18\a \x03(\v2\x12.vpner.RoutingJumpR\x05jumps\x12\x1d\n" +
+	"\n" +
+	"ipset_name\x18\b \x01(\tR\tipsetName\x12#\n" +
+	"\ripset_present\x18\t \x01(\bR\fipsetPresent\x12#\n" +
+	"\ripset_entries\x18\n" +
+	" \x01(\x05R\fipsetEntries\x12\x12\n" +
+	"\x04mark\x18\v \x01(\tR\x04mark\x12\x1f\n" +
+	"\vroute_table\x18\f \x01(\x05R\n" +
+	"routeTable\x12&\n" +
+	"\x0fip_rule_present\x18\r \x01(\bR\ripRulePresent\x12\x16\n" +
+	"\x06tproxy\x18\x0e \x01(\bR\x06tproxy\x12%\n" +
+	"\x0etarget_present\x18\x0f \x01(\bR\rtargetPresent\";\n" +
+	"\vRoutingJump\x12\x12\n" +
+	"\x04rule\x18\x01 \x01(\tR\x04rule\x12\x18\n" +
+	"\apresent\x18\x02 \x01(\bR\apresent\"&\n" +
+	"\fTraceRequest\x12\x16\n" +
+	"\x06target\x18\x01 \x01(\tR\x06target\"\xea\x02\n" +
+	"\rTraceResponse\x12\x16\n" +
+	"\x06target\x18\x01 \x01(\tR\x06target\x12\x18\n" +
+	"\amatched\x18\x02 \x01(\bR\amatched\x12\x19\n" +
+	"\bvpn_type\x18\x03 \x01(\tR\avpnType\x12\x14\n" +
+	"\x05chain\x18\x04 \x01(\tR\x05chain\x12\x18\n" +
+	"\apattern\x18\x05 \x01(\tR\apattern\x12!\n" +
+	"\x03dns\x18\x06 \x03(\v2\x0f.vpner.TraceDnsR\x03dns\x12.\n" +
+	"\aentries\x18\a \x03(\v2\x14.vpner.TraceSetEntryR\aentries\x12#\n" +
+	"\rchain_running\x18\b \x01(\bR\fchainRunning\x122\n" +
+	"\arouting\x18\t \x03(\v2\x18.vpner.RoutingChainStateR\arouting\x12\x16\n" +
+	"\x06routed\x18\n" +
+	" \x01(\bR\x06routed\x12\x18\n" +
+	"\averdict\x18\v \x01(\tR\averdict\"\x96\x01\n" +
+	"\bTraceDns\x12\x14\n" +
+	"\x05qtype\x18\x01 \x01(\tR\x05qtype\x12\x16\n" +
+	"\x06source\x18\x02 \x01(\tR\x06source\x12\x16\n" +
+	"\x06server\x18\x03 \x01(\tR\x06server\x12\x14\n" +
+	"\x05rcode\x18\x04 \x01(\tR\x05rcode\x12\x18\n" +
+	"\aanswers\x18\x05 \x03(\tR\aanswers\x12\x14\n" +
+	"\x05error\x18\x06 \x01(\tR\x05error\"Q\n" +
+	"\rTraceSetEntry\x12\x10\n" +
+	"\x03set\x18\x01 \x01(\tR\x03set\x12\x14\n" +
+	"\x05entry\x18\x02 \x01(\tR\x05entry\x12\x18\n" +
+	"\acomment\x18\x03 \x01(\tR\acomment\"3\n" +
+	"\x12RoutingPlanRequest\x12\x1d\n" +
+	"\n" +
+	"chain_name\x18\x01 \x01(\tR\tchainName\"R\n" +
+	"\x04Plan\x12%\n" +
+	"\x05steps\x18\x01 \x03(\v2\x0f.vpner.PlanStepR\x05steps\x12#\n" +
+	"\x04diff\x18\x02 \x03(\v2\x0f.vpner.PlanDiffR\x04diff\"^\n" +
+	"\bPlanStep\x12\x12\n" +
+	"\x04tool\x18\x01 \x01(\tR\x04tool\x12\x14\n" +
+	"\x05table\x18\x02 \x01(\tR\x05table\x12\x12\n" +
+	"\x04args\x18\x03 \x03(\tR\x04args\x12\x14\n" +
+	"\x05lines\x18\x04 \x03(\tR\x05lines\"d\n" +
+	"\bPlanDiff\x12\x12\n" +
+	"\x04tool\x18\x01 \x01(\tR\x04tool\x12\x14\n" +
+	"\x05table\x18\x02 \x01(\tR\x05table\x12\x14\n" +
+	"\x05added\x18\x03 \x03(\tR\x05added\x12\x18\n" +
+	"\aremoved\x18\x04 \x03(\tR\aremoved\"#\n" +
 	"\aSuccess\x12\x18\n" +
 	"\amessage\x18\x01 \x01(\tR\amessage\"!\n" +
 	"\x05Error\x12\x18\n" +
 	"\amessage\x18\x01 \x01(\tR\amessage\"D\n" +
 	"\x13UnblockListResponse\x12-\n" +
-	"\x05rules\x18\x01 \x03(\v2\x17.structures.UnblockInfoR\x05rules\"J\n" +
+	"\x05rules\x18\x01 \x03(\v2\x17.structures.UnblockInfoR\x05rules\"c\n" +
 	"\x11UnblockAddRequest\x12\x16\n" +
 	"\x06domain\x18\x01 \x01(\tR\x06domain\x12\x1d\n" +
 	"\n" +
-	"chain_name\x18\x02 \x01(\tR\tchainName\"+\n" +
+	"chain_name\x18\x02 \x01(\tR\tchainName\x12\x17\n" +
+	"\adry_run\x18\x03 \x01(\bR\x06dryRun\"D\n" +
 	"\x11UnblockDelRequest\x12\x16\n" +
-	"\x06domain\x18\x01 \x01(\tR\x06domain\"R\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\x12\x17\n" +
+	"\adry_run\x18\x02 \x01(\bR\x06dryRun\"\x88\x01\n" +
+	"\x17ClientGroupListResponse\x123\n" +
+	"\x06groups\x18\x01 \x03(\v2\x1b.structures.ClientGroupInfoR\x06groups\x128\n" +
+	"\bpolicies\x18\x02 \x03(\v2\x1c.structures.ClientPolicyInfoR\bpolicies\"B\n" +
+	"\x12ClientGroupRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\amembers\x18\x02 \x03(\tR\amembers\"`\n" +
+	"\x13ClientPolicyRequest\x12\x1d\n" +
+	"\n" +
+	"chain_name\x18\x01 \x01(\tR\tchainName\x12\x12\n" +
+	"\x04only\x18\x02 \x03(\tR\x04only\x12\x16\n" +
+	"\x06except\x18\x03 \x03(\tR\x06except\"L\n" +
+	"\x17ClientFullTunnelRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1d\n" +
+	"\n" +
+	"chain_name\x18\x02 \x01(\tR\tchainName\"R\n" +
 	"\x15InterfaceListResponse\x129\n" +
 	"\n" +
 	"interfaces\x18\x01 \x03(\v2\x19.structures.InterfaceInfoR\n" +
@@ -1136,7 +3190,40 @@ const file_vpner_proto_rawDesc = "" +
 	"\x16InterfaceActionRequest\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\";\n" +
 	"\rManageRequest\x12*\n" +
-	"\x03act\x18\x01 \x01(\x0e2\x18.structures.ManageActionR\x03act\"B\n" +
+	"\x03act\x18\x01 \x01(\x0e2\x18.structures.ManageActionR\x03act\"s\n" +
+	"\tDnsRecord\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x14\n" +
+	"\x05value\x18\x03 \x01(\tR\x05value\x12\x10\n" +
+	"\x03ttl\x18\x04 \x01(\x05R\x03ttl\x12\x16\n" +
+	"\x06source\x18\x05 \x01(\tR\x06source\"C\n" +
+	"\x15DnsRecordListResponse\x12*\n" +
+	"\arecords\x18\x01 \x03(\v2\x10.vpner.DnsRecordR\arecords\"\x9c\x01\n" +
+	"\x12DnsQueryLogRequest\x12\x16\n" +
+	"\x06client\x18\x01 \x01(\tR\x06client\x12\x16\n" +
+	"\x06domain\x18\x02 \x01(\tR\x06domain\x12\x12\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\x12\x14\n" +
+	"\x05rcode\x18\x04 \x01(\tR\x05rcode\x12\x16\n" +
+	"\x06source\x18\x05 \x01(\tR\x06source\x12\x14\n" +
+	"\x05limit\x18\x06 \x01(\x05R\x05limit\"\xb9\x02\n" +
+	"\x10DnsQueryLogEntry\x12 \n" +
+	"\ftime_unix_ms\x18\x01 \x01(\x03R\n" +
+	"timeUnixMs\x12\x16\n" +
+	"\x06client\x18\x02 \x01(\tR\x06client\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x04 \x01(\tR\x04type\x12\x14\n" +
+	"\x05rcode\x18\x05 \x01(\tR\x05rcode\x12\x18\n" +
+	"\aanswers\x18\x06 \x03(\tR\aanswers\x12\x16\n" +
+	"\x06source\x18\a \x01(\tR\x06source\x12\x1a\n" +
+	"\bupstream\x18\b \x01(\tR\bupstream\x12\x16\n" +
+	"\x06cached\x18\t \x01(\bR\x06cached\x12\x12\n" +
+	"\x04rule\x18\n" +
+	" \x01(\tR\x04rule\x12\x14\n" +
+	"\x05chain\x18\v \x01(\tR\x05chain\x12\x1d\n" +
+	"\n" +
+	"latency_us\x18\f \x01(\x03R\tlatencyUs\"H\n" +
+	"\x13DnsQueryLogResponse\x121\n" +
+	"\aentries\x18\x01 \x03(\v2\x17.vpner.DnsQueryLogEntryR\aentries\"B\n" +
 	"\x11XrayCreateRequest\x12\x12\n" +
 	"\x04link\x18\x01 \x01(\tR\x04link\x12\x19\n" +
 	"\bauto_run\x18\x02 \x01(\bR\aautoRun\"F\n" +
@@ -1146,28 +3233,51 @@ const file_vpner_proto_rawDesc = "" +
 	"\x04link\x18\x02 \x01(\tR\x04link\",\n" +
 	"\vXrayRequest\x12\x1d\n" +
 	"\n" +
-	"chain_name\x18\x01 \x01(\tR\tchainName\"^\n" +
+	"chain_name\x18\x01 \x01(\tR\tchainName\"w\n" +
 	"\x11XrayManageRequest\x12\x1d\n" +
 	"\n" +
 	"chain_name\x18\x01 \x01(\tR\tchainName\x12*\n" +
-	"\x03act\x18\x02 \x01(\x0e2\x18.structures.ManageActionR\x03act\"N\n" +
+	"\x03act\x18\x02 \x01(\x0e2\x18.structures.ManageActionR\x03act\x12\x17\n" +
+	"\adry_run\x18\x03 \x01(\bR\x06dryRun\"N\n" +
 	"\x12XrayAutoRunRequest\x12\x1d\n" +
 	"\n" +
 	"chain_name\x18\x01 \x01(\tR\tchainName\x12\x19\n" +
-	"\bauto_run\x18\x02 \x01(\bR\aautoRun\"<\n" +
+	"\bauto_run\x18\x02 \x01(\bR\aautoRun\"c\n" +
+	"\x15XrayKillSwitchRequest\x12\x1d\n" +
+	"\n" +
+	"chain_name\x18\x01 \x01(\tR\tchainName\x12\x12\n" +
+	"\x04mode\x18\x02 \x01(\tR\x04mode\x12\x17\n" +
+	"\adry_run\x18\x03 \x01(\bR\x06dryRun\"f\n" +
+	"\x14XrayUDPPolicyRequest\x12\x1d\n" +
+	"\n" +
+	"chain_name\x18\x01 \x01(\tR\tchainName\x12\x16\n" +
+	"\x06policy\x18\x02 \x01(\tR\x06policy\x12\x17\n" +
+	"\adry_run\x18\x03 \x01(\bR\x06dryRun\"-\n" +
+	"\x12HookRestoreRequest\x12\x17\n" +
+	"\adry_run\x18\x01 \x01(\bR\x06dryRun\"<\n" +
 	"\x10XrayListResponse\x12(\n" +
-	"\x04list\x18\x01 \x03(\v2\x14.structures.XrayInfoR\x04list2\x98\b\n" +
+	"\x04list\x18\x01 \x03(\v2\x14.structures.XrayInfoR\x04list2\x9d\x10\n" +
 	"\fVpnerManager\x127\n" +
 	"\vUnblockList\x12\f.vpner.Empty\x1a\x1a.vpner.UnblockListResponse\x12>\n" +
 	"\n" +
 	"UnblockAdd\x12\x18.vpner.UnblockAddRequest\x1a\x16.vpner.GenericResponse\x12>\n" +
 	"\n" +
-	"UnblockDel\x12\x18.vpner.UnblockDelRequest\x1a\x16.vpner.GenericResponse\x12;\n" +
+	"UnblockDel\x12\x18.vpner.UnblockDelRequest\x1a\x16.vpner.GenericResponse\x12?\n" +
+	"\x0fClientGroupList\x12\f.vpner.Empty\x1a\x1e.vpner.ClientGroupListResponse\x12C\n" +
+	"\x0eClientGroupAdd\x12\x19.vpner.ClientGroupRequest\x1a\x16.vpner.GenericResponse\x12F\n" +
+	"\x11ClientGroupRemove\x12\x19.vpner.ClientGroupRequest\x1a\x16.vpner.GenericResponse\x12J\n" +
+	"\x14ClientGroupSetPolicy\x12\x1a.vpner.ClientPolicyRequest\x1a\x16.vpner.GenericResponse\x12R\n" +
+	"\x18ClientGroupSetFullTunnel\x12\x1e.vpner.ClientFullTunnelRequest\x1a\x16.vpner.GenericResponse\x12;\n" +
 	"\rInterfaceList\x12\f.vpner.Empty\x1a\x1c.vpner.InterfaceListResponse\x12;\n" +
 	"\rInterfaceScan\x12\f.vpner.Empty\x1a\x1c.vpner.InterfaceListResponse\x12E\n" +
 	"\fInterfaceAdd\x12\x1d.vpner.InterfaceActionRequest\x1a\x16.vpner.GenericResponse\x12E\n" +
 	"\fInterfaceDel\x12\x1d.vpner.InterfaceActionRequest\x1a\x16.vpner.GenericResponse\x129\n" +
-	"\tDnsManage\x12\x14.vpner.ManageRequest\x1a\x16.vpner.GenericResponse\x12>\n" +
+	"\tDnsManage\x12\x14.vpner.ManageRequest\x1a\x16.vpner.GenericResponse\x12;\n" +
+	"\rDnsRecordList\x12\f.vpner.Empty\x1a\x1c.vpner.DnsRecordListResponse\x128\n" +
+	"\fDnsRecordAdd\x12\x10.vpner.DnsRecord\x1a\x16.vpner.GenericResponse\x128\n" +
+	"\fDnsRecordDel\x12\x10.vpner.DnsRecord\x1a\x16.vpner.GenericResponse\x12D\n" +
+	"\vDnsQueryLog\x12\x19.vpner.DnsQueryLogRequest\x1a\x1a.vpner.DnsQueryLogResponse\x12I\n" +
+	"\x11DnsQueryLogFollow\x12\x19.vpner.DnsQueryLogRequest\x1a\x17.vpner.DnsQueryLogEntry0\x01\x12>\n" +
 	"\n" +
 	"XrayCreate\x12\x18.vpner.XrayCreateRequest\x1a\x16.vpner.GenericResponse\x12>\n" +
 	"\n" +
@@ -1178,8 +3288,13 @@ const file_vpner_proto_rawDesc = "" +
 	"\n" +
 	"XrayManage\x12\x18.vpner.XrayManageRequest\x1a\x16.vpner.GenericResponse\x126\n" +
 	"\bXrayTest\x12\x12.vpner.XrayRequest\x1a\x16.vpner.GenericResponse\x12C\n" +
-	"\x0eXraySetAutorun\x12\x19.vpner.XrayAutoRunRequest\x1a\x16.vpner.GenericResponse\x123\n" +
-	"\vHookRestore\x12\f.vpner.Empty\x1a\x16.vpner.GenericResponse\x12-\n" +
+	"\x0eXraySetAutorun\x12\x19.vpner.XrayAutoRunRequest\x1a\x16.vpner.GenericResponse\x12I\n" +
+	"\x11XraySetKillSwitch\x12\x1c.vpner.XrayKillSwitchRequest\x1a\x16.vpner.GenericResponse\x12G\n" +
+	"\x10XraySetUDPPolicy\x12\x1b.vpner.XrayUDPPolicyRequest\x1a\x16.vpner.GenericResponse\x12@\n" +
+	"\vHookRestore\x12\x19.vpner.HookRestoreRequest\x1a\x16.vpner.GenericResponse\x125\n" +
+	"\vRoutingPlan\x12\x19.vpner.RoutingPlanRequest\x1a\v.vpner.Plan\x12G\n" +
+	"\fRoutingState\x12\x1a.vpner.RoutingStateRequest\x1a\x1b.vpner.RoutingStateResponse\x122\n" +
+	"\x05Trace\x12\x13.vpner.TraceRequest\x1a\x14.vpner.TraceResponse\x12-\n" +
 	"\x06Status\x12\f.vpner.Empty\x1a\x15.vpner.StatusResponseB,Z*github.com/ApostolDmitry/vpner/proto;protob\x06proto3"
 
 var (
@@ -1194,81 +3309,155 @@ func file_vpner_proto_rawDescGZIP() []byte {
 	return file_vpner_proto_rawDescData
 }
 
-var file_vpner_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
+var file_vpner_proto_msgTypes = make([]protoimpl.MessageInfo, 46)
 var file_vpner_proto_goTypes = []any{
-	(*StatusResponse)(nil),         // 0: vpner.StatusResponse
-	(*ChainStatus)(nil),            // 1: vpner.ChainStatus
-	(*DohServerStatus)(nil),        // 2: vpner.DohServerStatus
-	(*Empty)(nil),                  // 3: vpner.Empty
-	(*GenericResponse)(nil),        // 4: vpner.GenericResponse
-	(*Success)(nil),                // 5: vpner.Success
-	(*Error)(nil),                  // 6: vpner.Error
-	(*UnblockListResponse)(nil),    // 7: vpner.UnblockListResponse
-	(*UnblockAddRequest)(nil),      // 8: vpner.UnblockAddRequest
-	(*UnblockDelRequest)(nil),      // 9: vpner.UnblockDelRequest
-	(*InterfaceListResponse)(nil),  // 10: vpner.InterfaceListResponse
-	(*InterfaceActionRequest)(nil), // 11: vpner.InterfaceActionRequest
-	(*ManageRequest)(nil),          // 12: vpner.ManageRequest
-	(*XrayCreateRequest)(nil),      // 13: vpner.XrayCreateRequest
-	(*XrayUpdateRequest)(nil),      // 14: vpner.XrayUpdateRequest
-	(*XrayRequest)(nil),            // 15: vpner.XrayRequest
-	(*XrayManageRequest)(nil),      // 16: vpner.XrayManageRequest
-	(*XrayAutoRunRequest)(nil),     // 17: vpner.XrayAutoRunRequest
-	(*XrayListResponse)(nil),       // 18: vpner.XrayListResponse
-	(*UnblockInfo)(nil),            // 19: structures.UnblockInfo
-	(*InterfaceInfo)(nil),          // 20: structures.InterfaceInfo
-	(ManageAction)(0),              // 21: structures.ManageAction
-	(*XrayInfo)(nil),               // 22: structures.XrayInfo
+	(*StatusResponse)(nil),          // 0: vpner.StatusResponse
+	(*ChainStatus)(nil),             // 1: vpner.ChainStatus
+	(*DohServerStatus)(nil),         // 2: vpner.DohServerStatus
+	(*IPSetUsage)(nil),              // 3: vpner.IPSetUsage
+	(*DnsCacheStatus)(nil),          // 4: vpner.DnsCacheStatus
+	(*BlocklistStatus)(nil),         // 5: vpner.BlocklistStatus
+	(*Empty)(nil),                   // 6: vpner.Empty
+	(*GenericResponse)(nil),         // 7: vpner.GenericResponse
+	(*RoutingStateRequest)(nil),     // 8: vpner.RoutingStateRequest
+	(*RoutingStateResponse)(nil),    // 9: vpner.RoutingStateResponse
+	(*RoutingChainState)(nil),       // 10: vpner.RoutingChainState
+	(*RoutingJump)(nil),             // 11: vpner.RoutingJump
+	(*TraceRequest)(nil),            // 12: vpner.TraceRequest
+	(*TraceResponse)(nil),           // 13: vpner.TraceResponse
+	(*TraceDns)(nil),                // 14: vpner.TraceDns
+	(*TraceSetEntry)(nil),           // 15: vpner.TraceSetEntry
+	(*RoutingPlanRequest)(nil),      // 16: vpner.RoutingPlanRequest
+	(*Plan)(nil),                    // 17: vpner.Plan
+	(*PlanStep)(nil),                // 18: vpner.PlanStep
+	(*PlanDiff)(nil),                // 19: vpner.PlanDiff
+	(*Success)(nil),                 // 20: vpner.Success
+	(*Error)(nil),                   // 21: vpner.Error
+	(*UnblockListResponse)(nil),     // 22: vpner.UnblockListResponse
+	(*UnblockAddRequest)(nil),       // 23: vpner.UnblockAddRequest
+	(*UnblockDelRequest)(nil),       // 24: vpner.UnblockDelRequest
+	(*ClientGroupListResponse)(nil), // 25: vpner.ClientGroupListResponse
+	(*ClientGroupRequest)(nil),      // 26: vpner.ClientGroupRequest
+	(*ClientPolicyRequest)(nil),     // 27: vpner.ClientPolicyRequest
+	(*ClientFullTunnelRequest)(nil), // 28: vpner.ClientFullTunnelRequest
+	(*InterfaceListResponse)(nil),   // 29: vpner.InterfaceListResponse
+	(*InterfaceActionRequest)(nil),  // 30: vpner.InterfaceActionRequest
+	(*ManageRequest)(nil),           // 31: vpner.ManageRequest
+	(*DnsRecord)(nil),               // 32: vpner.DnsRecord
+	(*DnsRecordListResponse)(nil),   // 33: vpner.DnsRecordListResponse
+	(*DnsQueryLogRequest)(nil),      // 34: vpner.DnsQueryLogRequest
+	(*DnsQueryLogEntry)(nil),        // 35: vpner.DnsQueryLogEntry
+	(*DnsQueryLogResponse)(nil),     // 36: vpner.DnsQueryLogResponse
+	(*XrayCreateRequest)(nil),       // 37: vpner.XrayCreateRequest
+	(*XrayUpdateRequest)(nil),       // 38: vpner.XrayUpdateRequest
+	(*XrayRequest)(nil),             // 39: vpner.XrayRequest
+	(*XrayManageRequest)(nil),       // 40: vpner.XrayManageRequest
+	(*XrayAutoRunRequest)(nil),      // 41: vpner.XrayAutoRunRequest
+	(*XrayKillSwitchRequest)(nil),   // 42: vpner.XrayKillSwitchRequest
+	(*XrayUDPPolicyRequest)(nil),    // 43: vpner.XrayUDPPolicyRequest
+	(*HookRestoreRequest)(nil),      // 44: vpner.HookRestoreRequest
+	(*XrayListResponse)(nil),        // 45: vpner.XrayListResponse
+	(*UnblockInfo)(nil),             // 46: structures.UnblockInfo
+	(*ClientGroupInfo)(nil),         // 47: structures.ClientGroupInfo
+	(*ClientPolicyInfo)(nil),        // 48: structures.ClientPolicyInfo
+	(*InterfaceInfo)(nil),           // 49: structures.InterfaceInfo
+	(ManageAction)(0),               // 50: structures.ManageAction
+	(*XrayInfo)(nil),                // 51: structures.XrayInfo
 }
 var file_vpner_proto_depIdxs = []int32{
 	1,  // 0: vpner.StatusResponse.chains:type_name -> vpner.ChainStatus
 	2,  // 1: vpner.StatusResponse.doh_servers:type_name -> vpner.DohServerStatus
-	5,  // 2: vpner.GenericResponse.success:type_name -> vpner.Success
-	6,  // 3: vpner.GenericResponse.error:type_name -> vpner.Error
-	19, // 4: vpner.UnblockListResponse.rules:type_name -> structures.UnblockInfo
-	20, // 5: vpner.InterfaceListResponse.interfaces:type_name -> structures.InterfaceInfo
-	21, // 6: vpner.ManageRequest.act:type_name -> structures.ManageAction
-	21, // 7: vpner.XrayManageRequest.act:type_name -> structures.ManageAction
-	22, // 8: vpner.XrayListResponse.list:type_name -> structures.XrayInfo
-	3,  // 9: vpner.VpnerManager.UnblockList:input_type -> vpner.Empty
-	8,  // 10: vpner.VpnerManager.UnblockAdd:input_type -> vpner.UnblockAddRequest
-	9,  // 11: vpner.VpnerManager.UnblockDel:input_type -> vpner.UnblockDelRequest
-	3,  // 12: vpner.VpnerManager.InterfaceList:input_type -> vpner.Empty
-	3,  // 13: vpner.VpnerManager.InterfaceScan:input_type -> vpner.Empty
-	11, // 14: vpner.VpnerManager.InterfaceAdd:input_type -> vpner.InterfaceActionRequest
-	11, // 15: vpner.VpnerManager.InterfaceDel:input_type -> vpner.InterfaceActionRequest
-	12, // 16: vpner.VpnerManager.DnsManage:input_type -> vpner.ManageRequest
-	13, // 17: vpner.VpnerManager.XrayCreate:input_type -> vpner.XrayCreateRequest
-	14, // 18: vpner.VpnerManager.XrayUpdate:input_type -> vpner.XrayUpdateRequest
-	15, // 19: vpner.VpnerManager.XrayDelete:input_type -> vpner.XrayRequest
-	3,  // 20: vpner.VpnerManager.XrayList:input_type -> vpner.Empty
-	16, // 21: vpner.VpnerManager.XrayManage:input_type -> vpner.XrayManageRequest
-	15, // 22: vpner.VpnerManager.XrayTest:input_type -> vpner.XrayRequest
-	17, // 23: vpner.VpnerManager.XraySetAutorun:input_type -> vpner.XrayAutoRunRequest
-	3,  // 24: vpner.VpnerManager.HookRestore:input_type -> vpner.Empty
-	3,  // 25: vpner.VpnerManager.Status:input_type -> vpner.Empty
-	7,  // 26: vpner.VpnerManager.UnblockList:output_type -> vpner.UnblockListResponse
-	4,  // 27: vpner.VpnerManager.UnblockAdd:output_type -> vpner.GenericResponse
-	4,  // 28: vpner.VpnerManager.UnblockDel:output_type -> vpner.GenericResponse
-	10, // 29: vpner.VpnerManager.InterfaceList:output_type -> vpner.InterfaceListResponse
-	10, // 30: vpner.VpnerManager.InterfaceScan:output_type -> vpner.InterfaceListResponse
-	4,  // 31: vpner.VpnerManager.InterfaceAdd:output_type -> vpner.GenericResponse
-	4,  // 32: vpner.VpnerManager.InterfaceDel:output_type -> vpner.GenericResponse
-	4,  // 33: vpner.VpnerManager.DnsManage:output_type -> vpner.GenericResponse
-	4,  // 34: vpner.VpnerManager.XrayCreate:output_type -> vpner.GenericResponse
-	4,  // 35: vpner.VpnerManager.XrayUpdate:output_type -> vpner.GenericResponse
-	4,  // 36: vpner.VpnerManager.XrayDelete:output_type -> vpner.GenericResponse
-	18, // 37: vpner.VpnerManager.XrayList:output_type -> vpner.XrayListResponse
-	4,  // 38: vpner.VpnerManager.XrayManage:output_type -> vpner.GenericResponse
-	4,  // 39: vpner.VpnerManager.XrayTest:output_type -> vpner.GenericResponse
-	4,  // 40: vpner.VpnerManager.XraySetAutorun:output_type -> vpner.GenericResponse
-	4,  // 41: vpner.VpnerManager.HookRestore:output_type -> vpner.GenericResponse
-	0,  // 42: vpner.VpnerManager.Status:output_type -> vpner.StatusResponse
-	26, // [26:43] is the sub-list for method output_type
-	9,  // [9:26] is the sub-list for method input_type
-	9,  // [9:9] is the sub-list for extension type_name
-	9,  // [9:9] is the sub-list for extension extendee
-	0,  // [0:9] is the sub-list for field type_name
+	3,  // 2: vpner.StatusResponse.ipsets:type_name -> vpner.IPSetUsage
+	5,  // 3: vpner.StatusResponse.blocklists:type_name -> vpner.BlocklistStatus
+	4,  // 4: vpner.StatusResponse.dns_cache:type_name -> vpner.DnsCacheStatus
+	20, // 5: vpner.GenericResponse.success:type_name -> vpner.Success
+	21, // 6: vpner.GenericResponse.error:type_name -> vpner.Error
+	17, // 7: vpner.GenericResponse.plan:type_name -> vpner.Plan
+	10, // 8: vpner.RoutingStateResponse.chains:type_name -> vpner.RoutingChainState
+	11, // 9: vpner.RoutingChainState.jumps:type_name -> vpner.RoutingJump
+	14, // 10: vpner.TraceResponse.dns:type_name -> vpner.TraceDns
+	15, // 11: vpner.TraceResponse.entries:type_name -> vpner.TraceSetEntry
+	10, // 12: vpner.TraceResponse.routing:type_name -> vpner.RoutingChainState
+	18, // 13: vpner.Plan.steps:type_name -> vpner.PlanStep
+	19, // 14: vpner.Plan.diff:type_name -> vpner.PlanDiff
+	46, // 15: vpner.UnblockListResponse.rules:type_name -> structures.UnblockInfo
+	47, // 16: vpner.ClientGroupListResponse.groups:type_name -> structures.ClientGroupInfo
+	48, // 17: vpner.ClientGroupListResponse.policies:type_name -> structures.ClientPolicyInfo
+	49, // 18: vpner.InterfaceListResponse.interfaces:type_name -> structures.InterfaceInfo
+	50, // 19: vpner.ManageRequest.act:type_name -> structures.ManageAction
+	32, // 20: vpner.DnsRecordListResponse.records:type_name -> vpner.DnsRecord
+	35, // 21: vpner.DnsQueryLogResponse.entries:type_name -> vpner.DnsQueryLogEntry
+	50, // 22: vpner.XrayManageRequest.act:type_name -> structures.ManageAction
+	51, // 23: vpner.XrayListResponse.list:type_name -> structures.XrayInfo
+	6,  // 24: vpner.VpnerManager.UnblockList:input_type -> vpner.Empty
+	23, // 25: vpner.VpnerManager.UnblockAdd:input_type -> vpner.UnblockAddRequest
+	24, // 26: vpner.VpnerManager.UnblockDel:input_type -> vpner.UnblockDelRequest
+	6,  // 27: vpner.VpnerManager.ClientGroupList:input_type -> vpner.Empty
+	26, // 28: vpner.VpnerManager.ClientGroupAdd:input_type -> vpner.ClientGroupRequest
+	26, // 29: vpner.VpnerManager.ClientGroupRemove:input_type -> vpner.ClientGroupRequest
+	27, // 30: vpner.VpnerManager.ClientGroupSetPolicy:input_type -> vpner.ClientPolicyRequest
+	28, // 31: vpner.VpnerManager.ClientGroupSetFullTunnel:input_type -> vpner.ClientFullTunnelRequest
+	6,  // 32: vpner.VpnerManager.InterfaceList:input_type -> vpner.Empty
+	6,  // 33: vpner.VpnerManager.InterfaceScan:input_type -> vpner.Empty
+	30, // 34: vpner.VpnerManager.InterfaceAdd:input_type -> vpner.InterfaceActionRequest
+	30, // 35: vpner.VpnerManager.InterfaceDel:input_type -> vpner.InterfaceActionRequest
+	31, // 36: vpner.VpnerManager.DnsManage:input_type -> vpner.ManageRequest
+	6,  // 37: vpner.VpnerManager.DnsRecordList:input_type -> vpner.Empty
+	32, // 38: vpner.VpnerManager.DnsRecordAdd:input_type -> vpner.DnsRecord
+	32, // 39: vpner.VpnerManager.DnsRecordDel:input_type -> vpner.DnsRecord
+	34, // 40: vpner.VpnerManager.DnsQueryLog:input_type -> vpner.DnsQueryLogRequest
+	34, // 41: vpner.VpnerManager.DnsQueryLogFollow:input_type -> vpner.DnsQueryLogRequest
+	37, // 42: vpner.VpnerManager.XrayCreate:input_type -> vpner.XrayCreateRequest
+	38, // 43: vpner.VpnerManager.XrayUpdate:input_type -> vpner.XrayUpdateRequest
+	39, // 44: vpner.VpnerManager.XrayDelete:input_type -> vpner.XrayRequest
+	6,  // 45: vpner.VpnerManager.XrayList:input_type -> vpner.Empty
+	40, // 46: vpner.VpnerManager.XrayManage:input_type -> vpner.XrayManageRequest
+	39, // 47: vpner.VpnerManager.XrayTest:input_type -> vpner.XrayRequest
+	41, // 48: vpner.VpnerManager.XraySetAutorun:input_type -> vpner.XrayAutoRunRequest
+	42, // 49: vpner.VpnerManager.XraySetKillSwitch:input_type -> vpner.XrayKillSwitchRequest
+	43, // 50: vpner.VpnerManager.XraySetUDPPolicy:input_type -> vpner.XrayUDPPolicyRequest
+	44, // 51: vpner.VpnerManager.HookRestore:input_type -> vpner.HookRestoreRequest
+	16, // 52: vpner.VpnerManager.RoutingPlan:input_type -> vpner.RoutingPlanRequest
+	8,  // 53: vpner.VpnerManager.RoutingState:input_type -> vpner.RoutingStateRequest
+	12, // 54: vpner.VpnerManager.Trace:input_type -> vpner.TraceRequest
+	6,  // 55: vpner.VpnerManager.Status:input_type -> vpner.Empty
+	22, // 56: vpner.VpnerManager.UnblockList:output_type -> vpner.UnblockListResponse
+	7,  // 57: vpner.VpnerManager.UnblockAdd:output_type -> vpner.GenericResponse
+	7,  // 58: vpner.VpnerManager.UnblockDel:output_type -> vpner.GenericResponse
+	25, // 59: vpner.VpnerManager.ClientGroupList:output_type -> vpner.ClientGroupListResponse
+	7,  // 60: vpner.VpnerManager.ClientGroupAdd:output_type -> vpner.GenericResponse
+	7,  // 61: vpner.VpnerManager.ClientGroupRemove:output_type -> vpner.GenericResponse
+	7,  // 62: vpner.VpnerManager.ClientGroupSetPolicy:output_type -> vpner.GenericResponse
+	7,  // 63: vpner.VpnerManager.ClientGroupSetFullTunnel:output_type -> vpner.GenericResponse
+	29, // 64: vpner.VpnerManager.InterfaceList:output_type -> vpner.InterfaceListResponse
+	29, // 65: vpner.VpnerManager.InterfaceScan:output_type -> vpner.InterfaceListResponse
+	7,  // 66: vpner.VpnerManager.InterfaceAdd:output_type -> vpner.GenericResponse
+	7,  // 67: vpner.VpnerManager.InterfaceDel:output_type -> vpner.GenericResponse
+	7,  // 68: vpner.VpnerManager.DnsManage:output_type -> vpner.GenericResponse
+	33, // 69: vpner.VpnerManager.DnsRecordList:output_type -> vpner.DnsRecordListResponse
+	7,  // 70: vpner.VpnerManager.DnsRecordAdd:output_type -> vpner.GenericResponse
+	7,  // 71: vpner.VpnerManager.DnsRecordDel:output_type -> vpner.GenericResponse
+	36, // 72: vpner.VpnerManager.DnsQueryLog:output_type -> vpner.DnsQueryLogResponse
+	35, // 73: vpner.VpnerManager.DnsQueryLogFollow:output_type -> vpner.DnsQueryLogEntry
+	7,  // 74: vpner.VpnerManager.XrayCreate:output_type -> vpner.GenericResponse
+	7,  // 75: vpner.VpnerManager.XrayUpdate:output_type -> vpner.GenericResponse
+	7,  // 76: vpner.VpnerManager.XrayDelete:output_type -> vpner.GenericResponse
+	45, // 77: vpner.VpnerManager.XrayList:output_type -> vpner.XrayListResponse
+	7,  // 78: vpner.VpnerManager.XrayManage:output_type -> vpner.GenericResponse
+	7,  // 79: vpner.VpnerManager.XrayTest:output_type -> vpner.GenericResponse
+	7,  // 80: vpner.VpnerManager.XraySetAutorun:output_type -> vpner.GenericResponse
+	7,  // 81: vpner.VpnerManager.XraySetKillSwitch:output_type -> vpner.GenericResponse
+	7,  // 82: vpner.VpnerManager.XraySetUDPPolicy:output_type -> vpner.GenericResponse
+	7,  // 83: vpner.VpnerManager.HookRestore:output_type -> vpner.GenericResponse
+	17, // 84: vpner.VpnerManager.RoutingPlan:output_type -> vpner.Plan
+	9,  // 85: vpner.VpnerManager.RoutingState:output_type -> vpner.RoutingStateResponse
+	13, // 86: vpner.VpnerManager.Trace:output_type -> vpner.TraceResponse
+	0,  // 87: vpner.VpnerManager.Status:output_type -> vpner.StatusResponse
+	56, // [56:88] is the sub-list for method output_type
+	24, // [24:56] is the sub-list for method input_type
+	24, // [24:24] is the sub-list for extension type_name
+	24, // [24:24] is the sub-list for extension extendee
+	0,  // [0:24] is the sub-list for field type_name
 }
 
 func init() { file_vpner_proto_init() }
@@ -1277,7 +3466,7 @@ func file_vpner_proto_init() {
 		return
 	}
 	file_structures_proto_init()
-	file_vpner_proto_msgTypes[4].OneofWrappers = []any{
+	file_vpner_proto_msgTypes[7].OneofWrappers = []any{
 		(*GenericResponse_Success)(nil),
 		(*GenericResponse_Error)(nil),
 	}
@@ -1287,7 +3476,7 @@ func file_vpner_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_vpner_proto_rawDesc), len(file_vpner_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   19,
+			NumMessages:   46,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,23 +19,38 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	VpnerManager_UnblockList_FullMethodName    = "/vpner.VpnerManager/UnblockList"
-	VpnerManager_UnblockAdd_FullMethodName     = "/vpner.VpnerManager/UnblockAdd"
-	VpnerManager_UnblockDel_FullMethodName     = "/vpner.VpnerManager/UnblockDel"
-	VpnerManager_InterfaceList_FullMethodName  = "/vpner.VpnerManager/InterfaceList"
-	VpnerManager_InterfaceScan_FullMethodName  = "/vpner.VpnerManager/InterfaceScan"
-	VpnerManager_InterfaceAdd_FullMethodName   = "/vpner.VpnerManager/InterfaceAdd"
-	VpnerManager_InterfaceDel_FullMethodName   = "/vpner.VpnerManager/InterfaceDel"
-	VpnerManager_DnsManage_FullMethodName      = "/vpner.VpnerManager/DnsManage"
-	VpnerManager_XrayCreate_FullMethodName     = "/vpner.VpnerManager/XrayCreate"
-	VpnerManager_XrayUpdate_FullMethodName     = "/vpner.VpnerManager/XrayUpdate"
-	VpnerManager_XrayDelete_FullMethodName     = "/vpner.VpnerManager/XrayDelete"
-	VpnerManager_XrayList_FullMethodName       = "/vpner.VpnerManager/XrayList"
-	VpnerManager_XrayManage_FullMethodName     = "/vpner.VpnerManager/XrayManage"
-	VpnerManager_XrayTest_FullMethodName       = "/vpner.VpnerManager/XrayTest"
-	VpnerManager_XraySetAutorun_FullMethodName = "/vpner.VpnerManager/XraySetAutorun"
-	VpnerManager_HookRestore_FullMethodName    = "/vpner.VpnerManager/HookRestore"
-	VpnerManager_Status_FullMethodName         = "/vpner.VpnerManager/Status"
+	VpnerManager_UnblockList_FullMethodName              = "/vpner.VpnerManager/UnblockList"
+	VpnerManager_UnblockAdd_FullMethodName               = "/vpner.VpnerManager/UnblockAdd"
+	VpnerManager_UnblockDel_FullMethodName               = "/vpner.VpnerManager/UnblockDel"
+	VpnerManager_ClientGroupList_FullMethodName          = "/vpner.VpnerManager/ClientGroupList"
+	VpnerManager_ClientGroupAdd_FullMethodName           = "/vpner.VpnerManager/ClientGroupAdd"
+	VpnerManager_ClientGroupRemove_FullMethodName        = "/vpner.VpnerManager/ClientGroupRemove"
+	VpnerManager_ClientGroupSetPolicy_FullMethodName     = "/vpner.VpnerManager/ClientGroupSetPolicy"
+	VpnerManager_ClientGroupSetFullTunnel_FullMethodName = "/vpner.VpnerManager/ClientGroupSetFullTunnel"
+	VpnerManager_InterfaceList_FullMethodName            = "/vpner.VpnerManager/InterfaceList"
+	VpnerManager_InterfaceScan_FullMethodName            = "/vpner.VpnerManager/InterfaceScan"
+	VpnerManager_InterfaceAdd_FullMethodName             = "/vpner.VpnerManager/InterfaceAdd"
+	VpnerManager_InterfaceDel_FullMethodName             = "/vpner.VpnerManager/InterfaceDel"
+	VpnerManager_DnsManage_FullMethodName                = "/vpner.VpnerManager/DnsManage"
+	VpnerManager_DnsRecordList_FullMethodName            = "/vpner.VpnerManager/DnsRecordList"
+	VpnerManager_DnsRecordAdd_FullMethodName             = "/vpner.VpnerManager/DnsRecordAdd"
+	VpnerManager_DnsRecordDel_FullMethodName             = "/vpner.VpnerManager/DnsRecordDel"
+	VpnerManager_DnsQueryLog_FullMethodName              = "/vpner.VpnerManager/DnsQueryLog"
+	VpnerManager_DnsQueryLogFollow_FullMethodName        = "/vpner.VpnerManager/DnsQueryLogFollow"
+	VpnerManager_XrayCreate_FullMethodName               = "/vpner.VpnerManager/XrayCreate"
+	VpnerManager_XrayUpdate_FullMethodName               = "/vpner.VpnerManager/XrayUpdate"
+	VpnerManager_XrayDelete_FullMethodName               = "/vpner.VpnerManager/XrayDelete"
+	VpnerManager_XrayList_FullMethodName                 = "/vpner.VpnerManager/XrayList"
+	VpnerManager_XrayManage_FullMethodName               = "/vpner.VpnerManager/XrayManage"
+	VpnerManager_XrayTest_FullMethodName                 = "/vpner.VpnerManager/XrayTest"
+	VpnerManager_XraySetAutorun_FullMethodName           = "/vpner.VpnerManager/XraySetAutorun"
+	VpnerManager_XraySetKillSwitch_FullMethodName        = "/vpner.VpnerManager/XraySetKillSwitch"
+	VpnerManager_XraySetUDPPolicy_FullMethodName         = "/vpner.VpnerManager/XraySetUDPPolicy"
+	VpnerManager_HookRestore_FullMethodName              = "/vpner.VpnerManager/HookRestore"
+	VpnerManager_RoutingPlan_FullMethodName              = "/vpner.VpnerManager/RoutingPlan"
+	VpnerManager_RoutingState_FullMethodName             = "/vpner.VpnerManager/RoutingState"
+	VpnerManager_Trace_FullMethodName                    = "/vpner.VpnerManager/Trace"
+	VpnerManager_Status_FullMethodName                   = "/vpner.VpnerManager/Status"
 )
 
 // VpnerManagerClient is the client API for VpnerManager service.
@@ -46,6 +61,12 @@ type VpnerManagerClient interface {
 	UnblockList(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*UnblockListResponse, error)
 	UnblockAdd(ctx context.Context, in *UnblockAddRequest, opts ...grpc.CallOption) (*GenericResponse, error)
 	UnblockDel(ctx context.Context, in *UnblockDelRequest, opts ...grpc.CallOption) (*GenericResponse, error)
+	// LAN client groups
+	ClientGroupList(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*ClientGroupListResponse, error)
+	ClientGroupAdd(ctx context.Context, in *ClientGroupRequest, opts ...grpc.CallOption) (*GenericResponse, error)
+	ClientGroupRemove(ctx context.Context, in *ClientGroupRequest, opts ...grpc.CallOption) (*GenericResponse, error)
+	ClientGroupSetPolicy(ctx context.Context, in *ClientPolicyRequest, opts ...grpc.CallOption) (*GenericResponse, error)
+	ClientGroupSetFullTunnel(ctx context.Context, in *ClientFullTunnelRequest, opts ...grpc.CallOption) (*GenericResponse, error)
 	// Interfaces
 	InterfaceList(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*InterfaceListResponse, error)
 	InterfaceScan(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*InterfaceListResponse, error)
@@ -53,6 +74,11 @@ type VpnerManagerClient interface {
 	InterfaceDel(ctx context.Context, in *InterfaceActionRequest, opts ...grpc.CallOption) (*GenericResponse, error)
 	// DNS management
 	DnsManage(ctx context.Context, in *ManageRequest, opts ...grpc.CallOption) (*GenericResponse, error)
+	DnsRecordList(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*DnsRecordListResponse, error)
+	DnsRecordAdd(ctx context.Context, in *DnsRecord, opts ...grpc.CallOption) (*GenericResponse, error)
+	DnsRecordDel(ctx context.Context, in *DnsRecord, opts ...grpc.CallOption) (*GenericResponse, error)
+	DnsQueryLog(ctx context.Context, in *DnsQueryLogRequest, opts ...grpc.CallOption) (*DnsQueryLogResponse, error)
+	DnsQueryLogFollow(ctx context.Context, in *DnsQueryLogRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DnsQueryLogEntry], error)
 	// xray manager
 	XrayCreate(ctx context.Context, in *XrayCreateRequest, opts ...grpc.CallOption) (*GenericResponse, error)
 	XrayUpdate(ctx context.Context, in *XrayUpdateRequest, opts ...grpc.CallOption) (*GenericResponse, error)
@@ -61,7 +87,12 @@ type VpnerManagerClient interface {
 	XrayManage(ctx context.Context, in *XrayManageRequest, opts ...grpc.CallOption) (*GenericResponse, error)
 	XrayTest(ctx context.Context, in *XrayRequest, opts ...grpc.CallOption) (*GenericResponse, error)
 	XraySetAutorun(ctx context.Context, in *XrayAutoRunRequest, opts ...grpc.CallOption) (*GenericResponse, error)
-	HookRestore(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GenericResponse, error)
+	XraySetKillSwitch(ctx context.Context, in *XrayKillSwitchRequest, opts ...grpc.CallOption) (*GenericResponse, error)
+	XraySetUDPPolicy(ctx context.Context, in *XrayUDPPolicyRequest, opts ...grpc.CallOption) (*GenericResponse, error)
+	HookRestore(ctx context.Context, in *HookRestoreRequest, opts ...grpc.CallOption) (*GenericResponse, error)
+	RoutingPlan(ctx context.Context, in *RoutingPlanRequest, opts ...grpc.CallOption) (*Plan, error)
+	RoutingState(ctx context.Context, in *RoutingStateRequest, opts ...grpc.CallOption) (*RoutingStateResponse, error)
+	Trace(ctx context.Context, in *TraceRequest, opts ...grpc.CallOption) (*TraceResponse, error)
 	// Daemon-wide status snapshot.
 	Status(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*StatusResponse, error)
 }
@@ -104,6 +135,56 @@ func (c *vpnerManagerClient) UnblockDel(ctx context.Context, in *UnblockDelReque
 	return out, nil
 }
 
+func (c *vpnerManagerClient) ClientGroupList(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*ClientGroupListResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ClientGroupListResponse)
+	err := c.cc.Invoke(ctx, VpnerManager_ClientGroupList_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vpnerManagerClient) ClientGroupAdd(ctx context.Context, in *ClientGroupRequest, opts ...grpc.CallOption) (*GenericResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GenericResponse)
+	err := c.cc.Invoke(ctx, VpnerManager_ClientGroupAdd_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vpnerManagerClient) ClientGroupRemove(ctx context.Context, in *ClientGroupRequest, opts ...grpc.CallOption) (*GenericResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GenericResponse)
+	err := c.cc.Invoke(ctx, VpnerManager_ClientGroupRemove_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vpnerManagerClient) ClientGroupSetPolicy(ctx context.Context, in *ClientPolicyRequest, opts ...grpc.CallOption) (*GenericResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GenericResponse)
+	err := c.cc.Invoke(ctx, VpnerManager_ClientGroupSetPolicy_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vpnerManagerClient) ClientGroupSetFullTunnel(ctx context.Context, in *ClientFullTunnelRequest, opts ...grpc.CallOption) (*GenericResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GenericResponse)
+	err := c.cc.Invoke(ctx, VpnerManager_ClientGroupSetFullTunnel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *vpnerManagerClient) InterfaceList(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*InterfaceListResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(InterfaceListResponse)
@@ -154,6 +235,65 @@ func (c *vpnerManagerClient) DnsManage(ctx context.Context, in *ManageRequest, o
 	return out, nil
 }
 
+func (c *vpnerManagerClient) DnsRecordList(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*DnsRecordListResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DnsRecordListResponse)
+	err := c.cc.Invoke(ctx, VpnerManager_DnsRecordList_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vpnerManagerClient) DnsRecordAdd(ctx context.Context, in *DnsRecord, opts ...grpc.CallOption) (*GenericResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GenericResponse)
+	err := c.cc.Invoke(ctx, VpnerManager_DnsRecordAdd_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vpnerManagerClient) DnsRecordDel(ctx context.Context, in *DnsRecord, opts ...grpc.CallOption) (*GenericResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GenericResponse)
+	err := c.cc.Invoke(ctx, VpnerManager_DnsRecordDel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vpnerManagerClient) DnsQueryLog(ctx context.Context, in *DnsQueryLogRequest, opts ...grpc.CallOption) (*DnsQueryLogResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DnsQueryLogResponse)
+	err := c.cc.Invoke(ctx, VpnerManager_DnsQueryLog_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vpnerManagerClient) DnsQueryLogFollow(ctx context.Context, in *DnsQueryLogRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DnsQueryLogEntry], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &VpnerManager_ServiceDesc.Streams[0], VpnerManager_DnsQueryLogFollow_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DnsQueryLogRequest, DnsQueryLogEntry]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VpnerManager_DnsQueryLogFollowClient = grpc.ServerStreamingClient[DnsQueryLogEntry]
+
 func (c *vpnerManagerClient) XrayCreate(ctx context.Context, in *XrayCreateRequest, opts ...grpc.CallOption) (*GenericResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(GenericResponse)
@@ -224,7 +364,27 @@ func (c *vpnerManagerClient) XraySetAutorun(ctx context.Context, in *XrayAutoRun
 	return out, nil
 }
 
-func (c *vpnerManagerClient) HookRestore(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GenericResponse, error) {
+func (c *vpnerManagerClient) XraySetKillSwitch(ctx context.Context, in *XrayKillSwitchRequest, opts ...grpc.CallOption) (*GenericResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GenericResponse)
+	err := c.cc.Invoke(ctx, VpnerManager_XraySetKillSwitch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vpnerManagerClient) XraySetUDPPolicy(ctx context.Context, in *XrayUDPPolicyRequest, opts ...grpc.CallOption) (*GenericResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GenericResponse)
+	err := c.cc.Invoke(ctx, VpnerManager_XraySetUDPPolicy_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vpnerManagerClient) HookRestore(ctx context.Context, in *HookRestoreRequest, opts ...grpc.CallOption) (*GenericResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(GenericResponse)
 	err := c.cc.Invoke(ctx, VpnerManager_HookRestore_FullMethodName, in, out, cOpts...)
@@ -234,6 +394,36 @@ func (c *vpnerManagerClient) HookRestore(ctx context.Context, in *Empty, opts ..
 	return out, nil
 }
 
+func (c *vpnerManagerClient) RoutingPlan(ctx context.Context, in *RoutingPlanRequest, opts ...grpc.CallOption) (*Plan, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Plan)
+	err := c.cc.Invoke(ctx, VpnerManager_RoutingPlan_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vpnerManagerClient) RoutingState(ctx context.Context, in *RoutingStateRequest, opts ...grpc.CallOption) (*RoutingStateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RoutingStateResponse)
+	err := c.cc.Invoke(ctx, VpnerManager_RoutingState_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vpnerManagerClient) Trace(ctx context.Context, in *TraceRequest, opts ...grpc.CallOption) (*TraceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TraceResponse)
+	err := c.cc.Invoke(ctx, VpnerManager_Trace_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *vpnerManagerClient) Status(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*StatusResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(StatusResponse)
@@ -252,6 +442,12 @@ type VpnerManagerServer interface {
 	UnblockList(context.Context, *Empty) (*UnblockListResponse, error)
 	UnblockAdd(context.Context, *UnblockAddRequest) (*GenericResponse, error)
 	UnblockDel(context.Context, *UnblockDelRequest) (*GenericResponse, error)
+	// LAN client groups
+	ClientGroupList(context.Context, *Empty) (*ClientGroupListResponse, error)
+	ClientGroupAdd(context.Context, *ClientGroupRequest) (*GenericResponse, error)
+	ClientGroupRemove(context.Context, *ClientGroupRequest) (*GenericResponse, error)
+	ClientGroupSetPolicy(context.Context, *ClientPolicyRequest) (*GenericResponse, error)
+	ClientGroupSetFullTunnel(context.Context, *ClientFullTunnelRequest) (*GenericResponse, error)
 	// Interfaces
 	InterfaceList(context.Context, *Empty) (*InterfaceListResponse, error)
 	InterfaceScan(context.Context, *Empty) (*InterfaceListResponse, error)
@@ -259,6 +455,11 @@ type VpnerManagerServer interface {
 	InterfaceDel(context.Context, *InterfaceActionRequest) (*GenericResponse, error)
 	// DNS management
 	DnsManage(context.Context, *ManageRequest) (*GenericResponse, error)
+	DnsRecordList(context.Context, *Empty) (*DnsRecordListResponse, error)
+	DnsRecordAdd(context.Context, *DnsRecord) (*GenericResponse, error)
+	DnsRecordDel(context.Context, *DnsRecord) (*GenericResponse, error)
+	DnsQueryLog(context.Context, *DnsQueryLogRequest) (*DnsQueryLogResponse, error)
+	DnsQueryLogFollow(*DnsQueryLogRequest, grpc.ServerStreamingServer[DnsQueryLogEntry]) error
 	// xray manager
 	XrayCreate(context.Context, *XrayCreateRequest) (*GenericResponse, error)
 	XrayUpdate(context.Context, *XrayUpdateRequest) (*GenericResponse, error)
@@ -267,7 +468,12 @@ type VpnerManagerServer interface {
 	XrayManage(context.Context, *XrayManageRequest) (*GenericResponse, error)
 	XrayTest(context.Context, *XrayRequest) (*GenericResponse, error)
 	XraySetAutorun(context.Context, *XrayAutoRunRequest) (*GenericResponse, error)
-	HookRestore(context.Context, *Empty) (*GenericResponse, error)
+	XraySetKillSwitch(context.Context, *XrayKillSwitchRequest) (*GenericResponse, error)
+	XraySetUDPPolicy(context.Context, *XrayUDPPolicyRequest) (*GenericResponse, error)
+	HookRestore(context.Context, *HookRestoreRequest) (*GenericResponse, error)
+	RoutingPlan(context.Context, *RoutingPlanRequest) (*Plan, error)
+	RoutingState(context.Context, *RoutingStateRequest) (*RoutingStateResponse, error)
+	Trace(context.Context, *TraceRequest) (*TraceResponse, error)
 	// Daemon-wide status snapshot.
 	Status(context.Context, *Empty) (*StatusResponse, error)
 	mustEmbedUnimplementedVpnerManagerServer()
@@ -289,6 +495,21 @@ func (UnimplementedVpnerManagerServer) UnblockAdd(context.Context, *UnblockAddRe
 func (UnimplementedVpnerManagerServer) UnblockDel(context.Context, *UnblockDelRequest) (*GenericResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UnblockDel not implemented")
 }
+func (UnimplementedVpnerManagerServer) ClientGroupList(context.Context, *Empty) (*ClientGroupListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ClientGroupList not implemented")
+}
+func (UnimplementedVpnerManagerServer) ClientGroupAdd(context.Context, *ClientGroupRequest) (*GenericResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ClientGroupAdd not implemented")
+}
+func (UnimplementedVpnerManagerServer) ClientGroupRemove(context.Context, *ClientGroupRequest) (*GenericResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ClientGroupRemove not implemented")
+}
+func (UnimplementedVpnerManagerServer) ClientGroupSetPolicy(context.Context, *ClientPolicyRequest) (*GenericResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ClientGroupSetPolicy not implemented")
+}
+func (UnimplementedVpnerManagerServer) ClientGroupSetFullTunnel(context.Context, *ClientFullTunnelRequest) (*GenericResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ClientGroupSetFullTunnel not implemented")
+}
 func (UnimplementedVpnerManagerServer) InterfaceList(context.Context, *Empty) (*InterfaceListResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method InterfaceList not implemented")
 }
@@ -304,6 +525,21 @@ func (UnimplementedVpnerManagerServer) InterfaceDel(context.Context, *InterfaceA
 func (UnimplementedVpnerManagerServer) DnsManage(context.Context, *ManageRequest) (*GenericResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DnsManage not implemented")
 }
+func (UnimplementedVpnerManagerServer) DnsRecordList(context.Context, *Empty) (*DnsRecordListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DnsRecordList not implemented")
+}
+func (UnimplementedVpnerManagerServer) DnsRecordAdd(context.Context, *DnsRecord) (*GenericResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DnsRecordAdd not implemented")
+}
+func (UnimplementedVpnerManagerServer) DnsRecordDel(context.Context, *DnsRecord) (*GenericResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DnsRecordDel not implemented")
+}
+func (UnimplementedVpnerManagerServer) DnsQueryLog(context.Context, *DnsQueryLogRequest) (*DnsQueryLogResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DnsQueryLog not implemented")
+}
+func (UnimplementedVpnerManagerServer) DnsQueryLogFollow(*DnsQueryLogRequest, grpc.ServerStreamingServer[DnsQueryLogEntry]) error {
+	return status.Errorf(codes.Unimplemented, "method DnsQueryLogFollow not implemented")
+}
 func (UnimplementedVpnerManagerServer) XrayCreate(context.Context, *XrayCreateRequest) (*GenericResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method XrayCreate not implemented")
 }
@@ -325,9 +561,24 @@ func (UnimplementedVpnerManagerServer) XrayTest(context.Context, *XrayRequest) (
 func (UnimplementedVpnerManagerServer) XraySetAutorun(context.Context, *XrayAutoRunRequest) (*GenericResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method XraySetAutorun not implemented")
 }
-func (UnimplementedVpnerManagerServer) HookRestore(context.Context, *Empty) (*GenericResponse, error) {
+func (UnimplementedVpnerManagerServer) XraySetKillSwitch(context.Context, *XrayKillSwitchRequest) (*GenericResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method XraySetKillSwitch not implemented")
+}
+func (UnimplementedVpnerManagerServer) XraySetUDPPolicy(context.Context, *XrayUDPPolicyRequest) (*GenericResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method XraySetUDPPolicy not implemented")
+}
+func (UnimplementedVpnerManagerServer) HookRestore(context.Context, *HookRestoreRequest) (*GenericResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method HookRestore not implemented")
 }
+func (UnimplementedVpnerManagerServer) RoutingPlan(context.Context, *RoutingPlanRequest) (*Plan, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RoutingPlan not implemented")
+}
+func (UnimplementedVpnerManagerServer) RoutingState(context.Context, *RoutingStateRequest) (*RoutingStateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RoutingState not implemented")
+}
+func (UnimplementedVpnerManagerServer) Trace(context.Context, *TraceRequest) (*TraceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Trace not implemented")
+}
 func (UnimplementedVpnerManagerServer) Status(context.Context, *Empty) (*StatusResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
 }
@@ -406,6 +657,96 @@ func _VpnerManager_UnblockDel_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _VpnerManager_ClientGroupList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VpnerManagerServer).ClientGroupList(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VpnerManager_ClientGroupList_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VpnerManagerServer).ClientGroupList(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VpnerManager_ClientGroupAdd_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClientGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VpnerManagerServer).ClientGroupAdd(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VpnerManager_ClientGroupAdd_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VpnerManagerServer).ClientGroupAdd(ctx, req.(*ClientGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VpnerManager_ClientGroupRemove_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClientGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VpnerManagerServer).ClientGroupRemove(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VpnerManager_ClientGroupRemove_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VpnerManagerServer).ClientGroupRemove(ctx, req.(*ClientGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VpnerManager_ClientGroupSetPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClientPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VpnerManagerServer).ClientGroupSetPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VpnerManager_ClientGroupSetPolicy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VpnerManagerServer).ClientGroupSetPolicy(ctx, req.(*ClientPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VpnerManager_ClientGroupSetFullTunnel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClientFullTunnelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VpnerManagerServer).ClientGroupSetFullTunnel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VpnerManager_ClientGroupSetFullTunnel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VpnerManagerServer).ClientGroupSetFullTunnel(ctx, req.(*ClientFullTunnelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _VpnerManager_InterfaceList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Empty)
 	if err := dec(in); err != nil {
@@ -496,6 +837,89 @@ func _VpnerManager_DnsManage_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _VpnerManager_DnsRecordList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VpnerManagerServer).DnsRecordList(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VpnerManager_DnsRecordList_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VpnerManagerServer).DnsRecordList(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VpnerManager_DnsRecordAdd_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DnsRecord)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VpnerManagerServer).DnsRecordAdd(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VpnerManager_DnsRecordAdd_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VpnerManagerServer).DnsRecordAdd(ctx, req.(*DnsRecord))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VpnerManager_DnsRecordDel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DnsRecord)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VpnerManagerServer).DnsRecordDel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VpnerManager_DnsRecordDel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VpnerManagerServer).DnsRecordDel(ctx, req.(*DnsRecord))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VpnerManager_DnsQueryLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DnsQueryLogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VpnerManagerServer).DnsQueryLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VpnerManager_DnsQueryLog_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VpnerManagerServer).DnsQueryLog(ctx, req.(*DnsQueryLogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VpnerManager_DnsQueryLogFollow_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DnsQueryLogRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VpnerManagerServer).DnsQueryLogFollow(m, &grpc.GenericServerStream[DnsQueryLogRequest, DnsQueryLogEntry]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VpnerManager_DnsQueryLogFollowServer = grpc.ServerStreamingServer[DnsQueryLogEntry]
+
 func _VpnerManager_XrayCreate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(XrayCreateRequest)
 	if err := dec(in); err != nil {
@@ -622,8 +1046,44 @@ func _VpnerManager_XraySetAutorun_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _VpnerManager_XraySetKillSwitch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(XrayKillSwitchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VpnerManagerServer).XraySetKillSwitch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VpnerManager_XraySetKillSwitch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VpnerManagerServer).XraySetKillSwitch(ctx, req.(*XrayKillSwitchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VpnerManager_XraySetUDPPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(XrayUDPPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VpnerManagerServer).XraySetUDPPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VpnerManager_XraySetUDPPolicy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VpnerManagerServer).XraySetUDPPolicy(ctx, req.(*XrayUDPPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _VpnerManager_HookRestore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(Empty)
+	in := new(HookRestoreRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
@@ -635,7 +1095,61 @@ func _VpnerManager_HookRestore_Handler(srv interface{}, ctx context.Context, dec
 		FullMethod: VpnerManager_HookRestore_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(VpnerManagerServer).HookRestore(ctx, req.(*Empty))
+		return srv.(VpnerManagerServer).HookRestore(ctx, req.(*HookRestoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VpnerManager_RoutingPlan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoutingPlanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VpnerManagerServer).RoutingPlan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VpnerManager_RoutingPlan_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VpnerManagerServer).RoutingPlan(ctx, req.(*RoutingPlanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VpnerManager_RoutingState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoutingStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VpnerManagerServer).RoutingState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VpnerManager_RoutingState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VpnerManagerServer).RoutingState(ctx, req.(*RoutingStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VpnerManager_Trace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TraceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VpnerManagerServer).Trace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VpnerManager_Trace_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VpnerManagerServer).Trace(ctx, req.(*TraceRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -677,6 +1191,26 @@ var VpnerManager_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UnblockDel",
 			Handler:    _VpnerManager_UnblockDel_Handler,
 		},
+		{
+			MethodName: "ClientGroupList",
+			Handler:    _VpnerManager_ClientGroupList_Handler,
+		},
+		{
+			MethodName: "ClientGroupAdd",
+			Handler:    _VpnerManager_ClientGroupAdd_Handler,
+		},
+		{
+			MethodName: "ClientGroupRemove",
+			Handler:    _VpnerManager_ClientGroupRemove_Handler,
+		},
+		{
+			MethodName: "ClientGroupSetPolicy",
+			Handler:    _VpnerManager_ClientGroupSetPolicy_Handler,
+		},
+		{
+			MethodName: "ClientGroupSetFullTunnel",
+			Handler:    _VpnerManager_ClientGroupSetFullTunnel_Handler,
+		},
 		{
 			MethodName: "InterfaceList",
 			Handler:    _VpnerManager_InterfaceList_Handler,
@@ -697,6 +1231,22 @@ var VpnerManager_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DnsManage",
 			Handler:    _VpnerManager_DnsManage_Handler,
 		},
+		{
+			MethodName: "DnsRecordList",
+			Handler:    _VpnerManager_DnsRecordList_Handler,
+		},
+		{
+			MethodName: "DnsRecordAdd",
+			Handler:    _VpnerManager_DnsRecordAdd_Handler,
+		},
+		{
+			MethodName: "DnsRecordDel",
+			Handler:    _VpnerManager_DnsRecordDel_Handler,
+		},
+		{
+			MethodName: "DnsQueryLog",
+			Handler:    _VpnerManager_DnsQueryLog_Handler,
+		},
 		{
 			MethodName: "XrayCreate",
 			Handler:    _VpnerManager_XrayCreate_Handler,
@@ -725,15 +1275,41 @@ var VpnerManager_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "XraySetAutorun",
 			Handler:    _VpnerManager_XraySetAutorun_Handler,
 		},
+		{
+			MethodName: "XraySetKillSwitch",
+			Handler:    _VpnerManager_XraySetKillSwitch_Handler,
+		},
+		{
+			MethodName: "XraySetUDPPolicy",
+			Handler:    _VpnerManager_XraySetUDPPolicy_Handler,
+		},
 		{
 			MethodName: "HookRestore",
 			Handler:    _VpnerManager_HookRestore_Handler,
 		},
+		{
+			MethodName: "RoutingPlan",
+			Handler:    _VpnerManager_RoutingPlan_Handler,
+		},
+		{
+			MethodName: "RoutingState",
+			Handler:    _VpnerManager_RoutingState_Handler,
+		},
+		{
+			MethodName: "Trace",
+			Handler:    _VpnerManager_Trace_Handler,
+		},
 		{
 			MethodName: "Status",
 			Handler:    _VpnerManager_Status_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "DnsQueryLogFollow",
+			Handler:       _VpnerManager_DnsQueryLogFollow_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "vpner.proto",
 }
@@ -13,6 +13,7 @@ const (
 	FamilyIPv6  = "ipv6"
 	TableNat    = "nat"
 	TableMangle = "mangle"
+	TableFilter = "filter"
 )
 
 type Scope struct {
@@ -41,6 +42,8 @@ func NormalizeTable(value string) (string, error) {
 		return TableNat, nil
 	case "mangle":
 		return TableMangle, nil
+	case "filter":
+		return TableFilter, nil
 	default:
 		return "", fmt.Errorf("unsupported table %q", value)
 	}
@@ -0,0 +1,296 @@
+package localzone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ApostolDmitry/vpner/internal/conf"
+	"github.com/ApostolDmitry/vpner/internal/logx"
+	"github.com/miekg/dns"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	SourceConfig  = "config"
+	SourceRecords = "records"
+
+	reloadInterval = 5 * time.Second
+)
+
+type Entry struct {
+	conf.StaticRecord
+	// Source is SourceConfig, SourceRecords or the path of a hosts file.
+	Source string
+}
+
+type recordsFile struct {
+	Records []conf.StaticRecord `yaml:"records"`
+}
+
+type fileStamp struct {
+	mod  time.Time
+	size int64
+}
+
+// Service answers local names from records in vpner.yaml, the records file
+// managed over RPC and hosts files. The files are reloaded when they change.
+type Service struct {
+	static      []conf.StaticRecord
+	hostsFiles  []string
+	recordsPath string
+
+	fileMu sync.Mutex
+
+	mu      sync.RWMutex
+	zone    *zone
+	entries []Entry
+	stamps  map[string]fileStamp
+}
+
+func New(cfg conf.ServerConfig) *Service {
+	s := &Service{
+		hostsFiles:  cfg.HostsFiles,
+		recordsPath: cfg.RecordsPath,
+		zone:        newZone(),
+	}
+	for _, raw := range cfg.Records {
+		rec, err := Normalize(raw)
+		if err != nil {
+			logx.Errorf("invalid DNS record in config: %v", err)
+			continue
+		}
+		s.static = append(s.static, rec)
+	}
+	if err := s.Reload(); err != nil {
+		logx.Warnf("local DNS records: %v", err)
+	}
+	return s
+}
+
+// Lookup answers qtype for name from the local records; see zone.lookup.
+func (s *Service) Lookup(name string, qtype uint16) ([]dns.RR, bool) {
+	s.mu.RLock()
+	z := s.zone
+	s.mu.RUnlock()
+	return z.lookup(name, qtype)
+}
+
+func (s *Service) List() []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.entries)
+}
+
+func (s *Service) Add(raw conf.StaticRecord) error {
+	rec, err := Normalize(raw)
+	if err != nil {
+		return err
+	}
+	return s.modify(func(f *recordsFile) error {
+		for _, r := range f.Records {
+			switch {
+			case r.Name != rec.Name:
+			case r.Type == rec.Type && r.Value == rec.Value:
+				return fmt.Errorf("record %s %s %s already exists", rec.Name, rec.Type, rec.Value)
+			case r.Type == "CNAME" || rec.Type == "CNAME":
+				return fmt.Errorf("%s cannot have a CNAME next to other records", rec.Name)
+			}
+		}
+		f.Records = append(f.Records, rec)
+		return nil
+	})
+}
+
+// Delete removes the records of the records file matching name and, when
+// given, type and value. Records from vpner.yaml and hosts files stay.
+func (s *Service) Delete(name, typ, value string) (int, error) {
+	match := canonical(conf.StaticRecord{Name: name, Type: typ, Value: value})
+	removed := 0
+	err := s.modify(func(f *recordsFile) error {
+		kept := f.Records[:0]
+		for _, r := range f.Records {
+			if r.Name == match.Name && (match.Type == "" || r.Type == match.Type) && (match.Value == "" || r.Value == match.Value) {
+				removed++
+				continue
+			}
+			kept = append(kept, r)
+		}
+		f.Records = kept
+		if removed == 0 {
+			return fmt.Errorf("no matching record in %s", s.recordsPath)
+		}
+		return nil
+	})
+	return removed, err
+}
+
+func (s *Service) modify(fn func(*recordsFile) error) error {
+	if s.recordsPath == "" {
+		return errors.New("no records file configured")
+	}
+	s.fileMu.Lock()
+	f, err := readRecordsFile(s.recordsPath)
+	if err == nil {
+		err = fn(f)
+	}
+	if err == nil {
+		err = writeRecordsFile(s.recordsPath, f)
+	}
+	s.fileMu.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.Reload()
+}
+
+// Run reloads the records whenever one of the files changes, until ctx is
+// done.
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.changed() {
+				continue
+			}
+			if err := s.Reload(); err != nil {
+				logx.Warnf("local DNS records: %v", err)
+			}
+		}
+	}
+}
+
+func (s *Service) files() []string {
+	files := slices.Clone(s.hostsFiles)
+	if s.recordsPath != "" {
+		files = append(files, s.recordsPath)
+	}
+	return files
+}
+
+func (s *Service) changed() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, path := range s.files() {
+		if stamp(path) != s.stamps[path] {
+			return true
+		}
+	}
+	return false
+}
+
+func stamp(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{mod: info.ModTime(), size: info.Size()}
+}
+
+// Reload rebuilds the zone from all sources. A source that cannot be read is
+// skipped and reported; the others still load.
+func (s *Service) Reload() error {
+	stamps := make(map[string]fileStamp)
+	for _, path := range s.files() {
+		stamps[path] = stamp(path)
+	}
+
+	var entries []Entry
+	for _, rec := range s.static {
+		entries = append(entries, Entry{StaticRecord: rec, Source: SourceConfig})
+	}
+	var errs []error
+	if s.recordsPath != "" {
+		s.fileMu.Lock()
+		f, err := readRecordsFile(s.recordsPath)
+		s.fileMu.Unlock()
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			for _, raw := range f.Records {
+				rec, err := Normalize(raw)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", s.recordsPath, err))
+					continue
+				}
+				entries = append(entries, Entry{StaticRecord: rec, Source: SourceRecords})
+			}
+		}
+	}
+	for _, path := range s.hostsFiles {
+		recs, err := readHostsFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, rec := range recs {
+			entries = append(entries, Entry{StaticRecord: rec, Source: path})
+		}
+	}
+
+	z := newZone()
+	for _, e := range entries {
+		z.add(e.StaticRecord)
+	}
+	z.addReverse()
+
+	s.mu.Lock()
+	s.zone, s.entries, s.stamps = z, entries, stamps
+	s.mu.Unlock()
+	logx.Debugf("local DNS records loaded: %d", len(entries))
+	return errors.Join(errs...)
+}
+
+func readHostsFile(path string) ([]conf.StaticRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read hosts file: %w", err)
+	}
+	defer f.Close()
+	recs, err := parseHosts(f)
+	if err != nil {
+		return nil, fmt.Errorf("read hosts file %s: %w", path, err)
+	}
+	return recs, nil
+}
+
+func readRecordsFile(path string) (*recordsFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &recordsFile{}, nil
+		}
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	var f recordsFile
+	if err := yaml.NewDecoder(file).Decode(&f); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse YAML file %s: %v", path, err)
+	}
+	return &f, nil
+}
+
+func writeRecordsFile(path string, f *recordsFile) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file for writing: %v", err)
+	}
+	defer file.Close()
+
+	encoder := yaml.NewEncoder(file)
+	defer encoder.Close()
+	if err := encoder.Encode(f); err != nil {
+		return fmt.Errorf("failed to write YAML data: %v", err)
+	}
+	return nil
+}
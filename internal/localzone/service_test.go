@@ -0,0 +1,74 @@
+package localzone
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ApostolDmitry/vpner/internal/conf"
+	"github.com/miekg/dns"
+)
+
+func TestServiceAddDeleteAndReload(t *testing.T) {
+	dir := t.TempDir()
+	hosts := filepath.Join(dir, "hosts")
+	if err := os.WriteFile(hosts, []byte("192.168.1.2 printer.home\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := New(conf.ServerConfig{
+		Records:     []conf.StaticRecord{{Name: "nas.home", Type: "A", Value: "192.168.1.10"}},
+		HostsFiles:  []string{hosts},
+		RecordsPath: filepath.Join(dir, "records.yaml"),
+	})
+
+	if _, ok := s.Lookup("printer.home", dns.TypeA); !ok {
+		t.Fatal("hosts file not loaded")
+	}
+	if err := s.Add(conf.StaticRecord{Name: "tv.home", Type: "A", Value: "192.168.1.30"}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := s.Add(conf.StaticRecord{Name: "TV.home", Type: "a", Value: "192.168.1.30"}); err == nil {
+		t.Fatal("duplicate record accepted")
+	}
+	if err := s.Add(conf.StaticRecord{Name: "tv.home", Type: "CNAME", Value: "nas.home"}); err == nil {
+		t.Fatal("CNAME next to an A record accepted")
+	}
+	if rrs, ok := s.Lookup("tv.home", dns.TypeA); !ok || len(rrs) != 1 {
+		t.Fatalf("added record not served: %v", rrs)
+	}
+	if len(s.List()) != 3 {
+		t.Fatalf("unexpected entries %+v", s.List())
+	}
+
+	if _, err := s.Delete("nas.home", "", ""); err == nil {
+		t.Fatal("record from vpner.yaml deleted")
+	}
+	if n, err := s.Delete("tv.home", "A", ""); err != nil || n != 1 {
+		t.Fatalf("Delete: %d %v", n, err)
+	}
+	if _, ok := s.Lookup("tv.home", dns.TypeA); ok {
+		t.Fatal("deleted record still served")
+	}
+
+	// Edits to a hosts file are picked up once it changes on disk.
+	if err := os.WriteFile(hosts, []byte("192.168.1.3 scanner.home\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(hosts, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if !s.changed() {
+		t.Fatal("hosts file change not detected")
+	}
+	if err := s.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if _, ok := s.Lookup("scanner.home", dns.TypeA); !ok {
+		t.Fatal("reloaded hosts file not served")
+	}
+	if _, ok := s.Lookup("printer.home", dns.TypeA); ok {
+		t.Fatal("removed hosts entry still served")
+	}
+}
@@ -0,0 +1,261 @@
+package localzone
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/ApostolDmitry/vpner/internal/conf"
+	"github.com/ApostolDmitry/vpner/internal/matcher"
+	"github.com/miekg/dns"
+)
+
+const (
+	defaultTTL = 300
+	// maxCNAMEChain bounds how many local aliases a lookup follows.
+	maxCNAMEChain = 8
+)
+
+var recordTypes = map[string]uint16{
+	"A":     dns.TypeA,
+	"AAAA":  dns.TypeAAAA,
+	"CNAME": dns.TypeCNAME,
+	"TXT":   dns.TypeTXT,
+	"PTR":   dns.TypePTR,
+}
+
+type record struct {
+	qtype uint16
+	value string
+	ttl   uint32
+}
+
+type wildcard struct {
+	pattern string
+	records []record
+}
+
+// zone holds the compiled records. Exact names are looked up directly;
+// wildcard patterns are tried in the order they were defined.
+type zone struct {
+	exact     map[string][]record
+	wildcards []wildcard
+}
+
+func newZone() *zone {
+	return &zone{exact: make(map[string][]record)}
+}
+
+// Normalize validates rec and returns it in canonical form: upper-case type,
+// lower-case name and value, PTR names given as an address turned into their
+// reverse name.
+func Normalize(rec conf.StaticRecord) (conf.StaticRecord, error) {
+	rec = canonical(rec)
+	if _, ok := recordTypes[rec.Type]; !ok {
+		return rec, fmt.Errorf("unsupported record type %q (want A, AAAA, CNAME, TXT or PTR)", rec.Type)
+	}
+	if err := validateName(rec.Name, rec.Type != "PTR"); err != nil {
+		return rec, err
+	}
+	switch rec.Type {
+	case "A":
+		if ip := net.ParseIP(rec.Value); ip == nil || ip.To4() == nil {
+			return rec, fmt.Errorf("A record %s needs an IPv4 address, got %q", rec.Name, rec.Value)
+		}
+	case "AAAA":
+		if ip := net.ParseIP(rec.Value); ip == nil || ip.To4() != nil {
+			return rec, fmt.Errorf("AAAA record %s needs an IPv6 address, got %q", rec.Name, rec.Value)
+		}
+	case "CNAME", "PTR":
+		if _, ok := dns.IsDomainName(rec.Value); !ok || rec.Value == "" {
+			return rec, fmt.Errorf("%s record %s needs a domain name, got %q", rec.Type, rec.Name, rec.Value)
+		}
+	case "TXT":
+		if rec.Value == "" {
+			return rec, fmt.Errorf("TXT record %s has no text", rec.Name)
+		}
+	}
+	return rec, nil
+}
+
+func canonical(rec conf.StaticRecord) conf.StaticRecord {
+	rec.Type = strings.ToUpper(strings.TrimSpace(rec.Type))
+	rec.Name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(rec.Name), "."))
+	if rec.Type != "TXT" {
+		rec.Value = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(rec.Value), "."))
+	}
+	if rec.Type == "PTR" {
+		if ip := net.ParseIP(rec.Name); ip != nil {
+			if rev, err := dns.ReverseAddr(ip.String()); err == nil {
+				rec.Name = strings.TrimSuffix(rev, ".")
+			}
+		}
+	}
+	return rec
+}
+
+func validateName(name string, allowWildcard bool) error {
+	if name == "" {
+		return fmt.Errorf("record name is required")
+	}
+	if strings.Contains(name, "*") {
+		if !allowWildcard {
+			return fmt.Errorf("record name %q cannot be a pattern", name)
+		}
+		if matcher.IsNetwork(name) {
+			return fmt.Errorf("record name %q is not a domain", name)
+		}
+		return matcher.Validate(name)
+	}
+	if _, ok := dns.IsDomainName(name); !ok || net.ParseIP(name) != nil {
+		return fmt.Errorf("record name %q is not a domain", name)
+	}
+	return nil
+}
+
+func (z *zone) add(rec conf.StaticRecord) {
+	r := record{qtype: recordTypes[rec.Type], value: rec.Value, ttl: uint32(rec.TTL)}
+	if rec.TTL <= 0 {
+		r.ttl = defaultTTL
+	}
+	if !strings.Contains(rec.Name, "*") {
+		z.exact[rec.Name] = append(z.exact[rec.Name], r)
+		return
+	}
+	for i := range z.wildcards {
+		if z.wildcards[i].pattern == rec.Name {
+			z.wildcards[i].records = append(z.wildcards[i].records, r)
+			return
+		}
+	}
+	z.wildcards = append(z.wildcards, wildcard{pattern: rec.Name, records: []record{r}})
+}
+
+// addReverse adds PTR records for the exact A and AAAA names that have none.
+func (z *zone) addReverse() {
+	ptrs := make(map[string][]record)
+	for name, recs := range z.exact {
+		for _, r := range recs {
+			if r.qtype != dns.TypeA && r.qtype != dns.TypeAAAA {
+				continue
+			}
+			rev, err := dns.ReverseAddr(r.value)
+			if err != nil {
+				continue
+			}
+			rev = strings.TrimSuffix(rev, ".")
+			if _, ok := z.exact[rev]; ok {
+				continue
+			}
+			ptrs[rev] = append(ptrs[rev], record{qtype: dns.TypePTR, value: name, ttl: r.ttl})
+		}
+	}
+	for rev, recs := range ptrs {
+		z.exact[rev] = recs
+	}
+}
+
+func (z *zone) find(name string) ([]record, bool) {
+	if recs, ok := z.exact[name]; ok {
+		return recs, true
+	}
+	for _, w := range z.wildcards {
+		if matcher.Match(w.pattern, name) {
+			return w.records, true
+		}
+	}
+	return nil, false
+}
+
+// lookup answers qtype for name. ok reports whether the zone knows the name at
+// all, so an empty answer means "no records of this type". CNAMEs are followed
+// through the zone; an answer ending in a CNAME points outside of it.
+func (z *zone) lookup(name string, qtype uint16) ([]dns.RR, bool) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	recs, ok := z.find(name)
+	if !ok {
+		return nil, false
+	}
+	var out []dns.RR
+	owner := name
+	for range maxCNAMEChain {
+		var cname *record
+		for i, r := range recs {
+			switch {
+			case r.qtype == qtype:
+				out = append(out, r.rr(owner))
+			case r.qtype == dns.TypeCNAME:
+				cname = &recs[i]
+			}
+		}
+		if cname == nil || qtype == dns.TypeCNAME {
+			break
+		}
+		out = append(out, cname.rr(owner))
+		owner = cname.value
+		if recs, ok = z.find(owner); !ok {
+			break
+		}
+	}
+	return out, true
+}
+
+func (r record) rr(owner string) dns.RR {
+	hdr := dns.RR_Header{Name: dns.Fqdn(owner), Rrtype: r.qtype, Class: dns.ClassINET, Ttl: r.ttl}
+	switch r.qtype {
+	case dns.TypeA:
+		return &dns.A{Hdr: hdr, A: net.ParseIP(r.value).To4()}
+	case dns.TypeAAAA:
+		return &dns.AAAA{Hdr: hdr, AAAA: net.ParseIP(r.value)}
+	case dns.TypeCNAME:
+		return &dns.CNAME{Hdr: hdr, Target: dns.Fqdn(r.value)}
+	case dns.TypePTR:
+		return &dns.PTR{Hdr: hdr, Ptr: dns.Fqdn(r.value)}
+	default:
+		return &dns.TXT{Hdr: hdr, Txt: splitTXT(r.value)}
+	}
+}
+
+// splitTXT cuts text into the 255-byte strings a TXT record is made of.
+func splitTXT(s string) []string {
+	var out []string
+	for len(s) > 255 {
+		out = append(out, s[:255])
+		s = s[255:]
+	}
+	return append(out, s)
+}
+
+// parseHosts reads an /etc/hosts style file into A and AAAA records.
+func parseHosts(r io.Reader) ([]conf.StaticRecord, error) {
+	var out []conf.StaticRecord
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		ip := net.ParseIP(fields[0])
+		if ip == nil {
+			continue
+		}
+		typ := "AAAA"
+		if ip.To4() != nil {
+			typ = "A"
+		}
+		for _, name := range fields[1:] {
+			rec, err := Normalize(conf.StaticRecord{Name: name, Type: typ, Value: ip.String()})
+			if err != nil {
+				continue
+			}
+			out = append(out, rec)
+		}
+	}
+	return out, sc.Err()
+}
@@ -0,0 +1,97 @@
+package localzone
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ApostolDmitry/vpner/internal/conf"
+	"github.com/miekg/dns"
+)
+
+func buildZone(t *testing.T, recs ...conf.StaticRecord) *zone {
+	t.Helper()
+	z := newZone()
+	for _, raw := range recs {
+		rec, err := Normalize(raw)
+		if err != nil {
+			t.Fatalf("Normalize(%+v): %v", raw, err)
+		}
+		z.add(rec)
+	}
+	z.addReverse()
+	return z
+}
+
+func TestZoneLookup(t *testing.T) {
+	z := buildZone(t,
+		conf.StaticRecord{Name: "NAS.home.", Type: "a", Value: "192.168.1.10"},
+		conf.StaticRecord{Name: "nas.home", Type: "AAAA", Value: "fd00::10"},
+		conf.StaticRecord{Name: "files.home", Type: "CNAME", Value: "nas.home"},
+		conf.StaticRecord{Name: "ext.home", Type: "CNAME", Value: "example.com"},
+		conf.StaticRecord{Name: "*.lab.home", Type: "A", Value: "192.168.1.20"},
+		conf.StaticRecord{Name: "nas.home", Type: "TXT", Value: "hello"},
+	)
+
+	rrs, ok := z.lookup("nas.home.", dns.TypeA)
+	if !ok || len(rrs) != 1 || rrs[0].(*dns.A).A.String() != "192.168.1.10" {
+		t.Fatalf("nas.home A: %v %v", rrs, ok)
+	}
+	if rrs, ok := z.lookup("nas.home", dns.TypeMX); !ok || len(rrs) != 0 {
+		t.Fatalf("nas.home MX should be an empty local answer: %v %v", rrs, ok)
+	}
+
+	rrs, ok = z.lookup("files.home", dns.TypeAAAA)
+	if !ok || len(rrs) != 2 {
+		t.Fatalf("files.home AAAA: %v", rrs)
+	}
+	if _, isCNAME := rrs[0].(*dns.CNAME); !isCNAME || rrs[1].Header().Name != "nas.home." {
+		t.Fatalf("expected CNAME then nas.home, got %v", rrs)
+	}
+
+	rrs, _ = z.lookup("ext.home", dns.TypeA)
+	if len(rrs) != 1 || rrs[0].(*dns.CNAME).Target != "example.com." {
+		t.Fatalf("ext.home should end in an outside CNAME: %v", rrs)
+	}
+
+	if rrs, ok := z.lookup("a.b.lab.home", dns.TypeA); !ok || len(rrs) != 1 || rrs[0].Header().Name != "a.b.lab.home." {
+		t.Fatalf("wildcard: %v %v", rrs, ok)
+	}
+	if _, ok := z.lookup("example.com", dns.TypeA); ok {
+		t.Fatal("unknown name answered locally")
+	}
+
+	rrs, _ = z.lookup("10.1.168.192.in-addr.arpa", dns.TypePTR)
+	if len(rrs) != 1 || rrs[0].(*dns.PTR).Ptr != "nas.home." {
+		t.Fatalf("reverse: %v", rrs)
+	}
+}
+
+func TestNormalizeRejectsBadRecords(t *testing.T) {
+	bad := []conf.StaticRecord{
+		{Name: "x.home", Type: "MX", Value: "mail.home"},
+		{Name: "x.home", Type: "A", Value: "fd00::1"},
+		{Name: "x.home", Type: "AAAA", Value: "10.0.0.1"},
+		{Name: "x.home", Type: "CNAME", Value: ""},
+		{Name: "a*b.home", Type: "A", Value: "10.0.0.1"},
+		{Name: "*.home", Type: "PTR", Value: "x.home"},
+	}
+	for _, rec := range bad {
+		if _, err := Normalize(rec); err == nil {
+			t.Errorf("Normalize(%+v) accepted a bad record", rec)
+		}
+	}
+	rec, err := Normalize(conf.StaticRecord{Name: "10.0.0.1", Type: "ptr", Value: "x.home."})
+	if err != nil || rec.Name != "1.0.0.10.in-addr.arpa" || rec.Value != "x.home" {
+		t.Fatalf("PTR by address: %+v %v", rec, err)
+	}
+}
+
+func TestParseHosts(t *testing.T) {
+	recs, err := parseHosts(strings.NewReader("# comment\n192.168.1.5 router router.home # gw\nfd00::5 router6\nbogus line\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 3 || recs[1].Name != "router.home" || recs[2].Type != "AAAA" {
+		t.Fatalf("unexpected records %+v", recs)
+	}
+}
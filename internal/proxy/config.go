@@ -1,11 +1,24 @@
 package proxy
 
-import "encoding/json"
+import (
+	"encoding/json"
 
-func renderConfig(l *Link, inboundPort int, tproxy bool) ([]byte, jobj, error) {
+	"github.com/ApostolDmitry/vpner/internal/chainpolicy"
+)
+
+type renderOptions struct {
+	tproxy       bool
+	udp          chainpolicy.UDPPolicy
+	outboundMark int
+	socksPort    int
+	fakeIP       bool
+}
+
+func renderConfig(l *Link, inboundPort int, opts renderOptions) ([]byte, jobj, error) {
 	outbound := buildOutbound(l)
+	setOutboundMark(outbound, opts.outboundMark)
 	cfg := jobj{
-		"inbounds":  []jobj{buildInbound(inboundPort, tproxy)},
+		"inbounds":  buildAllInbounds(inboundPort, opts),
 		"outbounds": []jobj{outbound},
 	}
 	data, err := json.MarshalIndent(cfg, "", "  ")
@@ -15,6 +28,31 @@ func renderConfig(l *Link, inboundPort int, tproxy bool) ([]byte, jobj, error) {
 	return data, outbound, nil
 }
 
+func buildAllInbounds(port int, opts renderOptions) []jobj {
+	inbounds := buildInbounds(port, opts.tproxy, opts.udp)
+	if opts.fakeIP {
+		for _, in := range inbounds {
+			in["sniffing"] = jobj{
+				"enabled":      true,
+				"destOverride": []string{"http", "tls", "quic"},
+				"routeOnly":    false,
+			}
+		}
+	}
+	return appendSocksInbound(inbounds, opts.socksPort)
+}
+
+func buildInbounds(port int, tproxy bool, udp chainpolicy.UDPPolicy) []jobj {
+	if tproxy || udp != chainpolicy.UDPTProxyUDPOnly {
+		return []jobj{buildInbound(port, tproxy)}
+	}
+	tcpIn := buildInbound(port, false)
+	tcpIn["settings"].(jobj)["network"] = "tcp"
+	udpIn := buildInbound(port, true)
+	udpIn["settings"].(jobj)["network"] = "udp"
+	return []jobj{tcpIn, udpIn}
+}
+
 func buildInbound(port int, tproxy bool) jobj {
 	in := jobj{
 		"port":     port,
@@ -38,6 +76,38 @@ func buildInbound(port int, tproxy bool) jobj {
 	return in
 }
 
+// appendSocksInbound adds a loopback SOCKS inbound that vpnerd uses to send
+// its own DNS queries out through the chain.
+func appendSocksInbound(inbounds []jobj, port int) []jobj {
+	if port <= 0 {
+		return inbounds
+	}
+	return append(inbounds, jobj{
+		"tag":      "vpner-socks",
+		"listen":   "127.0.0.1",
+		"port":     port,
+		"protocol": "socks",
+		"settings": jobj{"auth": "noauth", "udp": false},
+	})
+}
+
+func setOutboundMark(ob jobj, mark int) {
+	if mark == 0 {
+		return
+	}
+	stream, _ := ob["streamSettings"].(jobj)
+	if stream == nil {
+		stream = jobj{}
+		ob["streamSettings"] = stream
+	}
+	sockopt, _ := stream["sockopt"].(jobj)
+	if sockopt == nil {
+		sockopt = jobj{}
+		stream["sockopt"] = sockopt
+	}
+	sockopt["mark"] = mark
+}
+
 func normalizeVLESSEncryption(outbounds []jobj) {
 	for _, ob := range outbounds {
 		if proto, _ := ob["protocol"].(string); proto != "vless" {
@@ -13,6 +13,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ApostolDmitry/vpner/internal/chainpolicy"
 	"github.com/ApostolDmitry/vpner/internal/logx"
 )
 
@@ -28,12 +29,20 @@ type ChainInfo struct {
 	Port        int    `json:"port"`
 	AutoRun     bool   `json:"auto_run"`
 	InboundPort int    `json:"inbound_port"`
+	SocksPort   int    `json:"socks_port,omitempty"`
+
+	KillSwitch chainpolicy.KillSwitch `json:"kill_switch"`
+	UDPPolicy  chainpolicy.UDPPolicy  `json:"udp_policy"`
 }
 
 type Manager struct {
 	mu            sync.RWMutex
 	store         *store
 	tproxyEnabled bool
+	outboundMark  int
+	runGroup      int
+	socksInbound  bool
+	fakeIP        bool
 }
 
 func New(tproxyEnabled bool) (*Manager, error) {
@@ -50,6 +59,33 @@ func newManager(dir string, tproxyEnabled bool) (*Manager, error) {
 	return m, nil
 }
 
+func (x *Manager) SetLocalBypass(mark, gid int) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.outboundMark = mark
+	x.runGroup = gid
+}
+
+// SetSocksInbound makes every chain expose a loopback SOCKS inbound, allocated
+// the next time the chain starts.
+func (x *Manager) SetSocksInbound(enabled bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.socksInbound = enabled
+}
+
+// SetFakeIP makes chains override the destination of every sniffed
+// connection, since with fake-ip the address it was opened to is synthetic.
+func (x *Manager) SetFakeIP(enabled bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.fakeIP = enabled
+}
+
+func (x *Manager) renderOptions(udp chainpolicy.UDPPolicy) renderOptions {
+	return renderOptions{tproxy: x.tproxyEnabled, udp: udp, outboundMark: x.outboundMark, fakeIP: x.fakeIP}
+}
+
 func (x *Manager) Create(link string, autoRun bool) (string, error) {
 	x.mu.Lock()
 	defer x.mu.Unlock()
@@ -65,7 +101,7 @@ func (x *Manager) Create(link string, autoRun bool) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	data, outbound, err := renderConfig(parsed, port, x.tproxyEnabled)
+	data, outbound, err := renderConfig(parsed, port, x.renderOptions(chainpolicy.UDPLeak))
 	if err != nil {
 		return "", err
 	}
@@ -76,7 +112,7 @@ func (x *Manager) Create(link string, autoRun bool) (string, error) {
 	}
 
 	name := x.uniqueName()
-	if err := x.write(name, link, parsed, port, autoRun, data); err != nil {
+	if err := x.write(name, link, parsed, port, &chainMeta{AutoRun: autoRun}, data); err != nil {
 		return "", err
 	}
 	return name, nil
@@ -104,7 +140,7 @@ func (x *Manager) Update(name, link string) error {
 			return err
 		}
 	}
-	data, outbound, err := renderConfig(parsed, port, x.tproxyEnabled)
+	data, outbound, err := renderConfig(parsed, port, x.renderOptions(meta.udpPolicy()))
 	if err != nil {
 		return err
 	}
@@ -113,7 +149,7 @@ func (x *Manager) Update(name, link string) error {
 	} else if dup {
 		return fmt.Errorf("duplicate configuration exists")
 	}
-	return x.write(name, link, parsed, port, meta.AutoRun, data)
+	return x.write(name, link, parsed, port, meta, data)
 }
 
 func (x *Manager) Delete(name string) error {
@@ -137,15 +173,42 @@ func (x *Manager) SetAutoRun(name string, autoRun bool) error {
 	return x.store.writeMeta(name, meta)
 }
 
-func (x *Manager) write(name, link string, l *Link, port int, autoRun bool, configJSON []byte) error {
-	meta := &chainMeta{
-		Link:        link,
-		Protocol:    string(l.Protocol),
-		Address:     l.Address,
-		Port:        l.Port,
-		InboundPort: port,
-		AutoRun:     autoRun,
+func (x *Manager) SetKillSwitch(name string, mode chainpolicy.KillSwitch) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	meta, err := x.store.readMeta(name)
+	if err != nil {
+		return notFound(name, err)
+	}
+	if meta.KillSwitch == mode.String() {
+		return nil
+	}
+	meta.KillSwitch = mode.String()
+	return x.store.writeMeta(name, meta)
+}
+
+func (x *Manager) SetUDPPolicy(name string, policy chainpolicy.UDPPolicy) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	meta, err := x.store.readMeta(name)
+	if err != nil {
+		return notFound(name, err)
+	}
+	if meta.udpPolicy() == policy {
+		return nil
 	}
+	meta.UDPPolicy = policy.String()
+	return x.store.writeMeta(name, meta)
+}
+
+func (x *Manager) write(name, link string, l *Link, port int, meta *chainMeta, configJSON []byte) error {
+	meta.Link = link
+	meta.Protocol = string(l.Protocol)
+	meta.Address = l.Address
+	meta.Port = l.Port
+	meta.InboundPort = port
 	if err := x.store.writeMeta(name, meta); err != nil {
 		return fmt.Errorf("failed to write metadata: %w", err)
 	}
@@ -166,6 +229,12 @@ func (x *Manager) Start(ctx context.Context, name string) error {
 	}
 
 	cmd := exec.CommandContext(ctx, "xray", "run", "-config", path)
+	x.mu.RLock()
+	runGroup := x.runGroup
+	x.mu.RUnlock()
+	if runGroup > 0 {
+		setRunGroup(cmd, runGroup)
+	}
 	prefix := fmt.Sprintf("xray-%s", name)
 	cmd.Stdout = logx.NewStreamWriter(prefix, logx.LevelInfo)
 	cmd.Stderr = logx.NewStreamWriter(prefix, logx.LevelWarn)
@@ -190,25 +259,38 @@ func (x *Manager) prepareConfig(name string) (string, error) {
 		return "", notFound(name, err)
 	}
 
+	if x.socksInbound && meta.SocksPort == 0 {
+		if meta.SocksPort, err = x.findFreePort(); err != nil {
+			return "", err
+		}
+		if err := x.store.writeMeta(name, meta); err != nil {
+			return "", err
+		}
+	}
+	opts := x.renderOptions(meta.udpPolicy())
+	if x.socksInbound {
+		opts.socksPort = meta.SocksPort
+	}
+
 	if meta.Link != "" {
 		parsed, err := ParseLink(meta.Link)
 		if err != nil {
 			return "", err
 		}
-		data, _, err := renderConfig(parsed, meta.InboundPort, x.tproxyEnabled)
+		data, _, err := renderConfig(parsed, meta.InboundPort, opts)
 		if err != nil {
 			return "", err
 		}
 		if err := x.store.writeConfig(name, data); err != nil {
 			return "", err
 		}
-	} else if err := x.refreshLegacyConfig(name, meta); err != nil {
+	} else if err := x.refreshLegacyConfig(name, meta, opts); err != nil {
 		return "", err
 	}
 	return x.store.configPath(name), nil
 }
 
-func (x *Manager) refreshLegacyConfig(name string, meta *chainMeta) error {
+func (x *Manager) refreshLegacyConfig(name string, meta *chainMeta, opts renderOptions) error {
 	_, outbounds, err := x.store.readConfigParts(name)
 	if err != nil {
 		return err
@@ -217,8 +299,11 @@ func (x *Manager) refreshLegacyConfig(name string, meta *chainMeta) error {
 		return fmt.Errorf("config %s has no outbounds", name)
 	}
 	normalizeVLESSEncryption(outbounds)
+	for _, ob := range outbounds {
+		setOutboundMark(ob, opts.outboundMark)
+	}
 	cfg := jobj{
-		"inbounds":  []jobj{buildInbound(meta.InboundPort, x.tproxyEnabled)},
+		"inbounds":  buildAllInbounds(meta.InboundPort, opts),
 		"outbounds": outbounds,
 	}
 	data, err := json.MarshalIndent(cfg, "", "  ")
@@ -262,7 +347,7 @@ func (x *Manager) Infos() (map[string]ChainInfo, error) {
 	out := make(map[string]ChainInfo, len(names))
 	for _, n := range names {
 		if m, err := x.store.readMeta(n); err == nil {
-			out[n] = m.toInfo()
+			out[n] = m.toInfo(n)
 		}
 	}
 	return out, nil
@@ -276,7 +361,7 @@ func (x *Manager) Get(name string) (ChainInfo, error) {
 	if err != nil {
 		return ChainInfo{}, notFound(name, err)
 	}
-	return meta.toInfo(), nil
+	return meta.toInfo(name), nil
 }
 
 func (x *Manager) Test(name string) (string, error) {
@@ -387,15 +472,38 @@ func (x *Manager) usedPorts() map[int]bool {
 		return used
 	}
 	for _, n := range names {
-		if m, err := x.store.readMeta(n); err == nil && m.InboundPort > 0 {
+		m, err := x.store.readMeta(n)
+		if err != nil {
+			continue
+		}
+		if m.InboundPort > 0 {
 			used[m.InboundPort] = true
 		}
+		if m.SocksPort > 0 {
+			used[m.SocksPort] = true
+		}
 	}
 	return used
 }
 
 func fingerprint(ob jobj) string {
 	data, _ := json.Marshal(ob)
+	var clean jobj
+	if err := json.Unmarshal(data, &clean); err != nil {
+		return string(data)
+	}
+	if stream, ok := clean["streamSettings"].(jobj); ok {
+		if sockopt, ok := stream["sockopt"].(jobj); ok {
+			delete(sockopt, "mark")
+			if len(sockopt) == 0 {
+				delete(stream, "sockopt")
+			}
+		}
+		if len(stream) == 0 {
+			delete(clean, "streamSettings")
+		}
+	}
+	data, _ = json.Marshal(clean)
 	return string(data)
 }
 
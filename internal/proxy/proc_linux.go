@@ -0,0 +1,15 @@
+//go:build linux
+
+package proxy
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+func setRunGroup(cmd *exec.Cmd, gid int) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: uint32(os.Getuid()), Gid: uint32(gid)},
+	}
+}
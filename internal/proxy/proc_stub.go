@@ -0,0 +1,7 @@
+//go:build !linux
+
+package proxy
+
+import "os/exec"
+
+func setRunGroup(_ *exec.Cmd, _ int) {}
@@ -6,6 +6,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/ApostolDmitry/vpner/internal/chainpolicy"
 )
 
 func TestParseLinkVLESS(t *testing.T) {
@@ -100,7 +102,7 @@ func TestRenderConfigTProxy(t *testing.T) {
 	if err != nil {
 		t.Fatalf("ParseLink: %v", err)
 	}
-	data, _, err := renderConfig(l, 1080, true)
+	data, _, err := renderConfig(l, 1080, renderOptions{tproxy: true})
 	if err != nil {
 		t.Fatalf("renderConfig: %v", err)
 	}
@@ -119,11 +121,109 @@ func TestRenderConfigTProxy(t *testing.T) {
 	}
 }
 
+func TestRenderConfigUDPTProxyOnly(t *testing.T) {
+	t.Parallel()
+
+	l, err := ParseLink("vless://uuid@example.com:443?type=tcp&security=none")
+	if err != nil {
+		t.Fatalf("ParseLink: %v", err)
+	}
+	data, _, err := renderConfig(l, 1080, renderOptions{udp: chainpolicy.UDPTProxyUDPOnly})
+	if err != nil {
+		t.Fatalf("renderConfig: %v", err)
+	}
+
+	cfg := decodeConfig(t, data)
+	if len(cfg.Inbounds) != 2 {
+		t.Fatalf("expected tcp and udp inbounds, got %d", len(cfg.Inbounds))
+	}
+	tcpIn, udpIn := cfg.Inbounds[0], cfg.Inbounds[1]
+	if tcpIn["settings"].(map[string]any)["network"] != "tcp" || tcpIn["streamSettings"] != nil {
+		t.Fatalf("tcp inbound must stay on REDIRECT, got %#v", tcpIn)
+	}
+	if udpIn["settings"].(map[string]any)["network"] != "udp" || udpIn["port"] != float64(1080) {
+		t.Fatalf("unexpected udp inbound %#v", udpIn)
+	}
+	sock := udpIn["streamSettings"].(map[string]any)["sockopt"].(map[string]any)
+	if sock["tproxy"] != "tproxy" {
+		t.Fatalf("expected tproxy sockopt on udp inbound, got %#v", sock["tproxy"])
+	}
+
+	data, _, err = renderConfig(l, 1080, renderOptions{tproxy: true, udp: chainpolicy.UDPTProxyUDPOnly})
+	if err != nil {
+		t.Fatalf("renderConfig: %v", err)
+	}
+	if cfg := decodeConfig(t, data); len(cfg.Inbounds) != 1 {
+		t.Fatalf("full TPROXY mode needs a single inbound, got %d", len(cfg.Inbounds))
+	}
+}
+
+func TestRenderConfigOutboundMark(t *testing.T) {
+	t.Parallel()
+
+	l, err := ParseLink("vless://uuid@example.com:443?type=tcp&security=tls&sni=example.com")
+	if err != nil {
+		t.Fatalf("ParseLink: %v", err)
+	}
+	data, outbound, err := renderConfig(l, 1080, renderOptions{outboundMark: 0x2000})
+	if err != nil {
+		t.Fatalf("renderConfig: %v", err)
+	}
+
+	cfg := decodeConfig(t, data)
+	sock := cfg.Outbounds[0]["streamSettings"].(map[string]any)["sockopt"].(map[string]any)
+	if sock["mark"] != float64(0x2000) {
+		t.Fatalf("expected outbound sockopt mark, got %#v", sock["mark"])
+	}
+
+	_, plain, err := renderConfig(l, 1080, renderOptions{})
+	if err != nil {
+		t.Fatalf("renderConfig: %v", err)
+	}
+	if fingerprint(outbound) != fingerprint(plain) {
+		t.Fatal("bypass mark must not affect duplicate detection")
+	}
+}
+
+func TestRenderConfigSocksInbound(t *testing.T) {
+	t.Parallel()
+
+	l, _ := ParseLink("vless://uuid@example.com:443?type=tcp")
+	data, _, err := renderConfig(l, 1080, renderOptions{socksPort: 1081})
+	if err != nil {
+		t.Fatalf("renderConfig: %v", err)
+	}
+	cfg := decodeConfig(t, data)
+	if len(cfg.Inbounds) != 2 {
+		t.Fatalf("expected two inbounds, got %d", len(cfg.Inbounds))
+	}
+	socks := cfg.Inbounds[1]
+	if socks["protocol"] != "socks" || socks["listen"] != "127.0.0.1" || socks["port"] != float64(1081) {
+		t.Fatalf("unexpected socks inbound %#v", socks)
+	}
+}
+
+func TestRenderConfigFakeIPSniffing(t *testing.T) {
+	t.Parallel()
+
+	l, _ := ParseLink("vless://uuid@example.com:443?type=tcp")
+	data, _, err := renderConfig(l, 1080, renderOptions{fakeIP: true})
+	if err != nil {
+		t.Fatalf("renderConfig: %v", err)
+	}
+	cfg := decodeConfig(t, data)
+	sniffing, _ := cfg.Inbounds[0]["sniffing"].(map[string]any)
+	override, _ := sniffing["destOverride"].([]any)
+	if len(override) != 3 || override[2] != "quic" || sniffing["routeOnly"] != false {
+		t.Fatalf("unexpected sniffing %#v", sniffing)
+	}
+}
+
 func TestRenderConfigHasNoVpnerMetadata(t *testing.T) {
 	t.Parallel()
 
 	l, _ := ParseLink("vless://uuid@example.com:443?type=tcp")
-	data, outbound, err := renderConfig(l, 1080, false)
+	data, outbound, err := renderConfig(l, 1080, renderOptions{})
 	if err != nil {
 		t.Fatalf("renderConfig: %v", err)
 	}
@@ -233,6 +333,23 @@ func TestCreateAndUpdatePreservePortAndAutoRun(t *testing.T) {
 	}
 }
 
+func TestInvalidKillSwitchFailsSafe(t *testing.T) {
+	st := &store{dir: t.TempDir()}
+	if err := st.writeMeta("a", &chainMeta{Protocol: "vless", KillSwitch: "block"}); err == nil {
+		t.Fatal("unknown kill switch mode saved")
+	}
+	if err := os.WriteFile(st.metaPath("a"), []byte(`{"protocol":"vless","kill_switch":"block"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := st.readMeta("a")
+	if err != nil {
+		t.Fatalf("readMeta: %v", err)
+	}
+	if got := meta.toInfo("a").KillSwitch; got != chainpolicy.KillSwitchReject {
+		t.Fatalf("unknown stored mode read as %q", got)
+	}
+}
+
 type testConfig struct {
 	Inbounds  []map[string]any `json:"inbounds"`
 	Outbounds []map[string]any `json:"outbounds"`
@@ -2,10 +2,14 @@ package proxy
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+
+	"github.com/ApostolDmitry/vpner/internal/chainpolicy"
+	"github.com/ApostolDmitry/vpner/internal/logx"
 )
 
 const (
@@ -20,7 +24,10 @@ type chainMeta struct {
 	Address     string `json:"address"`
 	Port        int    `json:"port"`
 	InboundPort int    `json:"inbound_port"`
+	SocksPort   int    `json:"socks_port,omitempty"`
 	AutoRun     bool   `json:"auto_run"`
+	KillSwitch  string `json:"kill_switch,omitempty"`
+	UDPPolicy   string `json:"udp_policy,omitempty"`
 }
 
 type store struct {
@@ -49,6 +56,9 @@ func (s *store) readMeta(name string) (*chainMeta, error) {
 }
 
 func (s *store) writeMeta(name string, m *chainMeta) error {
+	if _, err := chainpolicy.ParseKillSwitch(m.KillSwitch); err != nil {
+		return fmt.Errorf("chain %s: %w", name, err)
+	}
 	data, err := json.MarshalIndent(m, "", "  ")
 	if err != nil {
 		return err
@@ -120,14 +130,32 @@ func (s *store) chains() ([]string, error) {
 	return names, nil
 }
 
-func (m *chainMeta) toInfo() ChainInfo {
+// toInfo falls back to rejecting traffic when the stored kill switch mode is
+// unknown: a damaged file must not turn the kill switch off.
+func (m *chainMeta) toInfo(name string) ChainInfo {
+	killSwitch, err := chainpolicy.ParseKillSwitch(m.KillSwitch)
+	if err != nil {
+		logx.Warnf("chain %s: %v; using %s", name, err, chainpolicy.KillSwitchReject)
+		killSwitch = chainpolicy.KillSwitchReject
+	}
 	return ChainInfo{
 		Type:        m.Protocol,
 		Host:        m.Address,
 		Port:        m.Port,
 		AutoRun:     m.AutoRun,
 		InboundPort: m.InboundPort,
+		SocksPort:   m.SocksPort,
+		KillSwitch:  killSwitch,
+		UDPPolicy:   m.udpPolicy(),
+	}
+}
+
+func (m *chainMeta) udpPolicy() chainpolicy.UDPPolicy {
+	policy, err := chainpolicy.ParseUDPPolicy(m.UDPPolicy)
+	if err != nil {
+		return chainpolicy.UDPLeak
 	}
+	return policy
 }
 
 func atomicWrite(path string, data []byte, perm os.FileMode) error {
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ApostolDmitry/vpner/internal/chainpolicy"
 	"github.com/ApostolDmitry/vpner/internal/logx"
 	proxy "github.com/ApostolDmitry/vpner/internal/proxy"
 )
@@ -16,6 +18,7 @@ type procEntry struct {
 	startedAt time.Time
 	restarts  int
 	lastExit  string
+	alive     atomic.Bool
 }
 
 type Service struct {
@@ -128,9 +131,11 @@ func (x *Service) supervise(ctx context.Context, name string, entry *procEntry,
 	backoff := x.baseBackoff
 	first := true
 	for {
+		entry.alive.Store(true)
 		runStart := time.Now()
 		err := x.start(ctx, name)
 		ran := time.Since(runStart)
+		entry.alive.Store(false)
 
 		if first {
 			first = false
@@ -195,6 +200,7 @@ func (x *Service) IsRunning(name string) bool {
 
 type ChainRuntime struct {
 	Running  bool
+	Alive    bool
 	Restarts int
 	LastExit string
 	Uptime   time.Duration
@@ -208,6 +214,7 @@ func (x *Service) Runtimes() map[string]ChainRuntime {
 	for name, e := range x.process {
 		out[name] = ChainRuntime{
 			Running:  true,
+			Alive:    e.alive.Load(),
 			Restarts: e.restarts,
 			LastExit: e.lastExit,
 			Uptime:   now.Sub(e.startedAt),
@@ -240,6 +247,14 @@ func (x *Service) SetAutorun(name string, autoRun bool) error {
 	return x.manager.SetAutoRun(name, autoRun)
 }
 
+func (x *Service) SetKillSwitch(name string, mode chainpolicy.KillSwitch) error {
+	return x.manager.SetKillSwitch(name, mode)
+}
+
+func (x *Service) SetUDPPolicy(name string, policy chainpolicy.UDPPolicy) error {
+	return x.manager.SetUDPPolicy(name, policy)
+}
+
 func (x *Service) IsChain(name string) bool {
 	return x.manager.IsChain(name)
 }
@@ -110,3 +110,29 @@ func TestStopReplacedChainKeepsNewProcess(t *testing.T) {
 	}
 	svc.StopOne("c")
 }
+
+func TestRuntimeAliveClearedDuringBackoff(t *testing.T) {
+	svc := newTestService(func(ctx context.Context, name string) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+		return fmt.Errorf("crash")
+	})
+	svc.baseBackoff = 300 * time.Millisecond
+	svc.maxBackoff = 300 * time.Millisecond
+
+	if err := svc.StartOne("c"); err != nil {
+		t.Fatalf("StartOne: %v", err)
+	}
+	defer svc.StopOne("c")
+
+	if rt := svc.Runtimes()["c"]; !rt.Alive {
+		t.Fatal("chain should be alive while its process runs")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if rt := svc.Runtimes()["c"]; !rt.Running || rt.Alive {
+		t.Fatalf("chain in restart backoff should be running but not alive, got %+v", rt)
+	}
+}
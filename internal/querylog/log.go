@@ -0,0 +1,231 @@
+package querylog
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ApostolDmitry/vpner/internal/conf"
+	"github.com/ApostolDmitry/vpner/internal/logx"
+)
+
+const (
+	defaultSize      = 1000
+	defaultMaxSizeKB = 1024
+	defaultMaxFiles  = 3
+	subscriberBuffer = 256
+	queueSize        = 1024
+)
+
+// Entry is one answered DNS query.
+type Entry struct {
+	Time     time.Time     `json:"time"`
+	Client   string        `json:"client"`
+	Name     string        `json:"name"`
+	Type     string        `json:"type"`
+	Rcode    string        `json:"rcode"`
+	Answers  []string      `json:"answers,omitempty"`
+	Source   string        `json:"source"`
+	Upstream string        `json:"upstream,omitempty"`
+	Cached   bool          `json:"cached,omitempty"`
+	Rule     string        `json:"rule,omitempty"`
+	Chain    string        `json:"chain,omitempty"`
+	Latency  time.Duration `json:"latency_ns"`
+}
+
+// Filter selects entries; empty fields match everything. Domain matches any
+// part of the name.
+type Filter struct {
+	Client string
+	Domain string
+	Type   string
+	Rcode  string
+	Source string
+}
+
+func (f Filter) Match(e Entry) bool {
+	if f.Client != "" && f.Client != e.Client {
+		return false
+	}
+	if f.Domain != "" && !strings.Contains(e.Name, strings.ToLower(strings.TrimSuffix(f.Domain, "."))) {
+		return false
+	}
+	if f.Type != "" && !strings.EqualFold(f.Type, e.Type) {
+		return false
+	}
+	if f.Rcode != "" && !strings.EqualFold(f.Rcode, e.Rcode) {
+		return false
+	}
+	if f.Source != "" && !strings.EqualFold(f.Source, e.Source) {
+		return false
+	}
+	return true
+}
+
+// Log keeps the latest queries in a ring buffer, copies them to an optional
+// rotating file and fans them out to followers. Entries are recorded by a
+// single writer goroutine so Add never holds up a DNS reply.
+type Log struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+	subs    map[chan Entry]struct{}
+	file    *rotator
+	tag     func(*Entry)
+
+	queue     chan Entry
+	dropped   atomic.Uint64
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// New returns nil when cfg.Size is negative.
+func New(cfg conf.QueryLogConfig) *Log {
+	if cfg.Size < 0 {
+		return nil
+	}
+	size := cfg.Size
+	if size == 0 {
+		size = defaultSize
+	}
+	l := &Log{
+		entries: make([]Entry, size),
+		subs:    make(map[chan Entry]struct{}),
+		queue:   make(chan Entry, queueSize),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if cfg.Path != "" {
+		maxSize := cfg.MaxSizeKB
+		if maxSize <= 0 {
+			maxSize = defaultMaxSizeKB
+		}
+		maxFiles := cfg.MaxFiles
+		if maxFiles <= 0 {
+			maxFiles = defaultMaxFiles
+		}
+		l.file = newRotator(cfg.Path, int64(maxSize)*1024, maxFiles)
+	}
+	go l.run()
+	return l
+}
+
+// SetTagger makes the writer call tag on every entry before recording it. It
+// must be called before the first Add.
+func (l *Log) SetTagger(tag func(*Entry)) {
+	l.tag = tag
+}
+
+// Add queues e for the writer. When the writer falls behind the entry is
+// dropped instead of blocking the caller.
+func (l *Log) Add(e Entry) {
+	select {
+	case <-l.stop:
+	case l.queue <- e:
+	default:
+		l.dropped.Add(1)
+	}
+}
+
+func (l *Log) run() {
+	defer close(l.done)
+	for {
+		select {
+		case e := <-l.queue:
+			l.record(e)
+		case <-l.stop:
+			for {
+				select {
+				case e := <-l.queue:
+					l.record(e)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (l *Log) record(e Entry) {
+	if n := l.dropped.Swap(0); n > 0 {
+		logx.Debugf("query log: dropped %d entries while the writer was busy", n)
+	}
+	if l.tag != nil {
+		l.tag(&e)
+	}
+
+	l.mu.Lock()
+	l.entries[l.next] = e
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+	for ch := range l.subs {
+		select {
+		case ch <- e:
+		default:
+			// A slow follower misses entries rather than stalling queries.
+		}
+	}
+	l.mu.Unlock()
+
+	if l.file != nil {
+		if err := l.file.write(e); err != nil {
+			logx.Debugf("query log write: %v", err)
+		}
+	}
+}
+
+// Tail returns up to limit of the newest entries matching f, oldest first.
+// A limit of 0 returns all of them.
+func (l *Log) Tail(f Filter, limit int) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []Entry
+	n := l.next
+	if l.full {
+		n = len(l.entries)
+	}
+	for i := 0; i < n && (limit <= 0 || len(out) < limit); i++ {
+		idx := (l.next - 1 - i + len(l.entries)) % len(l.entries)
+		if f.Match(l.entries[idx]) {
+			out = append(out, l.entries[idx])
+		}
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// Subscribe delivers every new entry until cancel is called.
+func (l *Log) Subscribe() (<-chan Entry, func()) {
+	ch := make(chan Entry, subscriberBuffer)
+	l.mu.Lock()
+	l.subs[ch] = struct{}{}
+	l.mu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			l.mu.Lock()
+			delete(l.subs, ch)
+			l.mu.Unlock()
+		})
+	}
+}
+
+// Close records the queued entries, stops the writer and closes the file.
+// Tail keeps working afterwards.
+func (l *Log) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.stop)
+		<-l.done
+	})
+	if l.file == nil {
+		return nil
+	}
+	return l.file.close()
+}
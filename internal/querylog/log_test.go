@@ -0,0 +1,119 @@
+package querylog
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ApostolDmitry/vpner/internal/conf"
+)
+
+func TestTailWrapsAndFilters(t *testing.T) {
+	l := New(conf.QueryLogConfig{Size: 3})
+	for _, name := range []string{"a.example", "b.example", "c.test", "d.example"} {
+		l.Add(Entry{Name: name, Client: "192.168.1.5", Type: "A"})
+	}
+	l.Close()
+	got := l.Tail(Filter{}, 0)
+	if len(got) != 3 || got[0].Name != "b.example" || got[2].Name != "d.example" {
+		t.Fatalf("unexpected ring contents %+v", got)
+	}
+	got = l.Tail(Filter{Domain: "Example."}, 1)
+	if len(got) != 1 || got[0].Name != "d.example" {
+		t.Fatalf("filtered tail %+v", got)
+	}
+	if got := l.Tail(Filter{Client: "192.168.1.6"}, 0); len(got) != 0 {
+		t.Fatalf("client filter %+v", got)
+	}
+	if New(conf.QueryLogConfig{Size: -1}) != nil {
+		t.Fatal("negative size should disable the log")
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	l := New(conf.QueryLogConfig{})
+	ch, cancel := l.Subscribe()
+	l.Add(Entry{Name: "example.com"})
+	select {
+	case e := <-ch:
+		if e.Name != "example.com" {
+			t.Fatalf("got %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscriber got nothing")
+	}
+	cancel()
+	cancel()
+	l.Add(Entry{Name: "example.org"})
+	l.Close()
+	select {
+	case e := <-ch:
+		t.Fatalf("cancelled subscriber got %+v", e)
+	default:
+	}
+}
+
+func TestFileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.log")
+	l := New(conf.QueryLogConfig{Path: path, MaxSizeKB: 1, MaxFiles: 2})
+	for i := 0; i < 50; i++ {
+		l.Add(Entry{Name: "rotation.example", Client: "192.168.1.5", Type: "AAAA", Rcode: "NOERROR", Source: "upstream"})
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+		if info.Size() > 1024 {
+			t.Fatalf("%s grew to %d bytes", p, info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("more than max-files kept: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if sc := bufio.NewScanner(f); !sc.Scan() || sc.Text()[0] != '{' {
+		t.Fatal("log file does not hold JSON lines")
+	}
+}
+
+func TestAddDropsWhenWriterIsBusy(t *testing.T) {
+	l := New(conf.QueryLogConfig{Size: 2 * queueSize})
+	release := make(chan struct{})
+	l.SetTagger(func(e *Entry) {
+		<-release
+		e.Rule = "tagged"
+	})
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 2*queueSize; i++ {
+			l.Add(Entry{Name: "busy.example"})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Add blocked on a busy writer")
+	}
+	close(release)
+	l.Close()
+
+	got := l.Tail(Filter{}, 0)
+	if len(got) == 0 || len(got) > queueSize+1 {
+		t.Fatalf("recorded %d entries, want 1..%d", len(got), queueSize+1)
+	}
+	if got[0].Rule != "tagged" {
+		t.Fatalf("entry not tagged: %+v", got[0])
+	}
+}
@@ -11,18 +11,45 @@ import (
 const (
 	answerCacheDefaultMax = 4096
 	answerCacheTTLCap     = 3600
+
+	// staleAnswerTTL is the TTL of expired answers served while the upstream
+	// is failing (RFC 8767 section 4).
+	staleAnswerTTL = 30
+
+	defaultServeStale   = 24 * time.Hour
+	defaultPrefetchHits = 3
 )
 
+// CacheStats counts answer cache lookups since the server started.
+type CacheStats struct {
+	Entries  int
+	Hits     uint64
+	Misses   uint64
+	Stale    uint64
+	Prefetch uint64
+}
+
 type answerCache struct {
 	mu      sync.Mutex
 	entries map[string]*cachedAnswer
 	max     int
+	// staleFor keeps expired answers around to serve when the upstream
+	// fails; 0 drops them on expiry.
+	staleFor time.Duration
+	// prefetchHits is how often an answer must be asked for before it is
+	// refreshed ahead of expiry; 0 disables prefetching.
+	prefetchHits int
+	stats        CacheStats
+	// gen counts stored answers; savedGen is the gen last written to disk.
+	gen, savedGen uint64
 }
 
 type cachedAnswer struct {
-	msg      *dns.Msg
-	storedAt time.Time
-	ttl      time.Duration
+	msg         *dns.Msg
+	storedAt    time.Time
+	ttl         time.Duration
+	hits        int
+	prefetching bool
 }
 
 func newAnswerCache(max int) *answerCache {
@@ -36,7 +63,17 @@ func cacheKey(q dns.Question) string {
 	return strings.ToLower(q.Name) + "|" + dns.Type(q.Qtype).String() + "|" + dns.Class(q.Qclass).String()
 }
 
+// get returns a fresh answer for req.
 func (c *answerCache) get(req *dns.Msg) *dns.Msg {
+	return c.lookup(req, true)
+}
+
+// peek is get without counting the lookup.
+func (c *answerCache) peek(req *dns.Msg) *dns.Msg {
+	return c.lookup(req, false)
+}
+
+func (c *answerCache) lookup(req *dns.Msg, count bool) *dns.Msg {
 	if len(req.Question) != 1 {
 		return nil
 	}
@@ -45,15 +82,27 @@ func (c *answerCache) get(req *dns.Msg) *dns.Msg {
 	c.mu.Lock()
 	e, ok := c.entries[key]
 	if !ok {
+		if count {
+			c.stats.Misses++
+		}
 		c.mu.Unlock()
 		return nil
 	}
 	elapsed := time.Since(e.storedAt)
 	if elapsed >= e.ttl {
-		delete(c.entries, key)
+		if elapsed >= e.ttl+c.staleFor {
+			delete(c.entries, key)
+		}
+		if count {
+			c.stats.Misses++
+		}
 		c.mu.Unlock()
 		return nil
 	}
+	if count {
+		c.stats.Hits++
+		e.hits++
+	}
 	resp := e.msg.Copy()
 	c.mu.Unlock()
 
@@ -63,6 +112,79 @@ func (c *answerCache) get(req *dns.Msg) *dns.Msg {
 	return resp
 }
 
+// claimPrefetch reports whether the answer to req is popular and close enough
+// to expiry to be refreshed in the background. Only one caller gets true until
+// the answer is replaced or prefetched is called.
+func (c *answerCache) claimPrefetch(req *dns.Msg) bool {
+	if c.prefetchHits <= 0 || len(req.Question) != 1 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[cacheKey(req.Question[0])]
+	if !ok || e.prefetching || e.hits < c.prefetchHits {
+		return false
+	}
+	left := e.ttl - time.Since(e.storedAt)
+	if left <= 0 || left > prefetchWindow(e.ttl) {
+		return false
+	}
+	e.prefetching = true
+	c.stats.Prefetch++
+	return true
+}
+
+// prefetchWindow is how long before expiry a popular answer is refreshed:
+// the last tenth of its TTL, but at least a couple of seconds.
+func prefetchWindow(ttl time.Duration) time.Duration {
+	w := ttl / 10
+	if w < 2*time.Second {
+		w = 2 * time.Second
+	}
+	return w
+}
+
+// prefetched clears the prefetch mark of req's answer so a failed refresh can
+// be retried by a later query.
+func (c *answerCache) prefetched(req *dns.Msg) {
+	if len(req.Question) != 1 {
+		return
+	}
+	c.mu.Lock()
+	if e, ok := c.entries[cacheKey(req.Question[0])]; ok {
+		e.prefetching = false
+	}
+	c.mu.Unlock()
+}
+
+// stale returns an expired answer for req that is still within the serve-stale
+// window, with every TTL set to staleAnswerTTL.
+func (c *answerCache) stale(req *dns.Msg) *dns.Msg {
+	if len(req.Question) != 1 || c.staleFor <= 0 {
+		return nil
+	}
+	c.mu.Lock()
+	e, ok := c.entries[cacheKey(req.Question[0])]
+	if !ok || time.Since(e.storedAt) >= e.ttl+c.staleFor {
+		c.mu.Unlock()
+		return nil
+	}
+	c.stats.Stale++
+	resp := e.msg.Copy()
+	c.mu.Unlock()
+
+	for _, section := range [][]dns.RR{resp.Answer, resp.Ns, resp.Extra} {
+		for _, rr := range section {
+			if h := rr.Header(); h.Rrtype != dns.TypeOPT {
+				h.Ttl = staleAnswerTTL
+			}
+		}
+	}
+	resp.Id = req.Id
+	resp.Question = req.Question
+	return resp
+}
+
 func (c *answerCache) put(resp *dns.Msg) {
 	if len(resp.Question) != 1 || resp.Rcode != dns.RcodeSuccess || len(resp.Answer) == 0 {
 		return
@@ -76,23 +198,44 @@ func (c *answerCache) put(resp *dns.Msg) {
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if len(c.entries) >= c.max {
+	key := cacheKey(resp.Question[0])
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.max {
 		c.evictLocked()
 	}
-	c.entries[cacheKey(resp.Question[0])] = &cachedAnswer{
+	c.entries[key] = &cachedAnswer{
 		msg:      stored,
 		storedAt: time.Now(),
 		ttl:      time.Duration(ttl) * time.Second,
 	}
+	c.gen++
 }
 
+func (c *answerCache) snapshot() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.stats
+	st.Entries = len(c.entries)
+	return st
+}
+
+// evictLocked drops an answer past its serve-stale window, then any expired
+// one, then an arbitrary one.
 func (c *answerCache) evictLocked() {
 	now := time.Now()
+	var expired string
 	for k, e := range c.entries {
-		if now.Sub(e.storedAt) >= e.ttl {
+		age := now.Sub(e.storedAt)
+		if age >= e.ttl+c.staleFor {
 			delete(c.entries, k)
 			return
 		}
+		if expired == "" && age >= e.ttl {
+			expired = k
+		}
+	}
+	if expired != "" {
+		delete(c.entries, expired)
+		return
 	}
 	for k := range c.entries {
 		delete(c.entries, k)
@@ -2,9 +2,11 @@ package resolver
 
 import (
 	"net"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/ApostolDmitry/vpner/internal/conf"
 	"github.com/miekg/dns"
 )
 
@@ -132,6 +134,87 @@ func TestAnswerCacheExpires(t *testing.T) {
 	}
 }
 
+func TestAnswerCacheServesStaleWithinWindow(t *testing.T) {
+	c := newAnswerCache(16)
+	c.staleFor = time.Minute
+	c.put(positiveA("stale.com", 10))
+	age := func(d time.Duration) {
+		c.mu.Lock()
+		for _, e := range c.entries {
+			e.storedAt = time.Now().Add(-d)
+		}
+		c.mu.Unlock()
+	}
+
+	q := new(dns.Msg)
+	q.SetQuestion("stale.com.", dns.TypeA)
+	if c.stale(q) == nil {
+		t.Fatal("fresh entry should also be usable as stale data")
+	}
+	age(30 * time.Second)
+	if c.get(q) != nil {
+		t.Fatal("expired entry must miss")
+	}
+	got := c.stale(q)
+	if got == nil || got.Answer[0].Header().Ttl != staleAnswerTTL {
+		t.Fatalf("expected a stale answer with TTL %d, got %v", staleAnswerTTL, got)
+	}
+	age(2 * time.Minute)
+	if c.stale(q) != nil {
+		t.Fatal("entry past the stale window served")
+	}
+
+	st := c.snapshot()
+	if st.Stale != 2 || st.Misses != 1 {
+		t.Fatalf("unexpected counters %+v", st)
+	}
+}
+
+func TestAnswerCachePrefetchesPopularEntries(t *testing.T) {
+	c := newAnswerCache(16)
+	c.prefetchHits = 2
+	c.put(positiveA("popular.com", 100))
+	q := new(dns.Msg)
+	q.SetQuestion("popular.com.", dns.TypeA)
+
+	c.get(q)
+	c.get(q)
+	if c.claimPrefetch(q) {
+		t.Fatal("prefetch claimed long before expiry")
+	}
+	c.mu.Lock()
+	for _, e := range c.entries {
+		e.storedAt = time.Now().Add(-95 * time.Second)
+	}
+	c.mu.Unlock()
+	if !c.claimPrefetch(q) {
+		t.Fatal("popular entry about to expire not prefetched")
+	}
+	if c.claimPrefetch(q) {
+		t.Fatal("prefetch claimed twice")
+	}
+	c.prefetched(q)
+	if !c.claimPrefetch(q) {
+		t.Fatal("failed prefetch cannot be retried")
+	}
+
+	c.put(positiveA("rare.com", 100))
+	rare := new(dns.Msg)
+	rare.SetQuestion("rare.com.", dns.TypeA)
+	c.get(rare)
+	c.mu.Lock()
+	for _, e := range c.entries {
+		e.storedAt = time.Now().Add(-95 * time.Second)
+	}
+	c.mu.Unlock()
+	if c.claimPrefetch(rare) {
+		t.Fatal("rarely asked entry prefetched")
+	}
+	if st := c.snapshot(); st.Prefetch != 2 || st.Hits != 3 {
+		t.Fatalf("unexpected counters %+v", st)
+	}
+}
+
 func TestRateLimiterBurstThenLimit(t *testing.T) {
 	l := newRateLimiter(5)
 	allowed := 0
@@ -147,3 +230,65 @@ func TestRateLimiterBurstThenLimit(t *testing.T) {
 		t.Fatal("a fresh source IP must start with a full bucket")
 	}
 }
+
+func TestServerTracePrefersCache(t *testing.T) {
+	s := &Server{cache: newAnswerCache(16)}
+	s.cache.put(positiveA("example.com", 100))
+
+	got := s.Trace("example.com", dns.TypeA)
+	if got.Source != TraceSourceCache || got.Err != nil || len(got.Answers) != 1 || got.Answers[0] != "1.2.3.4" {
+		t.Fatalf("unexpected trace %+v", got)
+	}
+
+	s.customRules = []compiledResolverRule{{Upstream: "127.0.0.1:1", Pattern: "*.lan"}}
+	s.customTimeout = 10 * time.Millisecond
+	if got := s.Trace("nas.lan", dns.TypeA); got.Source != TraceSourceCustom || got.Server != "127.0.0.1:1" {
+		t.Fatalf("expected custom-resolve trace, got %+v", got)
+	}
+}
+
+func TestServerServesStaleOnFailureRcodes(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rcode atomic.Int32
+	srv := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetRcode(req, int(rcode.Load()))
+		_ = w.WriteMsg(resp)
+	})}
+	go func() { _ = srv.ActivateAndServe() }()
+	defer srv.Shutdown()
+
+	up := NewUpstream(conf.UpstreamConfig{})
+	defer up.Close()
+	s := NewServer(conf.ServerConfig{
+		MaxConcurrentConn: 1,
+		ServeStale:        60,
+		CustomResolve:     map[string][]string{pc.LocalAddr().String(): {"*.example.com"}},
+	}, nil, up)
+	s.customTimeout = 2 * time.Second
+	s.cache.put(positiveA("www.example.com", 10))
+	s.cache.mu.Lock()
+	for _, e := range s.cache.entries {
+		e.storedAt = time.Now().Add(-30 * time.Second)
+	}
+	s.cache.mu.Unlock()
+	var logged entries
+	s.SetQueryLog(&logged)
+
+	for _, rc := range []int{dns.RcodeServerFailure, dns.RcodeRefused} {
+		rcode.Store(int32(rc))
+		r := new(dns.Msg)
+		r.SetQuestion("www.example.com.", dns.TypeA)
+		w := &recordingWriter{}
+		s.handleDNSRequest(w, r)
+		if w.msg == nil || w.msg.Rcode != dns.RcodeSuccess || len(w.msg.Answer) != 1 {
+			t.Fatalf("%s: expected the stale answer, got %v", dns.RcodeToString[rc], w.msg)
+		}
+		if e := logged[len(logged)-1]; e.Source != QuerySourceStale {
+			t.Fatalf("%s: logged source %q", dns.RcodeToString[rc], e.Source)
+		}
+	}
+}
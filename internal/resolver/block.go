@@ -0,0 +1,71 @@
+package resolver
+
+import (
+	"net"
+	"strings"
+
+	"github.com/ApostolDmitry/vpner/internal/logx"
+	"github.com/miekg/dns"
+)
+
+const (
+	BlockModeNXDomain = "nxdomain"
+	BlockModeZero     = "zero"
+	BlockModeRefused  = "refused"
+
+	blockedTTL = 300
+)
+
+// Blocker reports the name of the list that blocks a domain.
+type Blocker interface {
+	Blocked(domain string) (string, bool)
+}
+
+// SetBlocker makes the server refuse to resolve domains on the blocker's
+// lists, answering as configured in blocklist.mode.
+func (s *Server) SetBlocker(b Blocker) {
+	s.blocker = b
+}
+
+func parseBlockMode(mode string) string {
+	switch m := strings.ToLower(mode); m {
+	case "":
+		return BlockModeNXDomain
+	case BlockModeNXDomain, BlockModeZero, BlockModeRefused:
+		return m
+	default:
+		logx.Errorf("unknown blocklist mode %q, using %s", mode, BlockModeNXDomain)
+		return BlockModeNXDomain
+	}
+}
+
+func (s *Server) blockedAnswer(r *dns.Msg, domain string) (*dns.Msg, string) {
+	if s.blocker == nil || domain == "" {
+		return nil, ""
+	}
+	list, ok := s.blocker.Blocked(domain)
+	if !ok {
+		return nil, ""
+	}
+	m := new(dns.Msg)
+	switch s.blockMode {
+	case BlockModeRefused:
+		m.SetRcode(r, dns.RcodeRefused)
+	case BlockModeZero:
+		m.SetReply(r)
+		if len(r.Question) == 1 {
+			q := r.Question[0]
+			hdr := dns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: dns.ClassINET, Ttl: blockedTTL}
+			switch q.Qtype {
+			case dns.TypeA:
+				m.Answer = append(m.Answer, &dns.A{Hdr: hdr, A: net.IPv4zero.To4()})
+			case dns.TypeAAAA:
+				m.Answer = append(m.Answer, &dns.AAAA{Hdr: hdr, AAAA: net.IPv6zero})
+			}
+		}
+	default:
+		m.SetRcode(r, dns.RcodeNameError)
+	}
+	m.RecursionAvailable = true
+	return m, list
+}
@@ -0,0 +1,52 @@
+package resolver
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ApostolDmitry/vpner/internal/conf"
+	"github.com/miekg/dns"
+)
+
+type stubBlocker map[string]string
+
+func (b stubBlocker) Blocked(domain string) (string, bool) {
+	list, ok := b[domain]
+	return list, ok
+}
+
+func TestBlockedAnswerModes(t *testing.T) {
+	query := func(mode string, name string, qtype uint16) (*dns.Msg, string) {
+		s := NewServer(conf.ServerConfig{Blocklist: conf.BlocklistConfig{Mode: mode}}, nil, nil)
+		s.SetBlocker(stubBlocker{"ads.test": "ads"})
+		r := new(dns.Msg)
+		r.SetQuestion(dns.Fqdn(name), qtype)
+		return s.blockedAnswer(r, name)
+	}
+
+	if resp, _ := query("", "fine.test", dns.TypeA); resp != nil {
+		t.Fatalf("unblocked domain answered with %v", resp)
+	}
+	resp, list := query("", "ads.test", dns.TypeA)
+	if resp == nil || resp.Rcode != dns.RcodeNameError || list != "ads" {
+		t.Fatalf("expected NXDOMAIN from ads, got %v (%q)", resp, list)
+	}
+	if resp, _ := query("refused", "ads.test", dns.TypeA); resp == nil || resp.Rcode != dns.RcodeRefused {
+		t.Fatalf("expected REFUSED, got %v", resp)
+	}
+
+	resp, _ = query("zero", "ads.test", dns.TypeA)
+	if resp == nil || resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 1 {
+		t.Fatalf("expected one zero answer, got %v", resp)
+	}
+	if a, ok := resp.Answer[0].(*dns.A); !ok || !a.A.Equal(net.IPv4zero) {
+		t.Fatalf("unexpected answer %v", resp.Answer[0])
+	}
+	resp, _ = query("zero", "ads.test", dns.TypeAAAA)
+	if aaaa, ok := resp.Answer[0].(*dns.AAAA); !ok || !aaaa.AAAA.Equal(net.IPv6zero) {
+		t.Fatalf("unexpected answer %v", resp.Answer[0])
+	}
+	if resp, _ := query("zero", "ads.test", dns.TypeMX); resp == nil || len(resp.Answer) != 0 || resp.Rcode != dns.RcodeSuccess {
+		t.Fatalf("expected empty NOERROR for MX, got %v", resp)
+	}
+}
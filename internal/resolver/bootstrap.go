@@ -19,19 +19,22 @@ func (r *Upstream) dialContext(ctx context.Context, network, addr string) (net.C
 	if err != nil {
 		return nil, err
 	}
+	if r.proxyDial != nil {
+		return r.proxyDial(ctx, network, addr)
+	}
 	dialer := &net.Dialer{Timeout: secs(r.config.DialTimeout)}
 
 	if net.ParseIP(host) != nil {
 		return dialer.DialContext(ctx, network, addr)
 	}
 
-	ips, err := r.resolveHost(host)
+	ips, err := r.dialIPs(host)
 	if err != nil {
 		return nil, err
 	}
 
 	var lastErr error
-	for _, ip := range r.sortIPsForDial(ips) {
+	for _, ip := range ips {
 		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
 		if err == nil {
 			return conn, nil
@@ -44,6 +47,19 @@ func (r *Upstream) dialContext(ctx context.Context, network, addr string) (net.C
 	return nil, fmt.Errorf("all bootstrap IPs failed for %s: %w", host, lastErr)
 }
 
+// dialIPs returns the addresses to try for host, resolving names through the
+// bootstrap resolvers.
+func (r *Upstream) dialIPs(host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	ips, err := r.resolveHost(host)
+	if err != nil {
+		return nil, err
+	}
+	return r.sortIPsForDial(ips), nil
+}
+
 func (r *Upstream) sortIPsForDial(ips []net.IP) []net.IP {
 	out := cloneIPs(ips)
 	rand.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
@@ -74,6 +74,7 @@ func (r *Upstream) storeCache(host string, ips []net.IP, ttl time.Duration) {
 		ExpiresAt: now.Add(ttl),
 		StaleAt:   now.Add(ttl + secs(r.config.StaleTTL)),
 	}
+	r.cacheGen++
 }
 
 func (r *Upstream) evictExpiredLocked(now time.Time) {
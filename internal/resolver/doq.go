@@ -0,0 +1,171 @@
+package resolver
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// RFC 9250 error codes.
+const (
+	doqNoError          = 0x0
+	doqRequestCancelled = 0x3
+)
+
+// doqClient keeps one QUIC connection per DoQ server and sends every query on
+// a stream of its own.
+type doqClient struct {
+	host    string
+	port    int
+	tls     *tls.Config
+	timeout time.Duration
+	resolve func(host string) ([]net.IP, error)
+
+	mu   sync.Mutex
+	conn *quic.Conn
+}
+
+func newDoqClient(addr string, tlsConf *tls.Config, timeout time.Duration, resolve func(string) ([]net.IP, error)) (*doqClient, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", portStr)
+	}
+	tlsConf = tlsConf.Clone()
+	tlsConf.NextProtos = []string{"doq"}
+	tlsConf.MinVersion = tls.VersionTLS13
+	return &doqClient{host: host, port: port, tls: tlsConf, timeout: timeout, resolve: resolve}, nil
+}
+
+func (c *doqClient) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	conn, fresh, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := doqExchange(ctx, conn, query)
+	if err != nil && !fresh && ctx.Err() == nil {
+		c.drop(conn)
+		if conn, _, err = c.get(ctx); err != nil {
+			return nil, err
+		}
+		return doqExchange(ctx, conn, query)
+	}
+	return resp, err
+}
+
+func (c *doqClient) get(ctx context.Context) (*quic.Conn, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil && c.conn.Context().Err() == nil {
+		return c.conn, false, nil
+	}
+	ips, err := c.resolve(c.host)
+	if err != nil {
+		return nil, false, err
+	}
+	var lastErr error
+	for _, ip := range ips {
+		conn, err := c.dial(ctx, ip)
+		if err == nil {
+			c.conn = conn
+			return conn, true, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no bootstrap IPs available")
+	}
+	return nil, false, lastErr
+}
+
+func (c *doqClient) dial(ctx context.Context, ip net.IP) (*quic.Conn, error) {
+	pc, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := quic.Dial(ctx, pc, &net.UDPAddr{IP: ip, Port: c.port}, c.tls, &quic.Config{
+		HandshakeIdleTimeout: c.timeout,
+		MaxIdleTimeout:       30 * time.Second,
+	})
+	if err != nil {
+		_ = pc.Close()
+		return nil, err
+	}
+	context.AfterFunc(conn.Context(), func() { _ = pc.Close() })
+	return conn, nil
+}
+
+func (c *doqClient) drop(conn *quic.Conn) {
+	_ = conn.CloseWithError(doqNoError, "")
+	c.mu.Lock()
+	if c.conn == conn {
+		c.conn = nil
+	}
+	c.mu.Unlock()
+}
+
+func (c *doqClient) close() {
+	c.mu.Lock()
+	conn := c.conn
+	c.conn = nil
+	c.mu.Unlock()
+	if conn != nil {
+		_ = conn.CloseWithError(doqNoError, "")
+	}
+}
+
+func doqExchange(ctx context.Context, conn *quic.Conn, query []byte) ([]byte, error) {
+	if len(query) < dnsHeaderLen {
+		return nil, errors.New("short dns query")
+	}
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = stream.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		stream.CancelRead(doqRequestCancelled)
+		stream.CancelWrite(doqRequestCancelled)
+	})
+	defer stop()
+
+	// DoQ requires the message ID to be 0 on the wire.
+	origID := binary.BigEndian.Uint16(query)
+	msg := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(msg, uint16(len(query)))
+	copy(msg[2:], query)
+	binary.BigEndian.PutUint16(msg[2:], 0)
+	if _, err := stream.Write(msg); err != nil {
+		return nil, err
+	}
+	// Closing the send side tells the server no more queries follow.
+	_ = stream.Close()
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(stream, header); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(header))
+	if _, err := io.ReadFull(stream, resp); err != nil {
+		return nil, err
+	}
+	if len(resp) < dnsHeaderLen {
+		return nil, errors.New("short DoQ response")
+	}
+	binary.BigEndian.PutUint16(resp, origID)
+	return resp, nil
+}
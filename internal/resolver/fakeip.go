@@ -0,0 +1,70 @@
+package resolver
+
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// fakeIPTTL is kept short so clients come back soon after the mode or the
+// rule changes.
+const fakeIPTTL = 60
+
+// FakeIPs hands out synthetic addresses for domains routed through a chain
+// that recovers the real destination by sniffing. ok is false for domains
+// that resolve normally; a nil address with ok set means the domain has no
+// address of that family. LookupFakeIP answers the same question without
+// allocating, so a domain with no address yet reports a nil one.
+type FakeIPs interface {
+	FakeIP(domain string, ipv6 bool) (net.IP, bool)
+	LookupFakeIP(domain string, ipv6 bool) (net.IP, bool)
+}
+
+// SetFakeIPs makes the server answer A and AAAA queries of matching domains
+// with addresses from the pool instead of resolving them.
+func (s *Server) SetFakeIPs(f FakeIPs) {
+	s.fakeIPs = f
+}
+
+// fakeAnswer answers r from the fake-ip pool. With lookup set no address is
+// allocated, which keeps traces from filling the pool.
+func (s *Server) fakeAnswer(r *dns.Msg, domain string, lookup bool) *dns.Msg {
+	if s.fakeIPs == nil || domain == "" || len(r.Question) != 1 {
+		return nil
+	}
+	q := r.Question[0]
+	if q.Qclass != dns.ClassINET {
+		return nil
+	}
+	switch q.Qtype {
+	case dns.TypeA, dns.TypeAAAA, dns.TypeHTTPS, dns.TypeSVCB:
+	default:
+		return nil
+	}
+	get := s.fakeIPs.FakeIP
+	if lookup {
+		get = s.fakeIPs.LookupFakeIP
+	}
+	ip, ok := get(domain, q.Qtype == dns.TypeAAAA)
+	if !ok {
+		return nil
+	}
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.RecursionAvailable = true
+	if ip == nil {
+		return m
+	}
+	hdr := dns.RR_Header{Name: q.Name, Class: dns.ClassINET, Ttl: fakeIPTTL}
+	switch q.Qtype {
+	case dns.TypeA:
+		hdr.Rrtype = dns.TypeA
+		m.Answer = append(m.Answer, &dns.A{Hdr: hdr, A: ip.To4()})
+	case dns.TypeAAAA:
+		hdr.Rrtype = dns.TypeAAAA
+		m.Answer = append(m.Answer, &dns.AAAA{Hdr: hdr, AAAA: ip.To16()})
+	}
+	// HTTPS and SVCB get an empty answer: their address hints would bypass
+	// the fake address.
+	return m
+}
@@ -0,0 +1,93 @@
+package resolver
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ApostolDmitry/vpner/internal/conf"
+	"github.com/miekg/dns"
+)
+
+type stubFakeIPs map[string]net.IP
+
+func (f stubFakeIPs) FakeIP(domain string, ipv6 bool) (net.IP, bool) {
+	ip, ok := f[domain]
+	if !ok {
+		return nil, false
+	}
+	if (ip.To4() == nil) != ipv6 {
+		return nil, true
+	}
+	return ip, true
+}
+
+func (f stubFakeIPs) LookupFakeIP(domain string, ipv6 bool) (net.IP, bool) {
+	return f.FakeIP(domain, ipv6)
+}
+
+// lazyFakeIPs allocates on FakeIP only, like the real pool.
+type lazyFakeIPs struct{ allocated map[string]net.IP }
+
+func (f *lazyFakeIPs) FakeIP(domain string, ipv6 bool) (net.IP, bool) {
+	if f.allocated[domain] == nil {
+		f.allocated[domain] = net.ParseIP("198.18.0.1")
+	}
+	return f.allocated[domain], true
+}
+
+func (f *lazyFakeIPs) LookupFakeIP(domain string, ipv6 bool) (net.IP, bool) {
+	return f.allocated[domain], true
+}
+
+func TestTraceDoesNotAllocateFakeIP(t *testing.T) {
+	s := NewServer(conf.ServerConfig{}, nil, nil)
+	fake := &lazyFakeIPs{allocated: map[string]net.IP{}}
+	s.SetFakeIPs(fake)
+
+	got := s.Trace("example.com", dns.TypeA)
+	if got.Source != TraceSourceFakeIP || len(got.Answers) != 0 {
+		t.Fatalf("unexpected trace %+v", got)
+	}
+	if len(fake.allocated) != 0 {
+		t.Fatalf("trace allocated %v", fake.allocated)
+	}
+
+	fake.FakeIP("example.com", false)
+	got = s.Trace("example.com", dns.TypeA)
+	if len(got.Answers) != 1 || got.Answers[0] != "198.18.0.1" {
+		t.Fatalf("trace missed the allocated address: %+v", got)
+	}
+}
+
+func TestFakeAnswer(t *testing.T) {
+	s := NewServer(conf.ServerConfig{}, nil, nil)
+	s.SetFakeIPs(stubFakeIPs{"example.com": net.ParseIP("198.18.0.1")})
+
+	query := func(name string, qtype uint16) *dns.Msg {
+		r := new(dns.Msg)
+		r.SetQuestion(dns.Fqdn(name), qtype)
+		return s.fakeAnswer(r, name, false)
+	}
+
+	resp := query("example.com", dns.TypeA)
+	if resp == nil || len(resp.Answer) != 1 {
+		t.Fatalf("expected a fake A answer, got %v", resp)
+	}
+	a, ok := resp.Answer[0].(*dns.A)
+	if !ok || !a.A.Equal(net.ParseIP("198.18.0.1")) || a.Hdr.Ttl != fakeIPTTL {
+		t.Fatalf("unexpected answer %v", resp.Answer[0])
+	}
+
+	for _, qtype := range []uint16{dns.TypeAAAA, dns.TypeHTTPS} {
+		resp := query("example.com", qtype)
+		if resp == nil || resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 0 {
+			t.Fatalf("expected an empty answer for %s, got %v", dns.TypeToString[qtype], resp)
+		}
+	}
+	if resp := query("example.com", dns.TypeMX); resp != nil {
+		t.Fatalf("MX should be resolved normally, got %v", resp)
+	}
+	if resp := query("example.org", dns.TypeA); resp != nil {
+		t.Fatalf("unmatched domain answered with %v", resp)
+	}
+}
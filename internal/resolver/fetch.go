@@ -0,0 +1,45 @@
+package resolver
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Fetch downloads rawURL with the host resolved through the bootstrap
+// resolvers, so it works while the router itself uses vpnerd for DNS.
+func (r *Upstream) Fetch(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.fetchClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("fetch %s: %s", rawURL, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func (r *Upstream) fetchClient() *http.Client {
+	r.fetchOnce.Do(func() {
+		transport := &http.Transport{
+			DialContext:           r.dialContext,
+			ForceAttemptHTTP2:     true,
+			TLSHandshakeTimeout:   secs(r.config.TLSHandshakeTimeout),
+			ResponseHeaderTimeout: secs(r.config.HTTPTimeout),
+			IdleConnTimeout:       30 * time.Second,
+		}
+		if r.config.InsecureSkipVerify {
+			transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		}
+		r.fetcher = &http.Client{Transport: transport}
+	})
+	return r.fetcher
+}
@@ -19,7 +19,7 @@ import (
 
 func (r *Upstream) ForwardQuery(query []byte) ([]byte, error) {
 	if len(r.servers) == 0 {
-		return nil, errors.New("no DoH servers configured")
+		return nil, errors.New("no upstream servers configured")
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), secs(r.config.HTTPTimeout))
@@ -36,7 +36,7 @@ func (r *Upstream) ForwardQuery(query []byte) ([]byte, error) {
 	for _, s := range servers {
 		go func(s *upstreamState) {
 			start := time.Now()
-			resp, err := r.forwardToServer(ctx, s.server, query)
+			resp, err := r.forwardToServer(ctx, s, query)
 			r.updateServerStat(s, time.Since(start), err)
 			select {
 			case ch <- result{server: s, resp: resp, err: err}:
@@ -50,18 +50,47 @@ func (r *Upstream) ForwardQuery(query []byte) ([]byte, error) {
 		select {
 		case res := <-ch:
 			if res.err == nil {
-				logx.Debugf("doh server %s won race", res.server.server)
+				logx.Debugf("upstream %s won race", res.server.server)
 				return res.resp, nil
 			}
 			errs = append(errs, fmt.Sprintf("%s: %v", res.server.server, res.err))
 		case <-ctx.Done():
 			if len(errs) > 0 {
-				return nil, fmt.Errorf("doh timeout: %s", strings.Join(errs, "; "))
+				return nil, fmt.Errorf("upstream timeout: %s", strings.Join(errs, "; "))
 			}
 			return nil, ctx.Err()
 		}
 	}
-	return nil, fmt.Errorf("all DoH servers failed: %s", strings.Join(errs, "; "))
+	return nil, fmt.Errorf("all upstream servers failed: %s", strings.Join(errs, "; "))
+}
+
+// ExchangeWith sends query to a single target, such as a custom-resolve
+// upstream, keeping one pooled client and health counters per target. A target
+// without a scheme is a plain UDP resolver.
+func (r *Upstream) ExchangeWith(target string, query []byte, timeout time.Duration) ([]byte, error) {
+	s := r.target(target)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	resp, err := r.forwardToServer(ctx, s, query)
+	r.updateServerStat(s, time.Since(start), err)
+	return resp, err
+}
+
+func (r *Upstream) target(target string) *upstreamState {
+	r.targetsMu.Lock()
+	defer r.targetsMu.Unlock()
+	if s, ok := r.targets[target]; ok {
+		return s
+	}
+	server := target
+	if !strings.Contains(server, "://") {
+		server = protoUDP + "://" + server
+	}
+	s := r.newServerState(server)
+	s.server = target
+	r.targets[target] = s
+	return s
 }
 
 func (r *Upstream) orderServers() []*upstreamState {
@@ -98,7 +127,10 @@ func (r *Upstream) updateServerStat(s *upstreamState, latency time.Duration, err
 	s.lastError = time.Now()
 }
 
-func (r *Upstream) forwardToServer(ctx context.Context, serverURL string, query []byte) ([]byte, error) {
+func (r *Upstream) forwardToServer(ctx context.Context, s *upstreamState, query []byte) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
 	select {
 	case r.reqSem <- struct{}{}:
 		defer func() { <-r.reqSem }()
@@ -106,6 +138,31 @@ func (r *Upstream) forwardToServer(ctx context.Context, serverURL string, query
 		return nil, ctx.Err()
 	}
 
+	var (
+		resp []byte
+		err  error
+	)
+	switch s.proto {
+	case protoDoT, protoTCP:
+		resp, err = s.stream.exchange(ctx, query)
+	case protoUDP:
+		resp, err = s.udp.exchange(ctx, query)
+	case protoDoQ:
+		resp, err = s.doq.exchange(ctx, query)
+	default:
+		return r.forwardDoH(ctx, s.server, query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var msg dns.Msg
+	if err := msg.Unpack(resp); err != nil {
+		return nil, fmt.Errorf("invalid dns message from %s: %w", s.server, err)
+	}
+	return resp, nil
+}
+
+func (r *Upstream) forwardDoH(ctx context.Context, serverURL string, query []byte) ([]byte, error) {
 	u, err := url.Parse(serverURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid DoH url %q: %w", serverURL, err)
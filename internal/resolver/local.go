@@ -0,0 +1,76 @@
+package resolver
+
+import (
+	"strings"
+
+	"github.com/ApostolDmitry/vpner/internal/logx"
+	"github.com/miekg/dns"
+)
+
+// LocalRecords answers names defined on the router itself. ok reports whether
+// the name is local; an answer ending in a CNAME points outside the local
+// records.
+type LocalRecords interface {
+	Lookup(name string, qtype uint16) ([]dns.RR, bool)
+}
+
+// SetLocalRecords makes the server answer local names itself, ahead of
+// blocklists, custom resolvers and the upstream.
+func (s *Server) SetLocalRecords(l LocalRecords) {
+	s.local = l
+}
+
+func (s *Server) localAnswer(r *dns.Msg, domain string) *dns.Msg {
+	if s.local == nil || domain == "" || len(r.Question) != 1 {
+		return nil
+	}
+	q := r.Question[0]
+	if q.Qclass != dns.ClassINET {
+		return nil
+	}
+	rrs, ok := s.local.Lookup(domain, q.Qtype)
+	if !ok {
+		return nil
+	}
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+	m.RecursionAvailable = true
+	m.Answer = rrs
+	if n := len(rrs); n > 0 && q.Qtype != dns.TypeCNAME {
+		if cname, ok := rrs[n-1].(*dns.CNAME); ok {
+			m.Authoritative = false
+			m.Answer = append(m.Answer, s.resolveTarget(cname.Target, q.Qtype)...)
+		}
+	}
+	return m
+}
+
+// resolveTarget looks up the target of a local CNAME that points outside the
+// local records.
+func (s *Server) resolveTarget(target string, qtype uint16) []dns.RR {
+	if s.resolver == nil {
+		return nil
+	}
+	req := new(dns.Msg)
+	req.SetQuestion(target, qtype)
+	req.RecursionDesired = true
+	packed, err := req.Pack()
+	if err != nil {
+		return nil
+	}
+	raw, _, fallback, err := s.forward(strings.TrimSuffix(target, "."), packed)
+	if err != nil {
+		logx.Debugf("resolving local CNAME target %s: %v", target, err)
+		return nil
+	}
+	if fallback {
+		// Left to the client, so the target resolves through its chain.
+		return nil
+	}
+	resp := new(dns.Msg)
+	if err := resp.Unpack(raw); err != nil {
+		return nil
+	}
+	return resp.Answer
+}
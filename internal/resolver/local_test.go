@@ -0,0 +1,48 @@
+package resolver
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ApostolDmitry/vpner/internal/conf"
+	"github.com/miekg/dns"
+)
+
+type stubLocal map[string][]dns.RR
+
+func (l stubLocal) Lookup(name string, qtype uint16) ([]dns.RR, bool) {
+	rrs, ok := l[name]
+	if !ok {
+		return nil, false
+	}
+	var out []dns.RR
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == qtype {
+			out = append(out, rr)
+		}
+	}
+	return out, true
+}
+
+func TestLocalAnswer(t *testing.T) {
+	s := NewServer(conf.ServerConfig{}, nil, nil)
+	s.SetLocalRecords(stubLocal{"nas.home": {
+		&dns.A{Hdr: dns.RR_Header{Name: "nas.home.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300}, A: net.ParseIP("192.168.1.10")},
+	}})
+
+	query := func(name string, qtype uint16) *dns.Msg {
+		r := new(dns.Msg)
+		r.SetQuestion(dns.Fqdn(name), qtype)
+		return s.localAnswer(r, name)
+	}
+	resp := query("nas.home", dns.TypeA)
+	if resp == nil || !resp.Authoritative || len(resp.Answer) != 1 {
+		t.Fatalf("expected an authoritative local answer, got %v", resp)
+	}
+	if resp := query("nas.home", dns.TypeAAAA); resp == nil || resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 0 {
+		t.Fatalf("expected NODATA for AAAA, got %v", resp)
+	}
+	if resp := query("example.com", dns.TypeA); resp != nil {
+		t.Fatalf("non-local name answered with %v", resp)
+	}
+}
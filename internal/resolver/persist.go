@@ -0,0 +1,226 @@
+package resolver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const cacheStateVersion = 1
+
+type cacheState struct {
+	Version int           `json:"version"`
+	Answers []savedAnswer `json:"answers,omitempty"`
+	Hosts   []savedHost   `json:"hosts,omitempty"`
+}
+
+// savedAnswer is a cached response in wire format with its original TTL and
+// the time it expires.
+type savedAnswer struct {
+	Msg     []byte `json:"msg"`
+	TTL     int64  `json:"ttl"`
+	Expires int64  `json:"expires"`
+}
+
+type savedHost struct {
+	Host    string   `json:"host"`
+	IPs     []string `json:"ips"`
+	Expires int64    `json:"expires"`
+	Stale   int64    `json:"stale"`
+}
+
+// SaveCache writes the answer cache and the bootstrap host cache to path,
+// replacing the previous file atomically. Nothing is written when neither
+// changed since the last save or load.
+func (s *Server) SaveCache(path string) error {
+	st := cacheState{Version: cacheStateVersion}
+	var (
+		answerGen, hostGen uint64
+		changed            bool
+	)
+	if s.cache != nil {
+		var dirty bool
+		st.Answers, answerGen, dirty = s.cache.export()
+		changed = changed || dirty
+	}
+	if s.resolver != nil {
+		var dirty bool
+		st.Hosts, hostGen, dirty = s.resolver.exportHosts()
+		changed = changed || dirty
+	}
+	if !changed {
+		return nil
+	}
+
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if s.cache != nil {
+		s.cache.markSaved(answerGen)
+	}
+	if s.resolver != nil {
+		s.resolver.markHostsSaved(hostGen)
+	}
+	return nil
+}
+
+// LoadCache restores the entries saved by SaveCache that have not expired, or
+// are still within the serve-stale window, and returns how many it restored.
+// A missing file or one written by another version is ignored.
+func (s *Server) LoadCache(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read dns cache: %w", err)
+	}
+	var st cacheState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return 0, fmt.Errorf("parse dns cache %s: %w", path, err)
+	}
+	if st.Version != cacheStateVersion {
+		return 0, nil
+	}
+	n := 0
+	if s.cache != nil {
+		n += s.cache.restore(st.Answers)
+	}
+	if s.resolver != nil {
+		n += s.resolver.restoreHosts(st.Hosts)
+	}
+	return n, nil
+}
+
+// export returns the answers worth keeping, the generation they reflect and
+// whether that generation has not been saved yet.
+func (c *answerCache) export() ([]savedAnswer, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	out := make([]savedAnswer, 0, len(c.entries))
+	for _, e := range c.entries {
+		if now.Sub(e.storedAt) >= e.ttl+c.staleFor {
+			continue
+		}
+		wire, err := e.msg.Pack()
+		if err != nil {
+			continue
+		}
+		out = append(out, savedAnswer{
+			Msg:     wire,
+			TTL:     int64(e.ttl / time.Second),
+			Expires: e.storedAt.Add(e.ttl).Unix(),
+		})
+	}
+	return out, c.gen, c.gen != c.savedGen
+}
+
+func (c *answerCache) markSaved(gen uint64) {
+	c.mu.Lock()
+	c.savedGen = gen
+	c.mu.Unlock()
+}
+
+// restore adds saved answers that are still usable without replacing fresher
+// ones already in the cache.
+func (c *answerCache) restore(saved []savedAnswer) int {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, sa := range saved {
+		if len(c.entries) >= c.max {
+			break
+		}
+		ttl := time.Duration(sa.TTL) * time.Second
+		storedAt := time.Unix(sa.Expires, 0).Add(-ttl)
+		if ttl <= 0 || now.Sub(storedAt) >= ttl+c.staleFor {
+			continue
+		}
+		msg := new(dns.Msg)
+		if err := msg.Unpack(sa.Msg); err != nil || len(msg.Question) != 1 {
+			continue
+		}
+		key := cacheKey(msg.Question[0])
+		if _, ok := c.entries[key]; ok {
+			continue
+		}
+		c.entries[key] = &cachedAnswer{msg: msg, storedAt: storedAt, ttl: ttl}
+		n++
+	}
+	return n
+}
+
+func (r *Upstream) exportHosts() ([]savedHost, uint64, bool) {
+	r.cacheMu.RLock()
+	defer r.cacheMu.RUnlock()
+	now := time.Now()
+	out := make([]savedHost, 0, len(r.cache))
+	for host, e := range r.cache {
+		if !now.Before(e.StaleAt) || len(e.IPs) == 0 {
+			continue
+		}
+		sh := savedHost{Host: host, Expires: e.ExpiresAt.Unix(), Stale: e.StaleAt.Unix()}
+		for _, ip := range e.IPs {
+			sh.IPs = append(sh.IPs, ip.String())
+		}
+		out = append(out, sh)
+	}
+	return out, r.cacheGen, r.cacheGen != r.cacheSavedGen
+}
+
+func (r *Upstream) markHostsSaved(gen uint64) {
+	r.cacheMu.Lock()
+	r.cacheSavedGen = gen
+	r.cacheMu.Unlock()
+}
+
+func (r *Upstream) restoreHosts(saved []savedHost) int {
+	now := time.Now()
+	r.cacheMu.Lock()
+	defer r.cacheMu.Unlock()
+	n := 0
+	for _, sh := range saved {
+		if len(r.cache) >= r.config.MaxCacheEntries {
+			break
+		}
+		staleAt := time.Unix(sh.Stale, 0)
+		if !now.Before(staleAt) {
+			continue
+		}
+		if _, ok := r.cache[sh.Host]; ok {
+			continue
+		}
+		var ips []net.IP
+		for _, raw := range sh.IPs {
+			if ip := net.ParseIP(raw); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+		if len(ips) == 0 {
+			continue
+		}
+		r.cache[sh.Host] = cachedEntry{IPs: ips, ExpiresAt: time.Unix(sh.Expires, 0), StaleAt: staleAt}
+		n++
+	}
+	return n
+}
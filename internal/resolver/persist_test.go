@@ -0,0 +1,64 @@
+package resolver
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ApostolDmitry/vpner/internal/conf"
+	"github.com/miekg/dns"
+)
+
+func TestCachePersistsAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dnscache.json")
+	up := NewUpstream(conf.UpstreamConfig{})
+	defer up.Close()
+	up.storeCache("dns.example", []net.IP{net.ParseIP("9.9.9.9")}, time.Hour)
+
+	s := NewServer(conf.ServerConfig{ServeStale: -1}, nil, up)
+	s.cache.put(positiveA("kept.com", 300))
+	s.cache.put(positiveA("expired.com", 10))
+	s.cache.mu.Lock()
+	for _, e := range s.cache.entries {
+		if e.ttl == 10*time.Second {
+			e.storedAt = time.Now().Add(-time.Minute)
+		}
+	}
+	s.cache.mu.Unlock()
+
+	if err := s.SaveCache(path); err != nil {
+		t.Fatalf("SaveCache: %v", err)
+	}
+
+	up2 := NewUpstream(conf.UpstreamConfig{})
+	defer up2.Close()
+	s2 := NewServer(conf.ServerConfig{ServeStale: -1}, nil, up2)
+	n, err := s2.LoadCache(path)
+	if err != nil || n != 2 {
+		t.Fatalf("LoadCache restored %d entries: %v", n, err)
+	}
+	q := new(dns.Msg)
+	q.SetQuestion("kept.com.", dns.TypeA)
+	got := s2.cache.get(q)
+	if got == nil || got.Answer[0].Header().Ttl > 300 || got.Answer[0].(*dns.A).A.String() != "1.2.3.4" {
+		t.Fatalf("restored answer %v", got)
+	}
+	q.SetQuestion("expired.com.", dns.TypeA)
+	if s2.cache.get(q) != nil {
+		t.Fatal("expired answer restored")
+	}
+	if ips, ok := up2.cachedFresh("dns.example"); !ok || ips[0].String() != "9.9.9.9" {
+		t.Fatalf("bootstrap host not restored: %v", ips)
+	}
+
+	// Nothing changed since the load, so nothing is written.
+	unchanged := filepath.Join(t.TempDir(), "dnscache.json")
+	if err := s2.SaveCache(unchanged); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(unchanged); !os.IsNotExist(err) {
+		t.Fatalf("unchanged cache written: %v", err)
+	}
+}
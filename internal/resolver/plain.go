@@ -0,0 +1,49 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// udpClient queries a plain DNS server over UDP and repeats truncated answers
+// over TCP.
+type udpClient struct {
+	host    string
+	port    string
+	resolve func(host string) ([]net.IP, error)
+	tcp     *streamClient
+	client  dns.Client
+}
+
+func (c *udpClient) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	var msg dns.Msg
+	if err := msg.Unpack(query); err != nil {
+		return nil, err
+	}
+	ips, err := c.resolve(c.host)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, ip := range ips {
+		resp, _, err := c.client.ExchangeContext(ctx, &msg, net.JoinHostPort(ip.String(), c.port))
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		if resp.Truncated {
+			return c.tcp.exchange(ctx, query)
+		}
+		return resp.Pack()
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no addresses to query")
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,65 @@
+package resolver
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ApostolDmitry/vpner/internal/conf"
+	"github.com/miekg/dns"
+)
+
+func TestExchangeWithRetriesTruncatedOverTCP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := pc.LocalAddr().String()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		pc.Close()
+		t.Skipf("tcp port %s busy: %v", addr, err)
+	}
+	handler := func(truncate bool) dns.HandlerFunc {
+		return func(w dns.ResponseWriter, req *dns.Msg) {
+			resp := new(dns.Msg)
+			resp.SetReply(req)
+			if truncate {
+				resp.Truncated = true
+			} else {
+				resp.Answer = append(resp.Answer, &dns.A{
+					Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+					A:   net.ParseIP("10.1.2.3"),
+				})
+			}
+			_ = w.WriteMsg(resp)
+		}
+	}
+	udpSrv := &dns.Server{PacketConn: pc, Handler: handler(true)}
+	tcpSrv := &dns.Server{Listener: ln, Handler: handler(false)}
+	go func() { _ = udpSrv.ActivateAndServe() }()
+	go func() { _ = tcpSrv.ActivateAndServe() }()
+	defer udpSrv.Shutdown()
+	defer tcpSrv.Shutdown()
+
+	r := NewUpstream(conf.UpstreamConfig{})
+	defer r.Close()
+	q := new(dns.Msg)
+	q.SetQuestion("nas.lan.", dns.TypeA)
+	packed, _ := q.Pack()
+	raw, err := r.ExchangeWith(addr, packed, 2*time.Second)
+	if err != nil {
+		t.Fatalf("ExchangeWith: %v", err)
+	}
+	var resp dns.Msg
+	if err := resp.Unpack(raw); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Truncated || len(resp.Answer) != 1 {
+		t.Fatalf("expected the TCP answer, got %v", resp.String())
+	}
+	stats := r.ServerStats()
+	if len(stats) != 1 || stats[0].Server != addr || stats[0].Protocol != protoUDP || stats[0].Successes != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,58 @@
+package resolver
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/proxy"
+)
+
+// ViaProxy returns an upstream that sends queries to the DoH, DoT and TCP
+// servers of r through the SOCKS5 proxy at socksAddr, so answers match what
+// the proxy's exit sees. Server names are resolved by the proxy. Upstreams are
+// cached per proxy address and closed together with r.
+func (r *Upstream) ViaProxy(socksAddr string) (*Upstream, error) {
+	r.proxiesMu.Lock()
+	defer r.proxiesMu.Unlock()
+	if p, ok := r.proxies[socksAddr]; ok {
+		return p, nil
+	}
+
+	cfg := r.config
+	cfg.Servers = nil
+	for _, server := range r.config.Servers {
+		if proxiable(server) {
+			cfg.Servers = append(cfg.Servers, server)
+		}
+	}
+	if len(cfg.Servers) == 0 {
+		return nil, errors.New("no DoH, DoT or TCP upstream to reach through a proxy")
+	}
+	dialer, err := proxy.SOCKS5("tcp", socksAddr, nil, &net.Dialer{Timeout: secs(cfg.DialTimeout)})
+	if err != nil {
+		return nil, fmt.Errorf("socks proxy %s: %w", socksAddr, err)
+	}
+	ctxDialer, ok := dialer.(proxy.ContextDialer)
+	if !ok {
+		return nil, errors.New("socks dialer does not support contexts")
+	}
+	p := newUpstream(cfg, ctxDialer.DialContext)
+	r.proxies[socksAddr] = p
+	return p, nil
+}
+
+// proxiable reports whether server runs over TCP and can go through SOCKS.
+func proxiable(server string) bool {
+	u, err := url.Parse(server)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case protoDoH, protoDoT, protoTCP:
+		return true
+	}
+	return false
+}
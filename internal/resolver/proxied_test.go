@@ -0,0 +1,185 @@
+package resolver
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ApostolDmitry/vpner/internal/conf"
+	"github.com/miekg/dns"
+)
+
+// serveSocks5 accepts CONNECT requests without auth and records the
+// destinations it was asked for.
+func serveSocks5(t *testing.T, ln net.Listener, dests chan<- string) {
+	t.Helper()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			buf := make([]byte, 262)
+			if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+				return
+			}
+			if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte{5, 0})
+			if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+				return
+			}
+			var host string
+			switch buf[3] {
+			case 1:
+				_, _ = io.ReadFull(conn, buf[:4])
+				host = net.IP(buf[:4]).String()
+			case 3:
+				_, _ = io.ReadFull(conn, buf[:1])
+				n := int(buf[0])
+				_, _ = io.ReadFull(conn, buf[:n])
+				host = string(buf[:n])
+			default:
+				return
+			}
+			_, _ = io.ReadFull(conn, buf[:2])
+			dest := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(buf[:2]))))
+			dests <- dest
+			target, err := net.Dial("tcp", dest)
+			if err != nil {
+				_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+				return
+			}
+			defer target.Close()
+			_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+			go func() { _, _ = io.Copy(target, conn) }()
+			_, _ = io.Copy(conn, target)
+		}()
+	}
+}
+
+func TestViaProxyResolvesThroughSocks(t *testing.T) {
+	dnsLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &dns.Server{Listener: dnsLn, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		resp.Answer = append(resp.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.ParseIP("203.0.113.9"),
+		})
+		_ = w.WriteMsg(resp)
+	})}
+	go func() { _ = srv.ActivateAndServe() }()
+	defer srv.Shutdown()
+
+	socksLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer socksLn.Close()
+	dests := make(chan string, 4)
+	go serveSocks5(t, socksLn, dests)
+
+	r := NewUpstream(conf.UpstreamConfig{Servers: []string{"quic://dns.example", "tcp://" + dnsLn.Addr().String()}})
+	defer r.Close()
+	via, err := r.ViaProxy(socksLn.Addr().String())
+	if err != nil {
+		t.Fatalf("ViaProxy: %v", err)
+	}
+	if len(via.servers) != 1 || via.servers[0].proto != protoTCP {
+		t.Fatalf("expected only the tcp server, got %d", len(via.servers))
+	}
+	if again, _ := r.ViaProxy(socksLn.Addr().String()); again != via {
+		t.Fatal("proxied upstream not reused")
+	}
+
+	ips, _, err := via.ResolveDomainTTL("blocked.example", dns.TypeA)
+	if err != nil || len(ips) != 1 || !ips[0].Equal(net.ParseIP("203.0.113.9")) {
+		t.Fatalf("ResolveDomainTTL = %v, %v", ips, err)
+	}
+	if got := <-dests; got != dnsLn.Addr().String() {
+		t.Fatalf("socks CONNECT to %s, want %s", got, dnsLn.Addr())
+	}
+}
+
+type chainProxyStub string
+
+func (c chainProxyStub) ChainProxy(string) (string, bool) { return string(c), true }
+
+type syncRecorder struct {
+	mu      sync.Mutex
+	domains []string
+}
+
+func (r *syncRecorder) SyncFromAnswers(domain string, _ []net.IP, _ uint32) error {
+	r.mu.Lock()
+	r.domains = append(r.domains, domain)
+	r.mu.Unlock()
+	return nil
+}
+
+func TestChainFailureDoesNotFallBackSilently(t *testing.T) {
+	dnsLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &dns.Server{Listener: dnsLn, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		resp.Answer = append(resp.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.ParseIP("198.51.100.1"),
+		})
+		_ = w.WriteMsg(resp)
+	})}
+	go func() { _ = srv.ActivateAndServe() }()
+	defer srv.Shutdown()
+
+	// Nothing listens on the chain's SOCKS port any more.
+	deadLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead := deadLn.Addr().String()
+	deadLn.Close()
+
+	up := NewUpstream(conf.UpstreamConfig{Servers: []string{"tcp://" + dnsLn.Addr().String()}})
+	defer up.Close()
+
+	for _, fallback := range []bool{false, true} {
+		synced := &syncRecorder{}
+		s := NewServer(conf.ServerConfig{MaxConcurrentConn: 1, ResolveViaChainFallback: fallback}, synced, up)
+		s.SetChainProxies(chainProxyStub(dead))
+
+		r := new(dns.Msg)
+		r.SetQuestion("blocked.example.", dns.TypeA)
+		w := &recordingWriter{}
+		s.handleDNSRequest(w, r)
+		time.Sleep(50 * time.Millisecond)
+
+		want := dns.RcodeServerFailure
+		if fallback {
+			want = dns.RcodeSuccess
+		}
+		if w.msg == nil || w.msg.Rcode != want {
+			t.Fatalf("fallback=%v: got %v, want rcode %s", fallback, w.msg, dns.RcodeToString[want])
+		}
+		synced.mu.Lock()
+		if len(synced.domains) != 0 {
+			t.Fatalf("fallback=%v: answer synced into ipsets for %v", fallback, synced.domains)
+		}
+		synced.mu.Unlock()
+		if s.cache.get(r) != nil {
+			t.Fatalf("fallback=%v: WAN answer cached", fallback)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package resolver
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ApostolDmitry/vpner/internal/querylog"
+	"github.com/miekg/dns"
+)
+
+// Answer sources that only show up in the query log.
+const (
+	QuerySourceRateLimited = "rate-limit"
+	QuerySourceOverload    = "overload"
+	QuerySourceStale       = "stale"
+)
+
+// QueryLogger records every answered query.
+type QueryLogger interface {
+	Add(e querylog.Entry)
+}
+
+// SetQueryLog makes the server record each answer it sends.
+func (s *Server) SetQueryLog(l QueryLogger) {
+	s.queryLog = l
+}
+
+func (s *Server) logQuery(w dns.ResponseWriter, r, resp *dns.Msg, source, upstream string, start time.Time) {
+	if s.queryLog == nil || r == nil || resp == nil || len(r.Question) == 0 {
+		return
+	}
+	q := r.Question[0]
+	e := querylog.Entry{
+		Time:     start,
+		Client:   formatRemoteAddr(w),
+		Name:     strings.ToLower(strings.TrimSuffix(q.Name, ".")),
+		Type:     dns.Type(q.Qtype).String(),
+		Rcode:    formatRcode(resp),
+		Source:   source,
+		Upstream: upstream,
+		Cached:   source == TraceSourceCache,
+		Latency:  time.Since(start),
+	}
+	for _, rr := range resp.Answer {
+		e.Answers = append(e.Answers, strings.TrimPrefix(rr.String(), rr.Header().String()))
+	}
+	s.queryLog.Add(e)
+}
@@ -0,0 +1,56 @@
+package resolver
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ApostolDmitry/vpner/internal/conf"
+	"github.com/ApostolDmitry/vpner/internal/querylog"
+	"github.com/miekg/dns"
+)
+
+type recordingWriter struct {
+	dns.ResponseWriter
+	msg *dns.Msg
+}
+
+func (w *recordingWriter) RemoteAddr() net.Addr {
+	return &net.UDPAddr{IP: net.ParseIP("192.168.1.5"), Port: 53000}
+}
+
+func (w *recordingWriter) WriteMsg(m *dns.Msg) error {
+	w.msg = m
+	return nil
+}
+
+type entries []querylog.Entry
+
+func (e *entries) Add(entry querylog.Entry) { *e = append(*e, entry) }
+
+func TestQueryLogRecordsAnswers(t *testing.T) {
+	s := NewServer(conf.ServerConfig{MaxConcurrentConn: 1}, nil, nil)
+	s.SetLocalRecords(stubLocal{"nas.home": {
+		&dns.A{Hdr: dns.RR_Header{Name: "nas.home.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300}, A: net.ParseIP("192.168.1.10")},
+	}})
+	var logged entries
+	s.SetQueryLog(&logged)
+
+	r := new(dns.Msg)
+	r.SetQuestion("nas.home.", dns.TypeA)
+	w := &recordingWriter{}
+	s.handleDNSRequest(w, r)
+	r.SetQuestion("1.1.168.192.in-addr.arpa.", dns.TypePTR)
+	s.handleDNSRequest(w, r)
+
+	if len(logged) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", logged)
+	}
+	e := logged[0]
+	if e.Client != "192.168.1.5" || e.Name != "nas.home" || e.Type != "A" || e.Rcode != "NOERROR" ||
+		e.Source != TraceSourceLocal || len(e.Answers) != 1 || e.Answers[0] != "192.168.1.10" {
+		t.Fatalf("unexpected entry %+v", e)
+	}
+	if e := logged[1]; e.Source != TraceSourceZone || e.Rcode != "NXDOMAIN" {
+		t.Fatalf("unexpected zone entry %+v", e)
+	}
+}
@@ -11,44 +11,62 @@ import (
 var ErrNoRecords = errors.New("no records in DoH response")
 
 func (r *Upstream) ResolveDomain(domain string, qtype uint16) ([]net.IP, error) {
+	ips, _, err := r.ResolveDomainTTL(domain, qtype)
+	return ips, err
+}
+
+// ResolveDomainTTL is ResolveDomain that also returns the smallest TTL of the
+// matching answers.
+func (r *Upstream) ResolveDomainTTL(domain string, qtype uint16) ([]net.IP, uint32, error) {
 	msg := new(dns.Msg)
 	msg.SetQuestion(dns.Fqdn(domain), qtype)
 	msg.RecursionDesired = true
 
 	packed, err := msg.Pack()
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	respData, err := r.ForwardQuery(packed)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	resp := new(dns.Msg)
 	if err := resp.Unpack(respData); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	if resp.Rcode != dns.RcodeSuccess {
-		return nil, fmt.Errorf("dns error: %s", dns.RcodeToString[resp.Rcode])
+		return nil, 0, fmt.Errorf("dns error: %s", dns.RcodeToString[resp.Rcode])
 	}
 
-	var ips []net.IP
+	var (
+		ips []net.IP
+		ttl uint32
+	)
 	for _, ans := range resp.Answer {
+		var ip net.IP
 		switch rr := ans.(type) {
 		case *dns.A:
 			if qtype == dns.TypeA {
-				ips = append(ips, rr.A)
+				ip = rr.A
 			}
 		case *dns.AAAA:
 			if qtype == dns.TypeAAAA {
-				ips = append(ips, rr.AAAA)
+				ip = rr.AAAA
 			}
 		}
+		if ip == nil {
+			continue
+		}
+		if len(ips) == 0 || ans.Header().Ttl < ttl {
+			ttl = ans.Header().Ttl
+		}
+		ips = append(ips, ip)
 	}
 	if ips = dedupeIPs(ips); len(ips) == 0 {
-		return nil, ErrNoRecords
+		return nil, 0, ErrNoRecords
 	}
-	return ips, nil
+	return ips, ttl, nil
 }
 
 func (r *Upstream) ResolveA(domain string) ([]net.IP, error) {
@@ -2,6 +2,7 @@ package resolver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
@@ -22,7 +23,13 @@ type compiledResolverRule struct {
 }
 
 type IPSyncer interface {
-	SyncFromAnswers(domain string, ips []net.IP) error
+	SyncFromAnswers(domain string, ips []net.IP, ttl uint32) error
+}
+
+// ChainProxies finds the SOCKS inbound of the chain a domain is routed
+// through.
+type ChainProxies interface {
+	ChainProxy(domain string) (string, bool)
 }
 
 type Server struct {
@@ -32,6 +39,13 @@ type Server struct {
 	customRules   []compiledResolverRule
 	customTimeout time.Duration
 	resolver      *Upstream
+	chainProxies  ChainProxies
+	fakeIPs       FakeIPs
+	blocker       Blocker
+	local         LocalRecords
+	queryLog      QueryLogger
+	forwardZones  map[string]forwardZone
+	blockMode     string
 	cache         *answerCache
 	limiter       *rateLimiter
 	udpServer     *dns.Server
@@ -46,12 +60,26 @@ func NewServer(cfg conf.ServerConfig, ipManager IPSyncer, resolver *Upstream) *S
 		ipManager:     ipManager,
 		resolver:      resolver,
 		customTimeout: defaultCustomResolveTimeout,
+		blockMode:     parseBlockMode(cfg.Blocklist.Mode),
+		forwardZones:  compileForwardZones(cfg.ForwardZones, cfg.LocalDNS),
 	}
 	if cfg.CustomResolveTimeout > 0 {
 		s.customTimeout = time.Duration(cfg.CustomResolveTimeout) * time.Second
 	}
 	if cfg.Cache == nil || *cfg.Cache {
 		s.cache = newAnswerCache(cfg.CacheMaxEntries)
+		switch {
+		case cfg.ServeStale > 0:
+			s.cache.staleFor = time.Duration(cfg.ServeStale) * time.Second
+		case cfg.ServeStale == 0:
+			s.cache.staleFor = defaultServeStale
+		}
+		switch {
+		case cfg.PrefetchHits > 0:
+			s.cache.prefetchHits = cfg.PrefetchHits
+		case cfg.PrefetchHits == 0:
+			s.cache.prefetchHits = defaultPrefetchHits
+		}
 	}
 	if cfg.RateLimit > 0 {
 		s.limiter = newRateLimiter(cfg.RateLimit)
@@ -109,13 +137,22 @@ func (s *Server) SetNotifyStartedFunc(fn func()) {
 	s.notifyStarted = fn
 }
 
+// SetChainProxies makes queries for domains with an unblock rule go out
+// through the rule's chain.
+func (s *Server) SetChainProxies(p ChainProxies) {
+	s.chainProxies = p
+}
+
 func (s *Server) handleDNSRequest(w dns.ResponseWriter, r *dns.Msg) {
+	start := time.Now()
+	var logSource, logUpstream string
 	responded := false
 	reply := func(m *dns.Msg) {
 		responded = true
 		if err := w.WriteMsg(m); err != nil {
 			logx.Debugf("DNS write failed: %v", err)
 		}
+		s.logQuery(w, r, m, logSource, logUpstream, start)
 	}
 	servfail := func() {
 		m := new(dns.Msg)
@@ -139,7 +176,26 @@ func (s *Server) handleDNSRequest(w dns.ResponseWriter, r *dns.Msg) {
 		logx.Infof("DNS query from %s: %s", source, questions)
 	}
 
+	// serveStale answers with an expired cached answer after the upstream
+	// failed or answered SERVFAIL or REFUSED, if there is one.
+	serveStale := func() bool {
+		if s.cache == nil {
+			return false
+		}
+		stale := s.cache.stale(r)
+		if stale == nil {
+			return false
+		}
+		logSource = QuerySourceStale
+		reply(stale)
+		if s.config.Verbose {
+			logx.Infof("DNS response to %s for %s (stale): %s", source, questions, formatAnswers(stale))
+		}
+		return true
+	}
+
 	if s.limiter != nil && !s.limiter.allow(formatRemoteAddr(w)) {
+		logSource = QuerySourceRateLimited
 		m := new(dns.Msg)
 		m.SetRcode(r, dns.RcodeRefused)
 		reply(m)
@@ -148,12 +204,63 @@ func (s *Server) handleDNSRequest(w dns.ResponseWriter, r *dns.Msg) {
 
 	domain := extractDomain(r)
 
+	if local := s.localAnswer(r, domain); local != nil {
+		logSource = TraceSourceLocal
+		reply(local)
+		if s.config.Verbose {
+			logx.Infof("DNS response to %s for %s (local): %s", source, questions, formatAnswers(local))
+		}
+		go s.processDomainAnswers(domain, local)
+		return
+	}
+
+	zoned, via, zoneErr := s.zoneAnswer(r, domain)
+	if zoned != nil || zoneErr != nil {
+		logSource, logUpstream = TraceSourceZone, via
+	}
+	if zoneErr != nil {
+		logx.Warnf("forward zone error: %v", zoneErr)
+		servfail()
+		return
+	}
+	if zoned != nil {
+		reply(zoned)
+		if s.config.Verbose {
+			logx.Infof("DNS response to %s for %s (zone via %s, %s): %s",
+				source, questions, via, formatRcode(zoned), formatAnswers(zoned))
+		}
+		return
+	}
+
+	if blocked, list := s.blockedAnswer(r, domain); blocked != nil {
+		logSource, logUpstream = TraceSourceBlocked, list
+		reply(blocked)
+		if s.config.Verbose {
+			logx.Infof("DNS response to %s for %s: blocked by %s", source, questions, list)
+		}
+		return
+	}
+
+	if fake := s.fakeAnswer(r, domain, false); fake != nil {
+		logSource = TraceSourceFakeIP
+		reply(fake)
+		if s.config.Verbose {
+			logx.Infof("DNS response to %s for %s (fake-ip): %s", source, questions, formatAnswers(fake))
+		}
+		go s.processDomainAnswers(domain, fake)
+		return
+	}
+
 	if s.cache != nil {
 		if cached := s.cache.get(r); cached != nil {
+			logSource = TraceSourceCache
 			reply(cached)
 			if domain != "" {
 				go s.processDomainAnswers(domain, cached)
 			}
+			if s.cache.claimPrefetch(r) {
+				go s.prefetch(r.Copy(), domain)
+			}
 			return
 		}
 	}
@@ -163,19 +270,25 @@ func (s *Server) handleDNSRequest(w dns.ResponseWriter, r *dns.Msg) {
 		defer func() { <-s.connSemaphore }()
 	default:
 		logx.Warnf("DNS server overloaded, dropping query")
+		logSource = QuerySourceOverload
 		servfail()
 		return
 	}
 
 	if resolverIP := s.matchCustomResolver(domain); resolverIP != "" {
+		logSource, logUpstream = TraceSourceCustom, resolverIP
 		if s.config.Verbose {
 			logx.Infof("Domain %s resolved via custom %s", domain, resolverIP)
 		}
-		client := &dns.Client{Net: "udp", Timeout: s.customTimeout}
-		resp, _, err := client.Exchange(r.Copy(), resolverIP)
+		resp, err := s.exchangeCustom(resolverIP, r)
 		if err != nil {
 			logx.Warnf("custom resolver %s error: %v", resolverIP, err)
-			servfail()
+			if !serveStale() {
+				servfail()
+			}
+			return
+		}
+		if upstreamFailed(resp) && serveStale() {
 			return
 		}
 		resp.Id = r.Id
@@ -193,6 +306,7 @@ func (s *Server) handleDNSRequest(w dns.ResponseWriter, r *dns.Msg) {
 		return
 	}
 
+	logSource = TraceSourceUpstream
 	packed, err := r.Pack()
 	if err != nil {
 		logx.Warnf("DNS pack error: %v", err)
@@ -200,20 +314,34 @@ func (s *Server) handleDNSRequest(w dns.ResponseWriter, r *dns.Msg) {
 		return
 	}
 
-	resp, err := s.resolver.ForwardQuery(packed)
+	resp, chainAddr, fallback, err := s.forward(domain, packed)
+	if chainAddr != "" {
+		logSource, logUpstream = TraceSourceChain, chainAddr
+	}
 	if err != nil {
-		logx.Warnf("DoH forward error: %v", err)
-		servfail()
+		logx.Warnf("upstream forward error: %v", err)
+		if !serveStale() {
+			servfail()
+		}
 		return
 	}
 
 	msg := new(dns.Msg)
 	if err := msg.Unpack(resp); err != nil {
 		logx.Warnf("DNS unpack error: %v", err)
-		servfail()
+		if !serveStale() {
+			servfail()
+		}
+		return
+	}
+	if upstreamFailed(msg) && serveStale() {
 		return
 	}
 	msg.Id = r.Id
+	if fallback {
+		reply(msg)
+		return
+	}
 	if s.cache != nil {
 		s.cache.put(msg)
 	}
@@ -227,6 +355,12 @@ func (s *Server) handleDNSRequest(w dns.ResponseWriter, r *dns.Msg) {
 	}
 }
 
+// upstreamFailed reports whether resp is an upstream failure that a stale
+// cached answer is better than.
+func upstreamFailed(resp *dns.Msg) bool {
+	return resp.Rcode == dns.RcodeServerFailure || resp.Rcode == dns.RcodeRefused
+}
+
 func (s *Server) processDomainAnswers(domain string, msg *dns.Msg) {
 	if s.ipManager == nil || msg == nil {
 		return
@@ -235,11 +369,124 @@ func (s *Server) processDomainAnswers(domain string, msg *dns.Msg) {
 	if len(ips) == 0 {
 		return
 	}
-	if err := s.ipManager.SyncFromAnswers(domain, ips); err != nil {
+	if err := s.ipManager.SyncFromAnswers(domain, ips, addressTTL(msg)); err != nil {
 		logx.Warnf("IP rule sync error for domain %s: %v", domain, err)
 	}
 }
 
+// prefetch refreshes a popular cached answer shortly before it expires, so
+// clients keep getting it from the cache.
+func (s *Server) prefetch(r *dns.Msg, domain string) {
+	defer s.cache.prefetched(r)
+	select {
+	case s.connSemaphore <- struct{}{}:
+		defer func() { <-s.connSemaphore }()
+	default:
+		return
+	}
+
+	var resp *dns.Msg
+	if resolverIP := s.matchCustomResolver(domain); resolverIP != "" {
+		msg, err := s.exchangeCustom(resolverIP, r)
+		if err != nil {
+			logx.Debugf("prefetch of %s via %s failed: %v", domain, resolverIP, err)
+			return
+		}
+		resp = msg
+	} else {
+		packed, err := r.Pack()
+		if err != nil {
+			return
+		}
+		raw, _, fallback, err := s.forward(domain, packed)
+		if err != nil {
+			logx.Debugf("prefetch of %s failed: %v", domain, err)
+			return
+		}
+		if fallback {
+			return
+		}
+		resp = new(dns.Msg)
+		if err := resp.Unpack(raw); err != nil {
+			return
+		}
+	}
+	s.cache.put(resp)
+	if domain != "" {
+		s.processDomainAnswers(domain, resp)
+	}
+}
+
+// CacheStats reports the answer cache counters; ok is false when the cache
+// is disabled.
+func (s *Server) CacheStats() (CacheStats, bool) {
+	if s.cache == nil {
+		return CacheStats{}, false
+	}
+	return s.cache.snapshot(), true
+}
+
+// forward resolves through the chain of the domain's unblock rule when there is
+// one. It returns the chain proxy that was asked, or "" for the default
+// upstream. When the chain fails, the default upstream only answers with
+// resolve-via-chain-fallback, and fallback is set: such an answer may be
+// geo-poisoned, so it must be neither cached nor synced into the ipsets.
+func (s *Server) forward(domain string, packed []byte) (resp []byte, chainAddr string, fallback bool, err error) {
+	if via, addr, err := s.chainUpstream(domain); addr != "" {
+		if err == nil {
+			resp, err = via.ForwardQuery(packed)
+		}
+		if err == nil {
+			return resp, addr, false, nil
+		}
+		if !s.config.ResolveViaChainFallback {
+			return nil, addr, false, fmt.Errorf("chain proxy %s: %w", addr, err)
+		}
+		logx.Warnf("resolving %s through chain proxy %s failed, using default upstream: %v", domain, addr, err)
+		resp, err = s.resolver.ForwardQuery(packed)
+		return resp, "", true, err
+	}
+	resp, err = s.resolver.ForwardQuery(packed)
+	return resp, "", false, err
+}
+
+// chainUpstream returns the upstream reached through the SOCKS inbound of the
+// running chain domain is routed to, and the inbound's address. The address
+// is "" when domain is not bound to a chain.
+func (s *Server) chainUpstream(domain string) (*Upstream, string, error) {
+	if s.chainProxies == nil || s.resolver == nil || domain == "" {
+		return nil, "", nil
+	}
+	addr, ok := s.chainProxies.ChainProxy(domain)
+	if !ok {
+		return nil, "", nil
+	}
+	via, err := s.resolver.ViaProxy(addr)
+	if err != nil {
+		return nil, addr, err
+	}
+	return via, addr, nil
+}
+
+func (s *Server) exchangeCustom(target string, req *dns.Msg) (*dns.Msg, error) {
+	if s.resolver == nil {
+		return nil, errors.New("no upstream configured")
+	}
+	packed, err := req.Pack()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := s.resolver.ExchangeWith(target, packed, s.customTimeout)
+	if err != nil {
+		return nil, err
+	}
+	resp := new(dns.Msg)
+	if err := resp.Unpack(raw); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
 func (s *Server) matchCustomResolver(domain string) string {
 	domain = strings.TrimSuffix(domain, ".")
 	for _, rule := range s.customRules {
@@ -311,6 +558,21 @@ func formatRemoteAddr(w dns.ResponseWriter) string {
 	return addr.String()
 }
 
+// addressTTL returns the smallest TTL of the A and AAAA records in msg.
+func addressTTL(msg *dns.Msg) uint32 {
+	var ttl uint32
+	found := false
+	for _, rr := range msg.Answer {
+		switch rr.(type) {
+		case *dns.A, *dns.AAAA:
+			if t := rr.Header().Ttl; !found || t < ttl {
+				ttl, found = t, true
+			}
+		}
+	}
+	return ttl
+}
+
 func extractIPs(msg *dns.Msg) []net.IP {
 	if msg == nil {
 		return nil
@@ -0,0 +1,212 @@
+package resolver
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+// streamClient keeps one TCP connection per server, TLS-wrapped for DoT, and
+// pipelines queries over it, matching answers to queries by message ID.
+type streamClient struct {
+	addr string
+	tls  *tls.Config
+	dial func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	mu   sync.Mutex
+	conn *streamConn
+}
+
+func (c *streamClient) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	conn, fresh, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := conn.exchange(ctx, query)
+	if err != nil && !fresh && ctx.Err() == nil {
+		// The server may have dropped the idle connection under us.
+		c.drop(conn)
+		if conn, _, err = c.get(ctx); err != nil {
+			return nil, err
+		}
+		return conn.exchange(ctx, query)
+	}
+	return resp, err
+}
+
+func (c *streamClient) get(ctx context.Context) (*streamConn, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil && !c.conn.closed() {
+		return c.conn, false, nil
+	}
+	raw, err := c.dial(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, false, err
+	}
+	if c.tls == nil {
+		c.conn = newStreamConn(raw)
+		return c.conn, true, nil
+	}
+	conn := tls.Client(raw, c.tls)
+	if err := conn.HandshakeContext(ctx); err != nil {
+		_ = raw.Close()
+		return nil, false, err
+	}
+	c.conn = newStreamConn(conn)
+	return c.conn, true, nil
+}
+
+func (c *streamClient) drop(conn *streamConn) {
+	conn.fail(net.ErrClosed)
+	c.mu.Lock()
+	if c.conn == conn {
+		c.conn = nil
+	}
+	c.mu.Unlock()
+}
+
+func (c *streamClient) close() {
+	c.mu.Lock()
+	conn := c.conn
+	c.conn = nil
+	c.mu.Unlock()
+	if conn != nil {
+		conn.fail(net.ErrClosed)
+	}
+}
+
+type streamConn struct {
+	conn net.Conn
+	wmu  sync.Mutex
+
+	mu      sync.Mutex
+	pending map[uint16]chan []byte
+	nextID  uint16
+	err     error
+	done    chan struct{}
+}
+
+func newStreamConn(conn net.Conn) *streamConn {
+	c := &streamConn{
+		conn:    conn,
+		pending: make(map[uint16]chan []byte),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+func (c *streamConn) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	if len(query) < dnsHeaderLen {
+		return nil, errors.New("short dns query")
+	}
+	ch := make(chan []byte, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return nil, err
+	}
+	for {
+		c.nextID++
+		if _, busy := c.pending[c.nextID]; !busy {
+			break
+		}
+	}
+	id := c.nextID
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		if c.pending[id] == ch {
+			delete(c.pending, id)
+		}
+		c.mu.Unlock()
+	}()
+
+	// Queries share the connection, so each one gets an ID unique on it; the
+	// caller's ID is put back into the answer.
+	origID := binary.BigEndian.Uint16(query)
+	msg := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(msg, uint16(len(query)))
+	copy(msg[2:], query)
+	binary.BigEndian.PutUint16(msg[2:], id)
+
+	c.wmu.Lock()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = c.conn.SetWriteDeadline(deadline)
+	}
+	_, err := c.conn.Write(msg)
+	c.wmu.Unlock()
+	if err != nil {
+		c.fail(err)
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		binary.BigEndian.PutUint16(resp, origID)
+		return resp, nil
+	case <-c.done:
+		return nil, c.failure()
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			// A server that stops answering would otherwise keep the dead
+			// connection in use.
+			c.fail(ctx.Err())
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (c *streamConn) readLoop() {
+	header := make([]byte, 2)
+	for {
+		if _, err := io.ReadFull(c.conn, header); err != nil {
+			c.fail(err)
+			return
+		}
+		resp := make([]byte, binary.BigEndian.Uint16(header))
+		if _, err := io.ReadFull(c.conn, resp); err != nil {
+			c.fail(err)
+			return
+		}
+		if len(resp) < dnsHeaderLen {
+			continue
+		}
+		id := binary.BigEndian.Uint16(resp)
+		c.mu.Lock()
+		ch, ok := c.pending[id]
+		delete(c.pending, id)
+		c.mu.Unlock()
+		if ok {
+			ch <- resp
+		}
+	}
+}
+
+func (c *streamConn) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.done)
+	_ = c.conn.Close()
+}
+
+func (c *streamConn) failure() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *streamConn) closed() bool {
+	return c.failure() != nil
+}
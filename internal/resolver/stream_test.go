@@ -0,0 +1,105 @@
+package resolver
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ApostolDmitry/vpner/internal/conf"
+	"github.com/miekg/dns"
+)
+
+func TestNewServerStateSchemes(t *testing.T) {
+	r := &Upstream{config: normalizeConfig(conf.UpstreamConfig{})}
+	cases := []struct {
+		server, proto, addr string
+		bad                 bool
+	}{
+		{server: "https://dns.google/dns-query", proto: protoDoH},
+		{server: "tls://1.1.1.1", proto: protoDoT, addr: "1.1.1.1:853"},
+		{server: "tls://dns.quad9.net:8853", proto: protoDoT, addr: "dns.quad9.net:8853"},
+		{server: "quic://dns.adguard-dns.com", proto: protoDoQ},
+		{server: "tcp://192.168.1.1", proto: protoTCP, addr: "192.168.1.1:53"},
+		{server: "udp://192.168.1.1:5353", proto: protoUDP},
+		{server: "ftp://8.8.8.8", bad: true},
+		{server: "tls://", bad: true},
+	}
+	for _, tc := range cases {
+		s := r.newServerState(tc.server)
+		if (s.err != nil) != tc.bad {
+			t.Fatalf("%s: err = %v", tc.server, s.err)
+		}
+		if tc.bad {
+			continue
+		}
+		if s.proto != tc.proto {
+			t.Fatalf("%s: proto = %s, want %s", tc.server, s.proto, tc.proto)
+		}
+		if tc.addr != "" && s.stream.addr != tc.addr {
+			t.Fatalf("%s: addr = %s, want %s", tc.server, s.stream.addr, tc.addr)
+		}
+	}
+}
+
+func TestStreamConnPipelinesQueries(t *testing.T) {
+	client, server := net.Pipe()
+	conn := newStreamConn(client)
+	defer conn.fail(net.ErrClosed)
+
+	// Answer both queries only once both arrived, in reverse order.
+	go func() {
+		var queries [][]byte
+		for range 2 {
+			header := make([]byte, 2)
+			if _, err := io.ReadFull(server, header); err != nil {
+				return
+			}
+			q := make([]byte, binary.BigEndian.Uint16(header))
+			if _, err := io.ReadFull(server, q); err != nil {
+				return
+			}
+			queries = append(queries, q)
+		}
+		for i := len(queries) - 1; i >= 0; i-- {
+			var msg dns.Msg
+			_ = msg.Unpack(queries[i])
+			resp := new(dns.Msg)
+			resp.SetReply(&msg)
+			packed, _ := resp.Pack()
+			out := binary.BigEndian.AppendUint16(nil, uint16(len(packed)))
+			_, _ = server.Write(append(out, packed...))
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, name := range []string{"a.example.", "b.example."} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q := new(dns.Msg)
+			q.SetQuestion(name, dns.TypeA)
+			q.Id = 4242
+			packed, _ := q.Pack()
+			raw, err := conn.exchange(ctx, packed)
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				return
+			}
+			var resp dns.Msg
+			if err := resp.Unpack(raw); err != nil {
+				t.Errorf("%s: %v", name, err)
+				return
+			}
+			if resp.Id != 4242 || resp.Question[0].Name != name {
+				t.Errorf("%s: got id %d question %s", name, resp.Id, resp.Question[0].Name)
+			}
+		}()
+	}
+	wg.Wait()
+}
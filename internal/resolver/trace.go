@@ -0,0 +1,106 @@
+package resolver
+
+import (
+	"github.com/miekg/dns"
+)
+
+const (
+	TraceSourceCache    = "cache"
+	TraceSourceCustom   = "custom-resolve"
+	TraceSourceChain    = "chain"
+	TraceSourceFakeIP   = "fake-ip"
+	TraceSourceBlocked  = "blocklist"
+	TraceSourceLocal    = "local"
+	TraceSourceZone     = "forward-zone"
+	TraceSourceUpstream = "upstream"
+)
+
+type Trace struct {
+	Source  string
+	Server  string
+	Rcode   string
+	Answers []string
+	Err     error
+}
+
+// Trace reports how the server would answer domain without caching the
+// response or syncing its addresses into the ipsets.
+func (s *Server) Trace(domain string, qtype uint16) Trace {
+	req := new(dns.Msg)
+	req.SetQuestion(dns.Fqdn(domain), qtype)
+	req.RecursionDesired = true
+
+	if local := s.localAnswer(req, domain); local != nil {
+		return traceFrom(Trace{Source: TraceSourceLocal}, local)
+	}
+
+	if resp, via, err := s.zoneAnswer(req, domain); err != nil || resp != nil {
+		t := Trace{Source: TraceSourceZone, Server: via, Err: err}
+		if err != nil {
+			return t
+		}
+		return traceFrom(t, resp)
+	}
+
+	if blocked, list := s.blockedAnswer(req, domain); blocked != nil {
+		return traceFrom(Trace{Source: TraceSourceBlocked, Server: list}, blocked)
+	}
+
+	if fake := s.fakeAnswer(req, domain, true); fake != nil {
+		return traceFrom(Trace{Source: TraceSourceFakeIP}, fake)
+	}
+
+	if s.cache != nil {
+		if cached := s.cache.peek(req); cached != nil {
+			return traceFrom(Trace{Source: TraceSourceCache}, cached)
+		}
+	}
+
+	if resolverIP := s.matchCustomResolver(domain); resolverIP != "" {
+		t := Trace{Source: TraceSourceCustom, Server: resolverIP}
+		resp, err := s.exchangeCustom(resolverIP, req)
+		if err != nil {
+			t.Err = err
+			return t
+		}
+		return traceFrom(t, resp)
+	}
+
+	t := Trace{Source: TraceSourceUpstream}
+	if s.resolver == nil {
+		return t
+	}
+	packed, err := req.Pack()
+	if err != nil {
+		t.Err = err
+		return t
+	}
+	upstream := s.resolver
+	if via, addr, err := s.chainUpstream(domain); addr != "" {
+		t = Trace{Source: TraceSourceChain, Server: addr}
+		if err != nil {
+			t.Err = err
+			return t
+		}
+		upstream = via
+	}
+	raw, err := upstream.ForwardQuery(packed)
+	if err != nil {
+		t.Err = err
+		return t
+	}
+	resp := new(dns.Msg)
+	if err := resp.Unpack(raw); err != nil {
+		t.Err = err
+		return t
+	}
+	return traceFrom(t, resp)
+}
+
+func traceFrom(t Trace, msg *dns.Msg) Trace {
+	t.Rcode = formatRcode(msg)
+	for _, ip := range extractIPs(msg) {
+		t.Answers = append(t.Answers, ip.String())
+	}
+	return t
+}
@@ -1,8 +1,13 @@
 package resolver
 
 import (
+	"context"
 	"crypto/tls"
+	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -12,8 +17,25 @@ import (
 	"golang.org/x/sync/singleflight"
 )
 
+const (
+	protoDoH = "https"
+	protoDoT = "tls"
+	protoDoQ = "quic"
+	protoUDP = "udp"
+	protoTCP = "tcp"
+
+	defaultDNSPort = "53"
+	defaultDoTPort = "853"
+	dnsHeaderLen   = 12
+)
+
 type upstreamState struct {
 	server string
+	proto  string
+	err    error
+	stream *streamClient
+	doq    *doqClient
+	udp    *udpClient
 
 	lastLatency atomic.Int64
 	successes   atomic.Uint64
@@ -27,26 +49,45 @@ type upstreamState struct {
 type Upstream struct {
 	config     conf.UpstreamConfig
 	httpClient *http.Client
+	fetchOnce  sync.Once
+	fetcher    *http.Client
 
 	cache   map[string]cachedEntry
 	cacheMu sync.RWMutex
+	// cacheGen counts stored hosts; cacheSavedGen is the gen last written
+	// to disk.
+	cacheGen, cacheSavedGen uint64
 
 	sf      singleflight.Group
 	servers []*upstreamState
 	reqSem  chan struct{}
 
+	targetsMu sync.Mutex
+	targets   map[string]*upstreamState
+
+	// proxyDial, when set, carries every connection through a chain's SOCKS
+	// inbound; see ViaProxy.
+	proxyDial func(ctx context.Context, network, addr string) (net.Conn, error)
+	proxiesMu sync.Mutex
+	proxies   map[string]*Upstream
+
 	stopCh chan struct{}
 	wg     sync.WaitGroup
 }
 
 func NewUpstream(cfg conf.UpstreamConfig) *Upstream {
-	cfg = normalizeConfig(cfg)
+	return newUpstream(normalizeConfig(cfg), nil)
+}
 
+func newUpstream(cfg conf.UpstreamConfig, proxyDial func(context.Context, string, string) (net.Conn, error)) *Upstream {
 	r := &Upstream{
-		config: cfg,
-		cache:  make(map[string]cachedEntry),
-		reqSem: make(chan struct{}, cfg.MaxConcurrentRequests),
-		stopCh: make(chan struct{}),
+		config:    cfg,
+		cache:     make(map[string]cachedEntry),
+		reqSem:    make(chan struct{}, cfg.MaxConcurrentRequests),
+		targets:   make(map[string]*upstreamState),
+		proxyDial: proxyDial,
+		proxies:   make(map[string]*Upstream),
+		stopCh:    make(chan struct{}),
 	}
 	r.initServers()
 
@@ -80,27 +121,127 @@ func (r *Upstream) Close() {
 	if tr, ok := r.httpClient.Transport.(*http.Transport); ok {
 		tr.CloseIdleConnections()
 	}
+	if r.fetcher != nil {
+		r.fetcher.CloseIdleConnections()
+	}
+	r.proxiesMu.Lock()
+	for addr, p := range r.proxies {
+		p.Close()
+		delete(r.proxies, addr)
+	}
+	r.proxiesMu.Unlock()
+
+	r.targetsMu.Lock()
+	states := append([]*upstreamState(nil), r.servers...)
+	for _, s := range r.targets {
+		states = append(states, s)
+	}
+	r.targetsMu.Unlock()
+	for _, s := range states {
+		s.close()
+	}
+}
+
+func (s *upstreamState) close() {
+	if s.stream != nil {
+		s.stream.close()
+	}
+	if s.doq != nil {
+		s.doq.close()
+	}
 }
 
 func (r *Upstream) initServers() {
 	r.servers = make([]*upstreamState, 0, len(r.config.Servers))
 	for _, s := range r.config.Servers {
-		r.servers = append(r.servers, &upstreamState{server: s})
+		r.servers = append(r.servers, r.newServerState(s))
 	}
 }
 
+// newServerState sets up a server given as https://host/path (DoH),
+// tls://host[:port] (DoT), quic://host[:port] (DoQ) or udp:// and tcp://
+// host[:port] for plain DNS.
+func (r *Upstream) newServerState(server string) *upstreamState {
+	s := &upstreamState{server: server, proto: protoDoH}
+	u, err := url.Parse(server)
+	if err != nil {
+		s.err = fmt.Errorf("invalid upstream %q: %w", server, err)
+		return s
+	}
+	scheme := strings.ToLower(u.Scheme)
+	switch scheme {
+	case protoDoH:
+		return s
+	case protoDoT, protoDoQ, protoUDP, protoTCP:
+	default:
+		s.err = fmt.Errorf("unsupported upstream scheme %q in %q", u.Scheme, server)
+		return s
+	}
+	if u.Hostname() == "" {
+		s.err = fmt.Errorf("upstream %q has no host", server)
+		return s
+	}
+	s.proto = scheme
+	port := u.Port()
+	if port == "" {
+		port = defaultDoTPort
+		if scheme == protoUDP || scheme == protoTCP {
+			port = defaultDNSPort
+		}
+	}
+	addr := net.JoinHostPort(u.Hostname(), port)
+	switch scheme {
+	case protoTCP:
+		s.stream = &streamClient{addr: addr, dial: r.dialContext}
+		return s
+	case protoUDP:
+		s.udp = &udpClient{
+			host:    u.Hostname(),
+			port:    port,
+			resolve: r.dialIPs,
+			tcp:     &streamClient{addr: addr, dial: r.dialContext},
+		}
+		return s
+	}
+	tlsConf := &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: r.config.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if scheme == protoDoT {
+		s.stream = &streamClient{addr: addr, tls: tlsConf, dial: r.dialContext}
+		return s
+	}
+	if s.doq, err = newDoqClient(addr, tlsConf, secs(r.config.TLSHandshakeTimeout), r.dialIPs); err != nil {
+		s.err = fmt.Errorf("invalid upstream %q: %w", server, err)
+	}
+	return s
+}
+
 type ServerStat struct {
 	Server      string
+	Protocol    string
 	Successes   uint64
 	Failures    uint64
 	LastLatency time.Duration
 }
 
+// ServerStats reports the servers of doh.servers followed by the custom-resolve
+// targets used so far.
 func (r *Upstream) ServerStats() []ServerStat {
-	out := make([]ServerStat, 0, len(r.servers))
-	for _, s := range r.servers {
+	r.targetsMu.Lock()
+	targets := make([]*upstreamState, 0, len(r.targets))
+	for _, s := range r.targets {
+		targets = append(targets, s)
+	}
+	r.targetsMu.Unlock()
+	sort.Slice(targets, func(i, j int) bool { return targets[i].server < targets[j].server })
+
+	out := make([]ServerStat, 0, len(r.servers)+len(targets))
+	for _, s := range append(append([]*upstreamState(nil), r.servers...), targets...) {
 		out = append(out, ServerStat{
 			Server:      s.server,
+			Protocol:    s.proto,
 			Successes:   s.successes.Load(),
 			Failures:    s.failures.Load(),
 			LastLatency: time.Duration(s.lastLatency.Load()),
@@ -0,0 +1,135 @@
+package resolver
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ApostolDmitry/vpner/internal/logx"
+	"github.com/miekg/dns"
+)
+
+// lanZones are the usual LAN suffixes. They go to local-dns only when it is
+// set; otherwise they resolve like any other name, since some routers hand
+// them out from the upstream the client already uses.
+var lanZones = []string{
+	"lan",
+	"local",
+	"internal",
+	"home.arpa",
+}
+
+// defaultLocalZones never make sense to ask public resolvers about: private
+// and special-use reverse zones (RFC 6303, RFC 7793). They go to local-dns,
+// or get NXDOMAIN when it is not set.
+var defaultLocalZones = []string{
+	"10.in-addr.arpa",
+	"168.192.in-addr.arpa",
+	"254.169.in-addr.arpa",
+	"127.in-addr.arpa",
+	"0.in-addr.arpa",
+	"2.0.192.in-addr.arpa",
+	"100.51.198.in-addr.arpa",
+	"113.0.203.in-addr.arpa",
+	"255.255.255.255.in-addr.arpa",
+	"d.f.ip6.arpa",
+	"8.e.f.ip6.arpa",
+	"9.e.f.ip6.arpa",
+	"a.e.f.ip6.arpa",
+	"b.e.f.ip6.arpa",
+	"8.b.d.0.1.0.0.2.ip6.arpa",
+	"0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.ip6.arpa",
+	"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.ip6.arpa",
+}
+
+func init() {
+	for i := 16; i < 32; i++ {
+		defaultLocalZones = append(defaultLocalZones, fmt.Sprintf("%d.172.in-addr.arpa", i))
+	}
+	for i := 64; i < 128; i++ {
+		defaultLocalZones = append(defaultLocalZones, fmt.Sprintf("%d.100.in-addr.arpa", i))
+	}
+}
+
+type forwardZone struct {
+	upstreams []string
+	// builtin zones give way to custom-resolve patterns that cover the name.
+	builtin bool
+}
+
+// compileForwardZones merges the built-in local zones with the configured
+// ones; a configured zone replaces the default of the same name. The LAN
+// suffixes are built in only when local-dns is set.
+func compileForwardZones(zones map[string][]string, localDNS string) map[string]forwardZone {
+	out := make(map[string]forwardZone, len(defaultLocalZones)+len(lanZones)+len(zones))
+	var def []string
+	if localDNS != "" {
+		def = []string{localDNS}
+		for _, zone := range lanZones {
+			out[zone] = forwardZone{upstreams: def, builtin: true}
+		}
+	}
+	for _, zone := range defaultLocalZones {
+		out[zone] = forwardZone{upstreams: def, builtin: true}
+	}
+	for zone, upstreams := range zones {
+		zone = strings.ToLower(strings.Trim(strings.TrimSpace(zone), "."))
+		if zone == "" {
+			logx.Errorf("forward-zones: empty zone name")
+			continue
+		}
+		out[zone] = forwardZone{upstreams: upstreams}
+	}
+	return out
+}
+
+// forwardZone finds the most specific zone domain falls in. A built-in zone
+// is skipped when a custom-resolve pattern covers domain, so existing
+// custom-resolve rules for LAN names keep working.
+func (s *Server) forwardZone(domain string) (string, []string, bool) {
+	name := strings.ToLower(domain)
+	for name != "" {
+		if zone, ok := s.forwardZones[name]; ok {
+			if zone.builtin && s.matchCustomResolver(domain) != "" {
+				return "", nil, false
+			}
+			return name, zone.upstreams, true
+		}
+		dot := strings.IndexByte(name, '.')
+		if dot < 0 {
+			break
+		}
+		name = name[dot+1:]
+	}
+	return "", nil, false
+}
+
+// zoneAnswer resolves queries for forwarded zones with the zone's upstreams.
+// Zones without upstreams are answered with NXDOMAIN so they never leave the
+// router. The answer is neither cached nor synced into the ipsets.
+func (s *Server) zoneAnswer(r *dns.Msg, domain string) (*dns.Msg, string, error) {
+	if domain == "" {
+		return nil, "", nil
+	}
+	zone, upstreams, ok := s.forwardZone(domain)
+	if !ok {
+		return nil, "", nil
+	}
+	if len(upstreams) == 0 {
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeNameError)
+		m.Authoritative = true
+		m.RecursionAvailable = true
+		return m, zone, nil
+	}
+	var errs []error
+	for _, upstream := range upstreams {
+		resp, err := s.exchangeCustom(upstream, r)
+		if err == nil {
+			resp.Id = r.Id
+			return resp, upstream, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", upstream, err))
+	}
+	return nil, zone, fmt.Errorf("zone %s: %w", zone, errors.Join(errs...))
+}
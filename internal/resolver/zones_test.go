@@ -0,0 +1,88 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/ApostolDmitry/vpner/internal/conf"
+	"github.com/miekg/dns"
+)
+
+func TestForwardZoneMatch(t *testing.T) {
+	s := NewServer(conf.ServerConfig{
+		LocalDNS: "192.168.1.1:53",
+		ForwardZones: map[string][]string{
+			"Corp.Example.": {"10.0.0.53:53"},
+			"local":         {},
+		},
+	}, nil, nil)
+
+	cases := []struct {
+		domain, zone, upstream string
+		ok                     bool
+	}{
+		{"printer.lan", "lan", "192.168.1.1:53", true},
+		{"5.1.168.192.in-addr.arpa", "168.192.in-addr.arpa", "192.168.1.1:53", true},
+		{"1.0.20.172.in-addr.arpa", "20.172.in-addr.arpa", "192.168.1.1:53", true},
+		{"1.0.32.172.in-addr.arpa", "", "", false},
+		{"wiki.corp.example", "corp.example", "10.0.0.53:53", true},
+		{"example", "", "", false},
+		{"tv.local", "local", "", true},
+	}
+	for _, c := range cases {
+		zone, upstreams, ok := s.forwardZone(c.domain)
+		if ok != c.ok || zone != c.zone {
+			t.Errorf("%s: got zone %q %v, want %q %v", c.domain, zone, ok, c.zone, c.ok)
+			continue
+		}
+		if c.upstream != "" && (len(upstreams) != 1 || upstreams[0] != c.upstream) {
+			t.Errorf("%s: upstreams %v", c.domain, upstreams)
+		}
+		if c.ok && c.upstream == "" && len(upstreams) != 0 {
+			t.Errorf("%s: expected no upstreams, got %v", c.domain, upstreams)
+		}
+	}
+}
+
+func TestZoneAnswerWithoutLocalDNS(t *testing.T) {
+	s := NewServer(conf.ServerConfig{}, nil, nil)
+	r := new(dns.Msg)
+	r.SetQuestion("10.1.168.192.in-addr.arpa.", dns.TypePTR)
+	resp, zone, err := s.zoneAnswer(r, "10.1.168.192.in-addr.arpa")
+	if err != nil || resp == nil || resp.Rcode != dns.RcodeNameError || zone != "168.192.in-addr.arpa" {
+		t.Fatalf("expected NXDOMAIN from %s, got %v %v", zone, resp, err)
+	}
+	for _, name := range []string{"example.com", "printer.lan", "nas.home.arpa"} {
+		r.SetQuestion(dns.Fqdn(name), dns.TypeA)
+		if resp, zone, _ := s.zoneAnswer(r, name); resp != nil {
+			t.Fatalf("%s answered by forward zone %q without local-dns: %v", name, zone, resp)
+		}
+	}
+}
+
+func TestCustomResolveOverridesBuiltinZones(t *testing.T) {
+	s := NewServer(conf.ServerConfig{
+		LocalDNS:      "192.168.1.1:53",
+		CustomResolve: map[string][]string{"10.0.0.1:53": {"*.lan"}},
+		ForwardZones:  map[string][]string{"corp": {"10.0.0.53:53"}},
+	}, nil, nil)
+
+	r := new(dns.Msg)
+	r.SetQuestion("printer.lan.", dns.TypeA)
+	if resp, _, err := s.zoneAnswer(r, "printer.lan"); resp != nil || err != nil {
+		t.Fatalf("built-in zone answered a custom-resolve name: %v %v", resp, err)
+	}
+	if got := s.matchCustomResolver("printer.lan"); got != "10.0.0.1:53" {
+		t.Fatalf("custom resolver not matched: %q", got)
+	}
+	if _, _, ok := s.forwardZone("tv.local"); !ok {
+		t.Fatal("built-in zone without a custom-resolve pattern skipped")
+	}
+
+	s = NewServer(conf.ServerConfig{
+		CustomResolve: map[string][]string{"10.0.0.1:53": {"*.corp"}},
+		ForwardZones:  map[string][]string{"corp": {"10.0.0.53:53"}},
+	}, nil, nil)
+	if zone, upstreams, ok := s.forwardZone("wiki.corp"); !ok || zone != "corp" || upstreams[0] != "10.0.0.53:53" {
+		t.Fatalf("configured zone lost to custom-resolve: %q %v %v", zone, upstreams, ok)
+	}
+}
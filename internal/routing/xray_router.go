@@ -1,16 +1,26 @@
 package routing
 
 import (
+	"context"
+	"maps"
+	"net"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/ApostolDmitry/vpner/internal/firewall"
 	"github.com/ApostolDmitry/vpner/internal/logx"
 	proxy "github.com/ApostolDmitry/vpner/internal/proxy"
 )
 
+const serverLookupTimeout = 3 * time.Second
+
 type XrayRouter struct {
 	iptables  *firewall.IptablesManager
 	lanIfaces []string
+
+	serversMu sync.Mutex
+	servers   map[string][]string
 }
 
 func NewXrayRouter(ipt *firewall.IptablesManager, lanInterfaces []string) *XrayRouter {
@@ -23,13 +33,68 @@ func NewXrayRouter(ipt *firewall.IptablesManager, lanInterfaces []string) *XrayR
 	if len(lanIfaces) == 0 {
 		lanIfaces = []string{"br0"}
 	}
-	return &XrayRouter{iptables: ipt, lanIfaces: lanIfaces}
+	return &XrayRouter{iptables: ipt, lanIfaces: lanIfaces, servers: make(map[string][]string)}
+}
+
+func (r *XrayRouter) serverAddrs(host string, refresh bool) []string {
+	host = strings.TrimSpace(host)
+	if host == "" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{ip.String()}
+	}
+
+	r.serversMu.Lock()
+	cached, ok := r.servers[host]
+	r.serversMu.Unlock()
+	if ok && !refresh {
+		return cached
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), serverLookupTimeout)
+	defer cancel()
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		logx.Warnf("resolve proxy server %s: %v", host, err)
+		return cached
+	}
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, ip.IP.String())
+	}
+
+	r.serversMu.Lock()
+	r.servers[host] = addrs
+	r.serversMu.Unlock()
+	return addrs
 }
 
 func (r *XrayRouter) ready() bool {
 	return r != nil && r.iptables != nil
 }
 
+// Plan runs fn against a shadow router whose firewall commands are recorded
+// instead of executed. An empty chain plans for every chain.
+func (r *XrayRouter) Plan(chain string, fn func(*XrayRouter) error) (*firewall.Plan, error) {
+	if !r.ready() {
+		return &firewall.Plan{}, nil
+	}
+	r.serversMu.Lock()
+	servers := maps.Clone(r.servers)
+	r.serversMu.Unlock()
+	return r.iptables.Plan(chain, func(ipt *firewall.IptablesManager) error {
+		return fn(&XrayRouter{iptables: ipt, lanIfaces: r.lanIfaces, servers: servers})
+	})
+}
+
+func (r *XrayRouter) State(chain string) []firewall.ChainState {
+	if !r.ready() {
+		return nil
+	}
+	return r.iptables.RoutingState(chain)
+}
+
 func (r *XrayRouter) RoutingIntact() bool {
 	if !r.ready() {
 		return true
@@ -42,25 +107,79 @@ func (r *XrayRouter) Apply(chain string, info proxy.ChainInfo) error {
 		return nil
 	}
 
-	spec, state, err := r.iptables.PrepareXrayChain(chain, info.InboundPort, r.lanIfaces)
+	spec, state, err := r.iptables.PrepareXrayChain(chain, info.InboundPort, r.lanIfaces, r.serverAddrs(info.Host, true), info.UDPPolicy)
 	if err != nil {
 		return err
 	}
-	if state.V4Applied && (state.V6Applied || !r.iptables.IPv6Enabled()) {
+	if !state.V4Applied || (!state.V6Applied && r.iptables.IPv6Enabled()) {
+		if r.iptables.TProxyEnabled() {
+			err = r.iptables.BatchApplyAllTProxy([]firewall.ChainSpec{spec})
+		} else {
+			err = r.iptables.BatchApplyAllRedirect([]firewall.ChainSpec{spec})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return r.iptables.ReleaseKillSwitch(chain)
+}
+
+func (r *XrayRouter) Remove(chain string, info proxy.ChainInfo) error {
+	if !r.ready() {
+		return nil
+	}
+	if err := r.iptables.RemoveXrayChain(chain); err != nil {
+		return err
+	}
+	if !info.KillSwitch.Enabled() {
+		return nil
+	}
+	return r.iptables.EngageKillSwitch(chain, info.KillSwitch, r.lanIfaces)
+}
+
+func (r *XrayRouter) Purge(chain string) error {
+	if !r.ready() {
+		return nil
+	}
+	if err := r.iptables.RemoveXrayChain(chain); err != nil {
+		return err
+	}
+	return r.iptables.ReleaseKillSwitch(chain)
+}
+
+func (r *XrayRouter) UpdateKillSwitch(chain string, info proxy.ChainInfo, down bool) error {
+	if !r.ready() {
 		return nil
 	}
+	engaged := r.iptables.KillSwitchEngaged(chain)
+	switch {
+	case down && info.KillSwitch.Enabled() && !engaged:
+		return r.iptables.EngageKillSwitch(chain, info.KillSwitch, r.lanIfaces)
+	case (!down || !info.KillSwitch.Enabled()) && engaged:
+		return r.iptables.ReleaseKillSwitch(chain)
+	}
+	return nil
+}
 
-	if r.iptables.TProxyEnabled() {
-		return r.iptables.BatchApplyAllTProxy([]firewall.ChainSpec{spec})
+func (r *XrayRouter) KillSwitchEngaged(chain string) bool {
+	if !r.ready() {
+		return false
 	}
-	return r.iptables.BatchApplyAllRedirect([]firewall.ChainSpec{spec})
+	return r.iptables.KillSwitchEngaged(chain)
 }
 
-func (r *XrayRouter) Remove(chain string) error {
+func (r *XrayRouter) UpdateUDPPolicy(chain string, info proxy.ChainInfo) error {
 	if !r.ready() {
 		return nil
 	}
-	return r.iptables.RemoveXrayChain(chain)
+	return r.iptables.SetUDPPolicy(chain, info.UDPPolicy)
+}
+
+func (r *XrayRouter) UDPPolicyActive(info proxy.ChainInfo) string {
+	if !r.ready() {
+		return info.UDPPolicy.String()
+	}
+	return r.iptables.UDPPolicyActive(info.UDPPolicy)
 }
 
 func (r *XrayRouter) ClearAppliedState(table string, clearV4, clearV6 bool) {
@@ -92,9 +211,12 @@ func (r *XrayRouter) Restore(info map[string]proxy.ChainInfo, isRunning func(str
 	if table == "" || table == xrayTable {
 		for name, cfg := range info {
 			if isRunning != nil && !isRunning(name) {
+				if err := r.UpdateKillSwitch(name, cfg, true); err != nil {
+					logx.Errorf("kill switch for chain %s: %v", name, err)
+				}
 				continue
 			}
-			if _, _, err := r.iptables.PrepareXrayChain(name, cfg.InboundPort, r.lanIfaces); err != nil {
+			if _, _, err := r.iptables.PrepareXrayChain(name, cfg.InboundPort, r.lanIfaces, r.serverAddrs(cfg.Host, false), cfg.UDPPolicy); err != nil {
 				logx.Errorf("prepare xray chain %s: %v", name, err)
 			}
 		}
@@ -108,5 +230,6 @@ func (r *XrayRouter) Shutdown() {
 		return
 	}
 	r.iptables.RemoveAllXrayRoutes()
+	r.iptables.ReleaseAllKillSwitches()
 	r.iptables.Shutdown()
 }
@@ -0,0 +1,76 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/ApostolDmitry/vpner/internal/clientgroup"
+	grpcpb "github.com/ApostolDmitry/vpner/internal/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *VpnerServer) ClientGroupList(ctx context.Context, _ *grpcpb.Empty) (*grpcpb.ClientGroupListResponse, error) {
+	cfg, err := s.clients.List()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to retrieve client groups: %v", err)
+	}
+
+	resp := &grpcpb.ClientGroupListResponse{}
+	for name, members := range cfg.Groups {
+		resp.Groups = append(resp.Groups, &grpcpb.ClientGroupInfo{
+			Name:       name,
+			Members:    members,
+			FullTunnel: cfg.FullTunnel[name],
+		})
+	}
+	for chain, policy := range cfg.Policies {
+		resp.Policies = append(resp.Policies, &grpcpb.ClientPolicyInfo{
+			ChainName: chain,
+			Only:      policy.Only,
+			Except:    policy.Except,
+		})
+	}
+	sort.Slice(resp.Groups, func(i, j int) bool { return resp.Groups[i].Name < resp.Groups[j].Name })
+	sort.Slice(resp.Policies, func(i, j int) bool { return resp.Policies[i].ChainName < resp.Policies[j].ChainName })
+	return resp, nil
+}
+
+func (s *VpnerServer) ClientGroupAdd(ctx context.Context, req *grpcpb.ClientGroupRequest) (*grpcpb.GenericResponse, error) {
+	if err := s.clients.AddMembers(req.Name, req.Members); err != nil {
+		return errorGeneric(fmt.Sprintf("Failed to update client group: %v", err)), nil
+	}
+	return successGeneric(fmt.Sprintf("Client group %s updated", req.Name)), nil
+}
+
+func (s *VpnerServer) ClientGroupRemove(ctx context.Context, req *grpcpb.ClientGroupRequest) (*grpcpb.GenericResponse, error) {
+	if err := s.clients.RemoveMembers(req.Name, req.Members); err != nil {
+		return errorGeneric(fmt.Sprintf("Failed to update client group: %v", err)), nil
+	}
+	if len(req.Members) == 0 {
+		return successGeneric(fmt.Sprintf("Client group %s deleted", req.Name)), nil
+	}
+	return successGeneric(fmt.Sprintf("Client group %s updated", req.Name)), nil
+}
+
+func (s *VpnerServer) ClientGroupSetPolicy(ctx context.Context, req *grpcpb.ClientPolicyRequest) (*grpcpb.GenericResponse, error) {
+	policy := clientgroup.Policy{Only: req.Only, Except: req.Except}
+	if err := s.clients.SetPolicy(req.ChainName, policy); err != nil {
+		return errorGeneric(fmt.Sprintf("Failed to set client policy: %v", err)), nil
+	}
+	if policy.Empty() {
+		return successGeneric(fmt.Sprintf("Chain %s now applies to all clients", req.ChainName)), nil
+	}
+	return successGeneric(fmt.Sprintf("Client policy for chain %s updated", req.ChainName)), nil
+}
+
+func (s *VpnerServer) ClientGroupSetFullTunnel(ctx context.Context, req *grpcpb.ClientFullTunnelRequest) (*grpcpb.GenericResponse, error) {
+	if err := s.clients.SetFullTunnel(req.Name, req.ChainName); err != nil {
+		return errorGeneric(fmt.Sprintf("Failed to set full tunnel: %v", err)), nil
+	}
+	if req.ChainName == "" {
+		return successGeneric(fmt.Sprintf("Full tunnel disabled for client group %s", req.Name)), nil
+	}
+	return successGeneric(fmt.Sprintf("All traffic of client group %s now goes through chain %s", req.Name, req.ChainName)), nil
+}
@@ -1,11 +1,20 @@
 package rpc
 
 import (
+	"net"
 	"time"
 
+	"github.com/ApostolDmitry/vpner/internal/blocklist"
+	"github.com/ApostolDmitry/vpner/internal/chainpolicy"
+	"github.com/ApostolDmitry/vpner/internal/clientgroup"
+	"github.com/ApostolDmitry/vpner/internal/conf"
+	"github.com/ApostolDmitry/vpner/internal/dnssvc"
+	"github.com/ApostolDmitry/vpner/internal/firewall"
+	"github.com/ApostolDmitry/vpner/internal/localzone"
 	netif "github.com/ApostolDmitry/vpner/internal/netif"
 	proxy "github.com/ApostolDmitry/vpner/internal/proxy"
 	proxysvc "github.com/ApostolDmitry/vpner/internal/proxysvc"
+	"github.com/ApostolDmitry/vpner/internal/querylog"
 	"github.com/ApostolDmitry/vpner/internal/resolver"
 	routing "github.com/ApostolDmitry/vpner/internal/routing"
 	unblock "github.com/ApostolDmitry/vpner/internal/unblock"
@@ -16,6 +25,15 @@ type DNSController interface {
 	Stop()
 	IsRunning() bool
 	UpstreamStats() []resolver.ServerStat
+	CacheStats() (resolver.CacheStats, bool)
+	BlocklistStats() []blocklist.ListStat
+	Records() []localzone.Entry
+	AddRecord(rec conf.StaticRecord) error
+	DeleteRecord(name, typ, value string) (int, error)
+	QueryLog(f querylog.Filter, limit int) ([]querylog.Entry, error)
+	FollowQueryLog() (<-chan querylog.Entry, func(), error)
+	Trace(domain string, qtype uint16) resolver.Trace
+	WarmRule(pattern string)
 }
 
 type InterfaceController interface {
@@ -37,6 +55,8 @@ type XrayController interface {
 	Update(name, link string) error
 	Delete(name string) error
 	SetAutorun(name string, autoRun bool) error
+	SetKillSwitch(name string, mode chainpolicy.KillSwitch) error
+	SetUDPPolicy(name string, policy chainpolicy.UDPPolicy) error
 	IsChain(name string) bool
 	Runtimes() map[string]proxysvc.ChainRuntime
 	Test(name string) (string, error)
@@ -46,29 +66,52 @@ type UnblockController interface {
 	List() ([]unblock.RuleGroup, error)
 	AddRule(chainName, pattern string) error
 	DeleteRule(pattern string) error
+	PlanAddRule(chainName, pattern string) (*firewall.Plan, error)
+	PlanDeleteRule(pattern string) (*firewall.Plan, error)
+	MatchDomain(domain string) (string, string, string, bool)
+	MatchIP(ip net.IP) (string, string, string, bool)
+	TraceEntries(vpnType, chainName, domain string, ips []net.IP) ([]firewall.SetEntry, error)
+	SetUsage() []firewall.SetUsage
 	DeleteChain(vpnType, chainName string) error
 }
 
+type ClientGroupController interface {
+	List() (*clientgroup.Config, error)
+	AddMembers(group string, members []string) error
+	RemoveMembers(group string, members []string) error
+	SetPolicy(chain string, policy clientgroup.Policy) error
+	SetFullTunnel(group, chain string) error
+}
+
 type RoutingController interface {
 	Apply(chain string, info proxy.ChainInfo) error
-	Remove(chain string) error
+	Remove(chain string, info proxy.ChainInfo) error
+	Purge(chain string) error
+	UpdateKillSwitch(chain string, info proxy.ChainInfo, down bool) error
+	KillSwitchEngaged(chain string) bool
+	UpdateUDPPolicy(chain string, info proxy.ChainInfo) error
+	UDPPolicyActive(info proxy.ChainInfo) string
 	Restore(info map[string]proxy.ChainInfo, isRunning func(string) bool, restoreV4, restoreV6 bool, table string)
 	Shutdown()
 	ClearAppliedState(table string, clearV4, clearV6 bool)
 	ResetStateFamily(resetV4, resetV6 bool)
 	RoutingIntact() bool
+	Plan(chain string, fn func(*routing.XrayRouter) error) (*firewall.Plan, error)
+	State(chain string) []firewall.ChainState
 }
 
 type StatusInfo struct {
-	Version       string
-	StartedAt     time.Time
-	DNSPort       int
-	TProxyEnabled bool
+	Version         string
+	StartedAt       time.Time
+	DNSPort         int
+	TProxyEnabled   bool
+	FirewallBackend string
 }
 
 type Dependencies struct {
 	DNS              DNSController
 	Unblock          UnblockController
+	ClientGroups     ClientGroupController
 	InterfaceManager InterfaceController
 	XrayService      XrayController
 	XrayRouter       RoutingController
@@ -79,6 +122,7 @@ func NewVpnerServer(deps Dependencies) *VpnerServer {
 	return &VpnerServer{
 		dns:         deps.DNS,
 		unblock:     deps.Unblock,
+		clients:     deps.ClientGroups,
 		ifManager:   deps.InterfaceManager,
 		xrayService: deps.XrayService,
 		xrayRouter:  deps.XrayRouter,
@@ -88,5 +132,7 @@ func NewVpnerServer(deps Dependencies) *VpnerServer {
 
 var _ XrayController = (*proxysvc.Service)(nil)
 var _ UnblockController = (*unblock.Service)(nil)
+var _ ClientGroupController = (*clientgroup.Service)(nil)
+var _ DNSController = (*dnssvc.Service)(nil)
 var _ InterfaceController = (*netif.Manager)(nil)
 var _ RoutingController = (*routing.XrayRouter)(nil)
@@ -4,7 +4,11 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/ApostolDmitry/vpner/internal/conf"
 	grpcpb "github.com/ApostolDmitry/vpner/internal/grpc"
+	"github.com/ApostolDmitry/vpner/internal/querylog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func (s *VpnerServer) DnsManage(ctx context.Context, req *grpcpb.ManageRequest) (*grpcpb.GenericResponse, error) {
@@ -33,3 +37,110 @@ func (s *VpnerServer) DnsManage(ctx context.Context, req *grpcpb.ManageRequest)
 		return errorGeneric("Unknown DNS management action"), nil
 	}
 }
+
+func (s *VpnerServer) DnsRecordList(ctx context.Context, _ *grpcpb.Empty) (*grpcpb.DnsRecordListResponse, error) {
+	resp := &grpcpb.DnsRecordListResponse{}
+	for _, e := range s.dns.Records() {
+		resp.Records = append(resp.Records, &grpcpb.DnsRecord{
+			Name:   e.Name,
+			Type:   e.Type,
+			Value:  e.Value,
+			Ttl:    int32(e.TTL),
+			Source: e.Source,
+		})
+	}
+	return resp, nil
+}
+
+func (s *VpnerServer) DnsRecordAdd(ctx context.Context, req *grpcpb.DnsRecord) (*grpcpb.GenericResponse, error) {
+	err := s.dns.AddRecord(conf.StaticRecord{
+		Name:  req.Name,
+		Type:  req.Type,
+		Value: req.Value,
+		TTL:   int(req.Ttl),
+	})
+	if err != nil {
+		return errorGeneric(fmt.Sprintf("Failed to add record: %v", err)), nil
+	}
+	return successGeneric("Record added successfully"), nil
+}
+
+func (s *VpnerServer) DnsRecordDel(ctx context.Context, req *grpcpb.DnsRecord) (*grpcpb.GenericResponse, error) {
+	n, err := s.dns.DeleteRecord(req.Name, req.Type, req.Value)
+	if err != nil {
+		return errorGeneric(fmt.Sprintf("Failed to delete record: %v", err)), nil
+	}
+	return successGeneric(fmt.Sprintf("Deleted %d record(s)", n)), nil
+}
+
+func (s *VpnerServer) DnsQueryLog(ctx context.Context, req *grpcpb.DnsQueryLogRequest) (*grpcpb.DnsQueryLogResponse, error) {
+	entries, err := s.dns.QueryLog(queryLogFilter(req), int(req.Limit))
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	resp := &grpcpb.DnsQueryLogResponse{}
+	for _, e := range entries {
+		resp.Entries = append(resp.Entries, queryLogEntry(e))
+	}
+	return resp, nil
+}
+
+// DnsQueryLogFollow sends the latest limit matching queries, then every new
+// one until the client goes away.
+func (s *VpnerServer) DnsQueryLogFollow(req *grpcpb.DnsQueryLogRequest, stream grpcpb.VpnerManager_DnsQueryLogFollowServer) error {
+	filter := queryLogFilter(req)
+	ch, cancel, err := s.dns.FollowQueryLog()
+	if err != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	defer cancel()
+
+	if req.Limit > 0 {
+		entries, _ := s.dns.QueryLog(filter, int(req.Limit))
+		for _, e := range entries {
+			if err := stream.Send(queryLogEntry(e)); err != nil {
+				return err
+			}
+		}
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e := <-ch:
+			if !filter.Match(e) {
+				continue
+			}
+			if err := stream.Send(queryLogEntry(e)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func queryLogFilter(req *grpcpb.DnsQueryLogRequest) querylog.Filter {
+	return querylog.Filter{
+		Client: req.Client,
+		Domain: req.Domain,
+		Type:   req.Type,
+		Rcode:  req.Rcode,
+		Source: req.Source,
+	}
+}
+
+func queryLogEntry(e querylog.Entry) *grpcpb.DnsQueryLogEntry {
+	return &grpcpb.DnsQueryLogEntry{
+		TimeUnixMs: e.Time.UnixMilli(),
+		Client:     e.Client,
+		Name:       e.Name,
+		Type:       e.Type,
+		Rcode:      e.Rcode,
+		Answers:    e.Answers,
+		Source:     e.Source,
+		Upstream:   e.Upstream,
+		Cached:     e.Cached,
+		Rule:       e.Rule,
+		Chain:      e.Chain,
+		LatencyUs:  e.Latency.Microseconds(),
+	}
+}
@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"github.com/ApostolDmitry/vpner/internal/firewall"
 	grpcpb "github.com/ApostolDmitry/vpner/internal/grpc"
 )
 
@@ -30,3 +31,33 @@ func errorGeneric(msg string) *grpcpb.GenericResponse {
 		},
 	}
 }
+
+func planGeneric(msg string, plan *firewall.Plan) *grpcpb.GenericResponse {
+	resp := successGeneric(msg)
+	resp.Plan = planToProto(plan)
+	return resp
+}
+
+func planToProto(plan *firewall.Plan) *grpcpb.Plan {
+	out := &grpcpb.Plan{}
+	if plan == nil {
+		return out
+	}
+	for _, step := range plan.Steps {
+		out.Steps = append(out.Steps, &grpcpb.PlanStep{
+			Tool:  step.Tool,
+			Table: step.Table,
+			Args:  step.Args,
+			Lines: step.Lines,
+		})
+	}
+	for _, d := range plan.Diff {
+		out.Diff = append(out.Diff, &grpcpb.PlanDiff{
+			Tool:    d.Tool,
+			Table:   d.Table,
+			Added:   d.Added,
+			Removed: d.Removed,
+		})
+	}
+	return out
+}
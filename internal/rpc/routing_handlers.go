@@ -0,0 +1,66 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/ApostolDmitry/vpner/internal/firewall"
+	grpcpb "github.com/ApostolDmitry/vpner/internal/grpc"
+	routing "github.com/ApostolDmitry/vpner/internal/routing"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *VpnerServer) RoutingPlan(_ context.Context, req *grpcpb.RoutingPlanRequest) (*grpcpb.Plan, error) {
+	if req.ChainName != "" && !s.xrayService.IsChain(req.ChainName) {
+		return nil, status.Errorf(codes.NotFound, "chain %q does not exist", req.ChainName)
+	}
+	if s.xrayRouter == nil {
+		return planToProto(nil), nil
+	}
+	plan, err := s.xrayRouter.Plan(req.ChainName, func(r *routing.XrayRouter) error {
+		r.ClearAppliedState("", true, true)
+		s.restoreXrayRouting(r, req.ChainName, true, true, "")
+		return nil
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to plan routing: %v", err)
+	}
+	return planToProto(plan), nil
+}
+
+func (s *VpnerServer) RoutingState(_ context.Context, req *grpcpb.RoutingStateRequest) (*grpcpb.RoutingStateResponse, error) {
+	if req.ChainName != "" && !s.xrayService.IsChain(req.ChainName) {
+		return nil, status.Errorf(codes.NotFound, "chain %q does not exist", req.ChainName)
+	}
+	resp := &grpcpb.RoutingStateResponse{}
+	if s.xrayRouter == nil {
+		return resp, nil
+	}
+	for _, st := range s.xrayRouter.State(req.ChainName) {
+		resp.Chains = append(resp.Chains, chainStateToProto(st))
+	}
+	return resp, nil
+}
+
+func chainStateToProto(st firewall.ChainState) *grpcpb.RoutingChainState {
+	item := &grpcpb.RoutingChainState{
+		Chain:         st.Chain,
+		VpnType:       st.VPNType,
+		Family:        st.Family,
+		Table:         st.Table,
+		ChainName:     st.ChainName,
+		ChainPresent:  st.ChainPresent,
+		TargetPresent: st.TargetPresent,
+		IpsetName:     st.IPSetName,
+		IpsetPresent:  st.IPSetPresent,
+		IpsetEntries:  int32(st.IPSetEntries),
+		Mark:          st.Mark,
+		RouteTable:    int32(st.RouteTable),
+		IpRulePresent: st.IPRulePresent,
+		Tproxy:        st.TProxy,
+	}
+	for _, j := range st.Jumps {
+		item.Jumps = append(item.Jumps, &grpcpb.RoutingJump{Rule: j.Rule, Present: j.Present})
+	}
+	return item
+}
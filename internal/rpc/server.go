@@ -8,6 +8,7 @@ type VpnerServer struct {
 	grpcpb.UnimplementedVpnerManagerServer
 	dns         DNSController
 	unblock     UnblockController
+	clients     ClientGroupController
 	ifManager   InterfaceController
 	xrayService XrayController
 	xrayRouter  RoutingController
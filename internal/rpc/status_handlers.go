@@ -5,15 +5,17 @@ import (
 	"time"
 
 	grpcpb "github.com/ApostolDmitry/vpner/internal/grpc"
+	proxy "github.com/ApostolDmitry/vpner/internal/proxy"
 )
 
 func (s *VpnerServer) Status(_ context.Context, _ *grpcpb.Empty) (*grpcpb.StatusResponse, error) {
 	resp := &grpcpb.StatusResponse{
-		Version:       s.info.Version,
-		UptimeSeconds: int64(time.Since(s.info.StartedAt).Seconds()),
-		DnsRunning:    s.dns.IsRunning(),
-		DnsPort:       int32(s.info.DNSPort),
-		TproxyEnabled: s.info.TProxyEnabled,
+		Version:         s.info.Version,
+		UptimeSeconds:   int64(time.Since(s.info.StartedAt).Seconds()),
+		DnsRunning:      s.dns.IsRunning(),
+		DnsPort:         int32(s.info.DNSPort),
+		TproxyEnabled:   s.info.TProxyEnabled,
+		FirewallBackend: s.info.FirewallBackend,
 	}
 
 	runtimes := s.xrayService.Runtimes()
@@ -23,16 +25,20 @@ func (s *VpnerServer) Status(_ context.Context, _ *grpcpb.Empty) (*grpcpb.Status
 			listed[name] = true
 			rt := runtimes[name]
 			resp.Chains = append(resp.Chains, &grpcpb.ChainStatus{
-				Name:          name,
-				Type:          info.Type,
-				Host:          info.Host,
-				Port:          int32(info.Port),
-				InboundPort:   int32(info.InboundPort),
-				AutoRun:       info.AutoRun,
-				Running:       rt.Running,
-				Restarts:      int32(rt.Restarts),
-				UptimeSeconds: int64(rt.Uptime.Seconds()),
-				LastExit:      rt.LastExit,
+				Name:              name,
+				Type:              info.Type,
+				Host:              info.Host,
+				Port:              int32(info.Port),
+				InboundPort:       int32(info.InboundPort),
+				AutoRun:           info.AutoRun,
+				Running:           rt.Running,
+				Restarts:          int32(rt.Restarts),
+				UptimeSeconds:     int64(rt.Uptime.Seconds()),
+				LastExit:          rt.LastExit,
+				KillSwitch:        info.KillSwitch.String(),
+				KillSwitchEngaged: s.xrayRouter != nil && s.xrayRouter.KillSwitchEngaged(name),
+				UdpPolicy:         info.UDPPolicy.String(),
+				UdpPolicyActive:   s.udpPolicyActive(info),
 			})
 		}
 	}
@@ -56,14 +62,57 @@ func (s *VpnerServer) Status(_ context.Context, _ *grpcpb.Empty) (*grpcpb.Status
 		}
 	}
 
+	for _, u := range s.unblock.SetUsage() {
+		resp.Ipsets = append(resp.Ipsets, &grpcpb.IPSetUsage{
+			Name:       u.Name,
+			Chain:      u.Chain,
+			Entries:    int32(u.Entries),
+			MaxEntries: int32(u.MaxEntries),
+		})
+	}
+
 	for _, st := range s.dns.UpstreamStats() {
 		resp.DohServers = append(resp.DohServers, &grpcpb.DohServerStatus{
 			Server:        st.Server,
 			Successes:     st.Successes,
 			Failures:      st.Failures,
 			LastLatencyMs: st.LastLatency.Milliseconds(),
+			Protocol:      st.Protocol,
 		})
 	}
 
+	if st, ok := s.dns.CacheStats(); ok {
+		resp.DnsCache = &grpcpb.DnsCacheStatus{
+			Entries:  int32(st.Entries),
+			Hits:     st.Hits,
+			Misses:   st.Misses,
+			Stale:    st.Stale,
+			Prefetch: st.Prefetch,
+		}
+	}
+
+	for _, st := range s.dns.BlocklistStats() {
+		bs := &grpcpb.BlocklistStatus{
+			Name:   st.Name,
+			Source: st.Source,
+			Rules:  int32(st.Rules),
+			Hits:   st.Hits,
+		}
+		if !st.Updated.IsZero() {
+			bs.UpdatedUnix = st.Updated.Unix()
+		}
+		if st.Err != nil {
+			bs.Error = st.Err.Error()
+		}
+		resp.Blocklists = append(resp.Blocklists, bs)
+	}
+
 	return resp, nil
 }
+
+func (s *VpnerServer) udpPolicyActive(info proxy.ChainInfo) string {
+	if s.xrayRouter == nil {
+		return info.UDPPolicy.String()
+	}
+	return s.xrayRouter.UDPPolicyActive(info)
+}
@@ -0,0 +1,163 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/ApostolDmitry/vpner/internal/firewall"
+	grpcpb "github.com/ApostolDmitry/vpner/internal/grpc"
+	"github.com/ApostolDmitry/vpner/internal/resolver"
+	"github.com/ApostolDmitry/vpner/internal/vpnkind"
+	"github.com/miekg/dns"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *VpnerServer) Trace(_ context.Context, req *grpcpb.TraceRequest) (*grpcpb.TraceResponse, error) {
+	target := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(req.Target)), ".")
+	if target == "" {
+		return nil, status.Error(codes.InvalidArgument, "domain or IP is required")
+	}
+	resp := &grpcpb.TraceResponse{Target: target}
+
+	var (
+		domain string
+		ips    []net.IP
+	)
+	if ip := net.ParseIP(target); ip != nil {
+		ips = []net.IP{ip}
+		resp.VpnType, resp.Chain, resp.Pattern, resp.Matched = s.unblock.MatchIP(ip)
+	} else {
+		domain = target
+		resp.VpnType, resp.Chain, resp.Pattern, resp.Matched = s.unblock.MatchDomain(domain)
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			t := s.dns.Trace(domain, qtype)
+			resp.Dns = append(resp.Dns, traceDNSToProto(qtype, t))
+			for _, answer := range t.Answers {
+				if ip := net.ParseIP(answer); ip != nil {
+					ips = append(ips, ip)
+				}
+			}
+		}
+	}
+	if !resp.Matched {
+		resp.Verdict = fmt.Sprintf("not routed: no unblock rule matches %s", target)
+		return resp, nil
+	}
+
+	entries, err := s.unblock.TraceEntries(resp.VpnType, resp.Chain, domain, ips)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to read ipset entries: %v", err)
+	}
+	for _, e := range entries {
+		resp.Entries = append(resp.Entries, &grpcpb.TraceSetEntry{Set: e.Set, Entry: e.Entry, Comment: e.Comment})
+	}
+
+	var states []firewall.ChainState
+	if resp.VpnType == vpnkind.Xray.String() {
+		resp.ChainRunning = s.xrayService.IsRunning(resp.Chain)
+		if s.xrayRouter != nil {
+			states = s.xrayRouter.State(resp.Chain)
+		}
+	} else if ifaces, err := s.ifManager.FetchInterfaces(); err == nil {
+		resp.ChainRunning = ifaces[resp.Chain].State == "up"
+	}
+	for _, st := range states {
+		resp.Routing = append(resp.Routing, chainStateToProto(st))
+	}
+
+	resp.Routed, resp.Verdict = traceVerdict(resp, domain, ips, entries, states)
+	return resp, nil
+}
+
+func traceVerdict(resp *grpcpb.TraceResponse, domain string, ips []net.IP, entries []firewall.SetEntry, states []firewall.ChainState) (bool, string) {
+	if domain != "" && len(ips) == 0 {
+		return false, fmt.Sprintf("not routed: %s does not resolve to any address", domain)
+	}
+	ipv6 := false
+	for _, st := range states {
+		ipv6 = ipv6 || st.Family == "ipv6"
+	}
+	var covered, missing []string
+	for _, ip := range ips {
+		if ip.To4() == nil && !ipv6 {
+			continue
+		}
+		if entriesCover(entries, ip) {
+			covered = append(covered, ip.String())
+		} else {
+			missing = append(missing, ip.String())
+		}
+	}
+	if len(covered) == 0 {
+		if domain != "" {
+			return false, fmt.Sprintf("not routed yet: none of the addresses of %s are in the sets of chain %s; clients must resolve it through vpnerd DNS", domain, resp.Chain)
+		}
+		return false, fmt.Sprintf("not routed: %s is not in the sets of chain %s", resp.Target, resp.Chain)
+	}
+	if !resp.ChainRunning {
+		return false, fmt.Sprintf("not routed: chain %s is not running", resp.Chain)
+	}
+	if resp.VpnType == vpnkind.Xray.String() {
+		if len(states) == 0 {
+			return false, fmt.Sprintf("not routed: no firewall rules are applied for chain %s", resp.Chain)
+		}
+		for _, st := range states {
+			if problem := chainProblem(st); problem != "" {
+				return false, fmt.Sprintf("not routed: %s (%s)", problem, st.Family)
+			}
+		}
+	}
+	verdict := fmt.Sprintf("routed through %s (%s)", resp.Chain, resp.VpnType)
+	if len(missing) > 0 {
+		verdict += "; not in the sets yet: " + strings.Join(missing, ", ")
+	}
+	return true, verdict
+}
+
+func chainProblem(st firewall.ChainState) string {
+	switch {
+	case !st.IPSetPresent:
+		return fmt.Sprintf("ipset %s is missing", st.IPSetName)
+	case !st.ChainPresent:
+		return fmt.Sprintf("firewall chain %s is missing", st.ChainName)
+	case !st.TargetPresent:
+		return fmt.Sprintf("chain %s has no rule for %s", st.ChainName, st.IPSetName)
+	case st.Mark != "" && !st.IPRulePresent:
+		return fmt.Sprintf("ip rule for fwmark %s is missing", st.Mark)
+	}
+	for _, j := range st.Jumps {
+		if !j.Present {
+			return fmt.Sprintf("jump rule %q is missing", j.Rule)
+		}
+	}
+	if len(st.Jumps) == 0 {
+		return fmt.Sprintf("nothing jumps into %s", st.ChainName)
+	}
+	return ""
+}
+
+func entriesCover(entries []firewall.SetEntry, ip net.IP) bool {
+	for _, e := range entries {
+		if e.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func traceDNSToProto(qtype uint16, t resolver.Trace) *grpcpb.TraceDns {
+	out := &grpcpb.TraceDns{
+		Qtype:   dns.TypeToString[qtype],
+		Source:  t.Source,
+		Server:  t.Server,
+		Rcode:   t.Rcode,
+		Answers: t.Answers,
+	}
+	if t.Err != nil {
+		out.Error = t.Err.Error()
+	}
+	return out
+}
@@ -27,13 +27,28 @@ func (s *VpnerServer) UnblockList(ctx context.Context, _ *grpcpb.Empty) (*grpcpb
 }
 
 func (s *VpnerServer) UnblockAdd(ctx context.Context, req *grpcpb.UnblockAddRequest) (*grpcpb.GenericResponse, error) {
+	if req.DryRun {
+		plan, err := s.unblock.PlanAddRule(req.ChainName, req.Domain)
+		if err != nil {
+			return errorGeneric(fmt.Sprintf("Failed to add rule: %v", err)), nil
+		}
+		return planGeneric("Dry run: rule not added", plan), nil
+	}
 	if err := s.unblock.AddRule(req.ChainName, req.Domain); err != nil {
 		return errorGeneric(fmt.Sprintf("Failed to add rule: %v", err)), nil
 	}
+	s.dns.WarmRule(req.Domain)
 	return successGeneric("Rule added successfully"), nil
 }
 
 func (s *VpnerServer) UnblockDel(ctx context.Context, req *grpcpb.UnblockDelRequest) (*grpcpb.GenericResponse, error) {
+	if req.DryRun {
+		plan, err := s.unblock.PlanDeleteRule(req.Domain)
+		if err != nil {
+			return errorGeneric(fmt.Sprintf("Failed to delete rule: %v", err)), nil
+		}
+		return planGeneric("Dry run: rule not deleted", plan), nil
+	}
 	if err := s.unblock.DeleteRule(req.Domain); err != nil {
 		return errorGeneric(fmt.Sprintf("Failed to delete rule: %v", err)), nil
 	}
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"sort"
 
+	"github.com/ApostolDmitry/vpner/internal/chainpolicy"
 	grpcpb "github.com/ApostolDmitry/vpner/internal/grpc"
 	"github.com/ApostolDmitry/vpner/internal/hookscope"
+	routing "github.com/ApostolDmitry/vpner/internal/routing"
 	"github.com/ApostolDmitry/vpner/internal/vpnkind"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -39,6 +41,9 @@ func (s *VpnerServer) XrayList(_ context.Context, _ *grpcpb.Empty) (*grpcpb.Xray
 }
 
 func (s *VpnerServer) XrayManage(_ context.Context, req *grpcpb.XrayManageRequest) (*grpcpb.GenericResponse, error) {
+	if req.DryRun && req.Act != grpcpb.ManageAction_STATUS {
+		return s.planXrayManage(req), nil
+	}
 	switch req.Act {
 	case grpcpb.ManageAction_START:
 		if err := s.xrayService.StartOne(req.ChainName); err != nil {
@@ -81,25 +86,112 @@ func (s *VpnerServer) XraySetAutorun(_ context.Context, req *grpcpb.XrayAutoRunR
 	return successGeneric(fmt.Sprintf("Xray autorun %s: %s", state, req.ChainName)), nil
 }
 
-func (s *VpnerServer) HookRestore(ctx context.Context, _ *grpcpb.Empty) (*grpcpb.GenericResponse, error) {
-	scope := hookscope.FromIncomingContext(ctx)
-	restoreV4 := scope.RestoreIPv4()
-	restoreV6 := scope.RestoreIPv6()
+func (s *VpnerServer) XraySetKillSwitch(_ context.Context, req *grpcpb.XrayKillSwitchRequest) (*grpcpb.GenericResponse, error) {
+	if req.ChainName == "" {
+		return errorGeneric("Chain name is required"), nil
+	}
+	mode, err := chainpolicy.ParseKillSwitch(req.Mode)
+	if err != nil {
+		return errorGeneric(err.Error()), nil
+	}
+	if req.DryRun {
+		info, err := s.xrayService.GetInfo(req.ChainName)
+		if err != nil {
+			return errorGeneric(fmt.Sprintf("Failed to read chain: %v", err)), nil
+		}
+		info.KillSwitch = mode
+		down := !s.xrayService.IsRunning(req.ChainName)
+		return s.planRouting(req.ChainName, func(r *routing.XrayRouter) error {
+			return r.UpdateKillSwitch(req.ChainName, info, down)
+		}), nil
+	}
+	if err := s.xrayService.SetKillSwitch(req.ChainName, mode); err != nil {
+		return errorGeneric(fmt.Sprintf("Failed to update kill switch: %v", err)), nil
+	}
+	if s.xrayRouter != nil {
+		info, err := s.xrayService.GetInfo(req.ChainName)
+		if err != nil {
+			return errorGeneric(fmt.Sprintf("Failed to read chain: %v", err)), nil
+		}
+		if err := s.xrayRouter.UpdateKillSwitch(req.ChainName, info, !s.xrayService.IsRunning(req.ChainName)); err != nil {
+			return errorGeneric(fmt.Sprintf("Failed to apply kill switch: %v", err)), nil
+		}
+	}
+	return successGeneric(fmt.Sprintf("Xray kill switch %s: %s", mode, req.ChainName)), nil
+}
 
+func (s *VpnerServer) XraySetUDPPolicy(_ context.Context, req *grpcpb.XrayUDPPolicyRequest) (*grpcpb.GenericResponse, error) {
+	if req.ChainName == "" {
+		return errorGeneric("Chain name is required"), nil
+	}
+	policy, err := chainpolicy.ParseUDPPolicy(req.Policy)
+	if err != nil {
+		return errorGeneric(err.Error()), nil
+	}
+	if req.DryRun {
+		info, err := s.xrayService.GetInfo(req.ChainName)
+		if err != nil {
+			return errorGeneric(fmt.Sprintf("Failed to read chain: %v", err)), nil
+		}
+		info.UDPPolicy = policy
+		return s.planRouting(req.ChainName, func(r *routing.XrayRouter) error {
+			return r.UpdateUDPPolicy(req.ChainName, info)
+		}), nil
+	}
+	if err := s.xrayService.SetUDPPolicy(req.ChainName, policy); err != nil {
+		return errorGeneric(fmt.Sprintf("Failed to update UDP policy: %v", err)), nil
+	}
+	if s.xrayService.IsRunning(req.ChainName) {
+		if err := s.xrayService.StopOne(req.ChainName); err != nil {
+			return errorGeneric(fmt.Sprintf("Failed to stop Xray for restart: %v", err)), nil
+		}
+		if err := s.xrayService.StartOne(req.ChainName); err != nil {
+			return errorGeneric(fmt.Sprintf("UDP policy saved but %s failed to restart: %v", req.ChainName, err)), nil
+		}
+	}
 	if s.xrayRouter != nil {
-		if scope.Table != "" {
-			s.xrayRouter.ClearAppliedState(scope.Table, restoreV4, restoreV6)
-		} else if restoreV4 && restoreV6 {
-			s.xrayRouter.ClearAppliedState("", true, true)
-		} else {
-			s.xrayRouter.ResetStateFamily(restoreV4, restoreV6)
+		info, err := s.xrayService.GetInfo(req.ChainName)
+		if err != nil {
+			return errorGeneric(fmt.Sprintf("Failed to read chain: %v", err)), nil
+		}
+		if err := s.xrayRouter.UpdateUDPPolicy(req.ChainName, info); err != nil {
+			return errorGeneric(fmt.Sprintf("Failed to apply UDP policy: %v", err)), nil
+		}
+		if active := s.xrayRouter.UDPPolicyActive(info); active != policy.String() {
+			return successGeneric(fmt.Sprintf("Xray UDP policy %s: %s (active: %s)", policy, req.ChainName, active)), nil
 		}
 	}
+	return successGeneric(fmt.Sprintf("Xray UDP policy %s: %s", policy, req.ChainName)), nil
+}
 
-	s.RestoreXrayRouting(restoreV4, restoreV6, scope.Table)
+func (s *VpnerServer) HookRestore(ctx context.Context, req *grpcpb.HookRestoreRequest) (*grpcpb.GenericResponse, error) {
+	scope := hookscope.FromIncomingContext(ctx)
+	if req.DryRun {
+		return s.planRouting("", func(r *routing.XrayRouter) error {
+			s.hookRestore(r, scope)
+			return nil
+		}), nil
+	}
+	if s.xrayRouter != nil {
+		s.hookRestore(s.xrayRouter, scope)
+	}
 	return successGeneric("Routing restore triggered"), nil
 }
 
+func (s *VpnerServer) hookRestore(r RoutingController, scope hookscope.Scope) {
+	restoreV4 := scope.RestoreIPv4()
+	restoreV6 := scope.RestoreIPv6()
+
+	if scope.Table != "" {
+		r.ClearAppliedState(scope.Table, restoreV4, restoreV6)
+	} else if restoreV4 && restoreV6 {
+		r.ClearAppliedState("", true, true)
+	} else {
+		r.ResetStateFamily(restoreV4, restoreV6)
+	}
+	s.restoreXrayRouting(r, "", restoreV4, restoreV6, scope.Table)
+}
+
 func (s *VpnerServer) XrayCreate(_ context.Context, req *grpcpb.XrayCreateRequest) (*grpcpb.GenericResponse, error) {
 	name, err := s.xrayService.Create(req.Link, req.AutoRun)
 	if err != nil {
@@ -158,6 +250,9 @@ func (s *VpnerServer) XrayDelete(_ context.Context, req *grpcpb.XrayRequest) (*g
 			return errorGeneric(fmt.Sprintf("Failed to cleanup routing: %v", err)), nil
 		}
 	}
+	if err := s.purgeXrayRouting(req.ChainName); err != nil {
+		return errorGeneric(fmt.Sprintf("Failed to cleanup routing: %v", err)), nil
+	}
 	if err := s.xrayService.Delete(req.ChainName); err != nil {
 		return errorGeneric(fmt.Sprintf("Failed to delete Xray: %v", err)), nil
 	}
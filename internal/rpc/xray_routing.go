@@ -1,6 +1,13 @@
 package rpc
 
-import "github.com/ApostolDmitry/vpner/internal/logx"
+import (
+	"fmt"
+
+	grpcpb "github.com/ApostolDmitry/vpner/internal/grpc"
+	"github.com/ApostolDmitry/vpner/internal/logx"
+	proxy "github.com/ApostolDmitry/vpner/internal/proxy"
+	routing "github.com/ApostolDmitry/vpner/internal/routing"
+)
 
 func (s *VpnerServer) applyXrayRouting(chain string) error {
 	if s.xrayRouter == nil {
@@ -17,19 +24,84 @@ func (s *VpnerServer) removeXrayRouting(chain string) error {
 	if s.xrayRouter == nil {
 		return nil
 	}
-	return s.xrayRouter.Remove(chain)
+	info, err := s.xrayService.GetInfo(chain)
+	if err != nil {
+		return s.xrayRouter.Purge(chain)
+	}
+	return s.xrayRouter.Remove(chain, info)
+}
+
+func (s *VpnerServer) purgeXrayRouting(chain string) error {
+	if s.xrayRouter == nil {
+		return nil
+	}
+	return s.xrayRouter.Purge(chain)
+}
+
+func (s *VpnerServer) SyncKillSwitches() {
+	if s.xrayRouter == nil {
+		return
+	}
+	infoMap, err := s.xrayService.ListInfo()
+	if err != nil {
+		logx.Errorf("failed to list Xray configs: %v", err)
+		return
+	}
+	runtimes := s.xrayService.Runtimes()
+	for name, info := range infoMap {
+		rt := runtimes[name]
+		if err := s.xrayRouter.UpdateKillSwitch(name, info, !rt.Running || !rt.Alive); err != nil {
+			logx.Errorf("kill switch for chain %s: %v", name, err)
+		}
+	}
 }
 
 func (s *VpnerServer) RestoreXrayRouting(restoreV4, restoreV6 bool, table string) {
 	if s.xrayRouter == nil {
 		return
 	}
+	s.restoreXrayRouting(s.xrayRouter, "", restoreV4, restoreV6, table)
+}
+
+func (s *VpnerServer) restoreXrayRouting(r RoutingController, chain string, restoreV4, restoreV6 bool, table string) {
 	infoMap, err := s.xrayService.ListInfo()
 	if err != nil {
 		logx.Errorf("failed to list Xray configs: %v", err)
 		return
 	}
-	s.xrayRouter.Restore(infoMap, s.xrayService.IsRunning, restoreV4, restoreV6, table)
+	if chain != "" {
+		infoMap = map[string]proxy.ChainInfo{chain: infoMap[chain]}
+	}
+	r.Restore(infoMap, s.xrayService.IsRunning, restoreV4, restoreV6, table)
+}
+
+func (s *VpnerServer) planRouting(chain string, fn func(*routing.XrayRouter) error) *grpcpb.GenericResponse {
+	if s.xrayRouter == nil {
+		return planGeneric("Dry run: routing is disabled", nil)
+	}
+	plan, err := s.xrayRouter.Plan(chain, fn)
+	if err != nil {
+		return errorGeneric(fmt.Sprintf("Failed to plan routing: %v", err))
+	}
+	return planGeneric("Dry run: nothing was changed", plan)
+}
+
+func (s *VpnerServer) planXrayManage(req *grpcpb.XrayManageRequest) *grpcpb.GenericResponse {
+	info, err := s.xrayService.GetInfo(req.ChainName)
+	if err != nil {
+		if req.Act == grpcpb.ManageAction_START {
+			return errorGeneric(fmt.Sprintf("Failed to start Xray: %v", err))
+		}
+		return s.planRouting(req.ChainName, func(r *routing.XrayRouter) error {
+			return r.Purge(req.ChainName)
+		})
+	}
+	return s.planRouting(req.ChainName, func(r *routing.XrayRouter) error {
+		if req.Act == grpcpb.ManageAction_START {
+			return r.Apply(req.ChainName, info)
+		}
+		return r.Remove(req.ChainName, info)
+	})
 }
 
 func (s *VpnerServer) DisableAllXrayRouting() {
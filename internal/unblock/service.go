@@ -2,6 +2,7 @@ package unblock
 
 import (
 	"fmt"
+	"net"
 	"sort"
 
 	"github.com/ApostolDmitry/vpner/internal/firewall"
@@ -72,27 +73,51 @@ func (s *Service) List() ([]RuleGroup, error) {
 }
 
 func (s *Service) AddRule(chainName, pattern string) error {
+	vpnType, err := s.checkNewRule(chainName, pattern)
+	if err != nil {
+		return err
+	}
+	if err := s.manager.AddRule(vpnType, chainName, pattern); err != nil {
+		return fmt.Errorf("failed to add rule: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) PlanAddRule(chainName, pattern string) (*firewall.Plan, error) {
+	vpnType, err := s.checkNewRule(chainName, pattern)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.manager.PlanRule(vpnType, chainName, pattern, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to plan rule: %w", err)
+	}
+	return plan, nil
+}
+
+func (s *Service) checkNewRule(chainName, pattern string) (string, error) {
 	if chainName == "" {
-		return fmt.Errorf("chain name is required")
+		return "", fmt.Errorf("chain name is required")
 	}
 	if err := matcher.Validate(pattern); err != nil {
-		return fmt.Errorf("invalid pattern: %w", err)
+		return "", fmt.Errorf("invalid pattern: %w", err)
 	}
 
-	vpnType, exists := s.resolveChainType(chainName)
+	vpnType, exists := s.ChainType(chainName)
 	if !exists {
-		return fmt.Errorf("chain name %q does not exist", chainName)
+		return "", fmt.Errorf("chain name %q does not exist", chainName)
 	}
 
 	allRules, err := s.manager.GetAllRules()
 	if err != nil {
-		return fmt.Errorf("failed to load existing rules: %w", err)
+		return "", fmt.Errorf("failed to load existing rules: %w", err)
 	}
 	for typ, set := range allRules.Rules {
 		for existingChain, rules := range set {
 			for _, existing := range rules {
 				if matcher.Overlap(existing, pattern) {
-					return fmt.Errorf(
+					return "", fmt.Errorf(
 						"new rule %q overlaps with existing rule %q in [%s/%s]",
 						pattern,
 						existing,
@@ -103,27 +128,42 @@ func (s *Service) AddRule(chainName, pattern string) error {
 			}
 		}
 	}
+	return vpnType, nil
+}
 
-	if err := s.manager.AddRule(vpnType, chainName, pattern); err != nil {
-		return fmt.Errorf("failed to add rule: %w", err)
+func (s *Service) DeleteRule(pattern string) error {
+	vpnType, chainName, err := s.lookupRule(pattern)
+	if err != nil {
+		return err
+	}
+	if err := s.manager.DelRule(vpnType, chainName, pattern); err != nil {
+		return fmt.Errorf("failed to delete rule: %w", err)
 	}
-
 	return nil
 }
 
-func (s *Service) DeleteRule(pattern string) error {
+func (s *Service) PlanDeleteRule(pattern string) (*firewall.Plan, error) {
+	vpnType, chainName, err := s.lookupRule(pattern)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.manager.PlanRule(vpnType, chainName, pattern, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to plan rule: %w", err)
+	}
+	return plan, nil
+}
+
+func (s *Service) lookupRule(pattern string) (string, string, error) {
 	if err := matcher.Validate(pattern); err != nil {
-		return fmt.Errorf("invalid pattern: %w", err)
+		return "", "", fmt.Errorf("invalid pattern: %w", err)
 	}
 
 	vpnType, chainName, _, exists := s.manager.MatchDomain(pattern)
 	if !exists {
-		return fmt.Errorf("rule does not exist")
-	}
-	if err := s.manager.DelRule(vpnType, chainName, pattern); err != nil {
-		return fmt.Errorf("failed to delete rule: %w", err)
+		return "", "", fmt.Errorf("rule does not exist")
 	}
-	return nil
+	return vpnType, chainName, nil
 }
 
 func (s *Service) DeleteChain(vpnType, chainName string) error {
@@ -137,15 +177,41 @@ func (s *Service) MatchDomain(domain string) (string, string, string, bool) {
 	return s.manager.MatchDomain(domain)
 }
 
+func (s *Service) MatchIP(ip net.IP) (string, string, string, bool) {
+	return s.manager.MatchIP(ip)
+}
+
+func (s *Service) TraceEntries(vpnType, chainName, domain string, ips []net.IP) ([]firewall.SetEntry, error) {
+	return s.manager.TraceEntries(vpnType, chainName, domain, ips)
+}
+
+// DomainRules returns every domain pattern across all chains.
+func (s *Service) DomainRules() []string {
+	groups, err := s.List()
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, g := range groups {
+		for _, rule := range g.Rules {
+			if !matcher.IsNetwork(rule) {
+				out = append(out, rule)
+			}
+		}
+	}
+	return out
+}
+
 func (s *Service) RuntimeOptions() firewall.RuleRuntimeOptions {
 	return firewall.RuleRuntimeOptions{
 		IPv6Enabled:       s.manager.IPv6Enabled(),
 		IPSetDebug:        s.manager.IPSetDebug(),
 		IPSetStaleQueries: s.manager.IPSetStaleQueries(),
+		EntryTTL:          s.manager.EntryTTL(),
 	}
 }
 
-func (s *Service) resolveChainType(chainName string) (string, bool) {
+func (s *Service) ChainType(chainName string) (string, bool) {
 	if s.xrays != nil && s.xrays.IsChain(chainName) {
 		return vpnkind.Xray.String(), true
 	}
@@ -154,3 +220,19 @@ func (s *Service) resolveChainType(chainName string) (string, bool) {
 	}
 	return s.interfaces.LookupTrackedType(chainName)
 }
+
+func (s *Service) SaveSnapshot(path string) error {
+	return s.manager.SaveSnapshot(path)
+}
+
+func (s *Service) RestoreSnapshot(path string) (int, error) {
+	return s.manager.RestoreSnapshot(path)
+}
+
+func (s *Service) SetUsage() []firewall.SetUsage {
+	return s.manager.SetUsage()
+}
+
+func (s *Service) CheckCapacity() {
+	s.manager.CheckCapacity()
+}
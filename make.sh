@@ -97,7 +97,7 @@ render_ndm_hook() {
   cat <<'HOOK'
 #!/bin/sh
 case "${table}" in
-  nat|mangle)
+  nat|mangle|filter)
     case "${type}" in
       iptables)
         __INSTALL_PREFIX__/etc/vpner/vpnerhookcli --unix /tmp/vpner.sock --family ipv4 --table "${table}" >/dev/null 2>&1 &